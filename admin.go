@@ -0,0 +1,319 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Admin is an http.Handler for inspecting and changing registered limiters
+// at run time, for example to tighten a limit during an incident without
+// redeploying. It serves JSON:
+//
+//	GET   /limiters                         list limiters
+//	GET   /limiters/{name}                  rate, burst, tokens, shadow, stats
+//	PATCH /limiters/{name}                  set rate, burst or shadow
+//	POST  /limiters/{name}/reset            refill the bucket
+//	GET   /limiters/{name}/keys             keys of a keyed limiter
+//	GET   /limiters/{name}/keys/{key}       one key
+//	PATCH /limiters/{name}/keys/{key}       override a key's rate or burst
+//	POST  /limiters/{name}/keys/{key}/reset refill a key's bucket
+//
+// PATCH bodies are objects with any of "rate", as a number of events per
+// second or a string in the syntax of ParseRate, "burst" and "shadow". For
+// a keyed limiter they change the defaults. Admin does no authentication;
+// mount it behind your own, and under a prefix with http.StripPrefix.
+type Admin struct {
+	mu       sync.Mutex
+	limiters map[string]*RateLimiter
+	keyed    map[string]*KeyedLimiter
+	mux      http.ServeMux
+}
+
+// NewAdmin returns an Admin with no limiters registered.
+func NewAdmin() *Admin {
+	a := &Admin{limiters: make(map[string]*RateLimiter), keyed: make(map[string]*KeyedLimiter)}
+	a.mux.HandleFunc("GET /limiters", a.list)
+	a.mux.HandleFunc("GET /limiters/{name}", a.withLimiter(a.get, a.getKeyed))
+	a.mux.HandleFunc("PATCH /limiters/{name}", a.withLimiter(a.patch, a.patchKeyed))
+	a.mux.HandleFunc("POST /limiters/{name}/reset", a.withLimiter(a.reset, nil))
+	a.mux.HandleFunc("GET /limiters/{name}/keys", a.withLimiter(nil, a.listKeys))
+	a.mux.HandleFunc("GET /limiters/{name}/keys/{key}", a.withKey(a.get))
+	a.mux.HandleFunc("PATCH /limiters/{name}/keys/{key}", a.withLimiter(nil, a.patchKey))
+	a.mux.HandleFunc("POST /limiters/{name}/keys/{key}/reset", a.withKey(a.reset))
+	return a
+}
+
+// Register adds rl under name, replacing any limiter of that name.
+func (a *Admin) Register(name string, rl *RateLimiter) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.keyed, name)
+	a.limiters[name] = rl
+}
+
+// RegisterKeyed adds kl under name, replacing any limiter of that name.
+func (a *Admin) RegisterKeyed(name string, kl *KeyedLimiter) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.limiters, name)
+	a.keyed[name] = kl
+}
+
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mux.ServeHTTP(w, r)
+}
+
+// adminLimiter is the JSON view of a limiter.
+type adminLimiter struct {
+	Name   string     `json:"name"`
+	Keyed  bool       `json:"keyed,omitempty"`
+	Rate   float64    `json:"rate"`
+	Burst  int        `json:"burst"`
+	Tokens *float64   `json:"tokens,omitempty"`
+	Shadow bool       `json:"shadow"`
+	Paused bool       `json:"paused,omitempty"`
+	Keys   *int       `json:"keys,omitempty"`
+	Stats  *adminStat `json:"stats,omitempty"`
+}
+
+// adminStat is the JSON view of Stats.
+type adminStat struct {
+	Since     time.Time  `json:"since"`
+	Allowed   uint64     `json:"allowed"`
+	Denied    uint64     `json:"denied"`
+	LastSeen  *time.Time `json:"last_seen,omitempty"`
+	TotalWait float64    `json:"total_wait_seconds"`
+	MaxWait   float64    `json:"max_wait_seconds"`
+}
+
+func newAdminStat(s Stats) *adminStat {
+	as := &adminStat{
+		Since:     s.Since,
+		Allowed:   s.Allowed,
+		Denied:    s.Denied,
+		TotalWait: s.TotalWait.Seconds(),
+		MaxWait:   s.MaxWait.Seconds(),
+	}
+	if !s.LastSeen.IsZero() {
+		as.LastSeen = &s.LastSeen
+	}
+	return as
+}
+
+func viewLimiter(name string, rl *RateLimiter) adminLimiter {
+	s := rl.Stats()
+	return adminLimiter{
+		Name:   name,
+		Rate:   float64(rl.Rate()),
+		Burst:  rl.Burst(),
+		Tokens: &s.Tokens,
+		Shadow: rl.Shadow(),
+		Paused: rl.Paused(),
+		Stats:  newAdminStat(s),
+	}
+}
+
+func viewKeyed(name string, kl *KeyedLimiter) adminLimiter {
+	cfg := kl.config.Load()
+	keys := kl.Len()
+	return adminLimiter{Name: name, Keyed: true, Rate: float64(cfg.rate), Burst: cfg.burst, Shadow: cfg.shadow, Keys: &keys}
+}
+
+func (a *Admin) list(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	views := make([]adminLimiter, 0, len(a.limiters)+len(a.keyed))
+	for name, rl := range a.limiters {
+		views = append(views, viewLimiter(name, rl))
+	}
+	for name, kl := range a.keyed {
+		views = append(views, viewKeyed(name, kl))
+	}
+	a.mu.Unlock()
+	sort.Slice(views, func(i, j int) bool { return views[i].Name < views[j].Name })
+	writeJSON(w, http.StatusOK, views)
+}
+
+// withLimiter looks up the limiter named in the path and passes it to
+// plain or keyed. A nil handler answers 400 for that kind of limiter.
+func (a *Admin) withLimiter(
+	plain func(w http.ResponseWriter, r *http.Request, name string, rl *RateLimiter),
+	keyed func(w http.ResponseWriter, r *http.Request, name string, kl *KeyedLimiter),
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		a.mu.Lock()
+		rl, kl := a.limiters[name], a.keyed[name]
+		a.mu.Unlock()
+		switch {
+		case rl != nil && plain != nil:
+			plain(w, r, name, rl)
+		case kl != nil && keyed != nil:
+			keyed(w, r, name, kl)
+		case rl != nil || kl != nil:
+			writeError(w, http.StatusBadRequest, fmt.Errorf("not supported for limiter %q", name))
+		default:
+			writeError(w, http.StatusNotFound, fmt.Errorf("no limiter %q", name))
+		}
+	}
+}
+
+// withKey looks up the key named in the path of a keyed limiter and passes
+// its limiter to fn. Keys without a live limiter are not found.
+func (a *Admin) withKey(fn func(w http.ResponseWriter, r *http.Request, name string, rl *RateLimiter)) http.HandlerFunc {
+	return a.withLimiter(nil, func(w http.ResponseWriter, r *http.Request, _ string, kl *KeyedLimiter) {
+		key := r.PathValue("key")
+		s := kl.shard(key)
+		s.mu.Lock()
+		rl := s.limiters[key]
+		s.mu.Unlock()
+		if rl == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no key %q", key))
+			return
+		}
+		fn(w, r, key, rl)
+	})
+}
+
+func (a *Admin) get(w http.ResponseWriter, r *http.Request, name string, rl *RateLimiter) {
+	writeJSON(w, http.StatusOK, viewLimiter(name, rl))
+}
+
+func (a *Admin) getKeyed(w http.ResponseWriter, r *http.Request, name string, kl *KeyedLimiter) {
+	writeJSON(w, http.StatusOK, viewKeyed(name, kl))
+}
+
+func (a *Admin) listKeys(w http.ResponseWriter, r *http.Request, name string, kl *KeyedLimiter) {
+	views := []adminLimiter{}
+	kl.RangeStats(func(key string, s Stats) bool {
+		tokens := s.Tokens
+		views = append(views, adminLimiter{Name: key, Tokens: &tokens, Stats: newAdminStat(s)})
+		return true
+	})
+	sort.Slice(views, func(i, j int) bool { return views[i].Name < views[j].Name })
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (a *Admin) reset(w http.ResponseWriter, r *http.Request, name string, rl *RateLimiter) {
+	rl.Reset()
+	writeJSON(w, http.StatusOK, viewLimiter(name, rl))
+}
+
+// adminPatch is the body of a PATCH request.
+type adminPatch struct {
+	Rate   json.RawMessage `json:"rate"`
+	Burst  *int            `json:"burst"`
+	Shadow *bool           `json:"shadow"`
+}
+
+// parse decodes the body of r into p, returning the rate if one was given.
+func (p *adminPatch) parse(r *http.Request) (*Rate, error) {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, err
+	}
+	if p.Burst != nil && *p.Burst < 0 {
+		return nil, fmt.Errorf("%w: negative burst %d", ErrInvalidLimit, *p.Burst)
+	}
+	if p.Rate == nil || string(p.Rate) == "null" {
+		return nil, nil
+	}
+	var rate Rate
+	var n float64
+	var s string
+	switch {
+	case json.Unmarshal(p.Rate, &n) == nil:
+		rate = Rate(n)
+	case json.Unmarshal(p.Rate, &s) == nil:
+		var err error
+		if rate, _, err = ParseRate(s); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("rate must be a number or a string")
+	}
+	if rate < 0 {
+		return nil, fmt.Errorf("%w: negative rate", ErrInvalidLimit)
+	}
+	return &rate, nil
+}
+
+func (a *Admin) patch(w http.ResponseWriter, r *http.Request, name string, rl *RateLimiter) {
+	var p adminPatch
+	rate, err := p.parse(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if rate != nil {
+		rl.SetRate(*rate)
+	}
+	if p.Burst != nil {
+		rl.SetBurst(*p.Burst)
+	}
+	if p.Shadow != nil {
+		rl.SetShadow(*p.Shadow)
+	}
+	writeJSON(w, http.StatusOK, viewLimiter(name, rl))
+}
+
+func (a *Admin) patchKeyed(w http.ResponseWriter, r *http.Request, name string, kl *KeyedLimiter) {
+	var p adminPatch
+	rate, err := p.parse(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if rate != nil || p.Burst != nil {
+		cfg := kl.config.Load()
+		newRate, newBurst := cfg.rate, cfg.burst
+		if rate != nil {
+			newRate = *rate
+		}
+		if p.Burst != nil {
+			newBurst = *p.Burst
+		}
+		kl.SetDefaults(newRate, newBurst)
+	}
+	if p.Shadow != nil {
+		kl.SetShadow(*p.Shadow)
+	}
+	writeJSON(w, http.StatusOK, viewKeyed(name, kl))
+}
+
+func (a *Admin) patchKey(w http.ResponseWriter, r *http.Request, name string, kl *KeyedLimiter) {
+	var p adminPatch
+	rate, err := p.parse(r)
+	if err == nil && p.Shadow != nil {
+		err = errors.New("shadow mode applies to the whole keyed limiter")
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	key := r.PathValue("key")
+	if rate != nil {
+		kl.SetRate(key, *rate)
+	}
+	if p.Burst != nil {
+		kl.SetBurst(key, *p.Burst)
+	}
+	a.get(w, r, key, kl.Get(key))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,90 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func adminDo(t *testing.T, h http.Handler, method, path, body string) (int, map[string]any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	var v map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &v)
+	return rec.Code, v
+}
+
+func TestAdminLimiter(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(10, 5, clk)
+	rl.AllowN(3)
+	a := NewAdmin()
+	a.Register("api", rl)
+
+	code, v := adminDo(t, a, "GET", "/limiters/api", "")
+	if code != 200 || v["rate"] != 10.0 || v["tokens"] != 2.0 {
+		t.Fatalf("GET limiter: %d %v", code, v)
+	}
+	code, v = adminDo(t, a, "PATCH", "/limiters/api", `{"rate": "60/m", "burst": 8, "shadow": true}`)
+	if code != 200 || v["rate"] != 1.0 || v["burst"] != 8.0 || v["shadow"] != true {
+		t.Fatalf("PATCH limiter: %d %v", code, v)
+	}
+	if rl.Rate() != 1 || rl.Burst() != 8 || !rl.Shadow() {
+		t.Fatal("PATCH did not change the limiter")
+	}
+	if code, v = adminDo(t, a, "POST", "/limiters/api/reset", ""); code != 200 || v["tokens"] != 8.0 {
+		t.Fatalf("reset: %d %v", code, v)
+	}
+
+	if code, _ = adminDo(t, a, "PATCH", "/limiters/api", `{"rate": "fast"}`); code != http.StatusBadRequest {
+		t.Fatalf("PATCH with a bad rate: %d, want 400", code)
+	}
+	if code, _ = adminDo(t, a, "GET", "/limiters/nope", ""); code != http.StatusNotFound {
+		t.Fatalf("GET unknown limiter: %d, want 404", code)
+	}
+}
+
+func TestAdminKeyed(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(1, 2, clk)
+	kl.Allow("10.0.0.1")
+	kl.AllowN("10.0.0.2", 2)
+	a := NewAdmin()
+	a.RegisterKeyed("clients", kl)
+	a.Register("global", New(1, 1, clk))
+
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest("GET", "/limiters", nil))
+	var list []map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 2 || list[0]["name"] != "clients" || list[0]["keys"] != 2.0 {
+		t.Fatalf("GET /limiters: %v %s", err, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest("GET", "/limiters/clients/keys", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 2 || list[1]["tokens"] != 0.0 {
+		t.Fatalf("GET keys: %v %s", err, rec.Body)
+	}
+
+	if code, v := adminDo(t, a, "PATCH", "/limiters/clients/keys/10.0.0.2", `{"burst": 10}`); code != 200 || v["burst"] != 10.0 {
+		t.Fatalf("PATCH key: %d %v", code, v)
+	}
+	if code, v := adminDo(t, a, "POST", "/limiters/clients/keys/10.0.0.2/reset", ""); code != 200 || v["tokens"] != 10.0 {
+		t.Fatalf("reset key: %d %v", code, v)
+	}
+	if code, _ := adminDo(t, a, "GET", "/limiters/clients/keys/10.0.0.9", ""); code != http.StatusNotFound {
+		t.Fatalf("GET unknown key: %d, want 404", code)
+	}
+	if code, v := adminDo(t, a, "PATCH", "/limiters/clients", `{"rate": 5, "shadow": true}`); code != 200 || v["rate"] != 5.0 || v["burst"] != 2.0 {
+		t.Fatalf("PATCH keyed: %d %v", code, v)
+	}
+	if r := kl.Get("10.0.0.1").Rate(); r != 5 || !kl.Get("10.0.0.1").Shadow() {
+		t.Fatalf("PATCH keyed did not change the defaults: rate %v", r)
+	}
+	if code, _ := adminDo(t, a, "GET", "/limiters/global/keys", ""); code != http.StatusBadRequest {
+		t.Fatalf("keys of a plain limiter: %d, want 400", code)
+	}
+}
@@ -0,0 +1,169 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// AtomicLimiter is a token bucket whose state is a single int64 updated
+// with compare-and-swap, so Allow never takes a lock and dozens of
+// goroutines can share one limiter without contention. It implements the
+// generic cell rate algorithm: the state is the theoretical arrival time
+// of the next event, which admits exactly what a RateLimiter with the same
+// rate and burst would. Its rate and burst are fixed; use RateLimiter for
+// reconfiguration, reservations, observers and the other extras.
+type AtomicLimiter struct {
+	tat      atomic.Int64 // theoretical arrival time, in ns since start
+	interval int64        // ns per token
+	tau      int64        // ns of capacity: burst * interval
+	budget   atomic.Int64 // tokens left when the rate is zero
+	rate     Rate
+	burst    int
+	start    time.Time
+	clock    Clock
+}
+
+// NewAtomic returns an AtomicLimiter allowing rate events per second with
+// the given burst, starting with a full bucket.
+func NewAtomic(rate Rate, burst int, clk Clock) *AtomicLimiter {
+	if clk == nil {
+		clk = realClock{}
+	}
+	l := &AtomicLimiter{rate: rate, burst: burst, start: clk.Now(), clock: clk}
+	switch {
+	case rate == InfiniteRate:
+	case rate <= 0:
+		l.budget.Store(int64(burst))
+	default:
+		l.interval = int64(rate.durationFromTokens(1))
+		l.tau = int64(burst) * l.interval
+	}
+	return l
+}
+
+func (l *AtomicLimiter) Rate() Rate { return l.rate }
+func (l *AtomicLimiter) Burst() int { return l.burst }
+
+func (l *AtomicLimiter) now() int64 {
+	return int64(l.clock.Now().Sub(l.start))
+}
+
+// AvailableTokens returns the number of tokens available now.
+func (l *AtomicLimiter) AvailableTokens() float64 {
+	switch {
+	case l.rate == InfiniteRate:
+		return float64(l.burst)
+	case l.rate <= 0:
+		return float64(l.budget.Load())
+	}
+	now := l.now()
+	tat := l.tat.Load()
+	if tat < now {
+		tat = now
+	}
+	return float64(l.tau-(tat-now)) / float64(l.interval)
+}
+
+func (l *AtomicLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+// AllowN reports whether n events may happen now, taking the tokens if so.
+// A negative n is denied.
+func (l *AtomicLimiter) AllowN(n int) bool {
+	_, ok := l.reserve(n, 0)
+	return ok
+}
+
+// Wait is shorthand for WaitN(context.Background(), n).
+func (l *AtomicLimiter) Wait(n int) error {
+	return l.WaitN(context.Background(), n)
+}
+
+// WaitN blocks until n tokens are available or ctx is done, in which case
+// the tokens are returned.
+func (l *AtomicLimiter) WaitN(ctx context.Context, n int) error {
+	select {
+	case <-ctx.Done():
+		return ctxError(n, ctx.Err(), 0)
+	default:
+	}
+	if n < 0 {
+		return negativeError(n)
+	}
+	if n > l.burst && l.rate != InfiniteRate {
+		return burstError(n, l.burst)
+	}
+	delay, ok := l.reserve(n, InfiniteDuration)
+	if !ok {
+		return neverError(n)
+	}
+	if delay <= 0 {
+		return nil
+	}
+	if err := sleepCtx(ctx, l.clock, delay); err != nil {
+		l.refund(n)
+		return ctxError(n, err, delay)
+	}
+	return nil
+}
+
+// reserve takes n tokens if they are available within maxWait and returns
+// how long the caller must wait before using them.
+func (l *AtomicLimiter) reserve(n int, maxWait time.Duration) (time.Duration, bool) {
+	switch {
+	case n < 0:
+		return 0, false
+	case l.rate == InfiniteRate:
+		return 0, true
+	case n > l.burst:
+		return 0, false
+	case l.rate <= 0:
+		for {
+			left := l.budget.Load()
+			if left < int64(n) {
+				return 0, false
+			}
+			if l.budget.CompareAndSwap(left, left-int64(n)) {
+				return 0, true
+			}
+		}
+	}
+	cost := int64(n) * l.interval
+	for {
+		now := l.now()
+		old := l.tat.Load()
+		tat := old
+		if tat < now {
+			tat = now
+		}
+		next := tat + cost
+		delay := time.Duration(next - now - l.tau)
+		if delay < 0 {
+			delay = 0
+		}
+		if delay > maxWait {
+			return 0, false
+		}
+		if l.tat.CompareAndSwap(old, next) {
+			return delay, true
+		}
+	}
+}
+
+// refund returns n tokens taken by an abandoned wait.
+func (l *AtomicLimiter) refund(n int) {
+	cost := int64(n) * l.interval
+	for {
+		now := l.now()
+		old := l.tat.Load()
+		next := old - cost
+		if next < now {
+			next = now
+		}
+		if old <= now || l.tat.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
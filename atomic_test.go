@@ -0,0 +1,107 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAtomicMatchesRateLimiter(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	a := NewAtomic(Every(100*time.Millisecond), 3, clk)
+	rl := New(Every(100*time.Millisecond), 3, clk)
+
+	for step, n := range []int{2, 1, 1, 4, 1, 3, 1, 2} {
+		if got, want := a.AllowN(n), rl.AllowN(n); got != want {
+			t.Fatalf("step %d: AllowN(%d) = %v, RateLimiter says %v", step, n, got, want)
+		}
+		if got, want := a.AvailableTokens(), rl.AvailableTokens(); math.Abs(got-want) > 1e-9 {
+			t.Fatalf("step %d: %v tokens, RateLimiter has %v", step, got, want)
+		}
+		clk.Sleep(70 * time.Millisecond)
+	}
+}
+
+func TestAtomicWait(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	a := NewAtomic(10, 1, clk)
+	for i := 0; i < 3; i++ {
+		if err := a.Wait(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 200*time.Millisecond {
+		t.Fatalf("expected 200ms of waiting, got %v", got)
+	}
+	if err := a.Wait(2); !errors.Is(err, ErrBurstExceeded) {
+		t.Fatalf("expected ErrBurstExceeded, got %v", err)
+	}
+}
+
+func TestAtomicWaitCanceledRefunds(t *testing.T) {
+	clk := &blockingClock{newFakeClock(time.Unix(0, 0)), make(chan struct{})}
+	defer close(clk.release)
+	a := NewAtomic(1, 1, clk)
+	a.Allow()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := a.WaitN(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	if got := a.AvailableTokens(); got != 0 {
+		t.Fatalf("expected the wait's token back, got %v tokens", got)
+	}
+}
+
+func TestAtomicZeroAndInfiniteRate(t *testing.T) {
+	zero := NewAtomic(0, 2, nil)
+	if !zero.AllowN(2) || zero.Allow() {
+		t.Fatal("expected a zero rate to admit only the burst")
+	}
+	if !NewAtomic(InfiniteRate, 0, nil).AllowN(1000) {
+		t.Fatal("expected an infinite rate to admit everything")
+	}
+}
+
+func TestAtomicConcurrent(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	a := NewAtomic(1, 100, clk)
+	var mu sync.Mutex
+	allowed := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if a.Allow() {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 100 {
+		t.Fatalf("expected exactly the burst to be admitted, got %d", allowed)
+	}
+}
+
+func BenchmarkAllowParallel(b *testing.B) {
+	for name, l := range map[string]Limiter{
+		"RateLimiter":   New(1e9, 1<<30, nil),
+		"AtomicLimiter": NewAtomic(1e9, 1<<30, nil),
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					l.Allow()
+				}
+			})
+		})
+	}
+}
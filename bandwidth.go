@@ -0,0 +1,168 @@
+package ratelimiter
+
+import (
+	"context"
+	"io"
+	"math"
+)
+
+// BandwidthLimiter is a limiter that Reader, Writer and Conn wait on for
+// the bytes they move: a *RateLimiter, or a *ByteLimiter to count bytes
+// exactly at rates where float64 tokens lose precision.
+type BandwidthLimiter interface {
+	WaitN(ctx context.Context, n int) error
+	// maxWaitN returns the most tokens one WaitN can be granted, or -1 if
+	// there is no limit.
+	maxWaitN() int
+}
+
+func (rl *RateLimiter) maxWaitN() int {
+	if rl.Rate() == InfiniteRate {
+		return -1
+	}
+	return rl.Burst()
+}
+
+func (l *ByteLimiter) maxWaitN() int {
+	return int(min(l.Burst(), math.MaxInt))
+}
+
+// byteMeter converts byte counts to tokens at one token per unit bytes,
+// carrying partial units over to the next call so nothing is lost to
+// rounding.
+type byteMeter struct {
+	l        BandwidthLimiter
+	ctx      context.Context
+	deadline *deadline // a Conn's, waited on instead of ctx
+	unit     int
+	pending  int
+}
+
+// maxChunk returns the most bytes that can be charged in one wait, or 0 if
+// there is no limit.
+func (m *byteMeter) maxChunk() int {
+	switch most := m.l.maxWaitN(); {
+	case most < 0 || most > math.MaxInt/m.unit:
+		return 0
+	case most > 0:
+		return most * m.unit
+	}
+	return 1
+}
+
+// charge waits for the tokens covering n more bytes.
+func (m *byteMeter) charge(n int) error {
+	m.pending += n
+	tokens := m.pending / m.unit
+	m.pending %= m.unit
+	if tokens == 0 {
+		return nil
+	}
+	if m.deadline != nil {
+		return m.deadline.wait(m.l, tokens)
+	}
+	return m.l.WaitN(m.ctx, tokens)
+}
+
+// read reads at most as many bytes as the limiter's burst covers from r,
+// then blocks until the limiter has paid for them.
+func (m *byteMeter) read(r io.Reader, p []byte) (int, error) {
+	if max := m.maxChunk(); max > 0 && len(p) > max {
+		p = p[:max]
+	}
+	n, err := r.Read(p)
+	if n > 0 {
+		if werr := m.charge(n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// write splits p into chunks the limiter's burst covers and waits for each
+// chunk's tokens before writing it to w.
+func (m *byteMeter) write(w io.Writer, p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if max := m.maxChunk(); max > 0 && len(chunk) > max {
+			chunk = chunk[:max]
+		}
+		if err := m.charge(len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Reader throttles reads from an underlying reader to a limiter's rate, by
+// default at one token per byte. It is not safe for concurrent use.
+type Reader struct {
+	r io.Reader
+	m byteMeter
+}
+
+// NewReader returns a Reader reading from r at the rate of l.
+func NewReader(r io.Reader, l BandwidthLimiter) *Reader {
+	return &Reader{r: r, m: byteMeter{l: l, ctx: context.Background(), unit: 1}}
+}
+
+// WithUnit charges one token per unit bytes instead of per byte. Use it to
+// keep token counts small at high bandwidths, e.g. a unit of 1024 with a
+// rate in KiB/s.
+func (r *Reader) WithUnit(unit int) *Reader {
+	if unit > 0 {
+		r.m.unit = unit
+	}
+	return r
+}
+
+// WithContext makes reads give up waiting with ctx.Err() once ctx is done.
+func (r *Reader) WithContext(ctx context.Context) *Reader {
+	r.m.ctx = ctx
+	return r
+}
+
+// Read reads at most as many bytes as the limiter's burst covers, then
+// blocks until the limiter has paid for them.
+func (r *Reader) Read(p []byte) (int, error) {
+	return r.m.read(r.r, p)
+}
+
+// Writer throttles writes to an underlying writer to a limiter's rate, by
+// default at one token per byte. It is not safe for concurrent use.
+type Writer struct {
+	w io.Writer
+	m byteMeter
+}
+
+// NewWriter returns a Writer writing to w at the rate of l.
+func NewWriter(w io.Writer, l BandwidthLimiter) *Writer {
+	return &Writer{w: w, m: byteMeter{l: l, ctx: context.Background(), unit: 1}}
+}
+
+// WithUnit charges one token per unit bytes instead of per byte.
+func (w *Writer) WithUnit(unit int) *Writer {
+	if unit > 0 {
+		w.m.unit = unit
+	}
+	return w
+}
+
+// WithContext makes writes give up waiting with ctx.Err() once ctx is done.
+func (w *Writer) WithContext(ctx context.Context) *Writer {
+	w.m.ctx = ctx
+	return w
+}
+
+// Write splits p into chunks the limiter's burst covers and waits for each
+// chunk's tokens before writing it.
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.m.write(w.w, p)
+}
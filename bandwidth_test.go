@@ -0,0 +1,69 @@
+package ratelimiter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReaderThrottles(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(1000, 100, clk) // 1000 B/s, 100 B burst
+	data, err := io.ReadAll(NewReader(strings.NewReader(strings.Repeat("x", 1100)), rl))
+	if err != nil || len(data) != 1100 {
+		t.Fatalf("expected 1100 bytes, got %d (%v)", len(data), err)
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != time.Second {
+		t.Fatalf("expected 1000 bytes beyond the burst to take 1s, got %v", got)
+	}
+}
+
+func TestReaderWithByteLimiter(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	bl := NewByteLimiter(8*Kilobit, 100, clk) // 1000 B/s, 100 B burst
+	data, err := io.ReadAll(NewReader(strings.NewReader(strings.Repeat("x", 1100)), bl))
+	if err != nil || len(data) != 1100 {
+		t.Fatalf("expected 1100 bytes, got %d (%v)", len(data), err)
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != time.Second {
+		t.Fatalf("expected 1000 bytes beyond the burst to take 1s, got %v", got)
+	}
+	if got := bl.Available(); got != 0 {
+		t.Fatalf("%d bytes left, want every byte read charged exactly", got)
+	}
+}
+
+func TestWriterThrottlesWithUnit(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(10, 4, clk) // 10 KiB/s, 4 KiB burst
+	var buf bytes.Buffer
+	w := NewWriter(&buf, rl).WithUnit(1024)
+
+	n, err := w.Write(make([]byte, 14*1024+512))
+	if err != nil || n != 14*1024+512 {
+		t.Fatalf("expected full write, got %d (%v)", n, err)
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != time.Second {
+		t.Fatalf("expected 10 KiB beyond the burst to take 1s, got %v", got)
+	}
+	// The half unit left over is charged with the next write.
+	w.Write(make([]byte, 512))
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 1100*time.Millisecond {
+		t.Fatalf("expected carried-over half unit to complete a token, got %v", got)
+	}
+}
+
+func TestWriterContext(t *testing.T) {
+	rl := New(1, 1, newFakeClock(time.Unix(0, 0)))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var buf bytes.Buffer
+	n, err := NewWriter(&buf, rl).WithContext(ctx).Write([]byte("hi"))
+	if !errors.Is(err, context.Canceled) || n != 0 {
+		t.Fatalf("expected canceled write of 0 bytes, got %d (%v)", n, err)
+	}
+}
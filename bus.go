@@ -0,0 +1,76 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Bus is a publish-subscribe message bus over which GossipLimiters share
+// their counts. With nats.go, Publish is Conn.Publish and Subscribe is
+// Conn.Subscribe with a handler passing Msg.Data, returning the
+// Subscription's Unsubscribe; other buses plug in the same way.
+type Bus interface {
+	Publish(subject string, data []byte) error
+	Subscribe(subject string, handler func(data []byte)) (unsubscribe func() error, err error)
+}
+
+// Share coordinates g with its peers over bus instead of gossip: it
+// subscribes to subject, merging the counts peers publish, and publishes
+// this instance's own count to subject at once and then every interval
+// in which it changed. Publishing fewer than every event trades accuracy
+// for bus traffic, as with Gossip. Publish failures are passed to onError,
+// if not nil. The returned stop function publishes a final count and
+// unsubscribes.
+func (g *GossipLimiter) Share(bus Bus, subject string, interval time.Duration, onError func(error)) (stop func() error, err error) {
+	unsubscribe, err := bus.Subscribe(subject, func(data []byte) {
+		_ = g.Merge(data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	published := ^uint64(0) // publish at once, giving peers a baseline
+	publish := func() error {
+		msg, n, changed := g.ownMessage(published)
+		if !changed {
+			return nil
+		}
+		if err := bus.Publish(subject, msg); err != nil {
+			return err
+		}
+		published = n
+		return nil
+	}
+	if err := publish(); err != nil && onError != nil {
+		onError(err)
+	}
+	halt := RunEvery(interval, func() {
+		if err := publish(); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+	var once sync.Once
+	return func() error {
+		err := errors.New("rate: sharing already stopped")
+		once.Do(func() {
+			halt()
+			err = errors.Join(publish(), unsubscribe())
+		})
+		return err
+	}, nil
+}
+
+// ownMessage returns a message with only this instance's count, and the
+// count, reporting whether it differs from published.
+func (g *GossipLimiter) ownMessage(published uint64) ([]byte, uint64, bool) {
+	g.mu.Lock()
+	n := g.counts[g.id]
+	g.mu.Unlock()
+	if n == published {
+		return nil, n, false
+	}
+	msg, _ := json.Marshal(gossipMessage{Counts: map[string]uint64{g.id: n}})
+	return msg, n, true
+}
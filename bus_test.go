@@ -0,0 +1,85 @@
+package ratelimiter
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBus delivers messages synchronously to every subscriber.
+type fakeBus struct {
+	mu   sync.Mutex
+	subs map[int]func([]byte)
+	next int
+	err  error
+}
+
+func (b *fakeBus) Publish(subject string, data []byte) error {
+	b.mu.Lock()
+	if b.err != nil {
+		b.mu.Unlock()
+		return b.err
+	}
+	var handlers []func([]byte)
+	for _, h := range b.subs {
+		handlers = append(handlers, h)
+	}
+	b.mu.Unlock()
+	for _, h := range handlers {
+		h(data)
+	}
+	return nil
+}
+
+func (b *fakeBus) Subscribe(subject string, handler func([]byte)) (func() error, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.next
+	b.next++
+	b.subs[id] = handler
+	return func() error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, id)
+		return nil
+	}, nil
+}
+
+func TestGossipShare(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	bus := &fakeBus{subs: make(map[int]func([]byte))}
+	a := NewGossip("a", 1, 10, clk)
+	b := NewGossip("b", 1, 10, clk)
+
+	stopB, err := b.Share(bus, "limits.api", time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopB()
+	stopA, err := a.Share(bus, "limits.api", time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.AllowN(3)
+	if err := stopA(); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Limiter().AvailableTokens(); got != 7 {
+		t.Fatalf("b has %v tokens after a published, want 7", got)
+	}
+	if err := stopA(); err == nil {
+		t.Fatal("a second stop succeeded")
+	}
+
+	var errs []error
+	bus.err = errors.New("bus down")
+	stopA, err = a.Share(bus, "limits.api", time.Hour, func(err error) { errs = append(errs, err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopA()
+	if len(errs) != 1 {
+		t.Fatalf("publish errors = %v", errs)
+	}
+}
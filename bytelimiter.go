@@ -0,0 +1,229 @@
+package ratelimiter
+
+import (
+	"context"
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// Byte sizes for the rates and bursts of a ByteLimiter counting bytes. Bit
+// sizes are given in bytes too, so a rate of 10*Gigabit is 10 Gbit/s.
+const (
+	Byte     int64 = 1
+	Kilobyte       = 1000 * Byte
+	Megabyte       = 1000 * Kilobyte
+	Gigabyte       = 1000 * Megabyte
+	Kibibyte       = 1024 * Byte
+	Mebibyte       = 1024 * Kibibyte
+	Gibibyte       = 1024 * Mebibyte
+	Kilobit        = Kilobyte / 8
+	Megabit        = Megabyte / 8
+	Gigabit        = Gigabyte / 8
+)
+
+const nanosPerSecond = uint64(time.Second)
+
+// ByteLimiter is a token bucket that counts whole units, typically bytes,
+// in int64 with exact integer refills. A RateLimiter keeps its tokens in a
+// float64, which is exact only to 2^53 and loses a little to rounding on
+// every refill; at bandwidths of gigabytes per second those losses add up
+// over long transfers. A ByteLimiter carries the fraction of a unit
+// refilled between calls over to the next, so no unit is ever gained or
+// lost, whatever the rate and however often it is called.
+//
+// Rates are in units per second and bursts in units. A zero rate admits
+// only the initial burst. A ByteLimiter can drive a Reader, Writer or Conn.
+type ByteLimiter struct {
+	mu     sync.Mutex
+	rate   int64
+	burst  int64
+	tokens int64  // negative while waiters are in debt
+	rem    uint64 // refill carried over, in unit-nanoseconds below one unit
+	last   int64  // ns since epoch of the last refill
+	epoch  time.Time
+	clock  Clock
+}
+
+// NewByteLimiter returns a full ByteLimiter refilling rate units per
+// second up to burst.
+func NewByteLimiter(rate, burst int64, clk Clock) *ByteLimiter {
+	if clk == nil {
+		clk = realClock{}
+	}
+	return &ByteLimiter{rate: max(rate, 0), burst: max(burst, 0), tokens: max(burst, 0), epoch: clk.Now(), clock: clk}
+}
+
+func (l *ByteLimiter) Rate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+func (l *ByteLimiter) Burst() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.burst
+}
+
+// Available returns the units available now, negative while waiters are
+// owed them.
+func (l *ByteLimiter) Available() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refillLocked(l.now())
+	return l.tokens
+}
+
+// SetRate changes the refill rate, refilling at the old rate up to now.
+func (l *ByteLimiter) SetRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refillLocked(l.now())
+	l.rate = max(rate, 0)
+}
+
+func (l *ByteLimiter) Allow() bool {
+	return l.AllowBytes(1)
+}
+
+// AllowN is AllowBytes for n units.
+func (l *ByteLimiter) AllowN(n int) bool {
+	return l.AllowBytes(int64(n))
+}
+
+// AllowBytes reports whether n units are available now, taking them if
+// so. A negative n is denied.
+func (l *ByteLimiter) AllowBytes(n int64) bool {
+	if n < 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refillLocked(l.now())
+	if l.tokens < n {
+		return false
+	}
+	l.tokens -= n
+	return true
+}
+
+// Wait is shorthand for WaitN(context.Background(), n).
+func (l *ByteLimiter) Wait(n int) error {
+	return l.WaitN(context.Background(), n)
+}
+
+// WaitN is WaitBytes for n units.
+func (l *ByteLimiter) WaitN(ctx context.Context, n int) error {
+	return l.WaitBytes(ctx, int64(n))
+}
+
+// WaitBytes blocks until n units are available, taking them, or ctx is
+// done. Callers are served in the order they arrive. Failures are
+// reported as a *WaitError, as by RateLimiter.WaitN: ErrBurstExceeded if n
+// exceeds the burst, ErrWaitTimeout if the units cannot be had before
+// ctx's deadline or ever, and ErrContextCanceled if ctx is canceled while
+// waiting, in which case the units are returned.
+func (l *ByteLimiter) WaitBytes(ctx context.Context, n int64) error {
+	if n < 0 {
+		return negativeError(int(n))
+	}
+	select {
+	case <-ctx.Done():
+		return ctxError(int(n), ctx.Err(), 0)
+	default:
+	}
+	l.mu.Lock()
+	if n > l.burst {
+		l.mu.Unlock()
+		return burstError(int(n), int(l.burst))
+	}
+	l.refillLocked(l.now())
+	if l.tokens < n && l.rate == 0 {
+		l.mu.Unlock()
+		return neverError(int(n))
+	}
+	l.tokens -= n
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = l.untilLocked(uint64(-l.tokens))
+	}
+	l.mu.Unlock()
+	if delay == 0 {
+		return nil
+	}
+
+	if d, ok := ctx.Deadline(); ok && time.Until(d) < delay {
+		l.refund(n)
+		return &WaitError{Err: ErrWaitTimeout, N: int(n), RetryAfter: delay, cause: context.DeadlineExceeded}
+	}
+	if err := sleepCtx(ctx, l.clock, delay); err != nil {
+		l.refund(n)
+		return ctxError(int(n), err, delay)
+	}
+	return nil
+}
+
+// refund returns n units taken by an abandoned wait.
+func (l *ByteLimiter) refund(n int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refillLocked(l.now())
+	l.tokens = min(l.tokens+n, l.burst)
+}
+
+func (l *ByteLimiter) now() int64 {
+	return int64(l.clock.Now().Sub(l.epoch))
+}
+
+// refillLocked adds the units refilled between the last refill and now,
+// exactly: the product of the elapsed nanoseconds and the rate is taken in
+// 128 bits, and the remainder below a whole unit is kept for the next
+// refill. Callers must hold l.mu.
+func (l *ByteLimiter) refillLocked(now int64) {
+	if now <= l.last {
+		return
+	}
+	elapsed := uint64(now - l.last)
+	l.last = now
+	if l.rate == 0 || l.tokens >= l.burst {
+		return
+	}
+	hi, lo := bits.Mul64(elapsed, uint64(l.rate))
+	lo, carry := bits.Add64(lo, l.rem, 0)
+	hi += carry
+	if hi >= nanosPerSecond {
+		// More than 2^64 units: the bucket is full whatever it held.
+		l.tokens, l.rem = l.burst, 0
+		return
+	}
+	gained, rem := bits.Div64(hi, lo, nanosPerSecond)
+	if gained >= uint64(l.burst-l.tokens) {
+		l.tokens, l.rem = l.burst, 0
+		return
+	}
+	l.tokens += int64(gained)
+	l.rem = rem
+}
+
+// untilLocked returns how long until missing more units have refilled,
+// rounded up to the nanosecond. Callers must hold l.mu, with a nonzero
+// rate.
+func (l *ByteLimiter) untilLocked(missing uint64) time.Duration {
+	hi, lo := bits.Mul64(missing, nanosPerSecond)
+	lo, borrow := bits.Sub64(lo, l.rem, 0)
+	hi -= borrow
+	rate := uint64(l.rate)
+	if hi >= rate {
+		return InfiniteDuration
+	}
+	q, r := bits.Div64(hi, lo, rate)
+	if r > 0 {
+		q++
+	}
+	if q > math.MaxInt64 {
+		return InfiniteDuration
+	}
+	return time.Duration(q)
+}
@@ -0,0 +1,94 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestByteLimiterExactRefill(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	bl := NewByteLimiter(10*Gigabit, 10*Gigabyte, clk)
+	if !bl.AllowBytes(10 * Gigabyte) {
+		t.Fatal("full burst denied")
+	}
+	// 10 Gbit/s is 1.25 bytes per nanosecond: a million 7ns refills must
+	// add exactly 8.75 MB, with the quarter bytes carried over.
+	for i := 0; i < 1_000_000; i++ {
+		clk.Sleep(7 * time.Nanosecond)
+		bl.AllowBytes(0)
+	}
+	if got := bl.Available(); got != 8_750_000 {
+		t.Fatalf("after 7ms at 10 Gbit/s: %d bytes, want 8750000", got)
+	}
+
+	// A rate that does not divide a second: 3 bytes/s refilled every 1ms.
+	bl = NewByteLimiter(3, 3, clk)
+	bl.AllowBytes(3)
+	for i := 0; i < 999; i++ {
+		clk.Sleep(time.Millisecond)
+		bl.AllowBytes(0)
+	}
+	if got := bl.Available(); got != 2 {
+		t.Fatalf("after 999ms at 3/s: %d, want 2", got)
+	}
+	clk.Sleep(time.Millisecond)
+	if got := bl.Available(); got != 3 {
+		t.Fatalf("after 1s at 3/s: %d, want 3", got)
+	}
+}
+
+func TestByteLimiterLargeCounts(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	// Well past float64's 2^53 exact integers.
+	const burst = 1<<60 + 1
+	bl := NewByteLimiter(1<<62, burst, clk)
+	if !bl.AllowBytes(burst - 1) {
+		t.Fatal("burst-1 denied")
+	}
+	if got := bl.Available(); got != 1 {
+		t.Fatalf("left %d, want 1", got)
+	}
+	clk.Sleep(24 * time.Hour)
+	if got := bl.Available(); got != burst {
+		t.Fatalf("after a day: %d, want the burst %d", got, int64(burst))
+	}
+}
+
+func TestByteLimiterWait(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	bl := NewByteLimiter(Gigabyte, Gigabyte, clk)
+	bl.AllowBytes(Gigabyte)
+	start := clk.Now()
+	if err := bl.WaitBytes(context.Background(), 500*Megabyte); err != nil {
+		t.Fatal(err)
+	}
+	if d := clk.Now().Sub(start); d != 500*time.Millisecond {
+		t.Fatalf("waited %v for half a second's bytes", d)
+	}
+	if got := bl.Available(); got != 0 {
+		t.Fatalf("left %d after the wait, want 0", got)
+	}
+
+	var we *WaitError
+	if err := bl.WaitBytes(context.Background(), 2*Gigabyte); !errors.As(err, &we) || !errors.Is(err, ErrBurstExceeded) {
+		t.Fatalf("over-burst wait: %v", err)
+	}
+	if err := bl.WaitBytes(context.Background(), -1); !errors.Is(err, ErrNegativeTokens) {
+		t.Fatalf("negative wait: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := bl.WaitBytes(ctx, Megabyte); !errors.Is(err, ErrContextCanceled) {
+		t.Fatalf("canceled wait: %v", err)
+	}
+	if got := bl.Available(); got != 0 {
+		t.Fatalf("canceled wait took bytes: %d left", got)
+	}
+
+	bl.SetRate(0)
+	if err := bl.WaitBytes(context.Background(), 1); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("wait at zero rate: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+// Package clocktest provides a controllable clock for deterministic tests
+// of code that uses the ratelimiter package.
+//
+// A Clock only moves when told to. Tests typically start the code under
+// test, call BlockUntil to wait for it to go to sleep, then Advance past the
+// point it is waiting for:
+//
+//	clk := clocktest.New(time.Unix(0, 0))
+//	rl := ratelimiter.New(1, 1, clk)
+//	rl.Allow()
+//	go rl.Wait(1)
+//	clk.BlockUntil(1)
+//	clk.Advance(time.Second) // the Wait returns
+package clocktest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/navrang-singh/ratelimiter"
+)
+
+// Clock is a fake ratelimiter.TimerClock. Its time changes only through
+// Advance and Set, or, for an auto-advancing clock, when something sleeps.
+// It is safe for concurrent use.
+type Clock struct {
+	mu     sync.Mutex
+	cond   sync.Cond // signaled when timers are added
+	now    time.Time
+	auto   bool
+	timers []*timer
+}
+
+var _ ratelimiter.TimerClock = (*Clock)(nil)
+
+// New returns a Clock set to t that moves only when advanced.
+func New(t time.Time) *Clock {
+	c := &Clock{now: t}
+	c.cond.L = &c.mu
+	return c
+}
+
+// NewAutoAdvancing returns a Clock set to t that jumps forward whenever
+// something sleeps or starts a timer, so waits return at once with the
+// clock showing the time they would have ended. It suits tests that check
+// delays rather than interleavings.
+func NewAutoAdvancing(t time.Time) *Clock {
+	c := New(t)
+	c.auto = true
+	return c
+}
+
+// Now returns the clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep blocks until the clock has been advanced by d.
+func (c *Clock) Sleep(d time.Duration) {
+	<-c.NewTimer(d).C()
+}
+
+// NewTimer returns a timer that fires once the clock has been advanced by
+// d. A timer for zero or less fires immediately.
+func (c *Clock) NewTimer(d time.Duration) ratelimiter.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &timer{c: c, when: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if c.auto && t.when.After(c.now) {
+		c.setLocked(t.when)
+	}
+	if !t.when.After(c.now) {
+		t.ch <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Advance moves the clock forward by d, firing any timers that come due.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(c.now.Add(d))
+}
+
+// Set moves the clock to t, firing any timers that come due. Setting the
+// clock backwards fires nothing.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(t)
+}
+
+// Waiters returns the number of pending sleeps and timers.
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil blocks until at least n sleeps or timers are pending.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+func (c *Clock) setLocked(t time.Time) {
+	c.now = t
+	pending := c.timers[:0]
+	for _, tm := range c.timers {
+		if tm.when.After(t) {
+			pending = append(pending, tm)
+			continue
+		}
+		tm.ch <- t
+	}
+	clear(c.timers[len(pending):])
+	c.timers = pending
+}
+
+// stopLocked removes t from the pending timers, reporting whether it was
+// there.
+func (c *Clock) stopLocked(t *timer) bool {
+	for i, tm := range c.timers {
+		if tm == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type timer struct {
+	c    *Clock
+	when time.Time
+	ch   chan time.Time
+}
+
+func (t *timer) C() <-chan time.Time { return t.ch }
+
+// Stop prevents the timer from firing. It reports whether the timer was
+// still pending.
+func (t *timer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	return t.c.stopLocked(t)
+}
@@ -0,0 +1,117 @@
+package clocktest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/navrang-singh/ratelimiter"
+	"github.com/navrang-singh/ratelimiter/clocktest"
+)
+
+var epoch = time.Unix(1000, 0)
+
+func TestTimerFiresOnAdvance(t *testing.T) {
+	clk := clocktest.New(epoch)
+	timer := clk.NewTimer(time.Second)
+
+	clk.Advance(999 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	clk.Advance(time.Millisecond)
+	select {
+	case got := <-timer.C():
+		if want := epoch.Add(time.Second); !got.Equal(want) {
+			t.Fatalf("timer fired at %v, want %v", got, want)
+		}
+	default:
+		t.Fatal("timer did not fire")
+	}
+	if clk.Waiters() != 0 {
+		t.Fatalf("Waiters() = %d after firing, want 0", clk.Waiters())
+	}
+}
+
+func TestTimerStop(t *testing.T) {
+	clk := clocktest.New(epoch)
+	timer := clk.NewTimer(time.Second)
+	if !timer.Stop() {
+		t.Fatal("Stop on a pending timer returned false")
+	}
+	if timer.Stop() {
+		t.Fatal("second Stop returned true")
+	}
+	clk.Advance(time.Hour)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+}
+
+func TestSetBackwardsFiresNothing(t *testing.T) {
+	clk := clocktest.New(epoch)
+	timer := clk.NewTimer(time.Second)
+	clk.Set(epoch.Add(-time.Hour))
+	if clk.Waiters() != 1 {
+		t.Fatalf("Waiters() = %d, want 1", clk.Waiters())
+	}
+	clk.Set(epoch.Add(time.Second))
+	<-timer.C()
+}
+
+func TestBlockUntilAndAdvanceDriveWait(t *testing.T) {
+	clk := clocktest.New(epoch)
+	rl := ratelimiter.New(1, 1, clk)
+	rl.Allow()
+
+	done := make(chan error, 1)
+	go func() { done <- rl.Wait(1) }()
+	clk.BlockUntil(1)
+
+	select {
+	case <-done:
+		t.Fatal("Wait returned before the clock moved")
+	default:
+	}
+	clk.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+}
+
+func TestCanceledWaitStopsTimer(t *testing.T) {
+	clk := clocktest.New(epoch)
+	rl := ratelimiter.New(1, 1, clk)
+	rl.Allow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- rl.WaitN(ctx, 1) }()
+	clk.BlockUntil(1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("WaitN error = %v, want Canceled", err)
+	}
+	if clk.Waiters() != 0 {
+		t.Fatalf("Waiters() = %d after cancel, want 0", clk.Waiters())
+	}
+}
+
+func TestAutoAdvancing(t *testing.T) {
+	clk := clocktest.NewAutoAdvancing(epoch)
+	rl := ratelimiter.New(2, 1, clk)
+	for i := 0; i < 3; i++ {
+		if err := rl.Wait(1); err != nil {
+			t.Fatalf("Wait: %v", err)
+		}
+	}
+	if got, want := clk.Now(), epoch.Add(time.Second); !got.Equal(want) {
+		t.Fatalf("clock at %v after three waits, want %v", got, want)
+	}
+}
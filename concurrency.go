@@ -0,0 +1,124 @@
+package ratelimiter
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// ConcurrencyLimiter caps the number of operations in flight at once.
+// Callers that cannot start immediately wait in a FIFO queue bounded by
+// maxQueue.
+type ConcurrencyLimiter struct {
+	mu       sync.Mutex
+	max      int
+	maxQueue int
+	inFlight int
+	waiters  list.List // of chan struct{}
+}
+
+// NewConcurrencyLimiter returns a ConcurrencyLimiter allowing max operations
+// in flight. Up to maxQueue callers may wait for a slot; if maxQueue is zero
+// Acquire never waits, and if it is negative the queue is unbounded.
+func NewConcurrencyLimiter(max, maxQueue int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{max: max, maxQueue: maxQueue}
+}
+
+func (c *ConcurrencyLimiter) Max() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.max
+}
+
+// SetMax changes the number of operations allowed in flight. Lowering it
+// does not interrupt operations already running.
+func (c *ConcurrencyLimiter) SetMax(max int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.max = max
+	c.notifyLocked()
+}
+
+// InFlight returns the number of acquired, unreleased slots.
+func (c *ConcurrencyLimiter) InFlight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inFlight
+}
+
+// Waiting returns the number of callers blocked in Acquire.
+func (c *ConcurrencyLimiter) Waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.waiters.Len()
+}
+
+// TryAcquire takes a slot if one is free without waiting.
+func (c *ConcurrencyLimiter) TryAcquire() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inFlight < c.max && c.waiters.Len() == 0 {
+		c.inFlight++
+		return true
+	}
+	return false
+}
+
+// Acquire takes a slot, waiting in line for one if necessary. It returns
+// ErrQueueFull if the wait queue is full, or ctx.Err() if ctx is done before
+// a slot is handed over. Every successful Acquire must be paired with a
+// Release.
+func (c *ConcurrencyLimiter) Acquire(ctx context.Context) error {
+	c.mu.Lock()
+	if c.inFlight < c.max && c.waiters.Len() == 0 {
+		c.inFlight++
+		c.mu.Unlock()
+		return nil
+	}
+	if c.maxQueue >= 0 && c.waiters.Len() >= c.maxQueue {
+		c.mu.Unlock()
+		return ErrQueueFull
+	}
+	ready := make(chan struct{})
+	elem := c.waiters.PushBack(ready)
+	c.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		select {
+		case <-ready:
+			// The slot was handed over while ctx was being canceled; pass
+			// it on.
+			c.inFlight--
+			c.notifyLocked()
+		default:
+			c.waiters.Remove(elem)
+		}
+		return ctx.Err()
+	}
+}
+
+// Release returns a slot taken by Acquire or TryAcquire.
+func (c *ConcurrencyLimiter) Release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inFlight == 0 {
+		panic("ratelimiter: Release without Acquire")
+	}
+	c.inFlight--
+	c.notifyLocked()
+}
+
+// notifyLocked hands free slots to waiters in arrival order.
+func (c *ConcurrencyLimiter) notifyLocked() {
+	for c.inFlight < c.max && c.waiters.Len() > 0 {
+		front := c.waiters.Front()
+		c.waiters.Remove(front)
+		c.inFlight++
+		close(front.Value.(chan struct{}))
+	}
+}
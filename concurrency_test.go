@@ -0,0 +1,70 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiterTryAcquire(t *testing.T) {
+	c := NewConcurrencyLimiter(2, 0)
+	if !c.TryAcquire() || !c.TryAcquire() {
+		t.Fatalf("expected two slots")
+	}
+	if c.TryAcquire() {
+		t.Fatalf("expected third slot to be refused")
+	}
+	if err := c.Acquire(context.Background()); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull without a queue, got %v", err)
+	}
+	c.Release()
+	if n := c.InFlight(); n != 1 {
+		t.Fatalf("expected 1 in flight, got %d", n)
+	}
+}
+
+func TestConcurrencyLimiterQueue(t *testing.T) {
+	c := NewConcurrencyLimiter(1, 1)
+	c.TryAcquire()
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- c.Acquire(context.Background()) }()
+	for c.Waiting() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.Acquire(context.Background()); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull with full queue, got %v", err)
+	}
+
+	c.Release()
+	if err := <-acquired; err != nil {
+		t.Fatalf("expected waiter to get the released slot, got %v", err)
+	}
+	if n := c.InFlight(); n != 1 {
+		t.Fatalf("expected slot to be handed over, got %d in flight", n)
+	}
+}
+
+func TestConcurrencyLimiterAcquireCanceled(t *testing.T) {
+	c := NewConcurrencyLimiter(1, -1)
+	c.TryAcquire()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- c.Acquire(ctx) }()
+	for c.Waiting() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if n := c.Waiting(); n != 0 {
+		t.Fatalf("expected canceled waiter to leave the queue, got %d", n)
+	}
+	c.Release()
+	if !c.TryAcquire() {
+		t.Fatalf("expected slot to be free after release")
+	}
+}
@@ -0,0 +1,139 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Conn is a net.Conn whose reads and writes are throttled independently,
+// at one token per byte. Waiting for tokens honors the read and write
+// deadlines: a wait that would outlast the deadline fails with
+// os.ErrDeadlineExceeded, like the underlying I/O would. Setting a deadline
+// during a wait applies to it at once, so a deadline in the past aborts it.
+type Conn struct {
+	net.Conn
+
+	rmu           sync.Mutex
+	read          *byteMeter
+	readDeadline  *deadline
+	wmu           sync.Mutex
+	write         *byteMeter
+	writeDeadline *deadline
+}
+
+// NewConn wraps c, throttling reads with read and writes with write. Either
+// limiter may be nil to leave that direction unthrottled.
+func NewConn(c net.Conn, read, write BandwidthLimiter) *Conn {
+	conn := &Conn{Conn: c, readDeadline: new(deadline), writeDeadline: new(deadline)}
+	if read != nil {
+		conn.read = &byteMeter{l: read, unit: 1, deadline: conn.readDeadline}
+	}
+	if write != nil {
+		conn.write = &byteMeter{l: write, unit: 1, deadline: conn.writeDeadline}
+	}
+	return conn
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	if c.read == nil {
+		return c.Conn.Read(p)
+	}
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	n, err := c.read.read(c.Conn, p)
+	return n, deadlineErr(err)
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	if c.write == nil {
+		return c.Conn.Write(p)
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	n, err := c.write.write(c.Conn, p)
+	return n, deadlineErr(err)
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// errDeadlineChanged cancels a wait whose deadline was changed under it.
+var errDeadlineChanged = errors.New("ratelimiter: deadline changed")
+
+// deadline is a deadline that can be set concurrently with its use. Waits
+// on it are serialized by the Conn's per-direction mutex, so there is at
+// most one to wake.
+type deadline struct {
+	mu     sync.Mutex
+	t      time.Time
+	cancel context.CancelCauseFunc // of the wait in progress, if any
+}
+
+func (d *deadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.t = t
+	if d.cancel != nil {
+		d.cancel(errDeadlineChanged)
+	}
+}
+
+// context returns a context for one wait that expires at the deadline, if
+// one is set, and is canceled with errDeadlineChanged if the deadline is
+// set before the wait ends.
+func (d *deadline) context() (context.Context, context.CancelFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ctx, cancel := context.WithCancelCause(context.Background())
+	d.cancel = cancel
+	stop := func() {}
+	if !d.t.IsZero() {
+		ctx, stop = context.WithDeadline(ctx, d.t)
+	}
+	return ctx, func() {
+		d.mu.Lock()
+		d.cancel = nil
+		d.mu.Unlock()
+		stop()
+		cancel(nil)
+	}
+}
+
+// wait waits on l for n tokens until the deadline, starting over with the
+// new deadline whenever it is changed meanwhile.
+func (d *deadline) wait(l BandwidthLimiter, n int) error {
+	for {
+		ctx, cancel := d.context()
+		err := l.WaitN(ctx, n)
+		changed := context.Cause(ctx) == errDeadlineChanged
+		cancel()
+		if err == nil || !changed {
+			return err
+		}
+	}
+}
+
+// deadlineErr reports an expired wait as the net package would.
+func deadlineErr(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return os.ErrDeadlineExceeded
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+package ratelimiter
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestConnThrottlesEachDirection(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := NewConn(client, New(100, 10, clk), nil)
+	defer conn.Close()
+
+	go func() {
+		server.Write(make([]byte, 60))
+		io.ReadFull(server, make([]byte, 1000))
+	}()
+	if _, err := io.ReadFull(conn, make([]byte, 60)); err != nil {
+		t.Fatal(err)
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 500*time.Millisecond {
+		t.Fatalf("expected 50 bytes beyond the burst to take 500ms, got %v", got)
+	}
+	if _, err := conn.Write(make([]byte, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 500*time.Millisecond {
+		t.Fatalf("expected unthrottled writes not to wait, got %v", got)
+	}
+}
+
+func TestConnWaitHonorsDeadline(t *testing.T) {
+	clk := &blockingClock{newFakeClock(time.Unix(0, 0)), make(chan struct{})}
+	defer close(clk.release)
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := NewConn(client, nil, New(1, 1, clk))
+	defer conn.Close()
+	go io.Copy(io.Discard, server)
+
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Millisecond))
+	_, err := conn.Write(make([]byte, 3))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected os.ErrDeadlineExceeded, got %v", err)
+	}
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		t.Fatalf("expected a timeout net.Error, got %v", err)
+	}
+}
+
+func TestConnDeadlineWakesWait(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := NewConn(client, nil, New(1, 1, nil))
+	defer conn.Close()
+	go io.Copy(io.Discard, server)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		conn.SetWriteDeadline(time.Now())
+	}()
+	start := time.Now()
+	_, err := conn.Write(make([]byte, 2))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected os.ErrDeadlineExceeded, got %v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("write waited %v for its second byte despite the deadline", d)
+	}
+}
@@ -0,0 +1,32 @@
+package ratelimiter
+
+import (
+	"context"
+	"math"
+)
+
+// AllowCost is AllowN for a request costing a fractional number of tokens,
+// for cost models that charge, say, 0.25 tokens for a cache hit and 3.5
+// for a heavy query. Costs are charged exactly; a negative or NaN cost is
+// denied. Observers see the cost rounded up in Decision.N.
+func (rl *RateLimiter) AllowCost(cost float64) bool {
+	_, allowed := rl.allow(rl.now(), cost)
+	return allowed
+}
+
+// WaitCost is WaitN for a request costing a fractional number of tokens.
+// Like WaitN it fails with ErrBurstExceeded for a cost above the burst and
+// with ErrNegativeTokens for a negative or NaN cost; WaitError.N holds the
+// cost rounded up.
+func (rl *RateLimiter) WaitCost(ctx context.Context, cost float64) error {
+	return rl.waitCost(ctx, cost, InfiniteDuration)
+}
+
+// costN rounds cost up to a whole number of tokens, for the int fields of
+// Decision and WaitError.
+func costN(cost float64) int {
+	if math.IsNaN(cost) {
+		return 0
+	}
+	return int(math.Ceil(cost))
+}
@@ -0,0 +1,63 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAllowCost(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(1, 4, clk)
+	for i := 0; i < 4; i++ {
+		if !rl.AllowCost(0.25) {
+			t.Fatalf("cache hit %d denied", i)
+		}
+	}
+	if tok := rl.AvailableTokens(); tok != 3 {
+		t.Fatalf("four quarter-token hits left %v tokens, want 3", tok)
+	}
+	if rl.AllowCost(3.5) {
+		t.Fatal("3.5-token query admitted with 3 tokens")
+	}
+	clk.Sleep(500 * time.Millisecond)
+	if !rl.AllowCost(3.5) || rl.AvailableTokens() != 0 {
+		t.Fatalf("3.5-token query after refill: %v tokens left", rl.AvailableTokens())
+	}
+	for _, cost := range []float64{-0.5, math.NaN()} {
+		if rl.AllowCost(cost) {
+			t.Fatalf("AllowCost(%v) admitted", cost)
+		}
+	}
+}
+
+func TestWaitCost(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(1, 4, clk)
+	rl.AllowN(4)
+	if err := rl.WaitCost(context.Background(), 1.5); err != nil {
+		t.Fatal(err)
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 1500*time.Millisecond {
+		t.Fatalf("waited %v for 1.5 tokens at 1/s", got)
+	}
+
+	var werr *WaitError
+	if err := rl.WaitCost(context.Background(), 4.5); !errors.As(err, &werr) || !errors.Is(err, ErrBurstExceeded) || werr.N != 5 {
+		t.Fatalf("WaitCost(4.5) = %v, want ErrBurstExceeded for 5 tokens", err)
+	}
+	if err := rl.WaitCost(context.Background(), -1); !errors.Is(err, ErrNegativeTokens) {
+		t.Fatalf("WaitCost(-1) = %v, want ErrNegativeTokens", err)
+	}
+
+	// A canceled fractional reservation comes back exactly.
+	rl.Reset()
+	r := rl.ReserveN(3)
+	rl.AllowCost(0.75)
+	r.Cancel()
+	if tok := rl.AvailableTokens(); tok != 3.25 {
+		t.Fatalf("%v tokens after refund, want 3.25", tok)
+	}
+}
@@ -0,0 +1,124 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DynamoDBClient is the subset of DynamoDB used by DynamoDBStore, in plain
+// types so that the core package does not depend on the AWS SDK. Number
+// attributes are passed as decimal strings, as DynamoDB represents them.
+// With aws-sdk-go-v2 each method is a single call:
+//
+//   - GetItem is a GetItem with ConsistentRead set, converting the N
+//     attributes of the returned item.
+//   - PutItem is a PutItem with the given ConditionExpression,
+//     ExpressionAttributeNames and ExpressionAttributeValues, all values
+//     being N attributes except the key, an S attribute. A
+//     ConditionalCheckFailedException is reported as false, not an error.
+type DynamoDBClient interface {
+	GetItem(ctx context.Context, table string, key map[string]string) (item map[string]string, found bool, err error)
+	PutItem(ctx context.Context, in DynamoDBPut) (bool, error)
+}
+
+// DynamoDBPut is a conditional PutItem.
+type DynamoDBPut struct {
+	Table     string
+	Item      map[string]string
+	Condition string
+	Names     map[string]string
+	Values    map[string]string
+}
+
+// DynamoDBConfig names the table and attributes of a DynamoDBStore. Empty
+// attribute names get the defaults shown.
+type DynamoDBConfig struct {
+	Table            string
+	KeyAttribute     string // "key", the partition key, a string
+	TokensAttribute  string // "tokens"
+	UpdatedAttribute string // "updated_at", in Unix nanoseconds
+	VersionAttribute string // "version"
+
+	// TTLAttribute, if set, is written with the Unix time in seconds at
+	// which the item may be deleted, TTL after its last write. Enable
+	// DynamoDB's time to live on it so that idle keys are removed; TTL
+	// should be longer than a bucket takes to refill, burst/rate.
+	TTLAttribute string
+	TTL          time.Duration
+}
+
+// DynamoDBStore is a Store in a DynamoDB table, one item per key, updated
+// with conditional writes on a version attribute.
+type DynamoDBStore struct {
+	client DynamoDBClient
+	cfg    DynamoDBConfig
+}
+
+// NewDynamoDBStore returns a DynamoDBStore for the table in cfg.
+func NewDynamoDBStore(client DynamoDBClient, cfg DynamoDBConfig) *DynamoDBStore {
+	for attr, def := range map[*string]string{
+		&cfg.KeyAttribute:     "key",
+		&cfg.TokensAttribute:  "tokens",
+		&cfg.UpdatedAttribute: "updated_at",
+		&cfg.VersionAttribute: "version",
+	} {
+		if *attr == "" {
+			*attr = def
+		}
+	}
+	return &DynamoDBStore{client: client, cfg: cfg}
+}
+
+func (d *DynamoDBStore) Get(ctx context.Context, key string) (BucketState, bool, error) {
+	item, found, err := d.client.GetItem(ctx, d.cfg.Table, map[string]string{d.cfg.KeyAttribute: key})
+	if err != nil || !found {
+		return BucketState{}, false, err
+	}
+	tokens, err1 := strconv.ParseFloat(item[d.cfg.TokensAttribute], 64)
+	updated, err2 := strconv.ParseInt(item[d.cfg.UpdatedAttribute], 10, 64)
+	version, err3 := strconv.ParseUint(item[d.cfg.VersionAttribute], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return BucketState{}, false, fmt.Errorf("rate: bad DynamoDB bucket item for %q: %v", key, item)
+	}
+	return BucketState{Tokens: tokens, UpdatedAt: time.Unix(0, updated), Version: version}, true, nil
+}
+
+func (d *DynamoDBStore) Set(ctx context.Context, key string, s BucketState) error {
+	old, _, err := d.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	// Bump the version so that concurrent compare-and-swaps fail.
+	_, err = d.client.PutItem(ctx, d.put(key, s, old.Version+1))
+	return err
+}
+
+func (d *DynamoDBStore) CompareAndSwap(ctx context.Context, key string, old, new BucketState) (bool, error) {
+	in := d.put(key, new, old.Version+1)
+	if old.Version == 0 {
+		in.Condition = "attribute_not_exists(#k)"
+		in.Names = map[string]string{"#k": d.cfg.KeyAttribute}
+	} else {
+		in.Condition = "#v = :v"
+		in.Names = map[string]string{"#v": d.cfg.VersionAttribute}
+		in.Values = map[string]string{":v": strconv.FormatUint(old.Version, 10)}
+	}
+	return d.client.PutItem(ctx, in)
+}
+
+// put returns an unconditional PutItem of s under key with the given
+// version.
+func (d *DynamoDBStore) put(key string, s BucketState, version uint64) DynamoDBPut {
+	item := map[string]string{
+		d.cfg.KeyAttribute:     key,
+		d.cfg.TokensAttribute:  strconv.FormatFloat(s.Tokens, 'g', -1, 64),
+		d.cfg.UpdatedAttribute: strconv.FormatInt(s.UpdatedAt.UnixNano(), 10),
+		d.cfg.VersionAttribute: strconv.FormatUint(version, 10),
+	}
+	if d.cfg.TTLAttribute != "" {
+		item[d.cfg.TTLAttribute] = strconv.FormatInt(s.UpdatedAt.Add(d.cfg.TTL).Unix(), 10)
+	}
+	return DynamoDBPut{Table: d.cfg.Table, Item: item}
+}
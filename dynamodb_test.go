@@ -0,0 +1,90 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDynamoDB is an in-memory DynamoDBClient understanding the two
+// conditions DynamoDBStore uses.
+type fakeDynamoDB struct {
+	mu    sync.Mutex
+	items map[string]map[string]string // by table and key value
+	puts  []DynamoDBPut
+}
+
+func (f *fakeDynamoDB) GetItem(_ context.Context, table string, key map[string]string) (map[string]string, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, v := range key {
+		item, ok := f.items[table+"/"+v]
+		return maps.Clone(item), ok, nil
+	}
+	return nil, false, fmt.Errorf("no key")
+}
+
+func (f *fakeDynamoDB) PutItem(_ context.Context, in DynamoDBPut) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.puts = append(f.puts, in)
+	id := in.Table + "/" + in.Item["pk"]
+	cur, exists := f.items[id]
+	switch in.Condition {
+	case "":
+	case "attribute_not_exists(#k)":
+		if exists {
+			return false, nil
+		}
+	case "#v = :v":
+		if !exists || cur[in.Names["#v"]] != in.Values[":v"] {
+			return false, nil
+		}
+	default:
+		return false, fmt.Errorf("unexpected condition %q", in.Condition)
+	}
+	f.items[id] = maps.Clone(in.Item)
+	return true, nil
+}
+
+func TestDynamoDBStore(t *testing.T) {
+	ctx := context.Background()
+	db := &fakeDynamoDB{items: make(map[string]map[string]string)}
+	s := NewDynamoDBStore(db, DynamoDBConfig{Table: "limits", KeyAttribute: "pk", TTLAttribute: "expires", TTL: time.Hour})
+
+	if ok, err := s.CompareAndSwap(ctx, "k", BucketState{}, BucketState{Tokens: 1.5, UpdatedAt: time.Unix(10, 5)}); !ok || err != nil {
+		t.Fatalf("create-only swap on a missing key: %v, %v", ok, err)
+	}
+	old, found, err := s.Get(ctx, "k")
+	if err != nil || !found || old.Tokens != 1.5 || !old.UpdatedAt.Equal(time.Unix(10, 5)) || old.Version != 1 {
+		t.Fatalf("Get = %+v, %v, %v", old, found, err)
+	}
+	if exp := db.items["limits/k"]["expires"]; exp != "3610" {
+		t.Fatalf("TTL attribute = %q, want 3610", exp)
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", BucketState{}, BucketState{Tokens: 2}); ok {
+		t.Fatal("create-only swap succeeded on an existing key")
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", old, BucketState{Tokens: 2}); !ok {
+		t.Fatal("swap with the current version failed")
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", old, BucketState{Tokens: 3}); ok {
+		t.Fatal("swap with a stale version succeeded")
+	}
+	if err := s.Set(ctx, "k", BucketState{Tokens: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if cur, _, _ := s.Get(ctx, "k"); cur.Tokens != 4 || cur.Version != 3 {
+		t.Fatalf("after Set: %+v", cur)
+	}
+
+	clk := newFakeClock(time.Unix(0, 0))
+	a := NewStoreLimiter(s, "api", 1, 2, clk)
+	b := NewStoreLimiter(s, "api", 1, 2, clk)
+	if !a.Allow() || !b.Allow() || a.Allow() {
+		t.Fatal("limiters on one table did not share a bucket")
+	}
+}
@@ -0,0 +1,22 @@
+module github.com/navrang-singh/ratelimiter/envoyrls
+
+go 1.22.2
+
+require (
+	github.com/envoyproxy/go-control-plane/envoy v1.32.4
+	github.com/navrang-singh/ratelimiter v0.0.0
+	google.golang.org/grpc v1.70.0
+	google.golang.org/protobuf v1.36.4
+)
+
+require (
+	github.com/cncf/xds/go v0.0.0-20240905190251-b4127c9b8d78 // indirect
+	github.com/envoyproxy/protoc-gen-validate v1.2.1 // indirect
+	github.com/planetscale/vtprotobuf v0.6.1-0.20240319094008-0393e58bdf10 // indirect
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20241202173237-19429a94021a // indirect
+)
+
+replace github.com/navrang-singh/ratelimiter => ../
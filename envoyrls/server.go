@@ -0,0 +1,207 @@
+// Package envoyrls serves the Envoy rate limit service API,
+// envoy.service.ratelimit.v3.RateLimitService, from ratelimiter limiters,
+// so that Envoy and Istio deployments can use them for global rate
+// limiting.
+package envoyrls
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/navrang-singh/ratelimiter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Unit is the period of a Rule's limit.
+type Unit = rlsv3.RateLimitResponse_RateLimit_Unit
+
+var unitDurations = map[Unit]time.Duration{
+	rlsv3.RateLimitResponse_RateLimit_SECOND: time.Second,
+	rlsv3.RateLimitResponse_RateLimit_MINUTE: time.Minute,
+	rlsv3.RateLimitResponse_RateLimit_HOUR:   time.Hour,
+	rlsv3.RateLimitResponse_RateLimit_DAY:    24 * time.Hour,
+	rlsv3.RateLimitResponse_RateLimit_WEEK:   7 * 24 * time.Hour,
+}
+
+// Entry matches one entry of a rate limit descriptor.
+type Entry struct {
+	Key string
+	// Value, if not empty, must equal the entry's value. If empty, every
+	// value gets a bucket of its own, as for a remote address.
+	Value string
+}
+
+// Rule limits the descriptors of Domain whose entries match Entries, in
+// order, to RequestsPerUnit per Unit, as a token bucket holding
+// RequestsPerUnit tokens.
+type Rule struct {
+	Name            string
+	Domain          string
+	Entries         []Entry
+	RequestsPerUnit uint32
+	Unit            Unit
+}
+
+// Server implements RateLimitServiceServer. Descriptors that match no rule
+// are not limited. All the descriptors of a request are charged together:
+// if any is over its limit, none is charged and the request is over limit.
+// The key rules and penalties of a rule's KeyedLimiter apply as they do to
+// AllowNDetailed: a denied key puts the request over limit and a bypassed
+// one is not charged. Limit overrides sent by Envoy in descriptors are
+// ignored.
+type Server struct {
+	rlsv3.UnimplementedRateLimitServiceServer
+	rules []*rule
+}
+
+type rule struct {
+	Rule
+	kl *ratelimiter.KeyedLimiter
+}
+
+// NewServer returns a Server enforcing rules with limiters on clk; a nil
+// clk means the real clock. Rules are tried in order.
+func NewServer(rules []Rule, clk ratelimiter.Clock) (*Server, error) {
+	s := &Server{}
+	for i, r := range rules {
+		d, ok := unitDurations[r.Unit]
+		if !ok {
+			return nil, fmt.Errorf("envoyrls: rule %d (%s): %w: unsupported unit %v", i, r.Name, ratelimiter.ErrInvalidLimit, r.Unit)
+		}
+		if len(r.Entries) == 0 {
+			return nil, fmt.Errorf("envoyrls: rule %d (%s): no descriptor entries", i, r.Name)
+		}
+		rate := ratelimiter.Per(int(r.RequestsPerUnit), d)
+		s.rules = append(s.rules, &rule{Rule: r, kl: ratelimiter.NewKeyed(rate, int(r.RequestsPerUnit), clk)})
+	}
+	return s, nil
+}
+
+// Register registers s with a gRPC server.
+func (s *Server) Register(g grpc.ServiceRegistrar) {
+	rlsv3.RegisterRateLimitServiceServer(g, s)
+}
+
+// Keyed returns the KeyedLimiter of the named rule, for observers, metrics
+// and overrides, or nil if there is none. Its keys are the descriptor
+// values joined with "|".
+func (s *Server) Keyed(name string) *ratelimiter.KeyedLimiter {
+	for _, r := range s.rules {
+		if r.Name == name {
+			return r.kl
+		}
+	}
+	return nil
+}
+
+// ShouldRateLimit decides a request from Envoy.
+func (s *Server) ShouldRateLimit(ctx context.Context, req *rlsv3.RateLimitRequest) (*rlsv3.RateLimitResponse, error) {
+	if req.GetDomain() == "" {
+		return nil, status.Error(codes.InvalidArgument, "envoyrls: domain is required")
+	}
+	if len(req.GetDescriptors()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "envoyrls: no descriptors")
+	}
+	hits := int(req.GetHitsAddend())
+	if hits == 0 {
+		hits = 1
+	}
+
+	// Descriptors that match a rule get its key and, unless a key rule
+	// decides them, the key's limiter.
+	matched := make([]*rule, len(req.Descriptors))
+	keys := make([]string, len(req.Descriptors))
+	limiters := make([]*ratelimiter.RateLimiter, len(req.Descriptors))
+	var charged []*ratelimiter.RateLimiter
+	denied := -1
+	for i, d := range req.Descriptors {
+		for _, r := range s.rules {
+			key, ok := r.match(req.Domain, d.GetEntries())
+			if !ok {
+				continue
+			}
+			matched[i], keys[i] = r, key
+			switch r.kl.Rule(key) {
+			case ratelimiter.RuleDeny:
+				if denied < 0 {
+					denied = i
+				}
+			case ratelimiter.RuleNone:
+				limiters[i] = r.kl.Get(key)
+				charged = append(charged, limiters[i])
+			}
+			break
+		}
+	}
+	if denied < 0 && len(charged) > 0 {
+		if _, layer := ratelimiter.NewMulti(charged...).AllowNLayer(hits); layer != nil {
+			for i, l := range limiters {
+				if l == layer {
+					denied = i
+					matched[i].kl.ReportDenial(keys[i])
+					break
+				}
+			}
+		}
+	}
+
+	resp := &rlsv3.RateLimitResponse{OverallCode: rlsv3.RateLimitResponse_OK}
+	for i := range req.Descriptors {
+		st := &rlsv3.RateLimitResponse_DescriptorStatus{Code: rlsv3.RateLimitResponse_OK}
+		if r := matched[i]; r != nil {
+			if i == denied {
+				st.Code = rlsv3.RateLimitResponse_OVER_LIMIT
+				resp.OverallCode = rlsv3.RateLimitResponse_OVER_LIMIT
+			}
+			r.describe(st, limiters[i], i == denied)
+		}
+		resp.Statuses = append(resp.Statuses, st)
+	}
+	return resp, nil
+}
+
+// match returns the key of the descriptor entries if they match r.
+func (r *rule) match(domain string, entries []*ratelimitv3.RateLimitDescriptor_Entry) (string, bool) {
+	if domain != r.Domain || len(entries) != len(r.Entries) {
+		return "", false
+	}
+	values := make([]string, len(entries))
+	for i, e := range entries {
+		want := r.Entries[i]
+		if e.GetKey() != want.Key || want.Value != "" && e.GetValue() != want.Value {
+			return "", false
+		}
+		values[i] = e.GetValue()
+	}
+	return strings.Join(values, "|"), true
+}
+
+// describe fills in the limit and the state of the key's bucket rl, if
+// any, in a descriptor status. A key decided by a key rule has no bucket:
+// it has the whole limit left if bypassed and none if denied.
+func (r *rule) describe(st *rlsv3.RateLimitResponse_DescriptorStatus, rl *ratelimiter.RateLimiter, denied bool) {
+	st.CurrentLimit = &rlsv3.RateLimitResponse_RateLimit{
+		Name:            r.Name,
+		RequestsPerUnit: r.RequestsPerUnit,
+		Unit:            r.Unit,
+	}
+	if rl == nil {
+		if !denied {
+			st.LimitRemaining = r.RequestsPerUnit
+		}
+		return
+	}
+	s := rl.Status()
+	st.LimitRemaining = uint32(max(0, math.Floor(s.Remaining)))
+	if !s.ResetAt.IsZero() {
+		st.DurationUntilReset = durationpb.New(s.ResetAt.Sub(s.At))
+	}
+}
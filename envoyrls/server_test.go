@@ -0,0 +1,108 @@
+package envoyrls
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/navrang-singh/ratelimiter"
+	"github.com/navrang-singh/ratelimiter/clocktest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func descriptor(kv ...string) *ratelimitv3.RateLimitDescriptor {
+	d := &ratelimitv3.RateLimitDescriptor{}
+	for i := 0; i < len(kv); i += 2 {
+		d.Entries = append(d.Entries, &ratelimitv3.RateLimitDescriptor_Entry{Key: kv[i], Value: kv[i+1]})
+	}
+	return d
+}
+
+func TestShouldRateLimit(t *testing.T) {
+	s, err := NewServer([]Rule{
+		{Name: "per-ip", Domain: "edge", Entries: []Entry{{Key: "remote_address"}}, RequestsPerUnit: 2, Unit: rlsv3.RateLimitResponse_RateLimit_SECOND},
+		{Name: "login", Domain: "edge", Entries: []Entry{{Key: "path", Value: "/login"}}, RequestsPerUnit: 1, Unit: rlsv3.RateLimitResponse_RateLimit_MINUTE},
+	}, clocktest.New(time.Unix(0, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ask := func(ds ...*ratelimitv3.RateLimitDescriptor) *rlsv3.RateLimitResponse {
+		t.Helper()
+		resp, err := s.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{Domain: "edge", Descriptors: ds})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := ask(descriptor("remote_address", "10.0.0.1"), descriptor("path", "/login"), descriptor("path", "/other"))
+	if resp.OverallCode != rlsv3.RateLimitResponse_OK || len(resp.Statuses) != 3 {
+		t.Fatalf("first request: %v", resp)
+	}
+	if st := resp.Statuses[0]; st.LimitRemaining != 1 || st.CurrentLimit.GetName() != "per-ip" || st.DurationUntilReset.AsDuration() != 500*time.Millisecond {
+		t.Fatalf("per-ip status: %v", st)
+	}
+	if st := resp.Statuses[2]; st.CurrentLimit != nil {
+		t.Fatalf("unmatched descriptor has a limit: %v", st)
+	}
+
+	// The login limit is spent, so the request is over limit and the
+	// per-IP bucket is not charged.
+	resp = ask(descriptor("remote_address", "10.0.0.1"), descriptor("path", "/login"))
+	if resp.OverallCode != rlsv3.RateLimitResponse_OVER_LIMIT || resp.Statuses[1].Code != rlsv3.RateLimitResponse_OVER_LIMIT || resp.Statuses[0].Code != rlsv3.RateLimitResponse_OK {
+		t.Fatalf("second request: %v", resp)
+	}
+	if got := s.Keyed("per-ip").Get("10.0.0.1").AvailableTokens(); got != 1 {
+		t.Fatalf("per-ip tokens after denial = %v, want 1", got)
+	}
+
+	if _, err := s.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{Domain: "edge"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("request without descriptors: %v", err)
+	}
+}
+
+func TestNewServerRejectsUnits(t *testing.T) {
+	_, err := NewServer([]Rule{{Domain: "d", Entries: []Entry{{Key: "k"}}, RequestsPerUnit: 1, Unit: rlsv3.RateLimitResponse_RateLimit_MONTH}}, nil)
+	if err == nil {
+		t.Fatal("NewServer accepted a monthly unit")
+	}
+}
+
+func TestShouldRateLimitKeyRules(t *testing.T) {
+	s, err := NewServer([]Rule{
+		{Name: "per-ip", Domain: "edge", Entries: []Entry{{Key: "remote_address"}}, RequestsPerUnit: 1, Unit: rlsv3.RateLimitResponse_RateLimit_MINUTE},
+	}, clocktest.New(time.Unix(0, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kl := s.Keyed("per-ip")
+	kl.SetRule("10.0.0.1", ratelimiter.RuleDeny)
+	kl.SetRule("10.0.0.2", ratelimiter.RuleBypass)
+	kl.SetPenalty(ratelimiter.Penalty{Threshold: 2, Window: time.Minute, Cooldown: time.Hour})
+	ask := func(ip string) rlsv3.RateLimitResponse_Code {
+		t.Helper()
+		resp, err := s.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{Domain: "edge", Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("remote_address", ip)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.OverallCode
+	}
+
+	if ask("10.0.0.1") != rlsv3.RateLimitResponse_OVER_LIMIT {
+		t.Fatal("deny-listed key admitted")
+	}
+	for i := 0; i < 3; i++ {
+		if ask("10.0.0.2") != rlsv3.RateLimitResponse_OK {
+			t.Fatalf("bypassed key limited on request %d", i)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		ask("10.0.0.3")
+	}
+	if _, ok := kl.CooldownUntil("10.0.0.3"); !ok {
+		t.Fatal("repeated denials did not start a cooldown")
+	}
+}
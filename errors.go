@@ -0,0 +1,112 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrBurstExceeded is returned when a request asks for more tokens than
+	// the limiter can ever hold at once. Retrying will not help.
+	ErrBurstExceeded = errors.New("rate: request exceeds burst")
+
+	// ErrWaitTimeout is returned when the tokens cannot be had before the
+	// caller's deadline, including when the limiter will never provide
+	// them.
+	ErrWaitTimeout = errors.New("rate: wait timed out")
+
+	// ErrContextCanceled is returned when the caller's context is canceled
+	// while waiting.
+	ErrContextCanceled = errors.New("rate: context canceled")
+
+	// ErrQueueFull is returned when a limiter's wait queue has no room for
+	// a request.
+	ErrQueueFull = errors.New("rate: queue is full")
+
+	// ErrInvalidLimit is returned by NewChecked for a rate or burst that
+	// does not describe a usable limiter.
+	ErrInvalidLimit = errors.New("rate: invalid limit")
+
+	// ErrPaused is returned by WaitN on a limiter paused with PauseDeny.
+	ErrPaused = errors.New("rate: limiter is paused")
+
+	// ErrDenied is returned by KeyedLimiter.Wait for a key with RuleDeny.
+	ErrDenied = errors.New("rate: key is denied")
+
+	// ErrDelayExceeded is returned by WaitMaxDuration when the tokens
+	// cannot be had within the maximum delay. The WaitError's RetryAfter
+	// is the delay they would have needed.
+	ErrDelayExceeded = errors.New("rate: wait exceeds maximum delay")
+
+	// ErrReserveTooFar is returned by WaitN when the tokens would be
+	// reserved further ahead than SetMaxFutureReserve allows. RetryAfter
+	// is how long until the reservation would fit.
+	ErrReserveTooFar = errors.New("rate: reservation too far in the future")
+
+	// ErrNegativeTokens is returned by WaitN for a negative number of
+	// tokens. Tokens are only returned to a limiter by canceling a
+	// reservation.
+	ErrNegativeTokens = errors.New("rate: negative token count")
+)
+
+// WaitError describes a failed wait. It matches one of ErrBurstExceeded,
+// ErrWaitTimeout, ErrContextCanceled, ErrQueueFull, ErrPaused, ErrDenied,
+// ErrDelayExceeded, ErrReserveTooFar or ErrNegativeTokens with errors.Is,
+// and also the context error that caused it, if any.
+type WaitError struct {
+	// Err is the sentinel describing the failure.
+	Err error
+	// N is the number of tokens requested.
+	N int
+	// Limit is the burst or capacity the request was checked against, or
+	// zero if it does not apply.
+	Limit int
+	// RetryAfter suggests how long to wait before retrying: zero if
+	// unknown, InfiniteDuration if the tokens will never be available.
+	RetryAfter time.Duration
+
+	cause error
+}
+
+func (e *WaitError) Error() string {
+	msg := fmt.Sprintf("rate: Wait(n=%d): %s", e.N, strings.TrimPrefix(e.Err.Error(), "rate: "))
+	if e.Limit > 0 {
+		msg += fmt.Sprintf(" (limit %d)", e.Limit)
+	}
+	return msg
+}
+
+func (e *WaitError) Unwrap() []error {
+	if e.cause == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Err, e.cause}
+}
+
+// burstError reports a request for n tokens that can never fit in limit.
+func burstError(n, limit int) error {
+	return &WaitError{Err: ErrBurstExceeded, N: n, Limit: limit}
+}
+
+// negativeError reports a request for a negative number of tokens.
+func negativeError(n int) error {
+	return &WaitError{Err: ErrNegativeTokens, N: n}
+}
+
+// neverError reports a request for n tokens the limiter will never provide.
+func neverError(n int) error {
+	return &WaitError{Err: ErrWaitTimeout, N: n, RetryAfter: InfiniteDuration}
+}
+
+// ctxError reports a wait for n tokens abandoned because its context ended
+// with err, retryAfter before the tokens would have been available.
+func ctxError(n int, err error, retryAfter time.Duration) error {
+	sentinel := ErrContextCanceled
+	if errors.Is(err, context.DeadlineExceeded) {
+		sentinel = ErrWaitTimeout
+	}
+	return &WaitError{Err: sentinel, N: n, RetryAfter: retryAfter, cause: err}
+}
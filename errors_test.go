@@ -0,0 +1,57 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitErrorBurstExceeded(t *testing.T) {
+	rl := New(1, 2, newFakeClock(time.Unix(0, 0)))
+	err := rl.Wait(3)
+	if !errors.Is(err, ErrBurstExceeded) {
+		t.Fatalf("expected ErrBurstExceeded, got %v", err)
+	}
+	var we *WaitError
+	if !errors.As(err, &we) || we.N != 3 || we.Limit != 2 {
+		t.Fatalf("expected a WaitError for n=3 limit=2, got %#v", err)
+	}
+	if got, want := err.Error(), "rate: Wait(n=3): request exceeds burst (limit 2)"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestWaitErrorNever(t *testing.T) {
+	lb := NewLeakyBucket(0, 1, newFakeClock(time.Unix(0, 0)))
+	err := lb.Wait(1)
+	var we *WaitError
+	if !errors.Is(err, ErrWaitTimeout) || !errors.As(err, &we) || we.RetryAfter != InfiniteDuration {
+		t.Fatalf("expected ErrWaitTimeout never to succeed, got %#v", err)
+	}
+}
+
+func TestWaitErrorContext(t *testing.T) {
+	clk := &blockingClock{newFakeClock(time.Unix(0, 0)), make(chan struct{})}
+	defer close(clk.release)
+	rl := New(Every(time.Second), 1, clk)
+	rl.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := rl.WaitN(ctx, 1)
+	if !errors.Is(err, ErrWaitTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected ErrWaitTimeout and DeadlineExceeded, got %v", err)
+	}
+	var we *WaitError
+	if !errors.As(err, &we) || we.RetryAfter != time.Second {
+		t.Fatalf("expected a retry-after of 1s, got %#v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	err = rl.WaitN(ctx, 1)
+	if !errors.Is(err, ErrContextCanceled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected ErrContextCanceled and Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package ratelimiter
+
+import "context"
+
+// EtcdClient is the subset of etcd used by EtcdStore. With clientv3 each
+// method is a single call:
+//
+//   - Get is a Get, returning the value and ModRevision of the only key.
+//   - Put is a Put.
+//   - PutIfModRevision is a Txn comparing ModRevision(key) with rev and
+//     putting value if it is equal, reporting Succeeded. etcd reports a
+//     ModRevision of 0 for keys that do not exist.
+type EtcdClient interface {
+	Get(ctx context.Context, key string) (value []byte, modRevision int64, found bool, err error)
+	Put(ctx context.Context, key string, value []byte) error
+	PutIfModRevision(ctx context.Context, key string, value []byte, rev int64) (bool, error)
+}
+
+// EtcdStore is a Store in etcd, using mod revisions as versions, so that
+// clusters already running etcd can enforce global limits with its strong
+// consistency. Every Allow is a linearizable read and a transaction, so
+// the store suits limits of up to a few hundred events per second.
+type EtcdStore struct {
+	client EtcdClient
+	prefix string
+}
+
+// NewEtcdStore returns an EtcdStore keeping buckets under prefix plus the
+// limiter key.
+func NewEtcdStore(client EtcdClient, prefix string) *EtcdStore {
+	return &EtcdStore{client: client, prefix: prefix}
+}
+
+func (e *EtcdStore) Get(ctx context.Context, key string) (BucketState, bool, error) {
+	value, rev, found, err := e.client.Get(ctx, e.prefix+key)
+	if err != nil || !found {
+		return BucketState{}, false, err
+	}
+	s, err := decodeBucket(value)
+	if err != nil {
+		return BucketState{}, false, err
+	}
+	s.Version = uint64(rev)
+	return s, true, nil
+}
+
+func (e *EtcdStore) Set(ctx context.Context, key string, s BucketState) error {
+	return e.client.Put(ctx, e.prefix+key, encodeBucket(s))
+}
+
+func (e *EtcdStore) CompareAndSwap(ctx context.Context, key string, old, new BucketState) (bool, error) {
+	return e.client.PutIfModRevision(ctx, e.prefix+key, encodeBucket(new), int64(old.Version))
+}
@@ -0,0 +1,85 @@
+package ratelimiter
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEtcd is an in-memory EtcdClient with a global revision counter.
+type fakeEtcd struct {
+	mu   sync.Mutex
+	rev  int64
+	kvs  map[string][]byte
+	mods map[string]int64
+}
+
+func newFakeEtcd() *fakeEtcd {
+	return &fakeEtcd{kvs: make(map[string][]byte), mods: make(map[string]int64)}
+}
+
+func (f *fakeEtcd) Get(_ context.Context, key string) ([]byte, int64, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.kvs[key]
+	return bytes.Clone(v), f.mods[key], ok, nil
+}
+
+func (f *fakeEtcd) Put(_ context.Context, key string, value []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.putLocked(key, value)
+	return nil
+}
+
+func (f *fakeEtcd) PutIfModRevision(_ context.Context, key string, value []byte, rev int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.mods[key] != rev {
+		return false, nil
+	}
+	f.putLocked(key, value)
+	return true, nil
+}
+
+func (f *fakeEtcd) putLocked(key string, value []byte) {
+	f.rev++
+	f.kvs[key] = bytes.Clone(value)
+	f.mods[key] = f.rev
+}
+
+func TestEtcdStore(t *testing.T) {
+	ctx := context.Background()
+	etcd := newFakeEtcd()
+	etcd.Put(ctx, "other", nil) // revisions are global, not per key
+	s := NewEtcdStore(etcd, "/limits/")
+
+	if ok, err := s.CompareAndSwap(ctx, "k", BucketState{}, BucketState{Tokens: 1.5, UpdatedAt: time.Unix(10, 5)}); !ok || err != nil {
+		t.Fatalf("create-only swap on a missing key: %v, %v", ok, err)
+	}
+	if _, ok := etcd.kvs["/limits/k"]; !ok {
+		t.Fatal("bucket not stored under the prefix")
+	}
+	old, found, err := s.Get(ctx, "k")
+	if err != nil || !found || old.Tokens != 1.5 || !old.UpdatedAt.Equal(time.Unix(10, 5)) || old.Version != 2 {
+		t.Fatalf("Get = %+v, %v, %v", old, found, err)
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", BucketState{}, BucketState{Tokens: 2}); ok {
+		t.Fatal("create-only swap succeeded on an existing key")
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", old, BucketState{Tokens: 2}); !ok {
+		t.Fatal("swap with the current revision failed")
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", old, BucketState{Tokens: 3}); ok {
+		t.Fatal("swap with a stale revision succeeded")
+	}
+
+	clk := newFakeClock(time.Unix(0, 0))
+	a := NewStoreLimiter(s, "api", 1, 2, clk)
+	b := NewStoreLimiter(s, "api", 1, 2, clk)
+	if !a.Allow() || !b.Allow() || a.Allow() {
+		t.Fatal("limiters on one etcd did not share a bucket")
+	}
+}
@@ -0,0 +1,153 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrExecutorClosed is returned by Executor.Submit after Shutdown.
+var ErrExecutorClosed = errors.New("rate: executor is shut down")
+
+// Executor runs tasks on a fixed pool of workers, starting each only once
+// its limiter admits it. The limiter sets the dispatch rate and the pool
+// size caps how many tasks run at once, so a backlog of jobs against a
+// rate-limited API can be handed over in one go.
+type Executor struct {
+	l       Limiter
+	tasks   chan execTask
+	mu      sync.RWMutex // held for reading while submitting
+	closed  bool
+	stop    context.Context // canceled when Shutdown gives up
+	abort   context.CancelFunc
+	once    sync.Once
+	done    chan struct{} // closed when every worker has exited
+	workers int
+
+	running   atomic.Int64
+	submitted atomic.Uint64
+	completed atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+type execTask struct {
+	ctx context.Context
+	fn  func(ctx context.Context)
+}
+
+// ExecutorStats describes the load on an Executor.
+type ExecutorStats struct {
+	Workers   int
+	Queued    int // submitted tasks not yet started
+	Running   int
+	Submitted uint64
+	Completed uint64
+	// Dropped counts tasks never run because their context ended, or
+	// Shutdown gave up, before the limiter admitted them.
+	Dropped uint64
+}
+
+// NewExecutor starts an Executor with workers goroutines, each running one
+// task at a time as l admits them, and room for queue submitted tasks
+// waiting for a worker. Workers of zero or less means GOMAXPROCS.
+func NewExecutor(l Limiter, workers, queue int) *Executor {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	e := &Executor{
+		l:       l,
+		tasks:   make(chan execTask, max(queue, 0)),
+		done:    make(chan struct{}),
+		workers: workers,
+	}
+	e.stop, e.abort = context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for t := range e.tasks {
+				e.run(t)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(e.done)
+	}()
+	return e
+}
+
+// Submit queues task to run once a worker is free and the limiter admits
+// it, blocking while the queue is full. It fails with ctx's error if ctx
+// is done first, and with ErrExecutorClosed after Shutdown. The task runs
+// with a context derived from ctx, so ctx must outlive the task: detach it
+// with context.WithoutCancel for fire-and-forget submissions. A task whose
+// ctx ends before it starts is dropped.
+func (e *Executor) Submit(ctx context.Context, task func(ctx context.Context)) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return ErrExecutorClosed
+	}
+	select {
+	case e.tasks <- execTask{ctx: ctx, fn: task}:
+		e.submitted.Add(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run waits for the limiter to admit t and then runs it.
+func (e *Executor) run(t execTask) {
+	ctx, cancel := context.WithCancel(t.ctx)
+	defer cancel()
+	stop := context.AfterFunc(e.stop, cancel)
+	defer stop()
+	if err := e.l.WaitN(ctx, 1); err != nil || e.stop.Err() != nil {
+		e.dropped.Add(1)
+		return
+	}
+	e.running.Add(1)
+	defer e.running.Add(-1)
+	defer e.completed.Add(1)
+	t.fn(ctx)
+}
+
+// Shutdown stops accepting tasks and waits for those already submitted to
+// run to completion. If ctx is done first, Shutdown cancels the contexts
+// of running tasks, drops the tasks still queued and returns ctx's error
+// without waiting further.
+func (e *Executor) Shutdown(ctx context.Context) error {
+	e.once.Do(func() {
+		go func() {
+			// Waits for blocked Submits, which the workers unblock.
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			e.closed = true
+			close(e.tasks)
+		}()
+	})
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		e.abort()
+		return ctx.Err()
+	}
+}
+
+// Stats returns the executor's current load and totals.
+func (e *Executor) Stats() ExecutorStats {
+	return ExecutorStats{
+		Workers:   e.workers,
+		Queued:    len(e.tasks),
+		Running:   int(e.running.Load()),
+		Submitted: e.submitted.Load(),
+		Completed: e.completed.Load(),
+		Dropped:   e.dropped.Load(),
+	}
+}
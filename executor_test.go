@@ -0,0 +1,92 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExecutorDispatchRate(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	e := NewExecutor(New(10, 1, clk), 1, 10)
+	var mu sync.Mutex
+	var at []time.Duration
+	for i := 0; i < 3; i++ {
+		err := e.Submit(context.Background(), func(ctx context.Context) {
+			mu.Lock()
+			at = append(at, clk.Now().Sub(time.Unix(0, 0)))
+			mu.Unlock()
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond}
+	for i := range want {
+		if at[i] != want[i] {
+			t.Fatalf("tasks ran at %v, want %v", at, want)
+		}
+	}
+	if s := e.Stats(); s.Submitted != 3 || s.Completed != 3 || s.Queued != 0 || s.Running != 0 {
+		t.Fatalf("stats after shutdown: %+v", s)
+	}
+	if err := e.Submit(context.Background(), func(context.Context) {}); !errors.Is(err, ErrExecutorClosed) {
+		t.Fatalf("Submit after Shutdown = %v, want ErrExecutorClosed", err)
+	}
+}
+
+func TestExecutorConcurrencyCap(t *testing.T) {
+	e := NewExecutor(New(InfiniteRate, 0, nil), 2, 10)
+	release := make(chan struct{})
+	var running, peak atomic.Int64
+	for i := 0; i < 6; i++ {
+		e.Submit(context.Background(), func(ctx context.Context) {
+			n := running.Add(1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			<-release
+			running.Add(-1)
+		})
+	}
+	for e.Stats().Running < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if s := e.Stats(); s.Queued != 4 {
+		t.Fatalf("%d tasks queued behind two workers, want 4", s.Queued)
+	}
+	close(release)
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if peak.Load() != 2 {
+		t.Fatalf("%d tasks ran at once, want 2", peak.Load())
+	}
+}
+
+func TestExecutorShutdownGivesUp(t *testing.T) {
+	e := NewExecutor(New(0, 1, nil), 1, 10)
+	ran := make(chan struct{}, 3)
+	for i := 0; i < 3; i++ {
+		e.Submit(context.Background(), func(context.Context) { ran <- struct{}{} })
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := e.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want the deadline", err)
+	}
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 1 {
+		t.Fatalf("%d tasks ran on a limiter with one token, want 1", len(ran))
+	}
+	if s := e.Stats(); s.Dropped != 2 {
+		t.Fatalf("%d tasks dropped, want 2", s.Dropped)
+	}
+}
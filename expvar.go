@@ -0,0 +1,38 @@
+package ratelimiter
+
+import (
+	"expvar"
+	"sync/atomic"
+)
+
+// PublishExpvar publishes live stats of rl in expvar under name, so they
+// appear on /debug/vars as
+//
+//	{"rate": 10, "burst": 20, "tokens": 13.5, "allowed": 1042, "denied": 17}
+//
+// Counting starts when PublishExpvar is called. Like expvar.Publish, it
+// panics if name is already in use.
+func PublishExpvar(name string, rl *RateLimiter) {
+	expvar.Publish(name, expvarFunc(rl))
+}
+
+// expvarFunc returns the expvar.Func that PublishExpvar publishes.
+func expvarFunc(rl *RateLimiter) expvar.Func {
+	var allowed, denied atomic.Int64
+	rl.AddObserver(ObserverFunc(func(d Decision) {
+		if d.Allowed {
+			allowed.Add(1)
+		} else {
+			denied.Add(1)
+		}
+	}))
+	return func() interface{} {
+		return map[string]interface{}{
+			"rate":    float64(rl.Rate()),
+			"burst":   rl.Burst(),
+			"tokens":  rl.AvailableTokens(),
+			"allowed": allowed.Load(),
+			"denied":  denied.Load(),
+		}
+	}
+}
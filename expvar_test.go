@@ -0,0 +1,28 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	rl := New(Every(time.Second), 2, newFakeClock(time.Unix(0, 0)))
+	v := expvarFunc(rl)
+	rl.AllowN(2)
+	rl.Allow()
+
+	var got struct {
+		Rate    float64
+		Burst   int
+		Tokens  float64
+		Allowed int64
+		Denied  int64
+	}
+	if err := json.Unmarshal([]byte(v.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Rate != 1 || got.Burst != 2 || got.Tokens != 0 || got.Allowed != 1 || got.Denied != 1 {
+		t.Fatalf("unexpected stats %+v", got)
+	}
+}
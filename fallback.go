@@ -0,0 +1,290 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// FailurePolicy decides what a FallbackLimiter does while its distributed
+// store is failing.
+type FailurePolicy int
+
+const (
+	// FailClosed denies every event.
+	FailClosed FailurePolicy = iota
+	// FailOpen admits every event.
+	FailOpen
+	// FailLocal admits events against a local limiter running at a
+	// fraction of the distributed rate.
+	FailLocal
+)
+
+func (p FailurePolicy) String() string {
+	switch p {
+	case FailClosed:
+		return "fail-closed"
+	case FailOpen:
+		return "fail-open"
+	case FailLocal:
+		return "fail-local"
+	}
+	return "unknown"
+}
+
+// RemoteLimiter is a limiter kept in a distributed store, such as a
+// StoreLimiter or RedisLimiter, whose calls may fail.
+type RemoteLimiter interface {
+	Rate() Rate
+	Burst() int
+	AllowNContext(ctx context.Context, n int) (bool, error)
+	WaitN(ctx context.Context, n int) error
+}
+
+// FallbackStats describes how often a FallbackLimiter fell back.
+type FallbackStats struct {
+	Policy      FailurePolicy
+	Active      bool      // the last store call failed or the breaker is open
+	Since       time.Time // when the current fallback started
+	Activations uint64    // times the store started failing
+	Errors      uint64    // failed store calls
+	LastError   error
+	BreakerOpen bool   // store calls are skipped
+	Trips       uint64 // times the breaker opened
+}
+
+// Breaker configures the circuit breaker of a FallbackLimiter, which stops
+// calling a failing or slow store for a while so that every decision does
+// not wait out its timeouts.
+type Breaker struct {
+	// Failures is the number of consecutive failed or slow store calls
+	// that open the breaker. Zero disables the breaker.
+	Failures int
+	// Latency, if positive, is the duration beyond which a call made by
+	// Allow, AllowN or AllowNContext counts as slow, even if it succeeds.
+	Latency time.Duration
+	// Timeout, if positive, bounds each such call.
+	Timeout time.Duration
+	// Cooldown is how long the breaker stays open. After it, one trial
+	// call goes to the store: the breaker closes if it succeeds promptly
+	// and opens again otherwise.
+	Cooldown time.Duration
+}
+
+// ErrBreakerOpen is returned by WaitN of a FailClosed FallbackLimiter
+// whose breaker is open.
+var ErrBreakerOpen = errors.New("rate: store circuit breaker open")
+
+// FallbackLimiter applies a FailurePolicy to the decisions of a
+// RemoteLimiter whose store is unreachable, instead of denying every
+// event. It falls back on every failed call and returns to the store as
+// soon as a call succeeds.
+type FallbackLimiter struct {
+	remote   RemoteLimiter
+	policy   FailurePolicy
+	local    *RateLimiter
+	clock    Clock
+	mu       sync.Mutex
+	stats    FallbackStats
+	onChange func(active bool, err error)
+
+	breaker   Breaker
+	failures  int // consecutive failed or slow calls
+	openUntil time.Time
+	trial     bool // a trial call is in flight
+}
+
+// NewFallback returns a FallbackLimiter following policy when remote
+// fails. For FailLocal, the local limiter runs at fraction of remote's rate
+// and burst, such as 1/k for a cluster of k instances; the fraction is
+// ignored by the other policies.
+func NewFallback(remote RemoteLimiter, policy FailurePolicy, fraction float64, clk Clock) *FallbackLimiter {
+	if clk == nil {
+		clk = realClock{}
+	}
+	l := &FallbackLimiter{remote: remote, policy: policy, clock: clk}
+	l.stats.Policy = policy
+	if policy == FailLocal {
+		rate := remote.Rate()
+		if rate != InfiniteRate {
+			rate = Rate(float64(rate) * fraction)
+		}
+		l.local = New(rate, max(1, int(float64(remote.Burst())*fraction)), clk)
+	}
+	return l
+}
+
+// SetBreaker enables a circuit breaker around store calls, replacing any
+// earlier one, and closes it. While it is open, decisions follow the
+// failure policy without calling the store. It must be called before the
+// limiter is used concurrently.
+func (l *FallbackLimiter) SetBreaker(b Breaker) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.breaker = b
+	l.failures = 0
+	l.trial = false
+	l.stats.BreakerOpen = false
+}
+
+// Local returns the local limiter of a FailLocal policy, and nil for the
+// other policies.
+func (l *FallbackLimiter) Local() *RateLimiter {
+	return l.local
+}
+
+// OnChange registers fn to be called when the limiter starts falling back,
+// with the error that caused it, and when the store recovers, with a nil
+// error.
+func (l *FallbackLimiter) OnChange(fn func(active bool, err error)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onChange = fn
+}
+
+// Stats returns the fallback counters.
+func (l *FallbackLimiter) Stats() FallbackStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+func (l *FallbackLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+func (l *FallbackLimiter) AllowN(n int) bool {
+	return l.AllowNContext(context.Background(), n)
+}
+
+// AllowNContext is like AllowN but honors ctx.
+func (l *FallbackLimiter) AllowNContext(ctx context.Context, n int) bool {
+	if !l.callStore() {
+		return l.fallbackAllow(n)
+	}
+	callCtx, cancel := l.callContext(ctx)
+	start := l.clock.Now()
+	ok, err := l.remote.AllowNContext(callCtx, n)
+	cancel()
+	slow := l.breaker.Latency > 0 && l.clock.Now().Sub(start) > l.breaker.Latency
+	if err == nil || ctx.Err() != nil {
+		l.record(nil, slow)
+		return ok && err == nil
+	}
+	l.record(err, slow)
+	return l.fallbackAllow(n)
+}
+
+func (l *FallbackLimiter) fallbackAllow(n int) bool {
+	switch l.policy {
+	case FailOpen:
+		return true
+	case FailLocal:
+		return l.local.AllowN(n)
+	}
+	return false
+}
+
+// Wait is shorthand for WaitN(context.Background(), n).
+func (l *FallbackLimiter) Wait(n int) error {
+	return l.WaitN(context.Background(), n)
+}
+
+// WaitN blocks until n tokens are available or ctx is done. If the store
+// fails, a FailClosed limiter returns its error, a FailOpen limiter returns
+// nil at once and a FailLocal limiter waits on its local limiter.
+func (l *FallbackLimiter) WaitN(ctx context.Context, n int) error {
+	if !l.callStore() {
+		return l.fallbackWait(ctx, n, ErrBreakerOpen)
+	}
+	err := l.remote.WaitN(ctx, n)
+	var we *WaitError
+	if err == nil || errors.As(err, &we) || ctx.Err() != nil {
+		l.record(nil, false)
+		return err
+	}
+	l.record(err, false)
+	return l.fallbackWait(ctx, n, err)
+}
+
+func (l *FallbackLimiter) fallbackWait(ctx context.Context, n int, err error) error {
+	switch l.policy {
+	case FailOpen:
+		return nil
+	case FailLocal:
+		return l.local.WaitN(ctx, n)
+	}
+	return err
+}
+
+// callContext returns the context for a store call, bounded by the
+// breaker's Timeout.
+func (l *FallbackLimiter) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if l.breaker.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, l.breaker.Timeout)
+}
+
+// callStore reports whether the breaker lets a call through to the store:
+// always while it is closed, and one trial call at a time once an open
+// breaker has cooled down.
+func (l *FallbackLimiter) callStore() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.stats.BreakerOpen {
+		return true
+	}
+	if l.trial || l.clock.Now().Before(l.openUntil) {
+		return false
+	}
+	l.trial = true
+	return true
+}
+
+// record updates the breaker and stats with the outcome of a store call:
+// nil if it succeeded, and slow if it took longer than the breaker's
+// Latency.
+func (l *FallbackLimiter) record(err error, slow bool) {
+	l.mu.Lock()
+	s := &l.stats
+	trial := l.trial
+	l.trial = false
+	if l.breaker.Failures > 0 {
+		if err != nil || slow {
+			l.failures++
+			if trial || l.failures >= l.breaker.Failures {
+				if !s.BreakerOpen {
+					s.Trips++
+				}
+				s.BreakerOpen = true
+				l.openUntil = l.clock.Now().Add(l.breaker.Cooldown)
+				l.failures = 0
+			}
+		} else {
+			l.failures = 0
+			s.BreakerOpen = false
+		}
+	}
+	failing := err != nil || s.BreakerOpen
+	if !failing && !s.Active {
+		l.mu.Unlock()
+		return
+	}
+	changed := s.Active != failing
+	s.Active = failing
+	if err != nil {
+		s.Errors++
+		s.LastError = err
+	}
+	if changed && failing {
+		s.Activations++
+		s.Since = l.clock.Now()
+	}
+	fn := l.onChange
+	l.mu.Unlock()
+	if changed && fn != nil {
+		fn(failing, err)
+	}
+}
@@ -0,0 +1,125 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFallbackPolicies(t *testing.T) {
+	down := errors.New("store down")
+	for _, tc := range []struct {
+		policy  FailurePolicy
+		allowed int // of 10 events while the store is down
+		waitErr error
+	}{
+		{FailClosed, 0, down},
+		{FailOpen, 10, nil},
+		{FailLocal, 2, nil},
+	} {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			clk := newFakeClock(time.Unix(0, 0))
+			store := &failingStore{Store: NewMemoryStore()}
+			l := NewFallback(NewStoreLimiter(store, "api", 10, 10, clk), tc.policy, 0.25, clk)
+			var changes []bool
+			l.OnChange(func(active bool, err error) { changes = append(changes, active) })
+
+			store.err = down
+			allowed := 0
+			for range 10 {
+				if l.Allow() {
+					allowed++
+				}
+			}
+			if allowed != tc.allowed {
+				t.Errorf("allowed %d events while down, want %d", allowed, tc.allowed)
+			}
+			if err := l.Wait(1); !errors.Is(err, tc.waitErr) {
+				t.Errorf("Wait = %v, want %v", err, tc.waitErr)
+			}
+			st := l.Stats()
+			if !st.Active || st.Activations != 1 || st.Errors != 11 || st.LastError != down {
+				t.Errorf("stats while down = %+v", st)
+			}
+
+			store.err = nil
+			if !l.Allow() {
+				t.Error("denied after the store recovered")
+			}
+			if l.Stats().Active || len(changes) != 2 || !changes[0] || changes[1] {
+				t.Errorf("after recovery: %+v, changes %v", l.Stats(), changes)
+			}
+		})
+	}
+}
+
+func TestFallbackKeepsLimitErrors(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewFallback(NewStoreLimiter(NewMemoryStore(), "api", 1, 1, clk), FailOpen, 0, clk)
+	if err := l.WaitN(context.Background(), 2); !errors.Is(err, ErrBurstExceeded) {
+		t.Fatalf("WaitN beyond the burst = %v", err)
+	}
+	if l.Stats().Active {
+		t.Fatal("a limit error activated the fallback")
+	}
+}
+
+// slowStore is a Store whose reads take delay on clk.
+type slowStore struct {
+	failingStore
+	clk   *fakeClock
+	delay time.Duration
+	calls int
+}
+
+func (s *slowStore) Get(ctx context.Context, key string) (BucketState, bool, error) {
+	s.calls++
+	s.clk.Sleep(s.delay)
+	return s.failingStore.Get(ctx, key)
+}
+
+func TestFallbackBreaker(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	store := &slowStore{failingStore: failingStore{Store: NewMemoryStore(), err: errors.New("down")}, clk: clk}
+	l := NewFallback(NewStoreLimiter(store, "api", 100, 100, clk), FailClosed, 0, clk)
+	l.SetBreaker(Breaker{Failures: 3, Latency: 100 * time.Millisecond, Cooldown: time.Second})
+
+	for range 5 {
+		l.Allow()
+	}
+	if store.calls != 3 || !l.Stats().BreakerOpen {
+		t.Fatalf("breaker open = %v after %d store calls, want open after 3", l.Stats().BreakerOpen, store.calls)
+	}
+	if err := l.Wait(1); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("Wait with the breaker open = %v", err)
+	}
+
+	// A failed trial reopens the breaker at once.
+	clk.Sleep(time.Second)
+	l.Allow()
+	l.Allow()
+	if store.calls != 4 || !l.Stats().BreakerOpen {
+		t.Fatalf("after a failed trial: %d calls, %+v", store.calls, l.Stats())
+	}
+
+	// Slow successes count as failures.
+	store.err = nil
+	store.delay = 200 * time.Millisecond
+	clk.Sleep(time.Second)
+	if !l.Allow() {
+		t.Fatal("slow trial call denied")
+	}
+	if !l.Stats().BreakerOpen {
+		t.Fatal("a slow trial closed the breaker")
+	}
+
+	store.delay = 0
+	clk.Sleep(time.Second)
+	if !l.Allow() || l.Stats().BreakerOpen || l.Stats().Active {
+		t.Fatalf("breaker did not close after a good trial: %+v", l.Stats())
+	}
+	if st := l.Stats(); st.Trips != 1 || st.Activations != 1 {
+		t.Fatalf("stats = %+v, want one trip and one activation", st)
+	}
+}
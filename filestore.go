@@ -0,0 +1,194 @@
+package ratelimiter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStore is a Store kept in a local file, so that the buckets of a
+// single-node service, such as daily quotas in StoreLimiters, survive
+// restarts without external infrastructure. Unlike the snapshots of
+// Persist, every write is recorded: FileStore appends a line to a log for
+// each change, and compacts the log when it has grown to several times
+// the number of keys. Writes reach the operating system before they
+// return; call Sync to flush them to disk.
+//
+// A FileStore must be opened by one process at a time.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	buckets map[string]BucketState
+	records int // lines in the log
+}
+
+// fileRecord is one line of a FileStore log.
+type fileRecord struct {
+	Key       string  `json:"k"`
+	Tokens    float64 `json:"t"`
+	UpdatedAt int64   `json:"u"` // Unix nanoseconds
+	Version   uint64  `json:"v"`
+}
+
+// minCompactRecords is the log length below which a FileStore is not
+// compacted.
+const minCompactRecords = 1024
+
+// OpenFileStore opens the FileStore at path, creating it if it does not
+// exist. A line torn by a crash at the end of the log is discarded.
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, buckets: make(map[string]BucketState)}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var r fileRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			break
+		}
+		s.buckets[r.Key] = BucketState{Tokens: r.Tokens, UpdatedAt: time.Unix(0, r.UpdatedAt), Version: r.Version}
+	}
+	if err := s.compactLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) Get(_ context.Context, key string) (BucketState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[key]
+	return b, ok, nil
+}
+
+func (s *FileStore) Set(_ context.Context, key string, b BucketState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b.Version = s.buckets[key].Version + 1
+	return s.putLocked(key, b)
+}
+
+func (s *FileStore) CompareAndSwap(_ context.Context, key string, old, new BucketState) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buckets[key].Version != old.Version {
+		return false, nil
+	}
+	new.Version = old.Version + 1
+	return true, s.putLocked(key, new)
+}
+
+// Delete removes key's bucket, so that it starts over from a full burst.
+func (s *FileStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.buckets[key]; !ok {
+		return nil
+	}
+	delete(s.buckets, key)
+	// A deleted key is only dropped from the log by compaction.
+	return s.compactLocked()
+}
+
+// Sync flushes the log to disk.
+func (s *FileStore) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	return s.f.Sync()
+}
+
+// Close compacts and closes the log. The store may not be used after.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	err := s.compactLocked()
+	if s.f != nil {
+		if cerr := s.f.Close(); err == nil {
+			err = cerr
+		}
+		s.f = nil
+	}
+	return err
+}
+
+// putLocked stores b under key and appends it to the log. Callers must
+// hold s.mu.
+func (s *FileStore) putLocked(key string, b BucketState) error {
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	line, err := json.Marshal(fileRecord{Key: key, Tokens: b.Tokens, UpdatedAt: b.UpdatedAt.UnixNano(), Version: b.Version})
+	if err != nil {
+		return err
+	}
+	if _, err := s.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	s.buckets[key] = b
+	s.records++
+	if s.records >= minCompactRecords && s.records > 4*len(s.buckets) {
+		return s.compactLocked()
+	}
+	return nil
+}
+
+// compactLocked rewrites the log with one line per key, through a
+// temporary file renamed into place, and reopens it for appending.
+// Callers must hold s.mu.
+func (s *FileStore) compactLocked() error {
+	var buf bytes.Buffer
+	for key, b := range s.buckets {
+		line, err := json.Marshal(fileRecord{Key: key, Tokens: b.Tokens, UpdatedAt: b.UpdatedAt.UnixNano(), Version: b.Version})
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	if s.f != nil {
+		s.f.Close()
+	}
+	s.f = f
+	s.records = len(s.buckets)
+	return nil
+}
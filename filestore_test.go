@@ -0,0 +1,80 @@
+package ratelimiter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStoreSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	clk := newFakeClock(time.Unix(0, 0))
+	path := filepath.Join(t.TempDir(), "buckets.log")
+
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := NewStoreLimiter(s, "quota:alice", Every(24*time.Hour/100), 100, clk)
+	if !day.AllowN(100) {
+		t.Fatal("first 100 events denied")
+	}
+	if err := s.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Crash without Close, leaving a torn line behind.
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"k":"quota:bob","t":`)
+	f.Close()
+
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	day = NewStoreLimiter(s, "quota:alice", Every(24*time.Hour/100), 100, clk)
+	if day.Allow() {
+		t.Fatal("quota reset by a restart")
+	}
+	if _, ok, _ := s.Get(ctx, "quota:bob"); ok {
+		t.Fatal("torn record was loaded")
+	}
+	if b, _, _ := s.Get(ctx, "quota:alice"); b.Version != 1 {
+		t.Fatalf("version = %d after reopening, want 1", b.Version)
+	}
+}
+
+func TestFileStoreCompacts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "buckets.log")
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 * minCompactRecords {
+		if err := s.Set(ctx, "k", BucketState{Tokens: float64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.records >= minCompactRecords {
+		t.Fatalf("log has %d records for one key", s.records)
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", BucketState{Version: 1}, BucketState{}); ok {
+		t.Fatal("swap with a stale version succeeded")
+	}
+	if err := s.Delete("k"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, "k", BucketState{}); err == nil {
+		t.Fatal("Set after Close succeeded")
+	}
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Fatalf("log after deleting every key = %q", data)
+	}
+}
@@ -0,0 +1,158 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WindowAlignment selects where a FixedWindowLimiter's windows start.
+type WindowAlignment int
+
+const (
+	// AlignToClock starts windows at multiples of the window size since
+	// the zero time, e.g. on the minute for one-minute windows.
+	AlignToClock WindowAlignment = iota
+	// AlignToFirstRequest starts a window at the first request made after
+	// the previous window ended.
+	AlignToFirstRequest
+)
+
+// FixedWindowLimiter admits at most limit events per fixed window. All
+// counts are reset when a new window starts.
+type FixedWindowLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	align  WindowAlignment
+	clock  Clock
+	start  time.Time
+	count  int
+}
+
+// NewFixedWindow returns a FixedWindowLimiter admitting limit events per
+// window, with windows aligned as specified.
+func NewFixedWindow(limit int, window time.Duration, align WindowAlignment, clk Clock) *FixedWindowLimiter {
+	if clk == nil {
+		clk = realClock{}
+	}
+	return &FixedWindowLimiter{
+		limit:  limit,
+		window: window,
+		align:  align,
+		clock:  clk,
+	}
+}
+
+func (l *FixedWindowLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+func (l *FixedWindowLimiter) Window() time.Duration {
+	return l.window
+}
+
+// Remaining returns the number of events still admitted in the current
+// window.
+func (l *FixedWindowLimiter) Remaining() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(l.clock.Now())
+	return l.limit - l.count
+}
+
+// ResetAt returns when the current window ends. With AlignToFirstRequest
+// and no open window it returns the zero time.
+func (l *FixedWindowLimiter) ResetAt() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(l.clock.Now())
+	if l.start.IsZero() {
+		return time.Time{}
+	}
+	return l.start.Add(l.window)
+}
+
+func (l *FixedWindowLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+// AllowN reports whether n events fit in the current window, recording
+// them if so. A negative n is denied.
+func (l *FixedWindowLimiter) AllowN(n int) bool {
+	_, ok := l.take(l.clock.Now(), n)
+	return ok
+}
+
+// Wait is shorthand for WaitN(context.Background(), n).
+func (l *FixedWindowLimiter) Wait(n int) error {
+	return l.WaitN(context.Background(), n)
+}
+
+// WaitN blocks until n events fit in the current window or ctx is done. A
+// negative n fails with ErrNegativeTokens.
+func (l *FixedWindowLimiter) WaitN(ctx context.Context, n int) error {
+	if n < 0 {
+		return negativeError(n)
+	}
+	if limit := l.Limit(); n > limit {
+		return burstError(n, limit)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctxError(n, ctx.Err(), 0)
+		default:
+		}
+		delay, ok := l.take(l.clock.Now(), n)
+		if ok {
+			return nil
+		}
+		if err := sleepCtx(ctx, l.clock, delay); err != nil {
+			return ctxError(n, err, delay)
+		}
+	}
+}
+
+// take records n events at t if they fit in the current window. Otherwise
+// it returns the time until the window ends. A negative n never fits.
+func (l *FixedWindowLimiter) take(t time.Time, n int) (time.Duration, bool) {
+	if n < 0 {
+		return InfiniteDuration, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.advance(t)
+	if l.start.IsZero() {
+		l.start = t
+	}
+	if l.count+n <= l.limit {
+		l.count += n
+		return 0, true
+	}
+	delay := l.start.Add(l.window).Sub(t)
+	if delay <= 0 {
+		delay = time.Nanosecond
+	}
+	return delay, false
+}
+
+// advance starts a new window if t is past the current one. With
+// AlignToFirstRequest the new window is left unopened until take.
+func (l *FixedWindowLimiter) advance(t time.Time) {
+	switch l.align {
+	case AlignToFirstRequest:
+		if !l.start.IsZero() && !t.Before(l.start.Add(l.window)) {
+			l.start = time.Time{}
+			l.count = 0
+		}
+	default:
+		if start := t.Truncate(l.window); !start.Equal(l.start) {
+			l.start = start
+			l.count = 0
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFixedWindowAlignToClock(t *testing.T) {
+	clk := newFakeClock(time.Unix(30, 0))
+	l := NewFixedWindow(2, time.Minute, AlignToClock, clk)
+
+	if !l.AllowN(2) || l.Allow() {
+		t.Fatalf("expected 2 events per window")
+	}
+	if reset := l.ResetAt(); !reset.Equal(time.Unix(60, 0)) {
+		t.Fatalf("expected window to end on the minute, got %v", reset)
+	}
+	clk.Sleep(30 * time.Second)
+	if r := l.Remaining(); r != 2 {
+		t.Fatalf("expected fresh window on the minute, got %d remaining", r)
+	}
+}
+
+func TestFixedWindowAlignToFirstRequest(t *testing.T) {
+	clk := newFakeClock(time.Unix(30, 0))
+	l := NewFixedWindow(1, time.Minute, AlignToFirstRequest, clk)
+
+	if !l.ResetAt().IsZero() {
+		t.Fatalf("expected no open window before the first request")
+	}
+	l.Allow()
+	clk.Sleep(40 * time.Second)
+	if l.Allow() {
+		t.Fatalf("expected window opened at first request to still be active")
+	}
+	clk.Sleep(20 * time.Second)
+	if !l.Allow() {
+		t.Fatalf("expected new window a minute after the first request")
+	}
+	if reset := l.ResetAt(); !reset.Equal(time.Unix(150, 0)) {
+		t.Fatalf("expected window to end at 150s, got %v", reset)
+	}
+}
+
+func TestFixedWindowWaitN(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewFixedWindow(3, time.Second, AlignToClock, clk)
+	l.AllowN(3)
+	clk.Sleep(250 * time.Millisecond)
+
+	if err := l.WaitN(context.Background(), 2); err != nil {
+		t.Fatalf("expected wait to succeed, got %v", err)
+	}
+	if got := clk.Now(); !got.Equal(time.Unix(1, 0)) {
+		t.Fatalf("expected to wait until next window, got %v", got)
+	}
+	if err := l.WaitN(context.Background(), 4); err == nil {
+		t.Fatalf("expected wait above limit to fail")
+	}
+}
+
+func TestFixedWindowNegativeN(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewFixedWindow(2, time.Minute, AlignToClock, clk)
+	if l.AllowN(-5) {
+		t.Fatal("AllowN(-5) admitted")
+	}
+	if err := l.WaitN(context.Background(), -5); !errors.Is(err, ErrNegativeTokens) {
+		t.Fatalf("WaitN(-5) = %v, want ErrNegativeTokens", err)
+	}
+	if !l.AllowN(2) || l.AllowN(1) {
+		t.Fatal("negative n changed the window")
+	}
+}
@@ -0,0 +1,227 @@
+package ratelimiter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// GossipLimiter enforces a global limit without a central store. Every
+// instance keeps a replica of the global token bucket, charged with its
+// own events as they happen and with the events of its peers as it learns
+// of them by gossip. Instances periodically send their view of every
+// instance's cumulative event count to a few random peers, which merge it
+// by taking the maximum per instance, so counts spread through the
+// cluster even if messages are lost.
+//
+// Decisions are eventually consistent: events admitted by peers but not
+// yet gossiped are not counted, so a cluster admits more than the limit
+// for roughly a gossip round after a burst. Events learnt late are charged
+// as debt of at most one burst.
+type GossipLimiter struct {
+	id     string
+	rl     *RateLimiter // replica of the global bucket
+	mu     sync.Mutex
+	counts map[string]uint64 // cumulative events by instance, own included
+}
+
+// gossipMessage is the encoding of a gossip message.
+type gossipMessage struct {
+	Counts map[string]uint64 `json:"counts"`
+}
+
+// NewGossip returns a GossipLimiter for a global limit of rate with burst.
+// id identifies this instance among its peers and must be unique to each
+// process start, such as a host name and start time, so that a restarted
+// instance does not inherit the counts of its predecessor.
+func NewGossip(id string, rate Rate, burst int, clk Clock) *GossipLimiter {
+	return &GossipLimiter{id: id, rl: New(rate, burst, clk), counts: map[string]uint64{id: 0}}
+}
+
+// Limiter returns the replica of the global bucket, for observers and
+// metrics.
+func (g *GossipLimiter) Limiter() *RateLimiter {
+	return g.rl
+}
+
+func (g *GossipLimiter) Allow() bool {
+	return g.AllowN(1)
+}
+
+func (g *GossipLimiter) AllowN(n int) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.rl.AllowN(n) {
+		return false
+	}
+	g.counts[g.id] += uint64(n)
+	return true
+}
+
+// Wait is shorthand for WaitN(context.Background(), n).
+func (g *GossipLimiter) Wait(n int) error {
+	return g.WaitN(context.Background(), n)
+}
+
+// WaitN blocks until n tokens are available in the replica or ctx is done.
+func (g *GossipLimiter) WaitN(ctx context.Context, n int) error {
+	if err := g.rl.WaitN(ctx, n); err != nil {
+		return err
+	}
+	g.mu.Lock()
+	g.counts[g.id] += uint64(n)
+	g.mu.Unlock()
+	return nil
+}
+
+// Message returns a gossip message with this instance's view of the
+// cluster's counts.
+func (g *GossipLimiter) Message() []byte {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	msg, _ := json.Marshal(gossipMessage{Counts: g.counts})
+	return msg
+}
+
+// Merge applies a gossip message from a peer, charging the replica with
+// the events it reports beyond those already known. The count of an
+// instance seen for the first time is taken as a baseline and not
+// charged, so that an instance joining a running cluster does not pay
+// for its peers' history.
+func (g *GossipLimiter) Merge(msg []byte) error {
+	var m gossipMessage
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return fmt.Errorf("rate: bad gossip message: %w", err)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var learnt uint64
+	for id, n := range m.Counts {
+		known, ok := g.counts[id]
+		if id == g.id || ok && n <= known {
+			continue
+		}
+		if ok {
+			learnt += n - known
+		}
+		g.counts[id] = n
+	}
+	if learnt > 0 {
+		g.rl.charge(float64(learnt))
+	}
+	return nil
+}
+
+// GossipTransport sends gossip messages to peers.
+type GossipTransport interface {
+	Send(ctx context.Context, peer string, msg []byte) error
+}
+
+// Gossip sends this instance's message to fanout random peers every
+// interval over t, passing failures to onError if it is not nil. peers is
+// called every round, so that membership may change. The returned
+// function stops gossiping.
+func (g *GossipLimiter) Gossip(t GossipTransport, peers func() []string, fanout int, interval time.Duration, onError func(error)) (stop func()) {
+	return RunEvery(interval, func() {
+		if err := g.gossipRound(t, peers(), fanout, interval); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+}
+
+// gossipRound sends the current message to fanout random peers, each
+// send bounded by timeout.
+func (g *GossipLimiter) gossipRound(t GossipTransport, peers []string, fanout int, timeout time.Duration) error {
+	msg := g.Message()
+	var errs []error
+	for _, i := range rand.Perm(len(peers))[:min(fanout, len(peers))] {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := t.Send(ctx, peers[i], msg); err != nil {
+			errs = append(errs, fmt.Errorf("gossip to %s: %w", peers[i], err))
+		}
+		cancel()
+	}
+	return errors.Join(errs...)
+}
+
+// ServePacket merges the gossip messages received on conn until it is
+// closed, returning net.ErrClosed then. Malformed packets are dropped.
+func (g *GossipLimiter) ServePacket(conn net.PacketConn) error {
+	buf := make([]byte, 64<<10)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		_ = g.Merge(buf[:n])
+	}
+}
+
+// ServeHTTP merges a gossip message POSTed by an HTTPTransport.
+func (g *GossipLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	msg, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err == nil {
+		err = g.Merge(msg)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UDPTransport sends gossip messages as UDP datagrams to peers given as
+// host:port. Messages are limited to a datagram, some 64 KB, which holds
+// the counts of several hundred instances.
+type UDPTransport struct {
+	Conn net.PacketConn
+}
+
+func (t UDPTransport) Send(_ context.Context, peer string, msg []byte) error {
+	addr, err := net.ResolveUDPAddr("udp", peer)
+	if err != nil {
+		return err
+	}
+	_, err = t.Conn.WriteTo(msg, addr)
+	return err
+}
+
+// HTTPTransport POSTs gossip messages to peers given as URLs served by
+// GossipLimiter.ServeHTTP.
+type HTTPTransport struct {
+	Client *http.Client // nil means http.DefaultClient
+}
+
+func (t HTTPTransport) Send(ctx context.Context, peer string, msg []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, peer, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("rate: gossip peer answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package ratelimiter
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGossipMerge(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	a := NewGossip("a", 1, 10, clk)
+	b := NewGossip("b", 1, 10, clk)
+	c := NewGossip("c", 1, 10, clk)
+	for _, g := range []*GossipLimiter{a, b, c} {
+		for _, peer := range []*GossipLimiter{a, b, c} {
+			g.Merge(peer.Message())
+		}
+	}
+
+	a.AllowN(4)
+	b.AllowN(3)
+	if err := b.Merge(a.Message()); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Limiter().AvailableTokens(); got != 3 {
+		t.Fatalf("b has %v tokens after learning of a's events, want 3", got)
+	}
+	// c learns of a's events through b, and duplicates are not charged.
+	c.Merge(b.Message())
+	c.Merge(a.Message())
+	if got := c.Limiter().AvailableTokens(); got != 3 {
+		t.Fatalf("c has %v tokens, want 3", got)
+	}
+	// Our own count is never taken from a peer.
+	a.Merge(c.Message())
+	if got := a.Limiter().AvailableTokens(); got != 3 {
+		t.Fatalf("a has %v tokens, want 3", got)
+	}
+
+	// New instances are a baseline, and debt is bounded by a burst.
+	b.Merge([]byte(`{"counts":{"d":100}}`))
+	if got := b.Limiter().AvailableTokens(); got != 3 {
+		t.Fatalf("b has %v tokens after meeting d, want 3", got)
+	}
+	b.Merge([]byte(`{"counts":{"d":130}}`))
+	if got := b.Limiter().AvailableTokens(); got != -10 {
+		t.Fatalf("b has %v tokens, want a debt of one burst", got)
+	}
+	if err := b.Merge([]byte("nope")); err == nil {
+		t.Fatal("merged a malformed message")
+	}
+}
+
+func TestGossipMergeKeepsReservations(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	g := NewGossip("a", 1, 2, clk)
+	g.Merge([]byte(`{"counts":{"b":0}}`))
+	// Waiters have taken the bucket deeper into debt than a burst; a merge
+	// adds no more debt, but must not pay theirs off either.
+	for i := 0; i < 3; i++ {
+		g.Limiter().ReserveN(2)
+	}
+	g.Merge([]byte(`{"counts":{"b":1}}`))
+	if got := g.Limiter().AvailableTokens(); got != -4 {
+		t.Fatalf("%v tokens after the merge, want the reservations' -4", got)
+	}
+}
+
+func TestGossipTransports(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	ctx := context.Background()
+
+	recv := NewGossip("recv", 1, 10, clk)
+	send := NewGossip("send", 1, 10, clk)
+	recv.Merge(send.Message())
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no UDP on loopback:", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- recv.ServePacket(conn) }()
+
+	send.AllowN(2)
+	out, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if err := (UDPTransport{Conn: out}).Send(ctx, conn.LocalAddr().String(), send.Message()); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for recv.Limiter().AvailableTokens() != 8 {
+		if time.Now().After(deadline) {
+			t.Fatal("UDP gossip not merged")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	conn.Close()
+	<-served
+
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+	send.AllowN(1)
+	if err := send.gossipRound(HTTPTransport{}, []string{srv.URL}, 3, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := recv.Limiter().AvailableTokens(); got != 7 {
+		t.Fatalf("recv has %v tokens after HTTP gossip, want 7", got)
+	}
+	if err := send.gossipRound(HTTPTransport{}, []string{"http://127.0.0.1:1"}, 1, time.Second); err == nil {
+		t.Fatal("gossip to a dead peer succeeded")
+	}
+}
@@ -0,0 +1,151 @@
+package ratelimiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// GradientConfig configures a GradientLimiter. Zero fields take the
+// documented defaults.
+type GradientConfig struct {
+	InitialLimit int // default 20
+	MinLimit     int // default 1
+	MaxLimit     int // default 1000
+
+	// Smoothing weighs each new estimate against the current limit, in
+	// (0, 1]. Default 0.2.
+	Smoothing float64
+
+	// Tolerance is how much latency may grow over the lowest seen before
+	// the limit is reduced. Default 1.5 (50% above minimum RTT).
+	Tolerance float64
+
+	// BackoffRatio multiplies the limit when a sample reports a dropped
+	// request. Default 0.9.
+	BackoffRatio float64
+
+	// ProbeInterval resets the lowest seen RTT every ProbeInterval samples
+	// so that a permanent shift in latency is eventually accepted. Zero
+	// never resets.
+	ProbeInterval int
+}
+
+// GradientLimiter estimates how much concurrency a downstream can take from
+// observed latencies, in the style of Netflix's concurrency-limits: the
+// limit shrinks as latency rises above the lowest seen RTT and grows by a
+// queue allowance of sqrt(limit) while latency stays near it.
+//
+// It does not limit anything itself; attach it to a ConcurrencyLimiter or a
+// RateLimiter with DriveConcurrency or DriveRate.
+type GradientLimiter struct {
+	mu      sync.Mutex
+	cfg     GradientConfig
+	limit   float64
+	minRTT  time.Duration
+	samples int
+	targets []func(limit float64, minRTT time.Duration)
+}
+
+// NewGradientLimiter returns a GradientLimiter configured by cfg.
+func NewGradientLimiter(cfg GradientConfig) *GradientLimiter {
+	if cfg.InitialLimit <= 0 {
+		cfg.InitialLimit = 20
+	}
+	if cfg.MinLimit <= 0 {
+		cfg.MinLimit = 1
+	}
+	if cfg.MaxLimit <= 0 {
+		cfg.MaxLimit = 1000
+	}
+	if cfg.Smoothing <= 0 || cfg.Smoothing > 1 {
+		cfg.Smoothing = 0.2
+	}
+	if cfg.Tolerance < 1 {
+		cfg.Tolerance = 1.5
+	}
+	if cfg.BackoffRatio <= 0 || cfg.BackoffRatio >= 1 {
+		cfg.BackoffRatio = 0.9
+	}
+	return &GradientLimiter{cfg: cfg, limit: float64(cfg.InitialLimit)}
+}
+
+// Limit returns the current concurrency estimate.
+func (g *GradientLimiter) Limit() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return int(g.limit)
+}
+
+// MinRTT returns the lowest latency seen since the last probe reset.
+func (g *GradientLimiter) MinRTT() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.minRTT
+}
+
+// Sample records the latency of a completed request. dropped reports that
+// the request failed because of overload (timeout, rejection), which backs
+// the limit off regardless of latency.
+func (g *GradientLimiter) Sample(latency time.Duration, dropped bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.samples++
+	if g.cfg.ProbeInterval > 0 && g.samples%g.cfg.ProbeInterval == 0 {
+		g.minRTT = 0
+	}
+
+	var next float64
+	switch {
+	case dropped:
+		next = g.limit * g.cfg.BackoffRatio
+	case latency <= 0:
+		return
+	default:
+		if g.minRTT == 0 || latency < g.minRTT {
+			g.minRTT = latency
+		}
+		gradient := g.cfg.Tolerance * float64(g.minRTT) / float64(latency)
+		gradient = math.Max(0.5, math.Min(1, gradient))
+		estimate := g.limit*gradient + math.Sqrt(g.limit)
+		next = g.limit*(1-g.cfg.Smoothing) + estimate*g.cfg.Smoothing
+	}
+	next = math.Max(float64(g.cfg.MinLimit), math.Min(float64(g.cfg.MaxLimit), next))
+
+	changed := int(next) != int(g.limit)
+	g.limit = next
+	if changed {
+		g.notifyLocked()
+	}
+}
+
+func (g *GradientLimiter) notifyLocked() {
+	for _, fn := range g.targets {
+		fn(g.limit, g.minRTT)
+	}
+}
+
+// DriveConcurrency makes c's maximum in-flight count follow the limit.
+func (g *GradientLimiter) DriveConcurrency(c *ConcurrencyLimiter) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	fn := func(limit float64, _ time.Duration) { c.SetMax(int(limit)) }
+	g.targets = append(g.targets, fn)
+	fn(g.limit, g.minRTT)
+}
+
+// DriveRate makes rl's rate follow the throughput the limit sustains at
+// the lowest seen latency, limit / minRTT. Until a latency has been sampled
+// the rate is left unchanged.
+func (g *GradientLimiter) DriveRate(rl *RateLimiter) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	fn := func(limit float64, minRTT time.Duration) {
+		if minRTT > 0 {
+			rl.SetRate(Rate(limit / minRTT.Seconds()))
+		}
+	}
+	g.targets = append(g.targets, fn)
+	fn(g.limit, g.minRTT)
+}
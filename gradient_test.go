@@ -0,0 +1,57 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGradientLimiterGrowsAtLowLatency(t *testing.T) {
+	g := NewGradientLimiter(GradientConfig{InitialLimit: 10, MaxLimit: 50})
+	for i := 0; i < 100; i++ {
+		g.Sample(10*time.Millisecond, false)
+	}
+	if got := g.Limit(); got != 50 {
+		t.Fatalf("expected limit to grow to max while latency is flat, got %d", got)
+	}
+}
+
+func TestGradientLimiterShrinksOnLatencyAndDrops(t *testing.T) {
+	g := NewGradientLimiter(GradientConfig{InitialLimit: 100, MaxLimit: 100})
+	g.Sample(10*time.Millisecond, false)
+	for i := 0; i < 50; i++ {
+		g.Sample(100*time.Millisecond, false)
+	}
+	if got := g.Limit(); got >= 100 {
+		t.Fatalf("expected limit to shrink when latency rises, got %d", got)
+	}
+
+	g = NewGradientLimiter(GradientConfig{InitialLimit: 100})
+	g.Sample(0, true)
+	if got := g.Limit(); got != 90 {
+		t.Fatalf("expected drop to back off to 90, got %d", got)
+	}
+}
+
+func TestGradientLimiterDrives(t *testing.T) {
+	g := NewGradientLimiter(GradientConfig{InitialLimit: 4, MaxLimit: 8})
+	c := NewConcurrencyLimiter(1, 0)
+	rl := New(1, 1, newFakeClock(time.Unix(0, 0)))
+	g.DriveConcurrency(c)
+	g.DriveRate(rl)
+	if c.Max() != 4 {
+		t.Fatalf("expected concurrency max to follow initial limit, got %d", c.Max())
+	}
+	if rl.Rate() != 1 {
+		t.Fatalf("expected rate to be unchanged before any sample, got %v", rl.Rate())
+	}
+
+	for i := 0; i < 100; i++ {
+		g.Sample(100*time.Millisecond, false)
+	}
+	if c.Max() != 8 {
+		t.Fatalf("expected concurrency max to follow limit, got %d", c.Max())
+	}
+	if rl.Rate() != 80 {
+		t.Fatalf("expected rate of limit/minRTT = 80/s, got %v", rl.Rate())
+	}
+}
@@ -0,0 +1,61 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+)
+
+// Group runs functions in goroutines, like errgroup.Group, but starts each
+// only once its limiter admits it, for fanning out calls to a rate-limited
+// API. The first function to fail, or a failed wait for the limiter,
+// cancels the group's context and determines the error Wait returns.
+type Group struct {
+	l      Limiter
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+	err    error
+}
+
+// NewGroup returns a Group gated by l and a context derived from ctx that
+// is canceled when a function fails or Wait returns. Pass that context to
+// the functions so that the rest stop early once one fails.
+func NewGroup(ctx context.Context, l Limiter) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{l: l, ctx: ctx, cancel: cancel}, ctx
+}
+
+// Go waits until the limiter admits another function and runs fn in a new
+// goroutine. Because it blocks, a loop calling Go is paced by the limiter
+// rather than leaving a goroutine per item waiting. If the group's context
+// is done first, fn is not run and Wait returns the wait's error, unless a
+// function failed before.
+func (g *Group) Go(fn func() error) {
+	if err := g.l.WaitN(g.ctx, 1); err != nil {
+		g.fail(err)
+		return
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := fn(); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+// Wait blocks until every function started by Go has returned, and
+// returns the first error.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel(g.err)
+	return g.err
+}
+
+func (g *Group) fail(err error) {
+	g.once.Do(func() {
+		g.err = err
+		g.cancel(err)
+	})
+}
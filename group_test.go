@@ -0,0 +1,54 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupPacesAndCollects(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	g, _ := NewGroup(context.Background(), New(10, 1, clk))
+	var started [3]time.Duration
+	var ran atomic.Int32
+	for i := range started {
+		g.Go(func() error {
+			ran.Add(1)
+			return nil
+		})
+		started[i] = clk.Now().Sub(time.Unix(0, 0))
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if started != [3]time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond} {
+		t.Fatalf("functions started at %v, want 100ms apart", started)
+	}
+	if ran.Load() != 3 {
+		t.Fatalf("%d functions ran, want 3", ran.Load())
+	}
+}
+
+func TestGroupFirstErrorCancels(t *testing.T) {
+	g, ctx := NewGroup(context.Background(), New(0, 1, nil))
+	errFirst := errors.New("first")
+	g.Go(func() error { return errFirst })
+	var ran atomic.Bool
+	// The limiter has no more tokens: the wait ends with the group's
+	// context, canceled by the first failure.
+	g.Go(func() error {
+		ran.Store(true)
+		return nil
+	})
+	if err := g.Wait(); !errors.Is(err, errFirst) {
+		t.Fatalf("Wait = %v, want the first error", err)
+	}
+	if ran.Load() {
+		t.Fatal("a function ran after the group failed")
+	}
+	if ctx.Err() == nil || !errors.Is(context.Cause(ctx), errFirst) {
+		t.Fatalf("group context not canceled by the failure: %v", context.Cause(ctx))
+	}
+}
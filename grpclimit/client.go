@@ -0,0 +1,78 @@
+package grpclimit
+
+import (
+	"context"
+
+	"github.com/navrang-singh/ratelimiter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryClientInterceptor waits on rl before each outgoing unary RPC.
+func UnaryClientInterceptor(rl ratelimiter.Limiter) grpc.UnaryClientInterceptor {
+	return unaryClient(waitOn(rl))
+}
+
+// StreamClientInterceptor waits on rl before opening each outgoing stream.
+func StreamClientInterceptor(rl ratelimiter.Limiter) grpc.StreamClientInterceptor {
+	return streamClient(waitOn(rl))
+}
+
+// MethodUnaryClientInterceptor waits on kl with each RPC's full method
+// name ("/pkg.Service/Method") as the key, so methods can be given their
+// own quotas with kl.SetRate and kl.SetBurst while the rest share kl's
+// defaults. kl's key rules and penalties apply: a denied method fails at
+// once with ResourceExhausted.
+func MethodUnaryClientInterceptor(kl *ratelimiter.KeyedLimiter) grpc.UnaryClientInterceptor {
+	return unaryClient(method(kl))
+}
+
+// MethodStreamClientInterceptor is the streaming counterpart of
+// MethodUnaryClientInterceptor.
+func MethodStreamClientInterceptor(kl *ratelimiter.KeyedLimiter) grpc.StreamClientInterceptor {
+	return streamClient(method(kl))
+}
+
+// waitFunc waits for a token for an RPC to fullMethod.
+type waitFunc func(ctx context.Context, fullMethod string) error
+
+// waitOn returns a waitFunc waiting on rl for every method.
+func waitOn(rl ratelimiter.Limiter) waitFunc {
+	return func(ctx context.Context, _ string) error { return rl.WaitN(ctx, 1) }
+}
+
+// method returns a waitFunc waiting on kl with each full method name as
+// the key.
+func method(kl *ratelimiter.KeyedLimiter) waitFunc {
+	return func(ctx context.Context, fullMethod string) error { return kl.Wait(ctx, fullMethod, 1) }
+}
+
+func unaryClient(wait waitFunc) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if err := waitStatus(ctx, wait(ctx, method)); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func streamClient(wait waitFunc) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if err := waitStatus(ctx, wait(ctx, method)); err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// waitStatus converts the error of a wait to a gRPC status.
+func waitStatus(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	return status.Error(codes.ResourceExhausted, err.Error())
+}
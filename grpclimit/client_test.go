@@ -0,0 +1,71 @@
+package grpclimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/navrang-singh/ratelimiter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMethodUnaryClientInterceptor(t *testing.T) {
+	clk := &fakeClock{}
+	kl := ratelimiter.NewKeyed(ratelimiter.Every(100*time.Millisecond), 1, clk)
+	kl.SetRate("/pkg.Service/Heavy", ratelimiter.Every(time.Second))
+	intercept := MethodUnaryClientInterceptor(kl)
+
+	calls := 0
+	invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		calls++
+		return nil
+	}
+	for i := 0; i < 2; i++ {
+		if err := intercept(context.Background(), "/pkg.Service/Heavy", nil, nil, nil, invoker); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if got := clk.Now().Sub(time.Time{}); got != time.Second {
+		t.Fatalf("expected heavy method to wait 1s, got %v", got)
+	}
+	for i := 0; i < 2; i++ {
+		intercept(context.Background(), "/pkg.Service/Light", nil, nil, nil, invoker)
+	}
+	if got := clk.Now().Sub(time.Time{}); got != 1100*time.Millisecond {
+		t.Fatalf("expected light method to wait 100ms more, got %v", got)
+	}
+	if calls != 4 {
+		t.Fatalf("expected 4 invocations, got %d", calls)
+	}
+}
+
+func TestMethodClientInterceptorKeyRules(t *testing.T) {
+	kl := ratelimiter.NewKeyed(ratelimiter.Every(time.Second), 1, &fakeClock{})
+	kl.SetRule("/pkg.Service/Banned", ratelimiter.RuleDeny)
+	invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		t.Fatal("denied method was invoked")
+		return nil
+	}
+	err := MethodUnaryClientInterceptor(kl)(context.Background(), "/pkg.Service/Banned", nil, nil, nil, invoker)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+}
+
+func TestStreamClientInterceptorCanceled(t *testing.T) {
+	rl := ratelimiter.New(ratelimiter.Every(time.Second), 1, &fakeClock{})
+	intercept := StreamClientInterceptor(rl)
+	streamer := func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+		t.Fatalf("expected stream not to be opened")
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := intercept(ctx, &grpc.StreamDesc{}, nil, "/pkg.Service/Stream", streamer)
+	if status.Code(err) != codes.Canceled {
+		t.Fatalf("expected Canceled, got %v", err)
+	}
+}
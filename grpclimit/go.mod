@@ -0,0 +1,18 @@
+module github.com/navrang-singh/ratelimiter/grpclimit
+
+go 1.22.2
+
+require (
+	github.com/navrang-singh/ratelimiter v0.0.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240604185151-ef581f913117
+	google.golang.org/grpc v1.66.0
+	google.golang.org/protobuf v1.34.2
+)
+
+require (
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+)
+
+replace github.com/navrang-singh/ratelimiter => ../
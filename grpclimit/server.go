@@ -0,0 +1,124 @@
+// Package grpclimit provides gRPC interceptors backed by ratelimiter
+// limiters.
+package grpclimit
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/navrang-singh/ratelimiter"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// KeyFunc returns the limiter key for an incoming RPC.
+type KeyFunc func(ctx context.Context, fullMethod string) string
+
+// PeerKey keys RPCs by the host of the calling peer's address.
+func PeerKey(ctx context.Context, _ string) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// MetadataKey keys RPCs by the first value of the named incoming metadata
+// entry, e.g. "x-api-key".
+func MetadataKey(name string) KeyFunc {
+	return func(ctx context.Context, _ string) string {
+		if vals := metadata.ValueFromIncomingContext(ctx, name); len(vals) > 0 {
+			return vals[0]
+		}
+		return ""
+	}
+}
+
+// UnaryServerInterceptor rejects unary RPCs that find no token in rl.
+func UnaryServerInterceptor(rl *ratelimiter.RateLimiter) grpc.UnaryServerInterceptor {
+	return unaryServer(func(context.Context, string) ratelimiter.Result { return rl.AllowNDetailed(1) })
+}
+
+// StreamServerInterceptor rejects streams that find no token in rl. The
+// token is taken when the stream is opened, not per message.
+func StreamServerInterceptor(rl *ratelimiter.RateLimiter) grpc.StreamServerInterceptor {
+	return streamServer(func(context.Context, string) ratelimiter.Result { return rl.AllowNDetailed(1) })
+}
+
+// KeyedUnaryServerInterceptor is like UnaryServerInterceptor but takes the
+// token from the limiter for the RPC's key, subject to kl's key rules and
+// penalties, as KeyedLimiter.AllowNDetailed is.
+func KeyedUnaryServerInterceptor(kl *ratelimiter.KeyedLimiter, key KeyFunc) grpc.UnaryServerInterceptor {
+	return unaryServer(keyed(kl, key))
+}
+
+// KeyedStreamServerInterceptor is like StreamServerInterceptor but takes
+// the token from the limiter for the stream's key.
+func KeyedStreamServerInterceptor(kl *ratelimiter.KeyedLimiter, key KeyFunc) grpc.StreamServerInterceptor {
+	return streamServer(keyed(kl, key))
+}
+
+// decideFunc takes a token for an incoming RPC.
+type decideFunc func(ctx context.Context, fullMethod string) ratelimiter.Result
+
+func keyed(kl *ratelimiter.KeyedLimiter, key KeyFunc) decideFunc {
+	return func(ctx context.Context, fullMethod string) ratelimiter.Result {
+		return kl.AllowNDetailed(key(ctx, fullMethod), 1)
+	}
+}
+
+func unaryServer(decide decideFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := admit(decide(ctx, info.FullMethod), info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamServer(decide decideFunc) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := admit(decide(ss.Context(), info.FullMethod), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// admit returns nil for an allowed RPC, and otherwise a ResourceExhausted
+// status carrying a RetryInfo detail with the time until it could be
+// retried, unless it never can.
+func admit(res ratelimiter.Result, fullMethod string) error {
+	if res.Allowed {
+		return nil
+	}
+	st := status.Newf(codes.ResourceExhausted, "%s is rate limited", fullMethod)
+	if res.RetryAfter != ratelimiter.InfiniteDuration {
+		retry := &errdetails.RetryInfo{RetryDelay: durationpb.New(res.RetryAfter)}
+		if withDetails, err := st.WithDetails(retry); err == nil {
+			st = withDetails
+		}
+	}
+	return st.Err()
+}
+
+// RetryDelay returns the delay carried by a RetryInfo detail of err, as
+// attached by the server interceptors.
+func RetryDelay(err error) (time.Duration, bool) {
+	for _, d := range status.Convert(err).Details() {
+		if ri, ok := d.(*errdetails.RetryInfo); ok && ri.RetryDelay != nil {
+			return ri.RetryDelay.AsDuration(), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,116 @@
+package grpclimit
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/navrang-singh/ratelimiter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type fakeClock struct {
+	mu   sync.Mutex
+	time time.Time
+}
+
+func (fc *fakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.time
+}
+
+func (fc *fakeClock) Sleep(d time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.time = fc.time.Add(d)
+}
+
+func okHandler(context.Context, interface{}) (interface{}, error) { return "ok", nil }
+
+var unaryInfo = &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Method"}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	rl := ratelimiter.New(ratelimiter.Every(500*time.Millisecond), 1, &fakeClock{})
+	intercept := UnaryServerInterceptor(rl)
+
+	if _, err := intercept(context.Background(), nil, unaryInfo, okHandler); err != nil {
+		t.Fatalf("expected first RPC to pass, got %v", err)
+	}
+	_, err := intercept(context.Background(), nil, unaryInfo, okHandler)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	if d, ok := RetryDelay(err); !ok || d != 500*time.Millisecond {
+		t.Fatalf("expected 500ms retry delay, got %v %v", d, ok)
+	}
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeStream) Context() context.Context { return s.ctx }
+
+func TestKeyedStreamServerInterceptor(t *testing.T) {
+	kl := ratelimiter.NewKeyed(ratelimiter.Every(time.Second), 1, &fakeClock{})
+	intercept := KeyedStreamServerInterceptor(kl, PeerKey)
+	info := &grpc.StreamServerInfo{FullMethod: "/pkg.Service/Stream"}
+	handler := func(interface{}, grpc.ServerStream) error { return nil }
+	from := func(ip string) grpc.ServerStream {
+		addr := &net.TCPAddr{IP: net.ParseIP(ip), Port: 1234}
+		return fakeStream{ctx: peer.NewContext(context.Background(), &peer.Peer{Addr: addr})}
+	}
+
+	if err := intercept(nil, from("10.0.0.1"), info, handler); err != nil {
+		t.Fatalf("expected first stream to pass, got %v", err)
+	}
+	if err := intercept(nil, from("10.0.0.1"), info, handler); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected same peer to be throttled, got %v", err)
+	}
+	if err := intercept(nil, from("10.0.0.2"), info, handler); err != nil {
+		t.Fatalf("expected other peer to pass, got %v", err)
+	}
+}
+
+func TestKeyedInterceptorRules(t *testing.T) {
+	kl := ratelimiter.NewKeyed(ratelimiter.Every(time.Second), 1, &fakeClock{})
+	kl.SetRule("blocked", ratelimiter.RuleDeny)
+	kl.SetRule("internal", ratelimiter.RuleBypass)
+	intercept := KeyedUnaryServerInterceptor(kl, MetadataKey("x-api-key"))
+	call := func(key string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", key))
+		_, err := intercept(ctx, nil, unaryInfo, okHandler)
+		return err
+	}
+
+	err := call("blocked")
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("deny-listed key: %v", err)
+	}
+	if _, ok := RetryDelay(err); ok {
+		t.Fatal("deny-listed key told to retry")
+	}
+	for i := 0; i < 3; i++ {
+		if err := call("internal"); err != nil {
+			t.Fatalf("bypassed key throttled on call %d: %v", i, err)
+		}
+	}
+}
+
+func TestMetadataKey(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "abc"))
+	if key := MetadataKey("x-api-key")(ctx, ""); key != "abc" {
+		t.Fatalf("expected key from metadata, got %q", key)
+	}
+	if key := MetadataKey("x-api-key")(context.Background(), ""); key != "" {
+		t.Fatalf("expected empty key without metadata, got %q", key)
+	}
+}
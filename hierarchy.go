@@ -0,0 +1,88 @@
+package ratelimiter
+
+import (
+	"math"
+	"time"
+)
+
+// NewChild returns a limiter allowing rate and burst whose events also
+// count against rl, and against rl's own parent if it has one. Every
+// admission through the child checks and charges the whole chain in one
+// step: either each limiter in it has the tokens, or none is charged.
+// This builds trees such as per-user limits under a per-tenant limit under
+// a global one. The child shares rl's clock.
+func (rl *RateLimiter) NewChild(rate Rate, burst int) *RateLimiter {
+	child := New(rate, burst, rl.clock)
+	child.parent = rl
+	return child
+}
+
+// Parent returns the limiter rl was created from by NewChild, or nil.
+func (rl *RateLimiter) Parent() *RateLimiter {
+	return rl.parent
+}
+
+// reserveChain is reserve for a limiter with ancestors. Limiters are
+// always locked leaf first, so concurrent calls anywhere in the tree
+// cannot deadlock. The reservation acts once every level has the tokens.
+// floor applies to rl alone, as in reserveAbove.
+func (rl *RateLimiter) reserveChain(t time.Time, cost float64, maxWait time.Duration, floor float64) Reservation {
+	var chain []*RateLimiter
+	for l := rl; l != nil; l = l.parent {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		chain = append(chain, l)
+	}
+
+	ok := true
+	var wait time.Duration
+	tokens := make([]float64, len(chain))
+	remaining := math.Inf(1)
+	for i, l := range chain {
+		if l.warmup != nil {
+			l.warmLocked(t)
+		}
+		tokens[i] = l.updateTokens(t)
+		if l.maxFuture > 0 {
+			maxWait = min(maxWait, l.maxFuture)
+		}
+		if l.paused != nil || !(cost >= 0) {
+			ok = false
+		}
+		if l.rate == InfiniteRate {
+			remaining = math.Min(remaining, tokens[i])
+			continue
+		}
+		tokens[i] -= cost
+		remaining = math.Min(remaining, tokens[i]+cost)
+		if cost > float64(l.maxTokens) || (i == 0 && floor > 0 && tokens[i] < floor*float64(l.maxTokens)) {
+			ok = false
+		}
+		if tokens[i] < 0 {
+			if w := l.bucketLocked().untilTokens(l.stampLocked(t), -tokens[i]); w > wait {
+				wait = w
+			}
+		}
+	}
+	if !ok || wait > maxWait || wait == InfiniteDuration {
+		return Reservation{r: rl, rate: rl.rate, tokens: cost, remaining: remaining, need: wait}
+	}
+
+	timeToAct := t.Add(wait)
+	remaining = math.Inf(1)
+	var parent *Reservation
+	for i := len(chain) - 1; i >= 0; i-- {
+		l := chain[i]
+		if l.rate != InfiniteRate && cost > 0 {
+			l.updatedAt = l.stampLocked(t)
+			l.tokens = tokens[i]
+			l.eventAt = l.nanos(timeToAct)
+			l.publishLocked()
+		}
+		remaining = math.Min(remaining, tokens[i])
+		parent = &Reservation{ok: true, r: l, rate: l.rate, tokens: cost, timeToAct: timeToAct, parent: parent}
+	}
+	res := *parent
+	res.remaining = remaining
+	return res
+}
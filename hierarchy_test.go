@@ -0,0 +1,77 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChildChargesParent(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	tenant := New(1, 3, clk)
+	alice := tenant.NewChild(1, 2)
+	bob := tenant.NewChild(1, 2)
+
+	if !alice.AllowN(2) {
+		t.Fatal("expected alice's first two events to be allowed")
+	}
+	if alice.Allow() {
+		t.Fatal("expected alice to be limited by her own burst")
+	}
+	if !bob.Allow() {
+		t.Fatal("expected bob to use the tenant's last token")
+	}
+	if bob.Allow() {
+		t.Fatal("expected bob to be limited by the tenant")
+	}
+	if got := bob.AvailableTokens(); got != 1 {
+		t.Fatalf("expected a denied event not to charge the child, got %v tokens", got)
+	}
+	if got := tenant.AvailableTokens(); got != 0 {
+		t.Fatalf("expected tenant to be drained, got %v tokens", got)
+	}
+}
+
+func TestChildChainThreeLevels(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	global := New(1, 1, clk)
+	user := global.NewChild(10, 10).NewChild(10, 10)
+
+	if !user.Allow() {
+		t.Fatal("expected first event to be allowed")
+	}
+	if user.Allow() {
+		t.Fatal("expected the global limit to apply to grandchildren")
+	}
+	if got := user.Parent().AvailableTokens(); got != 9 {
+		t.Fatalf("expected the denied event to be rolled back, got %v tokens", got)
+	}
+}
+
+func TestChildWaitAndCancel(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	parent := New(1, 1, clk)
+	child := parent.NewChild(10, 10)
+	parent.Allow()
+
+	r := child.Reserve()
+	if got := r.Delay(); got != time.Second {
+		t.Fatalf("expected the child to wait for the parent, got %v", got)
+	}
+	r.Cancel()
+	if got := parent.AvailableTokens(); got != 0 {
+		t.Fatalf("expected cancel to refund the parent, got %v tokens", got)
+	}
+	if got := child.AvailableTokens(); got != 10 {
+		t.Fatalf("expected cancel to refund the child, got %v tokens", got)
+	}
+
+	if err := child.Wait(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != time.Second {
+		t.Fatalf("expected Wait to take 1s, got %v", got)
+	}
+	if err := child.Wait(2); err == nil {
+		t.Fatal("expected Wait above the parent's burst to fail")
+	}
+}
@@ -0,0 +1,290 @@
+package ratelimiter
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KeyedLimiter manages one RateLimiter per key (user ID, IP address, API
+// key, ...). Limiters are created lazily on first use with the default rate
+// and burst, unless an override has been set for the key. Keys can be
+// spread over several shards, each with its own lock, so that busy servers
+// do not contend on a single mutex.
+type KeyedLimiter struct {
+	mu        sync.Mutex // serializes configuration changes and the sweeper
+	config    atomic.Pointer[keyedConfig]
+	clock     Clock
+	nano      NanoClock // clock, if it is one
+	nanoEpoch int64     // Nanotime at epoch
+	epoch     time.Time
+	shards    []*keyedShard
+	sweepStop func() // of the SetTTL sweeper
+	topDenied *misraGries
+}
+
+// keyedConfig is the configuration shared by all shards. It is replaced
+// rather than modified, so it can be read without locking.
+type keyedConfig struct {
+	rate      Rate
+	burst     int
+	observers []Observer
+	maxKeys   int // per shard
+	shadow    bool
+	penalty   *Penalty
+	rules     map[string]KeyRule
+	ruleFuncs []ruleFunc
+	tiers     map[string]limit
+	tierOf    func(key string) string
+	onEvict   func(key string, reason EvictReason)
+}
+
+// keyedShard holds the limiters for a subset of the keys.
+type keyedShard struct {
+	mu        sync.Mutex
+	limiters  map[string]*RateLimiter
+	overrides map[string]limit
+	penalties map[string]*penaltyState // expired by sweepPenaltiesLocked
+	swept     time.Time                // of penalties
+	keyTiers  map[string]string        // tier names of live keys
+	lru       list.List                // of keys, most recently used first
+	lruElems  map[string]*list.Element
+	evictions [2]uint64
+}
+
+// limit is a rate and burst pair.
+type limit struct {
+	rate  Rate
+	burst int
+}
+
+// NewKeyed returns a KeyedLimiter whose limiters default to rate and burst.
+func NewKeyed(rate Rate, burst int, clk Clock) *KeyedLimiter {
+	return NewKeyedSharded(rate, burst, 1, clk)
+}
+
+// NewKeyedSharded is like NewKeyed but spreads keys over the given number
+// of shards by hash. A few shards per CPU removes most lock contention.
+func NewKeyedSharded(rate Rate, burst, shards int, clk Clock) *KeyedLimiter {
+	if clk == nil {
+		clk = realClock{}
+	}
+	if shards < 1 {
+		shards = 1
+	}
+	kl := &KeyedLimiter{clock: clk, epoch: clk.Now(), shards: make([]*keyedShard, shards)}
+	if kl.nano, _ = clk.(NanoClock); kl.nano != nil {
+		kl.nanoEpoch = kl.nano.Nanotime()
+	}
+	for i := range kl.shards {
+		kl.shards[i] = &keyedShard{
+			limiters:  make(map[string]*RateLimiter),
+			overrides: make(map[string]limit),
+			penalties: make(map[string]*penaltyState),
+			keyTiers:  make(map[string]string),
+			lruElems:  make(map[string]*list.Element),
+		}
+	}
+	kl.config.Store(&keyedConfig{rate: rate, burst: burst})
+	return kl
+}
+
+// shard returns the shard holding key, chosen by FNV-1a hash.
+func (kl *KeyedLimiter) shard(key string) *keyedShard {
+	if len(kl.shards) == 1 {
+		return kl.shards[0]
+	}
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return kl.shards[h%uint32(len(kl.shards))]
+}
+
+// updateConfig replaces the configuration with a copy modified by fn and
+// returns it. Callers must hold kl.mu.
+func (kl *KeyedLimiter) updateConfig(fn func(*keyedConfig)) *keyedConfig {
+	c := *kl.config.Load()
+	fn(&c)
+	kl.config.Store(&c)
+	return &c
+}
+
+// eachShard calls fn with every shard, locking each in turn.
+func (kl *KeyedLimiter) eachShard(fn func(s *keyedShard)) {
+	for _, s := range kl.shards {
+		s.mu.Lock()
+		fn(s)
+		s.mu.Unlock()
+	}
+}
+
+// Get returns the limiter for key, creating it if necessary.
+func (kl *KeyedLimiter) Get(key string) *RateLimiter {
+	s := kl.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return kl.getLocked(s, key)
+}
+
+// getLocked is Get for callers holding s.mu.
+func (kl *KeyedLimiter) getLocked(s *keyedShard, key string) *RateLimiter {
+	if rl, ok := s.limiters[key]; ok {
+		s.touchLocked(key)
+		if len(s.penalties) > 0 {
+			kl.expireCooldownLocked(s, key, kl.now())
+		}
+		return rl
+	}
+	cfg := kl.config.Load()
+	if cfg.tierOf != nil {
+		if tier := cfg.tierOf(key); tier != "" {
+			s.keyTiers[key] = tier
+		}
+	}
+	l := s.limitFor(key, cfg)
+	rl := New(l.rate, l.burst, kl.clock)
+	rl.shadow = cfg.shadow
+	for _, o := range cfg.observers {
+		rl.AddObserver(keyedObserver{key, o})
+	}
+	if len(s.penalties) > 0 {
+		kl.resumeCooldownLocked(s, key, rl)
+	}
+	s.limiters[key] = rl
+	s.touchLocked(key)
+	s.enforceMaxKeysLocked(cfg)
+	return rl
+}
+
+// limitFor returns the rate and burst for key: its override, else those
+// of its tier, else the defaults.
+func (s *keyedShard) limitFor(key string, cfg *keyedConfig) limit {
+	if l, ok := s.overrides[key]; ok {
+		return l
+	}
+	if l, ok := cfg.tiers[s.keyTiers[key]]; ok {
+		return l
+	}
+	return limit{rate: cfg.rate, burst: cfg.burst}
+}
+
+func (kl *KeyedLimiter) Allow(key string) bool {
+	return kl.AllowN(key, 1)
+}
+
+func (kl *KeyedLimiter) AllowN(key string, n int) bool {
+	if res, ok := kl.ruledResult(key); ok {
+		return res.Allowed
+	}
+	if pen := kl.config.Load().penalty; pen != nil {
+		return kl.allowPenalized(key, n, pen).Allowed
+	}
+	return kl.Get(key).AllowN(n)
+}
+
+// Wait blocks until n tokens are available for key or ctx is done.
+func (kl *KeyedLimiter) Wait(ctx context.Context, key string, n int) error {
+	if res, ok := kl.ruledResult(key); ok {
+		if res.Allowed {
+			return nil
+		}
+		return &WaitError{Err: ErrDenied, N: n, RetryAfter: InfiniteDuration}
+	}
+	if kl.config.Load().penalty != nil {
+		if err := kl.waitPenalized(ctx, key, n); err != nil {
+			return err
+		}
+	}
+	return kl.Get(key).WaitN(ctx, n)
+}
+
+// SetRate overrides the rate for key. The override outlives the key's
+// limiter, so it still applies if the limiter is removed and recreated.
+// During a cooldown set by SetPenalty, the rate takes effect when the
+// cooldown ends.
+func (kl *KeyedLimiter) SetRate(key string, r Rate) {
+	s := kl.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := s.limitFor(key, kl.config.Load())
+	l.rate = r
+	s.overrides[key] = l
+	rl := kl.getLocked(s, key)
+	if !s.coolingLocked(key) {
+		rl.SetRate(r)
+	}
+}
+
+// SetBurst overrides the burst for key. See SetRate.
+func (kl *KeyedLimiter) SetBurst(key string, burst int) {
+	s := kl.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := s.limitFor(key, kl.config.Load())
+	l.burst = burst
+	s.overrides[key] = l
+	kl.getLocked(s, key).SetBurst(burst)
+}
+
+// SetDefaults changes the default rate and burst. Existing limiters without
+// an override or a tier are updated in place.
+func (kl *KeyedLimiter) SetDefaults(r Rate, burst int) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	c := kl.updateConfig(func(c *keyedConfig) {
+		c.rate = r
+		c.burst = burst
+	})
+	kl.eachShard(func(s *keyedShard) {
+		for key, rl := range s.limiters {
+			if _, ok := s.overrides[key]; ok {
+				continue
+			}
+			if _, ok := c.tiers[s.keyTiers[key]]; ok {
+				continue
+			}
+			if !s.coolingLocked(key) {
+				rl.SetRate(r)
+			}
+			rl.SetBurst(burst)
+		}
+	})
+}
+
+// ClearOverride removes any rate or burst override for key and resets its
+// limiter, if present, to its tier or the defaults.
+func (kl *KeyedLimiter) ClearOverride(key string) {
+	s := kl.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.overrides[key]; !ok {
+		return
+	}
+	delete(s.overrides, key)
+	if rl, ok := s.limiters[key]; ok {
+		l := s.limitFor(key, kl.config.Load())
+		if !s.coolingLocked(key) {
+			rl.SetRate(l.rate)
+		}
+		rl.SetBurst(l.burst)
+	}
+}
+
+// Remove forgets the limiter for key. Overrides and penalties are kept.
+func (kl *KeyedLimiter) Remove(key string) {
+	s := kl.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forgetLocked(key)
+}
+
+// Len returns the number of keys with a live limiter.
+func (kl *KeyedLimiter) Len() int {
+	n := 0
+	kl.eachShard(func(s *keyedShard) { n += len(s.limiters) })
+	return n
+}
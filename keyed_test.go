@@ -0,0 +1,103 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyedLimiterIndependentKeys(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(Every(100*time.Millisecond), 1, clk)
+
+	if !kl.Allow("a") || !kl.Allow("b") {
+		t.Fatalf("expected first token for each key to be allowed")
+	}
+	if kl.Allow("a") {
+		t.Fatalf("expected second token for key a to be denied")
+	}
+	if n := kl.Len(); n != 2 {
+		t.Fatalf("expected 2 live limiters, got %d", n)
+	}
+	if err := kl.Wait(context.Background(), "a", 1); err != nil {
+		t.Fatalf("expected wait on key a to succeed, got %v", err)
+	}
+}
+
+func TestKeyedLimiterOverrides(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(Every(time.Second), 1, clk)
+
+	kl.SetBurst("vip", 5)
+	kl.SetRate("vip", Every(10*time.Millisecond))
+	if !kl.AllowN("vip", 5) {
+		t.Fatalf("expected override burst to apply")
+	}
+
+	kl.Remove("vip")
+	if rl := kl.Get("vip"); rl.Burst() != 5 || rl.Rate() != Every(10*time.Millisecond) {
+		t.Fatalf("expected override to survive removal, got rate=%v burst=%d", rl.Rate(), rl.Burst())
+	}
+
+	kl.ClearOverride("vip")
+	if rl := kl.Get("vip"); rl.Burst() != 1 {
+		t.Fatalf("expected default burst after clearing override, got %d", rl.Burst())
+	}
+}
+
+func TestKeyedLimiterSetDefaults(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(Every(time.Second), 1, clk)
+	kl.Get("a")
+	kl.SetBurst("b", 3)
+
+	kl.SetDefaults(Every(time.Second), 2)
+	if b := kl.Get("a").Burst(); b != 2 {
+		t.Fatalf("expected existing limiter to pick up new default, got %d", b)
+	}
+	if b := kl.Get("b").Burst(); b != 3 {
+		t.Fatalf("expected override to be kept, got %d", b)
+	}
+}
+
+func TestKeyedLimiterSharded(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyedSharded(Every(time.Second), 1, 8, clk)
+	kl.SetBurst("vip", 3)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			kl.Allow(fmt.Sprint("key", i))
+		}(i)
+	}
+	wg.Wait()
+	if n := kl.Len(); n != 101 {
+		t.Fatalf("expected 101 live limiters, got %d", n)
+	}
+	used := 0
+	for _, s := range kl.shards {
+		if len(s.limiters) > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		t.Fatalf("expected keys to spread over shards, got %d in use", used)
+	}
+
+	if !kl.AllowN("vip", 3) || kl.Allow("key1") {
+		t.Fatal("expected per-key limits to hold across shards")
+	}
+	kl.SetDefaults(Every(time.Second), 2)
+	if b := kl.Get("key1").Burst(); b != 2 {
+		t.Fatalf("expected defaults to reach every shard, got burst %d", b)
+	}
+	kl.SetMaxKeys(16)
+	if n := kl.Len(); n > 16 {
+		t.Fatalf("expected at most 16 keys, got %d", n)
+	}
+}
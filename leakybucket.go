@@ -0,0 +1,129 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LeakyBucket releases events at a constant rate, one every 1/rate, never
+// in bursts. Callers that cannot be released immediately queue up to the
+// bucket's capacity; beyond that they are rejected with ErrQueueFull.
+type LeakyBucket struct {
+	mu       sync.Mutex
+	interval time.Duration
+	capacity int
+	clock    Clock
+	next     time.Time
+}
+
+// NewLeakyBucket returns a LeakyBucket releasing events at rate and queueing
+// at most capacity of them.
+func NewLeakyBucket(rate Rate, capacity int, clk Clock) *LeakyBucket {
+	if clk == nil {
+		clk = realClock{}
+	}
+	return &LeakyBucket{
+		interval: rate.durationFromTokens(1),
+		capacity: capacity,
+		clock:    clk,
+		next:     clk.Now(),
+	}
+}
+
+// QueueLen returns the number of events waiting to be released.
+func (lb *LeakyBucket) QueueLen() int {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.queued(lb.clock.Now())
+}
+
+// queued returns the number of events scheduled for release after t. The
+// last scheduled event is released one interval before next.
+func (lb *LeakyBucket) queued(t time.Time) int {
+	if lb.interval <= 0 {
+		return 0
+	}
+	d := lb.next.Sub(t) - lb.interval
+	if d <= 0 {
+		return 0
+	}
+	return int((d + lb.interval - 1) / lb.interval)
+}
+
+func (lb *LeakyBucket) Allow() bool {
+	return lb.AllowN(1)
+}
+
+// AllowN reports whether n events can be released right now, without
+// queueing, and if so schedules the following event n intervals later. An
+// n of zero or less is denied, as is one beyond the capacity, which could
+// never be released.
+func (lb *LeakyBucket) AllowN(n int) bool {
+	if n <= 0 || n > lb.capacity {
+		return false
+	}
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	t := lb.clock.Now()
+	if lb.interval == InfiniteDuration || lb.next.After(t) {
+		return false
+	}
+	lb.next = t.Add(time.Duration(n) * lb.interval)
+	return true
+}
+
+// Wait is shorthand for WaitN(context.Background(), n).
+func (lb *LeakyBucket) Wait(n int) error {
+	return lb.WaitN(context.Background(), n)
+}
+
+// WaitN queues n events and blocks until the last of them is released. It
+// fails with ErrQueueFull without waiting if the queue has no room, and
+// with an error matching ctx.Err() if ctx is done first, in which case the
+// events are removed from the queue if no later events were queued behind
+// them. A negative n fails with ErrNegativeTokens.
+func (lb *LeakyBucket) WaitN(ctx context.Context, n int) error {
+	if n < 0 {
+		return negativeError(n)
+	}
+	select {
+	case <-ctx.Done():
+		return ctxError(n, ctx.Err(), 0)
+	default:
+	}
+	if n > lb.capacity {
+		return burstError(n, lb.capacity)
+	}
+
+	lb.mu.Lock()
+	t := lb.clock.Now()
+	if lb.interval == InfiniteDuration {
+		lb.mu.Unlock()
+		return neverError(n)
+	}
+	if lb.queued(t)+n > lb.capacity {
+		lb.mu.Unlock()
+		return &WaitError{Err: ErrQueueFull, N: n, Limit: lb.capacity}
+	}
+	slot := lb.next
+	if slot.Before(t) {
+		slot = t
+	}
+	end := slot.Add(time.Duration(n) * lb.interval)
+	lb.next = end
+	lb.mu.Unlock()
+
+	release := slot.Add(time.Duration(n-1) * lb.interval)
+	if delay := release.Sub(t); delay > 0 {
+		if err := sleepCtx(ctx, lb.clock, delay); err != nil {
+			lb.mu.Lock()
+			if lb.next.Equal(end) {
+				lb.next = slot
+			}
+			lb.mu.Unlock()
+			return ctxError(n, err, release.Sub(lb.clock.Now()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLeakyBucketAllow(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	lb := NewLeakyBucket(Every(100*time.Millisecond), 5, clk)
+
+	if !lb.Allow() {
+		t.Fatalf("expected first event to be released")
+	}
+	if lb.Allow() {
+		t.Fatalf("expected second event to be denied: no bursts")
+	}
+	clk.Sleep(100 * time.Millisecond)
+	if !lb.Allow() {
+		t.Fatalf("expected event after one interval")
+	}
+}
+
+func TestLeakyBucketAllowNEdges(t *testing.T) {
+	for _, tc := range []struct {
+		n    int
+		want bool
+	}{
+		{-5, false},
+		{0, false},
+		{1, true},
+		{3, true},
+		{4, false},
+		{1 << 40, false},
+	} {
+		clk := newFakeClock(time.Unix(0, 0))
+		lb := NewLeakyBucket(Every(time.Second), 3, clk)
+		if got := lb.AllowN(tc.n); got != tc.want {
+			t.Errorf("AllowN(%d) = %v, want %v", tc.n, got, tc.want)
+		}
+		if !tc.want && !lb.Allow() {
+			t.Errorf("AllowN(%d) held back the next event", tc.n)
+		}
+	}
+}
+
+func TestLeakyBucketWaitNSpacing(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	lb := NewLeakyBucket(Every(100*time.Millisecond), 5, clk)
+
+	start := clk.Now()
+	for i := 0; i < 3; i++ {
+		if err := lb.Wait(1); err != nil {
+			t.Fatalf("wait %d: %v", i, err)
+		}
+		if got, want := clk.Now().Sub(start), time.Duration(i)*100*time.Millisecond; got != want {
+			t.Fatalf("event %d released at %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestLeakyBucketQueueFull(t *testing.T) {
+	clk := &blockingClock{newFakeClock(time.Unix(0, 0)), make(chan struct{})}
+	lb := NewLeakyBucket(Every(100*time.Millisecond), 2, clk)
+	lb.Allow()
+
+	errc := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errc <- lb.Wait(1) }()
+	}
+	for lb.QueueLen() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := lb.Wait(1); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	close(clk.release)
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err != nil {
+			t.Fatalf("expected queued wait to succeed, got %v", err)
+		}
+	}
+}
+
+func TestLeakyBucketCancelDequeues(t *testing.T) {
+	clk := &blockingClock{newFakeClock(time.Unix(0, 0)), make(chan struct{})}
+	defer close(clk.release)
+	lb := NewLeakyBucket(Every(100*time.Millisecond), 2, clk)
+	lb.Allow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- lb.WaitN(ctx, 1) }()
+	for lb.QueueLen() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if n := lb.QueueLen(); n != 0 {
+		t.Fatalf("expected canceled event to leave the queue, got %d queued", n)
+	}
+}
+
+func TestLeakyBucketNegativeN(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewLeakyBucket(Every(time.Second), 2, clk)
+	if l.AllowN(-5) {
+		t.Fatal("AllowN(-5) admitted")
+	}
+	if err := l.WaitN(context.Background(), -5); !errors.Is(err, ErrNegativeTokens) {
+		t.Fatalf("WaitN(-5) = %v, want ErrNegativeTokens", err)
+	}
+	if !l.AllowN(1) || l.AllowN(1) {
+		t.Fatal("negative n changed the queue")
+	}
+}
@@ -0,0 +1,169 @@
+package ratelimiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// LeaseLimiter serves events from blocks of tokens leased from a global
+// bucket in a Store, so that most calls admit locally and only every
+// block-th event costs a round trip. When a lease runs low it is topped
+// up in the background; a call that finds it empty leases synchronously.
+//
+// So that instances with heavy traffic cannot drain the pool and starve
+// smaller ones, a lease never takes more than half of the tokens available
+// in the pool, unless a single call needs more. Leased tokens that are not
+// used within the lease's TTL are forfeited, bounding how long an idle
+// instance holds on to capacity; Release returns them early.
+type LeaseLimiter struct {
+	mu         sync.Mutex
+	global     *StoreLimiter
+	block      int
+	ttl        time.Duration
+	tokens     int // leased and unused
+	expires    time.Time
+	refreshing bool
+	onError    func(error)
+}
+
+// NewLease returns a LeaseLimiter leasing up to block tokens at a time
+// from global, each lease expiring after ttl.
+func NewLease(global *StoreLimiter, block int, ttl time.Duration) *LeaseLimiter {
+	return &LeaseLimiter{global: global, block: max(block, 1), ttl: ttl}
+}
+
+// OnRefreshError registers fn to be called with store errors from
+// background refreshes, which are otherwise dropped.
+func (l *LeaseLimiter) OnRefreshError(fn func(error)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onError = fn
+}
+
+// Leased returns the number of leased tokens not yet used.
+func (l *LeaseLimiter) Leased() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expireLocked(l.global.clock.Now())
+	return l.tokens
+}
+
+func (l *LeaseLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+// AllowN reports whether n events may happen now. Store errors are
+// treated as a denial; use AllowNContext to observe them.
+func (l *LeaseLimiter) AllowN(n int) bool {
+	ok, err := l.AllowNContext(context.Background(), n)
+	return ok && err == nil
+}
+
+// AllowNContext is like AllowN but honors ctx and reports store errors.
+// A negative n is denied.
+func (l *LeaseLimiter) AllowNContext(ctx context.Context, n int) (bool, error) {
+	if n < 0 {
+		return false, nil
+	}
+	if l.takeLeased(n) {
+		return true, nil
+	}
+	got, err := l.lease(ctx, n)
+	if err != nil || got == 0 {
+		return false, err
+	}
+	l.mu.Lock()
+	l.addLocked(got)
+	l.mu.Unlock()
+	return l.takeLeased(n), nil
+}
+
+// Release returns the unused leased tokens to the pool, for example on
+// shutdown.
+func (l *LeaseLimiter) Release(ctx context.Context) error {
+	l.mu.Lock()
+	l.expireLocked(l.global.clock.Now())
+	n := l.tokens
+	l.tokens = 0
+	l.mu.Unlock()
+	if n == 0 {
+		return nil
+	}
+	_, err := l.global.take(ctx, -n)
+	return err
+}
+
+// takeLeased takes n leased tokens if there are enough, starting a
+// background refresh if the lease runs low.
+func (l *LeaseLimiter) takeLeased(n int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expireLocked(l.global.clock.Now())
+	if l.tokens < n {
+		return false
+	}
+	l.tokens -= n
+	if l.tokens < l.block/2 && !l.refreshing {
+		l.refreshing = true
+		go l.refresh()
+	}
+	return true
+}
+
+func (l *LeaseLimiter) refresh() {
+	got, err := l.lease(context.Background(), 1)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refreshing = false
+	if err != nil {
+		if l.onError != nil {
+			l.onError(err)
+		}
+		return
+	}
+	l.addLocked(got)
+}
+
+// lease takes a block of tokens, but at least need, from the pool and
+// returns how many it took: none if fewer than need are available.
+func (l *LeaseLimiter) lease(ctx context.Context, need int) (int, error) {
+	got := 0
+	err := l.global.update(ctx, func(tokens float64) (float64, bool) {
+		avail := int(math.Floor(tokens))
+		got = min(l.block, avail/2)
+		if got < need {
+			got = need
+		}
+		if got > avail {
+			got = 0
+			return tokens, false
+		}
+		return tokens - float64(got), true
+	})
+	if err != nil {
+		return 0, err
+	}
+	return got, nil
+}
+
+// addLocked adds got leased tokens, renewing the lease. Callers must hold
+// l.mu.
+func (l *LeaseLimiter) addLocked(got int) {
+	if got == 0 {
+		return
+	}
+	now := l.global.clock.Now()
+	l.expireLocked(now)
+	l.tokens += got
+	l.expires = now.Add(l.ttl)
+}
+
+// expireLocked forfeits the leased tokens if the lease has expired at t.
+// Callers must hold l.mu.
+func (l *LeaseLimiter) expireLocked(t time.Time) {
+	if l.tokens > 0 && !t.Before(l.expires) {
+		l.tokens = 0
+	}
+}
@@ -0,0 +1,81 @@
+package ratelimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waitRefreshed waits for a background refresh of l to finish.
+func waitRefreshed(l *LeaseLimiter) {
+	for {
+		l.mu.Lock()
+		done := !l.refreshing
+		l.mu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLeaseBlocks(t *testing.T) {
+	ctx := context.Background()
+	clk := newFakeClock(time.Unix(0, 0))
+	store := NewMemoryStore()
+	l := NewLease(NewStoreLimiter(store, "api", 1, 100, clk), 10, time.Minute)
+
+	if !l.Allow() {
+		t.Fatal("first event denied")
+	}
+	waitRefreshed(l)
+	if got := l.Leased(); got != 9 {
+		t.Fatalf("leased %d tokens after one event, want 9", got)
+	}
+	if s, _, _ := store.Get(ctx, "api"); s.Tokens != 90 {
+		t.Fatalf("pool has %v tokens, want 90", s.Tokens)
+	}
+	for range 5 {
+		l.Allow()
+	}
+	waitRefreshed(l)
+	if got := l.Leased(); got != 14 {
+		t.Fatalf("leased %d tokens after a refresh, want 14", got)
+	}
+
+	if err := l.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s, _, _ := store.Get(ctx, "api"); s.Tokens != 94 {
+		t.Fatalf("pool has %v tokens after release, want 94", s.Tokens)
+	}
+}
+
+func TestLeaseSharesScarceTokens(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	store := NewMemoryStore()
+	big := NewLease(NewStoreLimiter(store, "api", 1, 8, clk), 100, time.Minute)
+	small := NewLease(NewStoreLimiter(store, "api", 1, 8, clk), 100, time.Minute)
+
+	if !big.AllowN(1) {
+		t.Fatal("big denied")
+	}
+	waitRefreshed(big)
+	if got := big.Leased(); got != 5 {
+		t.Fatalf("big leased %d of the 8 tokens, want 6 less the one it used", got)
+	}
+	if !small.AllowN(1) {
+		t.Fatal("a large lease starved the small instance")
+	}
+}
+
+func TestLeaseExpires(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewLease(NewStoreLimiter(NewMemoryStore(), "api", 0, 10, clk), 4, time.Second)
+	l.Allow()
+	waitRefreshed(l)
+	clk.Sleep(time.Second)
+	if got := l.Leased(); got != 0 {
+		t.Fatalf("%d tokens left after the lease expired", got)
+	}
+}
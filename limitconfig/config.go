@@ -0,0 +1,228 @@
+// Package limitconfig builds ratelimiter limiters from declarative rules
+// in YAML or JSON.
+//
+// A configuration lists rules, each matching requests by key, path and
+// method and limiting every matching key separately:
+//
+//	rules:
+//	  - name: writes
+//	    path: /api/*
+//	    methods: [POST, PUT, DELETE]
+//	    rate: 100/m
+//	    burst: 20
+//	  - name: search
+//	    path: /search
+//	    rate: 10/s
+//	    algorithm: sliding_window
+//
+// The first matching rule applies; requests matching no rule are admitted.
+package limitconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/navrang-singh/ratelimiter"
+	"gopkg.in/yaml.v3"
+)
+
+// Algorithms accepted in Rule.Algorithm.
+const (
+	TokenBucket   = "token_bucket"
+	SlidingWindow = "sliding_window"
+	FixedWindow   = "fixed_window"
+	LeakyBucket   = "leaky_bucket"
+)
+
+// Config is a set of limit rules.
+type Config struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule limits the requests it matches, per key. Empty match fields match
+// everything; rules with a Path or Methods only match HTTP requests.
+type Rule struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Key, Path and Methods select the requests the rule applies to. Key
+	// and Path are patterns in the syntax of path.Match, so "user:*" or
+	// "/api/*/items"; Methods are HTTP methods.
+	Key     string   `json:"key,omitempty" yaml:"key,omitempty"`
+	Path    string   `json:"path,omitempty" yaml:"path,omitempty"`
+	Methods []string `json:"methods,omitempty" yaml:"methods,omitempty"`
+
+	// Rate is a limit in the syntax of ratelimiter.ParseRate, such as
+	// "100/m". Burst, if set, overrides its burst. For the window
+	// algorithms the burst is the number of events per window.
+	Rate  string `json:"rate" yaml:"rate"`
+	Burst int    `json:"burst,omitempty" yaml:"burst,omitempty"`
+
+	// Algorithm is one of token_bucket, the default, sliding_window,
+	// fixed_window or leaky_bucket.
+	Algorithm string `json:"algorithm,omitempty" yaml:"algorithm,omitempty"`
+}
+
+// RuleError reports an invalid rule.
+type RuleError struct {
+	Index int // in Config.Rules
+	Name  string
+	Err   error
+}
+
+func (e *RuleError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("limitconfig: rule %d: %v", e.Index, e.Err)
+	}
+	return fmt.Sprintf("limitconfig: rule %d (%s): %v", e.Index, e.Name, e.Err)
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// Parse decodes a configuration from YAML, or JSON, which is a subset of
+// it, and validates it. Unknown fields are errors.
+func Parse(data []byte) (*Config, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var c Config
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("limitconfig: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ParseJSON is Parse for JSON only, giving errors with JSON offsets.
+func ParseJSON(data []byte) (*Config, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var c Config
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("limitconfig: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Load reads and parses the configuration file at name, as JSON if it has
+// a .json extension and as YAML otherwise.
+func Load(name string) (*Config, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("limitconfig: %w", err)
+	}
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		return ParseJSON(data)
+	}
+	return Parse(data)
+}
+
+// Validate checks every rule, returning a *RuleError for the first invalid
+// one.
+func (c *Config) Validate() error {
+	names := make(map[string]bool)
+	for i, r := range c.Rules {
+		if _, err := r.limit(); err != nil {
+			return &RuleError{Index: i, Name: r.Name, Err: err}
+		}
+		if err := r.validateMatch(); err != nil {
+			return &RuleError{Index: i, Name: r.Name, Err: err}
+		}
+		if r.Name != "" && names[r.Name] {
+			return &RuleError{Index: i, Name: r.Name, Err: errors.New("duplicate rule name")}
+		}
+		names[r.Name] = true
+	}
+	return nil
+}
+
+func (r Rule) validateMatch() error {
+	for field, pattern := range map[string]string{"key": r.Key, "path": r.Path} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad %s pattern %q: %w", field, pattern, err)
+		}
+	}
+	for _, m := range r.Methods {
+		if m == "" || strings.ToUpper(m) != m {
+			return fmt.Errorf("bad method %q: methods are upper case", m)
+		}
+	}
+	return nil
+}
+
+// limit is a rule's parsed rate and algorithm.
+type limit struct {
+	algorithm string
+	rate      ratelimiter.Rate
+	burst     int
+}
+
+func (r Rule) limit() (limit, error) {
+	if r.Rate == "" {
+		return limit{}, fmt.Errorf("%w: rate is required", ratelimiter.ErrInvalidLimit)
+	}
+	rate, burst, err := ratelimiter.ParseRate(r.Rate)
+	if err != nil {
+		return limit{}, err
+	}
+	if r.Burst < 0 {
+		return limit{}, fmt.Errorf("%w: negative burst %d", ratelimiter.ErrInvalidLimit, r.Burst)
+	}
+	if r.Burst > 0 {
+		burst = r.Burst
+	}
+	l := limit{algorithm: r.Algorithm, rate: rate, burst: burst}
+	switch r.Algorithm {
+	case "":
+		l.algorithm = TokenBucket
+	case TokenBucket:
+	case SlidingWindow, FixedWindow, LeakyBucket:
+		if rate <= 0 || rate == ratelimiter.InfiniteRate {
+			return limit{}, fmt.Errorf("%w: %s needs a finite, positive rate", ratelimiter.ErrInvalidLimit, r.Algorithm)
+		}
+	default:
+		return limit{}, fmt.Errorf("unknown algorithm %q", r.Algorithm)
+	}
+	return l, nil
+}
+
+// window returns the window holding l.burst events at l.rate.
+func (l limit) window() time.Duration {
+	return time.Duration(float64(l.burst) / float64(l.rate) * float64(time.Second))
+}
+
+// matches reports whether the rule applies to a request.
+func (r Rule) matches(key, method, urlPath string) bool {
+	if r.Key != "" {
+		if ok, _ := path.Match(r.Key, key); !ok {
+			return false
+		}
+	}
+	if r.Path != "" {
+		if ok, _ := path.Match(r.Path, urlPath); !ok {
+			return false
+		}
+	}
+	if len(r.Methods) > 0 {
+		for _, m := range r.Methods {
+			if m == method {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
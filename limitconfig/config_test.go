@@ -0,0 +1,188 @@
+package limitconfig
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/navrang-singh/ratelimiter"
+	"github.com/navrang-singh/ratelimiter/clocktest"
+)
+
+const testConfig = `
+rules:
+  - name: writes
+    path: /api/*
+    methods: [POST, PUT]
+    rate: 1/s
+    burst: 2
+  - name: search
+    path: /search
+    rate: 2/s
+    algorithm: sliding_window
+  - name: batch
+    key: "batch:*"
+    rate: 1/m
+`
+
+func TestParseAndAllow(t *testing.T) {
+	c, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := c.Build(clocktest.New(time.Unix(0, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("alice", "POST", "/api/items"); !ok {
+			t.Fatalf("write %d denied", i)
+		}
+	}
+	if ok, rule := l.Allow("alice", "PUT", "/api/items"); ok || rule != "writes" {
+		t.Fatalf("third write: ok=%v rule=%q, want denied by writes", ok, rule)
+	}
+	if ok, rule := l.Allow("alice", "GET", "/api/items"); !ok || rule != "" {
+		t.Fatalf("read: ok=%v rule=%q, want allowed by no rule", ok, rule)
+	}
+	if ok, _ := l.Allow("bob", "POST", "/api/items"); !ok {
+		t.Fatal("keys share a bucket")
+	}
+
+	l.Allow("alice", "GET", "/search")
+	l.Allow("alice", "GET", "/search")
+	if ok, rule := l.Allow("alice", "GET", "/search"); ok || rule != "search" {
+		t.Fatalf("third search: ok=%v rule=%q, want denied by the sliding window", ok, rule)
+	}
+
+	if ok, _ := l.Allow("batch:nightly", "", ""); !ok {
+		t.Fatal("first batch event denied")
+	}
+	if ok, rule := l.Allow("batch:nightly", "", ""); ok || rule != "batch" {
+		t.Fatalf("second batch event: ok=%v rule=%q", ok, rule)
+	}
+	if l.Keyed("writes") == nil || l.Keyed("search") != nil {
+		t.Fatal("Keyed returned the wrong limiters")
+	}
+}
+
+func TestRuleErrors(t *testing.T) {
+	for _, tc := range []struct {
+		config string
+		index  int
+		want   string
+	}{
+		{`{"rules": [{"name": "a", "rate": "1/s"}, {"name": "b", "rate": "fast"}]}`, 1, `rule 1 (b)`},
+		{`{"rules": [{"rate": "1/s", "algorithm": "magic"}]}`, 0, `unknown algorithm "magic"`},
+		{`{"rules": [{"rate": "1/s", "path": "/a/["}]}`, 0, `bad path pattern`},
+		{`{"rules": [{"rate": "1/s", "methods": ["get"]}]}`, 0, `bad method "get"`},
+		{`{"rules": [{"name": "a", "rate": "1/s"}, {"name": "a", "rate": "1/s"}]}`, 1, `duplicate rule name`},
+	} {
+		_, err := ParseJSON([]byte(tc.config))
+		var re *RuleError
+		if !errors.As(err, &re) || re.Index != tc.index || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("ParseJSON(%s) = %v, want a RuleError for rule %d mentioning %q", tc.config, err, tc.index, tc.want)
+		}
+	}
+
+	_, err := ParseJSON([]byte(`{"rules": [{"name": "b", "rate": "fast"}]}`))
+	if !errors.Is(err, ratelimiter.ErrInvalidLimit) {
+		t.Errorf("bad rate: %v, want ErrInvalidLimit", err)
+	}
+	if _, err := Parse([]byte("rules:\n  - rate: 1/s\n    burts: 3\n")); err == nil {
+		t.Error("Parse accepted an unknown field")
+	}
+}
+
+func TestLoadAndHandler(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "limits.yaml")
+	if err := os.WriteFile(name, []byte(testConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := c.Build(clocktest.New(time.Unix(0, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), nil)
+
+	var codes []int
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/api/items", nil))
+		codes = append(codes, rec.Code)
+	}
+	if codes[0] != 200 || codes[1] != 200 || codes[2] != http.StatusTooManyRequests {
+		t.Fatalf("status codes %v, want 200 200 429", codes)
+	}
+}
+
+func TestIdleKeysDropped(t *testing.T) {
+	c, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clocktest.New(time.Unix(0, 0))
+	l, err := c.Build(clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := func() *rule { return l.match("", "GET", "/search") }
+	for i := 0; i < 100; i++ {
+		l.Allow(fmt.Sprintf("10.0.0.%d", i), "GET", "/search")
+	}
+	l.Allow("alice", "GET", "/search")
+	l.Allow("alice", "GET", "/search")
+	if n := len(search().limiters); n != 101 {
+		t.Fatalf("%d limiters for 101 keys", n)
+	}
+
+	// The search rule's window is one second; keys idle for two are
+	// dropped, while a denied key keeps its limiter until then.
+	clk.Advance(1500 * time.Millisecond)
+	l.Allow("alice", "GET", "/search")
+	clk.Advance(600 * time.Millisecond)
+	if ok, _ := l.Allow("bob", "GET", "/search"); !ok {
+		t.Fatal("new key denied")
+	}
+	if n := len(search().limiters); n != 2 {
+		t.Fatalf("%d limiters after the rotated keys went idle, want alice and bob", n)
+	}
+}
+
+func TestIdleTokenBucketKeysDropped(t *testing.T) {
+	c, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clocktest.New(time.Unix(0, 0))
+	l, err := c.Build(clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		l.Allow(fmt.Sprintf("10.0.0.%d", i), "POST", "/api/x")
+	}
+	writes := l.match("", "POST", "/api/x").keyed
+	if n := writes.Len(); n != 100 {
+		t.Fatalf("%d limiters for 100 keys", n)
+	}
+
+	// The writes rule refills its burst of two in two seconds; keys full
+	// for that long are dropped at the next sweep.
+	clk.Advance(4 * time.Second)
+	l.Allow("alice", "POST", "/api/x")
+	if n := writes.Len(); n != 1 {
+		t.Fatalf("%d limiters after the rotated keys went idle, want alice's", n)
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/navrang-singh/ratelimiter/limitconfig
+
+go 1.22.2
+
+require (
+	github.com/navrang-singh/ratelimiter v0.0.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+replace github.com/navrang-singh/ratelimiter => ../
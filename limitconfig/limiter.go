@@ -0,0 +1,153 @@
+package limitconfig
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/navrang-singh/ratelimiter"
+)
+
+// Limiter applies the rules of a Config. It is safe for concurrent use.
+type Limiter struct {
+	mu    sync.Mutex // serializes Apply
+	rules atomic.Pointer[[]*rule]
+	clock ratelimiter.Clock
+}
+
+// rule is a Rule with its limiters.
+type rule struct {
+	Rule
+	limit limit
+	*buckets
+}
+
+// buckets are the limiters of a rule, kept across reloads. A key idle for
+// two windows is dropped, so that keys such as client IPs do not pile up;
+// by then a fresh limiter admits the same as the old one.
+type buckets struct {
+	// keyed holds the buckets of a token bucket rule.
+	keyed *ratelimiter.KeyedLimiter
+
+	// For other algorithms, limiters holds one limiter per key.
+	mu       sync.Mutex
+	limiters map[string]*keyLimiter
+	swept    time.Time // when idle keys were last dropped
+}
+
+// keyLimiter is the limiter of one key and when it was last used.
+type keyLimiter struct {
+	ratelimiter.Limiter
+	last time.Time
+}
+
+// Build returns a Limiter for c, with limiters on clk; a nil clk means the
+// real clock.
+func (c *Config) Build(clk ratelimiter.Clock) (*Limiter, error) {
+	l := &Limiter{clock: clk}
+	if err := l.Apply(c); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Keyed returns the KeyedLimiter of the named token bucket rule, for
+// observers, overrides and metrics, or nil if there is none.
+func (l *Limiter) Keyed(name string) *ratelimiter.KeyedLimiter {
+	for _, r := range *l.rules.Load() {
+		if r.Name == name {
+			return r.keyed
+		}
+	}
+	return nil
+}
+
+// Allow reports whether a request for key with the given method and URL
+// path may happen now, and the name of the rule that limited it, if any.
+// Use an empty method and path for events that are not HTTP requests.
+func (l *Limiter) Allow(key, method, urlPath string) (ok bool, rule string) {
+	r := l.match(key, method, urlPath)
+	if r == nil {
+		return true, ""
+	}
+	return r.allow(key, r.limit, l.clock), r.Name
+}
+
+// Handler wraps next, answering requests denied by their rule with 429 Too
+// Many Requests. keyFunc returns a request's key; nil means
+// ratelimiter.ClientIP.
+func (l *Limiter) Handler(next http.Handler, keyFunc func(r *http.Request) string) http.Handler {
+	if keyFunc == nil {
+		keyFunc = ratelimiter.ClientIP
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if ok, _ := l.Allow(keyFunc(req), req.Method, req.URL.Path); !ok {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// match returns the first rule matching the request, or nil.
+func (l *Limiter) match(key, method, urlPath string) *rule {
+	for _, r := range *l.rules.Load() {
+		if r.matches(key, method, urlPath) {
+			return r
+		}
+	}
+	return nil
+}
+
+func (r *buckets) allow(key string, lim limit, clk ratelimiter.Clock) bool {
+	now := time.Now()
+	if clk != nil {
+		now = clk.Now()
+	}
+	idle := 2 * lim.window()
+	r.mu.Lock()
+	if now.Sub(r.swept) >= idle {
+		r.sweepLocked(now, idle, lim)
+		r.swept = now
+	}
+	if r.keyed != nil {
+		r.mu.Unlock()
+		return r.keyed.Allow(key)
+	}
+	kl, ok := r.limiters[key]
+	if !ok {
+		kl = &keyLimiter{Limiter: lim.newLimiter(clk)}
+		r.limiters[key] = kl
+	}
+	kl.last = now
+	r.mu.Unlock()
+	return kl.Allow()
+}
+
+// sweepLocked drops the keys idle at now. A token bucket has been idle
+// once it has stayed full for a window. Callers must hold r.mu.
+func (r *buckets) sweepLocked(now time.Time, idle time.Duration, lim limit) {
+	if r.keyed != nil {
+		r.keyed.ExpireIdle(lim.window())
+		return
+	}
+	for k, kl := range r.limiters {
+		if now.Sub(kl.last) >= idle {
+			delete(r.limiters, k)
+		}
+	}
+}
+
+// newLimiter returns a limiter for one key of a rule that is not a token
+// bucket.
+func (l limit) newLimiter(clk ratelimiter.Clock) ratelimiter.Limiter {
+	switch l.algorithm {
+	case SlidingWindow:
+		return ratelimiter.NewSlidingWindow(l.burst, l.window(), clk)
+	case FixedWindow:
+		return ratelimiter.NewFixedWindow(l.burst, l.window(), ratelimiter.AlignToClock, clk)
+	default:
+		return ratelimiter.NewLeakyBucket(l.rate, l.burst, clk)
+	}
+}
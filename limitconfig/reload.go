@@ -0,0 +1,96 @@
+package limitconfig
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/navrang-singh/ratelimiter"
+)
+
+// Apply replaces l's rules with those of c, all at once, keeping the state
+// of rules that survive. Rules are matched up by name, or by position if
+// unnamed. A token bucket rule whose rate or burst changed is updated in
+// place, as by KeyedLimiter.SetDefaults: its buckets keep their tokens and
+// reservations already made stand. Other rules whose limit or algorithm
+// changed start again with fresh limiters. If c is invalid, l is left as
+// it was.
+func (l *Limiter) Apply(c *Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	old := make(map[string]*rule)
+	if rules := l.rules.Load(); rules != nil {
+		for i, r := range *rules {
+			old[r.id(i)] = r
+		}
+	}
+	rules := make([]*rule, 0, len(c.Rules))
+	for i, r := range c.Rules {
+		lim, _ := r.limit()
+		nr := &rule{Rule: r, limit: lim}
+		prev, ok := old[r.id(i)]
+		switch {
+		case !ok:
+		case lim == prev.limit:
+			nr.buckets = prev.buckets
+		case lim.algorithm == TokenBucket && prev.keyed != nil:
+			// The window and leaky bucket limiters cannot change their
+			// limits, so only token buckets are updated in place.
+			nr.buckets = prev.buckets
+			nr.keyed.SetDefaults(lim.rate, lim.burst)
+		}
+		if nr.buckets == nil {
+			nr.buckets = newBuckets(lim, l.clock)
+		}
+		rules = append(rules, nr)
+	}
+	l.rules.Store(&rules)
+	return nil
+}
+
+// Source returns the current configuration, such as from a file or a
+// configuration service.
+type Source func() (*Config, error)
+
+// FileSource returns a Source reading the file at name with Load.
+func FileSource(name string) Source {
+	return func() (*Config, error) { return Load(name) }
+}
+
+// Watch reads src every interval and applies the configuration to l.
+// Errors, including invalid configurations, are passed to onError, if not
+// nil, and leave the current rules in place. The returned function stops
+// watching.
+func (l *Limiter) Watch(src Source, interval time.Duration, onError func(error)) (stop func()) {
+	return ratelimiter.RunEvery(interval, func() {
+		if err := l.reload(src); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+}
+
+func (l *Limiter) reload(src Source) error {
+	c, err := src()
+	if err != nil {
+		return err
+	}
+	return l.Apply(c)
+}
+
+// id identifies the rule at index i across reloads.
+func (r Rule) id(i int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return "#" + strconv.Itoa(i)
+}
+
+func newBuckets(lim limit, clk ratelimiter.Clock) *buckets {
+	if lim.algorithm == TokenBucket {
+		return &buckets{keyed: ratelimiter.NewKeyed(lim.rate, lim.burst, clk)}
+	}
+	return &buckets{limiters: make(map[string]*keyLimiter)}
+}
@@ -0,0 +1,110 @@
+package limitconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/navrang-singh/ratelimiter/clocktest"
+)
+
+func TestApplyKeepsBuckets(t *testing.T) {
+	c, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := c.Build(clocktest.New(time.Unix(0, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Allow("alice", "POST", "/api/items")
+	l.Allow("alice", "GET", "/search")
+	l.Allow("alice", "GET", "/search")
+	writes := l.Keyed("writes")
+
+	// Raise the writes burst and the search limit.
+	c.Rules[0].Burst = 3
+	c.Rules[1].Rate = "3/s"
+	if err := l.Apply(c); err != nil {
+		t.Fatal(err)
+	}
+	if l.Keyed("writes") != writes {
+		t.Fatal("changed token bucket rule was replaced")
+	}
+	// alice's bucket is not refilled by the reload.
+	if got := writes.Get("alice").AvailableTokens(); got != 1 {
+		t.Fatalf("alice's tokens after reload = %v, want 1", got)
+	}
+	// The sliding window rule starts again with the new limit.
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("alice", "GET", "/search"); !ok {
+			t.Fatalf("search %d denied after raising the limit", i)
+		}
+	}
+
+	c.Rules[0].Rate = "nonsense"
+	var re *RuleError
+	if err := l.Apply(c); !errors.As(err, &re) || re.Name != "writes" {
+		t.Fatalf("Apply with a bad rule: %v", err)
+	}
+	if l.Keyed("writes") != writes {
+		t.Fatal("failed Apply changed the rules")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "limits.yaml")
+	if err := os.WriteFile(name, []byte("rules: [{name: a, rate: 1/s}]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := c.Build(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var errs []error
+	stop := l.Watch(FileSource(name), time.Millisecond, func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	})
+	defer stop()
+
+	if err := os.WriteFile(name, []byte("rules: [{name: a, rate: 1/s}, {name: b, rate: 2/s}]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for l.Keyed("b") == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("new rule never applied")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := os.WriteFile(name, []byte("rules: [{name: a, rate: bad}]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		mu.Lock()
+		n := len(errs)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("invalid configuration not reported")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	if l.Keyed("b") == nil {
+		t.Fatal("invalid configuration replaced the rules")
+	}
+}
@@ -0,0 +1,32 @@
+package ratelimiter
+
+import "context"
+
+// Limiter is the behavior shared by every limiter in this package, so
+// middleware, decorators and integrations can be written once against it.
+type Limiter interface {
+	// Allow is shorthand for AllowN(1).
+	Allow() bool
+	// AllowN reports whether n events may happen now, consuming capacity
+	// for them if so.
+	AllowN(n int) bool
+	// Wait is shorthand for WaitN(context.Background(), n).
+	Wait(n int) error
+	// WaitN blocks until n events may happen or ctx is done.
+	WaitN(ctx context.Context, n int) error
+}
+
+var (
+	_ Limiter = (*RateLimiter)(nil)
+	_ Limiter = (*SlidingWindowLimiter)(nil)
+	_ Limiter = (*FixedWindowLimiter)(nil)
+	_ Limiter = (*LeakyBucket)(nil)
+	_ Limiter = (*MultiLimiter)(nil)
+	_ Limiter = (*RedisLimiter)(nil)
+	_ Limiter = (*StoreLimiter)(nil)
+	_ Limiter = (*AtomicLimiter)(nil)
+	_ Limiter = sketchKey{}
+	_ Limiter = (*ScheduleLimiter)(nil)
+	_ Limiter = (*QuotaLimiter)(nil)
+	_ Limiter = (*ByteLimiter)(nil)
+)
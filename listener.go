@@ -0,0 +1,82 @@
+package ratelimiter
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// Listener is a net.Listener whose Accept is gated by a connection rate
+// and, optionally, a cap on open connections. Accept blocks rather than
+// accepting and dropping sockets, so excess connections queue in the
+// kernel's backlog until the server is ready for them.
+type Listener struct {
+	net.Listener
+	rate   *RateLimiter
+	conns  *ConcurrencyLimiter
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewListener wraps l. Accept takes one token from rate per connection and,
+// if conns is non-nil, holds one of its slots until the connection is
+// closed. Either limiter may be nil. conns should allow at least one
+// waiter, or Accept fails with ErrQueueFull while the cap is reached.
+func NewListener(l net.Listener, rate *RateLimiter, conns *ConcurrencyLimiter) *Listener {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Listener{Listener: l, rate: rate, conns: conns, ctx: ctx, cancel: cancel}
+}
+
+// Accept waits for a connection slot and a token, then accepts the next
+// connection. Closing the listener unblocks a waiting Accept with
+// net.ErrClosed.
+func (l *Listener) Accept() (net.Conn, error) {
+	release := func() {}
+	if l.conns != nil {
+		if err := l.conns.Acquire(l.ctx); err != nil {
+			return nil, l.acceptErr(err)
+		}
+		release = l.conns.Release
+	}
+	if l.rate != nil {
+		if err := l.rate.WaitN(l.ctx, 1); err != nil {
+			release()
+			return nil, l.acceptErr(err)
+		}
+	}
+	c, err := l.Listener.Accept()
+	if err != nil {
+		release()
+		return nil, err
+	}
+	if l.conns == nil {
+		return c, nil
+	}
+	return &listenerConn{Conn: c, release: release}, nil
+}
+
+// Close closes the underlying listener and unblocks any waiting Accept.
+func (l *Listener) Close() error {
+	l.cancel()
+	return l.Listener.Close()
+}
+
+func (l *Listener) acceptErr(err error) error {
+	if l.ctx.Err() != nil {
+		return net.ErrClosed
+	}
+	return err
+}
+
+// listenerConn releases its connection slot when closed.
+type listenerConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *listenerConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
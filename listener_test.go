@@ -0,0 +1,101 @@
+package ratelimiter
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestListenerCapsOpenConnections(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := NewConcurrencyLimiter(1, 1)
+	l := NewListener(inner, nil, conns)
+	defer l.Close()
+
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+
+	first, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted := make(chan net.Conn)
+	go func() {
+		c, _ := l.Accept()
+		accepted <- c
+	}()
+	select {
+	case <-accepted:
+		t.Fatal("expected Accept to wait while the connection cap is reached")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	first.Close()
+	first.Close()
+	second := <-accepted
+	if second == nil {
+		t.Fatal("expected Accept to succeed once a slot was released")
+	}
+	second.Close()
+	if got := conns.InFlight(); got != 0 {
+		t.Fatalf("expected all slots released, got %d in flight", got)
+	}
+}
+
+func TestListenerThrottlesAccept(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewListener(inner, New(10, 1, clk), nil)
+	defer l.Close()
+
+	for i := 0; i < 3; i++ {
+		c, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		a, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.Close()
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 200*time.Millisecond {
+		t.Fatalf("expected three accepts at 10/s to take 200ms, got %v", got)
+	}
+}
+
+func TestListenerCloseUnblocksAccept(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := &blockingClock{newFakeClock(time.Unix(0, 0)), make(chan struct{})}
+	defer close(clk.release)
+	rl := New(1, 1, clk)
+	rl.Allow()
+	l := NewListener(inner, rl, nil)
+
+	errc := make(chan error)
+	go func() {
+		_, err := l.Accept()
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	l.Close()
+	if err := <-errc; !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected net.ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package ratelimiter
+
+// EvictReason says why a KeyedLimiter dropped a key's limiter.
+type EvictReason int
+
+const (
+	// EvictedLRU means the key was the least recently used when the
+	// limiter reached its SetMaxKeys bound.
+	EvictedLRU EvictReason = iota
+	// EvictedIdle means the key's bucket was idle for longer than its TTL.
+	EvictedIdle
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictedLRU:
+		return "lru"
+	case EvictedIdle:
+		return "idle"
+	}
+	return "unknown"
+}
+
+// SetMaxKeys bounds the number of live limiters. Creating a limiter beyond
+// the bound evicts the least recently used one, so a flood of distinct
+// keys such as spoofed source IPs cannot exhaust memory. Zero or less
+// removes the bound, which is the default. Lowering the bound evicts
+// immediately. A sharded limiter splits the bound evenly between its
+// shards and evicts the least recently used key of the full shard.
+func (kl *KeyedLimiter) SetMaxKeys(n int) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	perShard := 0
+	if n > 0 {
+		perShard = (n + len(kl.shards) - 1) / len(kl.shards)
+	}
+	cfg := kl.updateConfig(func(c *keyedConfig) { c.maxKeys = perShard })
+	kl.eachShard(func(s *keyedShard) { s.enforceMaxKeysLocked(cfg) })
+}
+
+// OnEvict registers fn to be called with each evicted key. fn runs with a
+// lock of kl's held and must not call back into kl.
+func (kl *KeyedLimiter) OnEvict(fn func(key string, reason EvictReason)) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	kl.updateConfig(func(c *keyedConfig) { c.onEvict = fn })
+}
+
+// Evictions returns the number of keys evicted for reason so far.
+func (kl *KeyedLimiter) Evictions(reason EvictReason) uint64 {
+	var n uint64
+	kl.eachShard(func(s *keyedShard) { n += s.evictions[reason] })
+	return n
+}
+
+// touchLocked marks key as the most recently used.
+func (s *keyedShard) touchLocked(key string) {
+	if elem, ok := s.lruElems[key]; ok {
+		s.lru.MoveToFront(elem)
+		return
+	}
+	s.lruElems[key] = s.lru.PushFront(key)
+}
+
+// forgetLocked drops key's limiter, along with its tier. Its penalty
+// state outlives the limiter, like an override does, so evicting a key
+// neither ends its cooldown nor forgives its strikes.
+func (s *keyedShard) forgetLocked(key string) {
+	delete(s.limiters, key)
+	delete(s.keyTiers, key)
+	if elem, ok := s.lruElems[key]; ok {
+		s.lru.Remove(elem)
+		delete(s.lruElems, key)
+	}
+}
+
+// evictLocked drops key's limiter and reports the eviction.
+func (s *keyedShard) evictLocked(key string, reason EvictReason, cfg *keyedConfig) {
+	s.forgetLocked(key)
+	s.evictions[reason]++
+	if cfg.onEvict != nil {
+		cfg.onEvict(key, reason)
+	}
+}
+
+func (s *keyedShard) enforceMaxKeysLocked(cfg *keyedConfig) {
+	for cfg.maxKeys > 0 && len(s.limiters) > cfg.maxKeys {
+		s.evictLocked(s.lru.Back().Value.(string), EvictedLRU, cfg)
+	}
+}
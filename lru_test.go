@@ -0,0 +1,55 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaxKeysEvictsLRU(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(1, 1, clk)
+	var evicted []string
+	kl.OnEvict(func(key string, reason EvictReason) {
+		if reason != EvictedLRU {
+			t.Errorf("expected an LRU eviction, got %v", reason)
+		}
+		evicted = append(evicted, key)
+	})
+	kl.SetMaxKeys(2)
+
+	kl.Allow("a")
+	kl.Allow("b")
+	kl.Allow("a")
+	kl.Allow("c")
+	if kl.Len() != 2 || len(evicted) != 1 || evicted[0] != "b" {
+		t.Fatalf("expected b to be evicted, got %v with %d keys", evicted, kl.Len())
+	}
+	if kl.Allow("a") {
+		t.Fatal("expected the recently used key to keep its bucket")
+	}
+
+	kl.SetMaxKeys(1)
+	if kl.Len() != 1 || evicted[1] != "c" {
+		t.Fatalf("expected lowering the bound to evict c, got %v", evicted)
+	}
+	if got := kl.Evictions(EvictedLRU); got != 2 {
+		t.Fatalf("expected 2 LRU evictions, got %d", got)
+	}
+}
+
+func TestEvictionsCountIdle(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(1, 1, clk)
+	kl.SetMaxKeys(10)
+	kl.Get("a")
+	kl.Remove("b")
+	kl.ExpireIdle(0)
+	if got := kl.Evictions(EvictedIdle); got != 1 {
+		t.Fatalf("expected 1 idle eviction, got %d", got)
+	}
+	kl.Get("b")
+	kl.Get("c")
+	if kl.Len() != 2 || kl.shards[0].lru.Len() != 2 {
+		t.Fatalf("expected the LRU list to track live keys, got %d/%d", kl.Len(), kl.shards[0].lru.Len())
+	}
+}
@@ -0,0 +1,68 @@
+package ratelimiter
+
+import (
+	"context"
+	"time"
+)
+
+// MemcacheClient is the subset of a memcached client used by
+// MemcacheStore. cas is the "CAS unique" memcached returns from gets and
+// checks in cas; clients that hide it behind an item type can be adapted
+// by keeping the items returned by Gets.
+type MemcacheClient interface {
+	// Gets returns the value stored under key and its CAS unique, with
+	// found false if nothing is stored.
+	Gets(ctx context.Context, key string) (value []byte, cas uint64, found bool, err error)
+	// Add stores value under key only if nothing is stored there, and
+	// reports whether it did.
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// CompareAndSwap stores value under key only if its CAS unique is
+	// still cas, and reports whether it did.
+	CompareAndSwap(ctx context.Context, key string, value []byte, cas uint64, ttl time.Duration) (bool, error)
+	// Set stores value under key unconditionally.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// MemcacheStore is a Store in memcached, using CAS uniques as versions, so
+// that StoreLimiters in many processes can share buckets in a memcached
+// cluster already in place.
+//
+// Memcached may evict a bucket at any time, which grants its key a fresh
+// burst; the store suits limits where that is acceptable.
+type MemcacheStore struct {
+	client MemcacheClient
+	prefix string
+	ttl    time.Duration
+}
+
+// NewMemcacheStore returns a MemcacheStore keeping buckets under prefix
+// plus the limiter key. Buckets expire after ttl without writes, which
+// should be longer than a bucket takes to refill, burst/rate; zero means
+// they never expire.
+func NewMemcacheStore(client MemcacheClient, prefix string, ttl time.Duration) *MemcacheStore {
+	return &MemcacheStore{client: client, prefix: prefix, ttl: ttl}
+}
+
+func (m *MemcacheStore) Get(ctx context.Context, key string) (BucketState, bool, error) {
+	value, cas, found, err := m.client.Gets(ctx, m.prefix+key)
+	if err != nil || !found {
+		return BucketState{}, false, err
+	}
+	s, err := decodeBucket(value)
+	if err != nil {
+		return BucketState{}, false, err
+	}
+	s.Version = cas
+	return s, true, nil
+}
+
+func (m *MemcacheStore) Set(ctx context.Context, key string, s BucketState) error {
+	return m.client.Set(ctx, m.prefix+key, encodeBucket(s), m.ttl)
+}
+
+func (m *MemcacheStore) CompareAndSwap(ctx context.Context, key string, old, new BucketState) (bool, error) {
+	if old.Version == 0 {
+		return m.client.Add(ctx, m.prefix+key, encodeBucket(new), m.ttl)
+	}
+	return m.client.CompareAndSwap(ctx, m.prefix+key, encodeBucket(new), old.Version, m.ttl)
+}
@@ -0,0 +1,97 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMemcache is an in-memory MemcacheClient.
+type fakeMemcache struct {
+	mu    sync.Mutex
+	items map[string]fakeItem
+	cas   uint64
+}
+
+type fakeItem struct {
+	value []byte
+	cas   uint64
+	ttl   time.Duration
+}
+
+func newFakeMemcache() *fakeMemcache {
+	return &fakeMemcache{items: make(map[string]fakeItem)}
+}
+
+func (f *fakeMemcache) Gets(_ context.Context, key string) ([]byte, uint64, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	it, ok := f.items[key]
+	return it.value, it.cas, ok, nil
+}
+
+func (f *fakeMemcache) Add(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.items[key]; ok {
+		return false, nil
+	}
+	f.storeLocked(key, value, ttl)
+	return true, nil
+}
+
+func (f *fakeMemcache) CompareAndSwap(_ context.Context, key string, value []byte, cas uint64, ttl time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if it, ok := f.items[key]; !ok || it.cas != cas {
+		return false, nil
+	}
+	f.storeLocked(key, value, ttl)
+	return true, nil
+}
+
+func (f *fakeMemcache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.storeLocked(key, value, ttl)
+	return nil
+}
+
+func (f *fakeMemcache) storeLocked(key string, value []byte, ttl time.Duration) {
+	f.cas++
+	f.items[key] = fakeItem{value: value, cas: f.cas, ttl: ttl}
+}
+
+func TestMemcacheStore(t *testing.T) {
+	ctx := context.Background()
+	mc := newFakeMemcache()
+	s := NewMemcacheStore(mc, "rl:", time.Hour)
+
+	if ok, _ := s.CompareAndSwap(ctx, "k", BucketState{}, BucketState{Tokens: 1, UpdatedAt: time.Unix(5, 0)}); !ok {
+		t.Fatal("create-only swap failed on a missing key")
+	}
+	old, found, err := s.Get(ctx, "k")
+	if err != nil || !found || old.Tokens != 1 || !old.UpdatedAt.Equal(time.Unix(5, 0)) {
+		t.Fatalf("Get = %+v, %v, %v", old, found, err)
+	}
+	if it := mc.items["rl:k"]; it.ttl != time.Hour {
+		t.Fatalf("stored with ttl %v, want 1h", it.ttl)
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", BucketState{}, BucketState{Tokens: 2}); ok {
+		t.Fatal("create-only swap succeeded on an existing key")
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", old, BucketState{Tokens: 2}); !ok {
+		t.Fatal("swap with the current CAS unique failed")
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", old, BucketState{Tokens: 3}); ok {
+		t.Fatal("swap with a stale CAS unique succeeded")
+	}
+
+	clk := newFakeClock(time.Unix(0, 0))
+	a := NewStoreLimiter(s, "api", 1, 2, clk)
+	b := NewStoreLimiter(NewMemcacheStore(mc, "rl:", time.Hour), "api", 1, 2, clk)
+	if !a.Allow() || !b.Allow() || a.Allow() || b.Allow() {
+		t.Fatal("limiters on one memcached did not share a bucket")
+	}
+}
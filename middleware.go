@@ -0,0 +1,66 @@
+package ratelimiter
+
+import (
+	"net"
+	"net/http"
+)
+
+// Middleware applies a KeyedLimiter to HTTP requests. Each request takes
+// one token from the limiter for its key; requests that find no token are
+// answered with DeniedStatus and never reach the wrapped handler.
+type Middleware struct {
+	Limiter *KeyedLimiter
+
+	// KeyFunc returns the limiter key for a request. Defaults to ClientIP.
+	KeyFunc func(r *http.Request) string
+
+	// DeniedStatus is the status code written for throttled requests.
+	// Defaults to 429 Too Many Requests.
+	DeniedStatus int
+
+	// Skip, if set, exempts the requests for which it returns true, e.g.
+	// health checks or routes with their own limits.
+	Skip func(r *http.Request) bool
+
+	// Headers, if true, adds RateLimit-* headers (and Retry-After on
+	// throttled requests) to every limited response. See Status.SetHeaders.
+	Headers bool
+}
+
+// Handler wraps next with the middleware.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	keyFunc := m.KeyFunc
+	if keyFunc == nil {
+		keyFunc = ClientIP
+	}
+	status := m.DeniedStatus
+	if status == 0 {
+		status = http.StatusTooManyRequests
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.Skip != nil && m.Skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		res := m.Limiter.AllowNDetailed(keyFunc(r), 1)
+		if m.Headers {
+			res.SetHeaders(w.Header())
+		}
+		if !res.Allowed {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientIP returns the host part of r.RemoteAddr. It does not look at
+// X-Forwarded-For or similar headers, which clients can forge; servers
+// behind a trusted proxy should supply their own KeyFunc.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
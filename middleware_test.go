@@ -0,0 +1,52 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func serve(h http.Handler, remoteAddr, path string) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestMiddlewareLimitsPerClientIP(t *testing.T) {
+	kl := NewKeyed(Every(time.Second), 1, newFakeClock(time.Unix(0, 0)))
+	h := (&Middleware{Limiter: kl}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	if code := serve(h, "10.0.0.1:1234", "/"); code != http.StatusOK {
+		t.Fatalf("expected first request to pass, got %d", code)
+	}
+	if code := serve(h, "10.0.0.1:5678", "/"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected same IP on another port to be throttled, got %d", code)
+	}
+	if code := serve(h, "10.0.0.2:1234", "/"); code != http.StatusOK {
+		t.Fatalf("expected other IP to pass, got %d", code)
+	}
+}
+
+func TestMiddlewareOptions(t *testing.T) {
+	kl := NewKeyed(Every(time.Second), 1, newFakeClock(time.Unix(0, 0)))
+	m := &Middleware{
+		Limiter:      kl,
+		KeyFunc:      func(r *http.Request) string { return r.Header.Get("X-API-Key") },
+		DeniedStatus: http.StatusServiceUnavailable,
+		Skip:         func(r *http.Request) bool { return r.URL.Path == "/healthz" },
+	}
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve(h, "10.0.0.1:1", "/")
+	if code := serve(h, "10.0.0.2:1", "/"); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected custom key and status, got %d", code)
+	}
+	for i := 0; i < 3; i++ {
+		if code := serve(h, "10.0.0.1:1", "/healthz"); code != http.StatusOK {
+			t.Fatalf("expected skipped route to bypass limiting, got %d", code)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimiter
+
+import "time"
+
+// NanoClock is a Clock that can also be read as a monotonic nanosecond
+// counter, for callers that want to measure intervals without comparing
+// time.Time values.
+type NanoClock interface {
+	TimerClock
+	// Nanotime returns the nanoseconds elapsed since a fixed origin. It
+	// never goes backwards.
+	Nanotime() int64
+}
+
+// monotonicClock reads only the runtime's monotonic clock, from which it
+// derives the wall times it reports.
+type monotonicClock struct {
+	base time.Time // with a monotonic reading
+}
+
+// NewMonotonicClock returns a clock driven only by the runtime's monotonic
+// counter. Its Now is the wall time at creation plus the monotonic time
+// elapsed since, so it never jumps with NTP steps or manual changes to the
+// system clock, even once a time has lost its monotonic reading by being
+// persisted, as State.UpdatedAt is, or rounded. In exchange it drifts from
+// the wall clock by whatever corrections the system clock receives.
+//
+// A limiter keeps time internally as integer nanoseconds since its
+// creation and reads them from a NanoClock's Nanotime, so with this clock
+// its arithmetic never involves wall time.
+func NewMonotonicClock() NanoClock {
+	return monotonicClock{base: time.Now()}
+}
+
+func (c monotonicClock) Nanotime() int64 {
+	return int64(time.Since(c.base))
+}
+
+func (c monotonicClock) Now() time.Time {
+	return c.base.Add(time.Duration(c.Nanotime()))
+}
+
+func (monotonicClock) Sleep(d time.Duration)          { time.Sleep(d) }
+func (monotonicClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+// setEpoch makes the clock's current time the limiter's epoch.
+func (rl *RateLimiter) setEpoch() {
+	rl.epoch = rl.clock.Now()
+	rl.nano, _ = rl.clock.(NanoClock)
+	if rl.nano != nil {
+		rl.nanoEpoch = rl.nano.Nanotime()
+	}
+}
+
+// now returns the current time of the limiter's clock. On a NanoClock it is
+// read from Nanotime and placed relative to the epoch, so that the
+// nanoseconds the limiter computes with come from the counter unchanged.
+func (rl *RateLimiter) now() time.Time {
+	if rl.nano != nil {
+		return rl.timeAt(rl.nano.Nanotime() - rl.nanoEpoch)
+	}
+	return rl.clock.Now()
+}
+
+// now returns the current time of the KeyedLimiter's clock, read as
+// RateLimiter.now reads it, for the times it compares with its limiters'.
+func (kl *KeyedLimiter) now() time.Time {
+	if kl.nano != nil {
+		return kl.epoch.Add(time.Duration(kl.nano.Nanotime() - kl.nanoEpoch))
+	}
+	return kl.clock.Now()
+}
@@ -0,0 +1,73 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonotonicClock(t *testing.T) {
+	clk := NewMonotonicClock()
+	n0, t0 := clk.Nanotime(), clk.Now()
+	time.Sleep(2 * time.Millisecond)
+	n1, t1 := clk.Nanotime(), clk.Now()
+	if n1-n0 < int64(2*time.Millisecond) {
+		t.Fatalf("Nanotime advanced %v across a 2ms sleep", time.Duration(n1-n0))
+	}
+	// Without monotonic readings, the times still compare as the counter
+	// does.
+	if d := t1.Round(0).Sub(t0.Round(0)); d < 2*time.Millisecond || d > time.Duration(n1-n0)+time.Millisecond {
+		t.Fatalf("wall times %v apart, counter %v", d, time.Duration(n1-n0))
+	}
+
+	rl := New(1000, 1, clk)
+	if !rl.Allow() || rl.Allow() {
+		t.Fatal("limiter on the monotonic clock did not admit exactly its burst")
+	}
+	if err := rl.Wait(1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// steppedClock is a NanoClock whose wall time runs backwards, an hour for
+// every second its counter advances, as if stepped back again and again.
+type steppedClock struct {
+	*fakeClock
+	ns int64
+}
+
+func (c *steppedClock) Nanotime() int64 { return c.ns }
+
+func (c *steppedClock) Now() time.Time {
+	return c.fakeClock.Now().Add(-time.Duration(c.ns) * 3600)
+}
+
+func (c *steppedClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+func TestLimiterReadsNanotime(t *testing.T) {
+	clk := &steppedClock{fakeClock: newFakeClock(time.Unix(0, 0))}
+	rl := New(10, 1, clk)
+	rl.Allow()
+	clk.ns += int64(100 * time.Millisecond)
+	if tok := rl.AvailableTokens(); tok != 1 {
+		t.Fatalf("%v tokens 100ms later by Nanotime, want 1", tok)
+	}
+	if !rl.Allow() || rl.Allow() {
+		t.Fatal("limiter did not refill by Nanotime")
+	}
+}
+
+func TestReservationReadsNanotime(t *testing.T) {
+	clk := &steppedClock{fakeClock: newFakeClock(time.Unix(0, 0))}
+	rl := New(1, 1, clk)
+	rl.Allow()
+	r := rl.Reserve()
+	clk.fakeClock.Sleep(-time.Hour) // the wall clock steps back; the counter does not
+	if d := r.Delay(); d != time.Second {
+		t.Fatalf("Delay after a wall step = %v, want 1s", d)
+	}
+	clk.ns += int64(500 * time.Millisecond)
+	r.Cancel()
+	if tok := rl.AvailableTokens(); tok != 0.5 {
+		t.Fatalf("%v tokens after canceling, want 0.5", tok)
+	}
+}
@@ -0,0 +1,134 @@
+package ratelimiter
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MultiLimiter combines independent limiters, such as 10/s and 1000/hour,
+// and admits an event only when every one of them does. Tokens taken from
+// some limiters for an event that another denies are refunded, so a denial
+// never leaks capacity.
+type MultiLimiter struct {
+	mu       sync.Mutex
+	limiters []*RateLimiter
+	clock    Clock
+}
+
+// NewMulti returns a MultiLimiter over limiters, reading time from the
+// first one's clock. A MultiLimiter with no limiters admits everything.
+func NewMulti(limiters ...*RateLimiter) *MultiLimiter {
+	m := &MultiLimiter{limiters: limiters, clock: realClock{}}
+	if len(limiters) > 0 {
+		m.clock = limiters[0].clock
+	}
+	return m
+}
+
+// NewLayered returns a MultiLimiter for layered limits written as
+// comma-separated rates in the syntax of ParseRate, such as
+// "10/s, 300/m, 5000/h". Each layer is a limiter named after its rate, so
+// that AllowNLayer can report which one denied.
+func NewLayered(spec string, clk Clock) (*MultiLimiter, error) {
+	var limiters []*RateLimiter
+	for _, layer := range strings.Split(spec, ",") {
+		layer = strings.TrimSpace(layer)
+		rate, burst, err := ParseRate(layer)
+		if err != nil {
+			return nil, err
+		}
+		rl := New(rate, burst, clk)
+		rl.name = layer
+		limiters = append(limiters, rl)
+	}
+	return NewMulti(limiters...), nil
+}
+
+// Limiters returns the combined limiters.
+func (m *MultiLimiter) Limiters() []*RateLimiter {
+	return m.limiters
+}
+
+func (m *MultiLimiter) Allow() bool {
+	return m.AllowN(1)
+}
+
+// AllowN reports whether every limiter has n tokens now, taking them from
+// all of them if so and from none otherwise.
+func (m *MultiLimiter) AllowN(n int) bool {
+	ok, _ := m.AllowNLayer(n)
+	return ok
+}
+
+// AllowNLayer is AllowN that also returns, if the event is denied, the
+// first limiter that denied it.
+func (m *MultiLimiter) AllowNLayer(n int) (bool, *RateLimiter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, denied := m.reserve(m.clock.Now(), n, 0)
+	return denied == nil, denied
+}
+
+// Wait is shorthand for WaitN(context.Background(), n).
+func (m *MultiLimiter) Wait(n int) error {
+	return m.WaitN(context.Background(), n)
+}
+
+// WaitN blocks until every limiter can provide n tokens or ctx is done. If
+// ctx is done first, the tokens are returned to all limiters.
+func (m *MultiLimiter) WaitN(ctx context.Context, n int) error {
+	select {
+	case <-ctx.Done():
+		return ctxError(n, ctx.Err(), 0)
+	default:
+	}
+	for _, l := range m.limiters {
+		if burst := l.Burst(); n > burst && l.Rate() != InfiniteRate {
+			return burstError(n, burst)
+		}
+	}
+
+	m.mu.Lock()
+	t := m.clock.Now()
+	rs, denied := m.reserve(t, n, InfiniteDuration)
+	m.mu.Unlock()
+	if denied != nil {
+		return neverError(n)
+	}
+	var delay time.Duration
+	for _, r := range rs {
+		if d := r.DelayFrom(t); d > delay {
+			delay = d
+		}
+	}
+	if delay <= 0 {
+		return nil
+	}
+	if err := sleepCtx(ctx, m.clock, delay); err != nil {
+		now := m.clock.Now()
+		for _, r := range rs {
+			r.CancelAt(now)
+		}
+		return ctxError(n, err, t.Add(delay).Sub(now))
+	}
+	return nil
+}
+
+// reserve reserves n tokens from each limiter, or from none if any cannot
+// provide them within maxWait, in which case it returns that limiter.
+func (m *MultiLimiter) reserve(t time.Time, n int, maxWait time.Duration) ([]Reservation, *RateLimiter) {
+	rs := make([]Reservation, 0, len(m.limiters))
+	for _, l := range m.limiters {
+		r := l.reserve(t, float64(n), maxWait)
+		if !r.ok {
+			for i := len(rs) - 1; i >= 0; i-- {
+				rs[i].CancelAt(t)
+			}
+			return nil, l
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
@@ -0,0 +1,97 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMultiRefundsOnDeny(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	perSecond := New(10, 10, clk)
+	perHour := New(Every(time.Hour), 2, clk)
+	m := NewMulti(perSecond, perHour)
+
+	if !m.AllowN(2) {
+		t.Fatal("expected first events to be allowed")
+	}
+	if m.Allow() {
+		t.Fatal("expected the hourly limit to deny")
+	}
+	if got := perSecond.AvailableTokens(); got != 8 {
+		t.Fatalf("expected the denied event to be refunded, got %v tokens", got)
+	}
+}
+
+func TestMultiWaitsForSlowest(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	fast := New(10, 1, clk)
+	slow := New(1, 1, clk)
+	m := NewMulti(fast, slow)
+
+	for i := 0; i < 2; i++ {
+		if err := m.Wait(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != time.Second {
+		t.Fatalf("expected to wait for the slowest limiter, got %v", got)
+	}
+	if err := m.Wait(2); err == nil {
+		t.Fatal("expected Wait above a burst to fail")
+	}
+}
+
+func TestMultiWaitCanceledRefunds(t *testing.T) {
+	clk := &blockingClock{newFakeClock(time.Unix(0, 0)), make(chan struct{})}
+	defer close(clk.release)
+	a := New(1, 1, clk)
+	b := New(1, 1, clk)
+	b.Allow()
+	m := NewMulti(a, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := m.WaitN(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if got := a.AvailableTokens(); got != 1 {
+		t.Fatalf("expected a canceled wait to refund, got %v tokens", got)
+	}
+}
+
+func TestMultiEmptyAllowsAll(t *testing.T) {
+	if !NewMulti().AllowN(1000) {
+		t.Fatal("expected an empty MultiLimiter to allow")
+	}
+}
+
+func TestNewLayered(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	m, err := NewLayered("2/s, 3/m", clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := m.AllowNLayer(2); !ok {
+		t.Fatal("first two events denied")
+	}
+	if ok, layer := m.AllowNLayer(1); ok || layer.Name() != "2/s" {
+		t.Fatalf("third event: ok=%v layer=%v, want denied by 2/s", ok, layer.Name())
+	}
+	clk.Sleep(time.Second)
+	if !m.Allow() {
+		t.Fatal("event denied after the per-second layer refilled")
+	}
+	clk.Sleep(time.Second)
+	if ok, layer := m.AllowNLayer(1); ok || layer.Name() != "3/m" {
+		t.Fatalf("fourth event: ok=%v, want denied by 3/m", ok)
+	}
+	if got := m.Limiters()[0].AvailableTokens(); got != 2 {
+		t.Fatalf("per-second layer charged for a denied event: %v tokens", got)
+	}
+
+	if _, err := NewLayered("10/s, nonsense", clk); !errors.Is(err, ErrInvalidLimit) {
+		t.Fatalf("NewLayered with a bad layer: %v, want ErrInvalidLimit", err)
+	}
+}
@@ -0,0 +1,149 @@
+package ratelimiter
+
+import "time"
+
+// Decision describes one admission decision made by a limiter.
+type Decision struct {
+	Time    time.Time
+	Key     string // set for limiters managed by a KeyedLimiter
+	N       int
+	Allowed bool
+
+	// Waited is true for decisions made by Wait and WaitN.
+	Waited bool
+
+	// Delay is how long the caller waited (Wait, WaitN) or must wait before
+	// acting (ReserveN).
+	Delay time.Duration
+
+	// Remaining is the number of tokens left right after the decision. It
+	// is negative when tokens have been reserved ahead of time.
+	Remaining float64
+
+	// Shadowed is true when a limiter in shadow mode let through a request
+	// it would otherwise have denied or delayed. Allowed and Delay then
+	// describe the decision that would have been enforced.
+	Shadowed bool
+}
+
+// Observer is notified of a limiter's decisions. Observers are called
+// synchronously on the caller's goroutine, without the limiter's lock held,
+// so they must be fast and safe for concurrent use.
+type Observer interface {
+	Observe(d Decision)
+}
+
+// WaitObserver is implemented by Observers that also want to know when a
+// caller starts blocking in Wait or WaitN. ObserveWaitStart receives the
+// planned delay; Observe is called as usual once the wait ends.
+type WaitObserver interface {
+	Observer
+	ObserveWaitStart(d Decision)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(d Decision)
+
+func (f ObserverFunc) Observe(d Decision) { f(d) }
+
+// AddObserver registers o to be notified of every decision made by rl.
+func (rl *RateLimiter) AddObserver(o Observer) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	// Copy on write so observe can iterate without holding the lock.
+	observers := make([]Observer, len(rl.observers), len(rl.observers)+1)
+	copy(observers, rl.observers)
+	rl.observers = append(observers, o)
+}
+
+func (rl *RateLimiter) observe(d Decision) {
+	rl.counters.record(d)
+	rl.mu.Lock()
+	observers := rl.observers
+	rl.mu.Unlock()
+	notify(observers, d)
+}
+
+func notify(observers []Observer, d Decision) {
+	for _, o := range observers {
+		o.Observe(d)
+	}
+}
+
+func (rl *RateLimiter) observeWaitStart(d Decision) {
+	rl.mu.Lock()
+	observers := rl.observers
+	rl.mu.Unlock()
+	for _, o := range observers {
+		if wo, ok := o.(WaitObserver); ok {
+			wo.ObserveWaitStart(d)
+		}
+	}
+}
+
+// Callbacks is an Observer that dispatches decisions to per-event
+// functions; nil functions are skipped. A successful wait fires OnWaitStart
+// when the caller starts blocking, then OnAllow and OnWaitEnd.
+type Callbacks struct {
+	OnAllow     func(d Decision)
+	OnDeny      func(d Decision)
+	OnWaitStart func(d Decision)
+	OnWaitEnd   func(d Decision)
+}
+
+func (c Callbacks) Observe(d Decision) {
+	if d.Allowed {
+		if c.OnAllow != nil {
+			c.OnAllow(d)
+		}
+	} else if c.OnDeny != nil {
+		c.OnDeny(d)
+	}
+	if d.Waited && c.OnWaitEnd != nil {
+		c.OnWaitEnd(d)
+	}
+}
+
+func (c Callbacks) ObserveWaitStart(d Decision) {
+	if c.OnWaitStart != nil {
+		c.OnWaitStart(d)
+	}
+}
+
+// AddObserver registers o with the limiter of every key, current and
+// future. Decisions reported to o carry the key.
+func (kl *KeyedLimiter) AddObserver(o Observer) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	kl.addObserverLocked(o)
+}
+
+// addObserverLocked is AddObserver for callers holding kl.mu.
+func (kl *KeyedLimiter) addObserverLocked(o Observer) {
+	kl.updateConfig(func(c *keyedConfig) {
+		c.observers = append(c.observers[:len(c.observers):len(c.observers)], o)
+	})
+	kl.eachShard(func(s *keyedShard) {
+		for key, rl := range s.limiters {
+			rl.AddObserver(keyedObserver{key, o})
+		}
+	})
+}
+
+// keyedObserver stamps decisions with the key of the limiter they came from.
+type keyedObserver struct {
+	key string
+	o   Observer
+}
+
+func (k keyedObserver) Observe(d Decision) {
+	d.Key = k.key
+	k.o.Observe(d)
+}
+
+func (k keyedObserver) ObserveWaitStart(d Decision) {
+	if wo, ok := k.o.(WaitObserver); ok {
+		d.Key = k.key
+		wo.ObserveWaitStart(d)
+	}
+}
@@ -0,0 +1,98 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	mu        sync.Mutex
+	decisions []Decision
+}
+
+func (r *recorder) Observe(d Decision) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decisions = append(r.decisions, d)
+}
+
+func TestObserverDecisions(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(Every(100*time.Millisecond), 1, clk)
+	rec := &recorder{}
+	rl.AddObserver(rec)
+
+	rl.Allow()
+	rl.Allow()
+	rl.WaitN(context.Background(), 1)
+
+	want := []Decision{
+		{Time: time.Unix(0, 0), N: 1, Allowed: true, Remaining: 0},
+		{Time: time.Unix(0, 0), N: 1, Allowed: false, Remaining: 0},
+		{Time: time.Unix(0, 0), N: 1, Allowed: true, Waited: true, Delay: 100 * time.Millisecond, Remaining: -1},
+	}
+	if len(rec.decisions) != len(want) {
+		t.Fatalf("expected %d decisions, got %d", len(want), len(rec.decisions))
+	}
+	for i, d := range rec.decisions {
+		if d != want[i] {
+			t.Errorf("decision %d = %+v, want %+v", i, d, want[i])
+		}
+	}
+}
+
+func TestKeyedObserver(t *testing.T) {
+	kl := NewKeyed(Every(time.Second), 1, newFakeClock(time.Unix(0, 0)))
+	kl.Allow("before")
+	rec := &recorder{}
+	kl.AddObserver(rec)
+	kl.Allow("before")
+	kl.Allow("after")
+
+	if len(rec.decisions) != 2 {
+		t.Fatalf("expected 2 decisions, got %d", len(rec.decisions))
+	}
+	if d := rec.decisions[0]; d.Key != "before" || d.Allowed {
+		t.Errorf("unexpected decision for existing key: %+v", d)
+	}
+	if d := rec.decisions[1]; d.Key != "after" || !d.Allowed {
+		t.Errorf("unexpected decision for new key: %+v", d)
+	}
+}
+
+func TestCallbacks(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(Every(100*time.Millisecond), 1, clk)
+	var events []string
+	record := func(name string) func(Decision) {
+		return func(d Decision) { events = append(events, name+":"+d.Key+":"+d.Delay.String()) }
+	}
+	kl.AddObserver(Callbacks{
+		OnAllow:     record("allow"),
+		OnDeny:      record("deny"),
+		OnWaitStart: record("start"),
+		OnWaitEnd:   record("end"),
+	})
+
+	kl.Allow("k")
+	kl.Allow("k")
+	kl.Wait(context.Background(), "k", 1)
+
+	want := []string{
+		"allow:k:0s",
+		"deny:k:0s",
+		"start:k:100ms",
+		"allow:k:100ms",
+		"end:k:100ms",
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected events %q, got %q", want, events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d = %q, want %q", i, events[i], want[i])
+		}
+	}
+}
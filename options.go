@@ -0,0 +1,118 @@
+package ratelimiter
+
+import "time"
+
+// Option configures a RateLimiter built by NewWithOptions.
+type Option func(*limiterOptions)
+
+type limiterOptions struct {
+	burst      int
+	clock      Clock
+	tokens     float64
+	hasTokens  bool
+	name       string
+	observers  []Observer
+	maxWaiting int
+	maxFuture  time.Duration
+	maxCatchUp time.Duration
+	jitter     float64
+	shadow     bool
+	smooth     bool
+
+	refillInterval time.Duration
+	refillAlign    WindowAlignment
+	warmupFraction float64
+	warmupLength   time.Duration
+	warmupIdle     time.Duration
+}
+
+// WithBurst sets the bucket size. The default is 1.
+func WithBurst(burst int) Option {
+	return func(o *limiterOptions) { o.burst = burst }
+}
+
+// WithClock sets the clock the limiter reads time from. The default is the
+// system clock.
+func WithClock(clk Clock) Option {
+	return func(o *limiterOptions) { o.clock = clk }
+}
+
+// WithInitialTokens sets how many tokens the bucket starts with. The
+// default is a full bucket.
+func WithInitialTokens(tokens float64) Option {
+	return func(o *limiterOptions) {
+		o.tokens = tokens
+		o.hasTokens = true
+	}
+}
+
+// WithName names the limiter, for logs and metrics.
+func WithName(name string) Option {
+	return func(o *limiterOptions) { o.name = name }
+}
+
+// WithObserver registers an observer, as AddObserver does. Pass an
+// observer from SlogObserver or a metrics integration to instrument the
+// limiter from the start.
+func WithObserver(obs Observer) Option {
+	return func(o *limiterOptions) { o.observers = append(o.observers, obs) }
+}
+
+// WithMaxWaiting bounds the WaitN queue, as SetMaxWaiting does.
+func WithMaxWaiting(n int) Option {
+	return func(o *limiterOptions) { o.maxWaiting = n }
+}
+
+// WithMaxFutureReserve bounds how far ahead reservations may act, as
+// SetMaxFutureReserve does.
+func WithMaxFutureReserve(d time.Duration) Option {
+	return func(o *limiterOptions) { o.maxFuture = d }
+}
+
+// WithJitter randomizes WaitN delays by up to fraction, as SetJitter
+// does.
+func WithJitter(fraction float64) Option {
+	return func(o *limiterOptions) { o.jitter = fraction }
+}
+
+// WithShadow starts the limiter in shadow mode, as SetShadow(true) does.
+func WithShadow() Option {
+	return func(o *limiterOptions) { o.shadow = true }
+}
+
+// NewWithOptions returns a limiter allowing rate events per second,
+// configured by opts. New features are added as options, so callers are
+// not broken as the limiter grows.
+func NewWithOptions(rate Rate, opts ...Option) *RateLimiter {
+	o := limiterOptions{burst: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	rl := New(rate, o.burst, o.clock)
+	if o.hasTokens {
+		rl.tokens = o.tokens
+	}
+	rl.catchUp = int64(max(o.maxCatchUp, 0))
+	rl.publishLocked()
+	rl.name = o.name
+	rl.observers = o.observers
+	rl.maxQueue = o.maxWaiting
+	rl.maxFuture = max(o.maxFuture, 0)
+	rl.jitter = max(o.jitter, 0)
+	rl.shadow = o.shadow
+	if o.smooth {
+		rl.SetSmooth(true)
+	}
+	if o.warmupLength > 0 {
+		rl.SetWarmup(o.warmupFraction, o.warmupLength, o.warmupIdle)
+	}
+	if o.refillInterval > 0 {
+		rl.SetRefillInterval(o.refillInterval, o.refillAlign)
+	}
+	return rl
+}
+
+// Name returns the name given with WithName, or "".
+func (rl *RateLimiter) Name() string {
+	return rl.name
+}
@@ -0,0 +1,39 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	var decisions int
+	rl := NewWithOptions(10,
+		WithBurst(5),
+		WithClock(clk),
+		WithInitialTokens(2),
+		WithName("api"),
+		WithObserver(ObserverFunc(func(Decision) { decisions++ })),
+		WithMaxWaiting(3),
+	)
+	if rl.Burst() != 5 || rl.Rate() != 10 || rl.Name() != "api" {
+		t.Fatalf("unexpected limiter: burst %d rate %v name %q", rl.Burst(), rl.Rate(), rl.Name())
+	}
+	if got := rl.AvailableTokens(); got != 2 {
+		t.Fatalf("expected 2 initial tokens, got %v", got)
+	}
+	rl.Allow()
+	if decisions != 1 {
+		t.Fatalf("expected the observer to be registered, got %d decisions", decisions)
+	}
+	if rl.maxQueue != 3 {
+		t.Fatalf("expected max waiting 3, got %d", rl.maxQueue)
+	}
+}
+
+func TestNewWithOptionsDefaults(t *testing.T) {
+	rl := NewWithOptions(1)
+	if rl.Burst() != 1 || rl.AvailableTokens() != 1 {
+		t.Fatalf("expected a full bucket of 1, got burst %d tokens %v", rl.Burst(), rl.AvailableTokens())
+	}
+}
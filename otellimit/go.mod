@@ -0,0 +1,21 @@
+module github.com/navrang-singh/ratelimiter/otellimit
+
+go 1.22.2
+
+require (
+	github.com/navrang-singh/ratelimiter v0.0.0
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/metric v1.28.0
+	go.opentelemetry.io/otel/sdk v1.28.0
+	go.opentelemetry.io/otel/sdk/metric v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+)
+
+replace github.com/navrang-singh/ratelimiter => ../
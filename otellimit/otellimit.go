@@ -0,0 +1,134 @@
+// Package otellimit instruments ratelimiter limiters with OpenTelemetry
+// metrics and traces.
+package otellimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/navrang-singh/ratelimiter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const scope = "github.com/navrang-singh/ratelimiter/otellimit"
+
+type config struct {
+	meterProvider  metric.MeterProvider
+	tracerProvider trace.TracerProvider
+}
+
+// Option configures Instrument.
+type Option func(*config)
+
+// WithMeterProvider sets the MeterProvider. Defaults to the global one.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) { c.meterProvider = mp }
+}
+
+// WithTracerProvider sets the TracerProvider. Defaults to the global one.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) { c.tracerProvider = tp }
+}
+
+// Limiter is a RateLimiter instrumented with OpenTelemetry.
+//
+// Every decision of the underlying limiter, whether made through Limiter or
+// directly, is counted in the ratelimiter.decisions counter and, for waits,
+// the ratelimiter.wait.duration histogram. The context-aware methods of
+// Limiter additionally record span events and attributes on the span in ctx
+// when a request is delayed or rejected.
+type Limiter struct {
+	*ratelimiter.RateLimiter
+	attrs  attribute.Set
+	tracer trace.Tracer
+}
+
+var _ ratelimiter.Limiter = (*Limiter)(nil)
+
+// Instrument returns rl instrumented under the given limiter name.
+func Instrument(rl *ratelimiter.RateLimiter, name string, opts ...Option) (*Limiter, error) {
+	cfg := config{
+		meterProvider:  otel.GetMeterProvider(),
+		tracerProvider: otel.GetTracerProvider(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	meter := cfg.meterProvider.Meter(scope)
+	decisions, err := meter.Int64Counter("ratelimiter.decisions",
+		metric.WithDescription("Admission decisions made by the limiter."))
+	if err != nil {
+		return nil, err
+	}
+	waits, err := meter.Float64Histogram("ratelimiter.wait.duration",
+		metric.WithDescription("Time callers spent blocked in Wait."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Limiter{
+		RateLimiter: rl,
+		attrs:       attribute.NewSet(attribute.String("ratelimiter.name", name)),
+		tracer:      cfg.tracerProvider.Tracer(scope),
+	}
+	allowed := metric.WithAttributeSet(attribute.NewSet(
+		attribute.String("ratelimiter.name", name), attribute.Bool("ratelimiter.allowed", true)))
+	denied := metric.WithAttributeSet(attribute.NewSet(
+		attribute.String("ratelimiter.name", name), attribute.Bool("ratelimiter.allowed", false)))
+	named := metric.WithAttributeSet(l.attrs)
+	rl.AddObserver(ratelimiter.ObserverFunc(func(d ratelimiter.Decision) {
+		ctx := context.Background()
+		if d.Allowed {
+			decisions.Add(ctx, 1, allowed)
+		} else {
+			decisions.Add(ctx, 1, denied)
+		}
+		if d.Waited {
+			waits.Record(ctx, d.Delay.Seconds(), named)
+		}
+	}))
+	return l, nil
+}
+
+// AllowNContext is like AllowN and adds a "ratelimiter.rejected" event to
+// the span in ctx when the request is denied.
+func (l *Limiter) AllowNContext(ctx context.Context, n int) bool {
+	if l.RateLimiter.AllowN(n) {
+		return true
+	}
+	trace.SpanFromContext(ctx).AddEvent("ratelimiter.rejected", trace.WithAttributes(
+		append(l.attrs.ToSlice(), attribute.Int("ratelimiter.n", n))...))
+	return false
+}
+
+// WaitN is like RateLimiter.WaitN but runs in a "ratelimiter.Wait" child
+// span of the span in ctx, recording how long it waited. The child span's
+// context is passed down, so trace context propagates through the wait.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	ctx, span := l.tracer.Start(ctx, "ratelimiter.Wait", trace.WithAttributes(
+		append(l.attrs.ToSlice(), attribute.Int("ratelimiter.n", n))...))
+	defer span.End()
+
+	start := l.now()
+	err := l.RateLimiter.WaitN(ctx, n)
+	if delay := l.now().Sub(start); delay > 0 {
+		span.SetAttributes(attribute.Float64("ratelimiter.delay_seconds", delay.Seconds()))
+		span.AddEvent("ratelimiter.delayed")
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "rate limited")
+	}
+	return err
+}
+
+// now reads the current time from the limiter's clock.
+func (l *Limiter) now() time.Time {
+	return l.Status().At
+}
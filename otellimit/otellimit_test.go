@@ -0,0 +1,105 @@
+package otellimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/navrang-singh/ratelimiter"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type fakeClock struct {
+	mu   sync.Mutex
+	time time.Time
+}
+
+func (fc *fakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.time
+}
+
+func (fc *fakeClock) Sleep(d time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.time = fc.time.Add(d)
+}
+
+func TestInstrument(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+
+	rl := ratelimiter.New(ratelimiter.Every(100*time.Millisecond), 1, &fakeClock{})
+	l, err := Instrument(rl, "api", WithMeterProvider(mp), WithTracerProvider(tp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	l.Allow()
+	if l.AllowNContext(ctx, 1) {
+		t.Fatalf("expected second token to be denied")
+	}
+	if err := l.WaitN(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("expected wait and parent spans, got %d", len(ended))
+	}
+	wait, req := ended[0], ended[1]
+	if wait.Name() != "ratelimiter.Wait" || wait.Parent().SpanID() != req.SpanContext().SpanID() {
+		t.Fatalf("expected wait span to be a child of the request span")
+	}
+	if !hasAttr(wait.Attributes(), attribute.Float64("ratelimiter.delay_seconds", 0.1)) {
+		t.Fatalf("expected delay attribute on wait span, got %v", wait.Attributes())
+	}
+	if events := req.Events(); len(events) != 1 || events[0].Name != "ratelimiter.rejected" {
+		t.Fatalf("expected rejection event on request span, got %v", events)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[bool]int64{}
+	var waits uint64
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			for _, dp := range data.DataPoints {
+				v, _ := dp.Attributes.Value("ratelimiter.allowed")
+				counts[v.AsBool()] += dp.Value
+			}
+		case metricdata.Histogram[float64]:
+			for _, dp := range data.DataPoints {
+				waits += dp.Count
+			}
+		}
+	}
+	if counts[true] != 2 || counts[false] != 1 {
+		t.Fatalf("expected 2 allowed and 1 denied, got %v", counts)
+	}
+	if waits != 1 {
+		t.Fatalf("expected 1 recorded wait, got %d", waits)
+	}
+}
+
+func hasAttr(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, a := range attrs {
+		if a == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package ratelimiter
+
+import (
+	"sync"
+	"time"
+)
+
+// Pacer spaces events evenly at a fixed rate, with the Take semantics of
+// go.uber.org/ratelimit: each call blocks until the next slot and returns
+// the time it fired. Unlike a token bucket, a Pacer never releases a burst
+// unless it is given slack.
+type Pacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	slack    time.Duration
+	last     time.Time
+	clock    Clock
+}
+
+// NewPacer returns a Pacer releasing rate events per second. slack is the
+// number of slots an idle caller may bank: after a pause, up to slack extra
+// calls fire immediately to catch up before pacing resumes. Zero slack
+// spaces every call strictly.
+func NewPacer(rate Rate, slack int, clk Clock) *Pacer {
+	if clk == nil {
+		clk = realClock{}
+	}
+	interval := rate.durationFromTokens(1)
+	return &Pacer{
+		interval: interval,
+		slack:    time.Duration(slack) * interval,
+		clock:    clk,
+	}
+}
+
+// Take blocks until the next slot and returns the time it fired.
+func (p *Pacer) Take() time.Time {
+	p.mu.Lock()
+	now := p.clock.Now()
+	if p.last.IsZero() {
+		p.last = now
+		p.mu.Unlock()
+		return now
+	}
+	slot := p.last.Add(p.interval)
+	if earliest := now.Add(-p.slack); slot.Before(earliest) {
+		slot = earliest
+	}
+	p.last = slot
+	p.mu.Unlock()
+
+	if delay := slot.Sub(now); delay > 0 {
+		p.clock.Sleep(delay)
+		return slot
+	}
+	return now
+}
@@ -0,0 +1,42 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPacerSpacesEvenly(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	p := NewPacer(10, 0, clk)
+
+	prev := p.Take()
+	for i := 0; i < 5; i++ {
+		next := p.Take()
+		if got := next.Sub(prev); got != 100*time.Millisecond {
+			t.Fatalf("take %d: expected 100ms spacing, got %v", i, got)
+		}
+		prev = next
+	}
+	if !prev.Equal(clk.Now()) {
+		t.Fatalf("expected Take to return when it fired, got %v at %v", prev, clk.Now())
+	}
+}
+
+func TestPacerSlack(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	p := NewPacer(10, 2, clk)
+	p.Take()
+	clk.Sleep(time.Second)
+
+	start := clk.Now()
+	for i := 0; i < 3; i++ {
+		p.Take()
+	}
+	if got := clk.Now().Sub(start); got != 0 {
+		t.Fatalf("expected banked slots to fire immediately, took %v", got)
+	}
+	p.Take()
+	if got := clk.Now().Sub(start); got != 100*time.Millisecond {
+		t.Fatalf("expected pacing to resume after the slack, got %v", got)
+	}
+}
@@ -0,0 +1,77 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var rateUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "second": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute,
+	"h": time.Hour, "hour": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour,
+}
+
+// ParseRate parses a limit such as "100/m", "5r/s burst=10", "0.5/s" or
+// "1000/10m", returning its rate and burst. The count may carry nginx's
+// "r" suffix; the period is a unit (s, m, h, d, or their long forms) or a
+// Go duration. "inf" parses as InfiniteRate. Without a burst= option the
+// burst is the count, rounded up, so "100/m" admits 100 events at once.
+// Errors match ErrInvalidLimit.
+func ParseRate(s string) (Rate, int, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0, 0, fmt.Errorf("%w: empty rate", ErrInvalidLimit)
+	}
+	rate, count, err := parseRatio(fields[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w %q: %v", ErrInvalidLimit, s, err)
+	}
+	burst := -1
+	for _, opt := range fields[1:] {
+		k, v, ok := strings.Cut(opt, "=")
+		if !ok || k != "burst" {
+			return 0, 0, fmt.Errorf("%w %q: unknown option %q", ErrInvalidLimit, s, opt)
+		}
+		burst, err = strconv.Atoi(v)
+		if err != nil || burst < 0 {
+			return 0, 0, fmt.Errorf("%w %q: bad burst %q", ErrInvalidLimit, s, v)
+		}
+	}
+	if burst < 0 {
+		// Compare against -MinInt, which a float64 holds exactly, where
+		// MaxInt would round up to it.
+		c := math.Ceil(count)
+		if c >= -float64(math.MinInt) {
+			return 0, 0, fmt.Errorf("%w %q: count too large for a burst", ErrInvalidLimit, s)
+		}
+		burst = int(c)
+	}
+	return rate, burst, nil
+}
+
+// parseRatio parses "count/period", returning the rate and the count.
+func parseRatio(s string) (Rate, float64, error) {
+	if s == "inf" {
+		return InfiniteRate, 0, nil
+	}
+	num, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("missing /period")
+	}
+	count, err := strconv.ParseFloat(strings.TrimSuffix(num, "r"), 64)
+	if err != nil || count < 0 || math.IsNaN(count) || math.IsInf(count, 0) {
+		return 0, 0, fmt.Errorf("bad count %q", num)
+	}
+	d, ok := rateUnits[period]
+	if !ok {
+		d, err = time.ParseDuration(period)
+		if err != nil || d <= 0 {
+			return 0, 0, fmt.Errorf("bad period %q", period)
+		}
+	}
+	return Rate(count / d.Seconds()), count, nil
+}
@@ -0,0 +1,41 @@
+package ratelimiter
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		rate  Rate
+		burst int
+	}{
+		{"100/m", PerMinute(100), 100},
+		{"5r/s burst=10", 5, 10},
+		{"0.5/s", 0.5, 1},
+		{"1000/10m", Per(1000, 10*time.Minute), 1000},
+		{"10000/day", PerDay(10000), 10000},
+		{"3/hour burst=0", PerHour(3), 0},
+		{"inf", InfiniteRate, 0},
+		{"1e300/s burst=5", 1e300, 5},
+	} {
+		rate, burst, err := ParseRate(tc.in)
+		if err != nil {
+			t.Errorf("ParseRate(%q): %v", tc.in, err)
+			continue
+		}
+		if rate != tc.rate || burst != tc.burst {
+			t.Errorf("ParseRate(%q) = %v, %d; want %v, %d", tc.in, rate, burst, tc.rate, tc.burst)
+		}
+	}
+}
+
+func TestParseRateErrors(t *testing.T) {
+	for _, in := range []string{"", "100", "x/s", "-1/s", "1/fortnight", "1/0s", "1/s burst=-1", "1/s limit=3", "1e300/s"} {
+		if _, _, err := ParseRate(in); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("ParseRate(%q): expected ErrInvalidLimit, got %v", in, err)
+		}
+	}
+}
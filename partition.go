@@ -0,0 +1,122 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Partition divides a global limit evenly among the instances of a
+// cluster, so that each runs a purely local limiter at the global rate
+// divided by the number of instances, with no store on the request path.
+// The split is only as even as the traffic: an instance that receives
+// more than its share is limited even if others are idle.
+type Partition struct {
+	mu      sync.Mutex
+	rate    Rate
+	burst   int
+	members int
+	rl      *RateLimiter
+}
+
+// NewPartition returns a Partition of a global limit of rate with burst
+// among members instances.
+func NewPartition(rate Rate, burst, members int, clk Clock) *Partition {
+	members = max(members, 1)
+	r, b := shareOf(rate, burst, members)
+	return &Partition{rate: rate, burst: burst, members: members, rl: New(r, b, clk)}
+}
+
+// shareOf returns an instance's share of rate and burst among members.
+// Bursts are rounded up, so that every instance can admit an event.
+func shareOf(rate Rate, burst, members int) (Rate, int) {
+	if rate != InfiniteRate {
+		rate /= Rate(members)
+	}
+	return rate, (burst + members - 1) / members
+}
+
+// Limiter returns the local limiter, whose rate and burst are changed as
+// members join and leave.
+func (p *Partition) Limiter() *RateLimiter {
+	return p.rl
+}
+
+// Members returns the number of instances the limit is divided among.
+func (p *Partition) Members() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.members
+}
+
+// SetMembers rebalances the limit among n instances, carrying the local
+// tokens over as SetRate and SetBurst do.
+func (p *Partition) SetMembers(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.members = max(n, 1)
+	p.applyLocked()
+}
+
+// SetLimit changes the global limit.
+func (p *Partition) SetLimit(rate Rate, burst int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rate, p.burst = rate, burst
+	p.applyLocked()
+}
+
+func (p *Partition) applyLocked() {
+	r, b := shareOf(p.rate, p.burst, p.members)
+	now := p.rl.now()
+	p.rl.SetRateAt(now, r)
+	p.rl.SetBurstAt(now, b)
+}
+
+func (p *Partition) Allow() bool {
+	return p.rl.Allow()
+}
+
+func (p *Partition) AllowN(n int) bool {
+	return p.rl.AllowN(n)
+}
+
+// Wait is shorthand for WaitN(context.Background(), n).
+func (p *Partition) Wait(n int) error {
+	return p.rl.Wait(n)
+}
+
+func (p *Partition) WaitN(ctx context.Context, n int) error {
+	return p.rl.WaitN(ctx, n)
+}
+
+// MemberSource returns the current number of instances, for example from
+// a service registry or the endpoints of a Kubernetes service.
+type MemberSource func(ctx context.Context) (int, error)
+
+// Discover calls src every interval and rebalances the limit when the
+// number of instances changes. Errors, and counts below one, are passed to
+// onError, if not nil, and leave the current split in place. The returned
+// function stops discovery.
+func (p *Partition) Discover(src MemberSource, interval time.Duration, onError func(error)) (stop func()) {
+	return RunEvery(interval, func() {
+		if err := p.refresh(src); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+}
+
+func (p *Partition) refresh(src MemberSource) error {
+	n, err := src(context.Background())
+	if err != nil {
+		return err
+	}
+	if n < 1 {
+		return fmt.Errorf("rate: member source reported %d instances", n)
+	}
+	if n != p.Members() {
+		p.SetMembers(n)
+	}
+	return nil
+}
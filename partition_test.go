@@ -0,0 +1,54 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPartitionRebalances(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	p := NewPartition(30, 10, 3, clk)
+	if r, b := p.Limiter().Rate(), p.Limiter().Burst(); r != 10 || b != 4 {
+		t.Fatalf("share of 3 = %v/%d, want 10/4", r, b)
+	}
+	if !p.AllowN(4) || p.Allow() {
+		t.Fatal("local burst not enforced")
+	}
+
+	p.SetMembers(2)
+	if r, b := p.Limiter().Rate(), p.Limiter().Burst(); r != 15 || b != 5 {
+		t.Fatalf("share of 2 = %v/%d, want 15/5", r, b)
+	}
+	clk.Sleep(100 * time.Millisecond)
+	if got := p.Limiter().AvailableTokens(); got != 1.5 {
+		t.Fatalf("refilled %v tokens at the new share, want 1.5", got)
+	}
+
+	p.SetMembers(0)
+	if p.Members() != 1 || p.Limiter().Rate() != 30 {
+		t.Fatalf("members %d at %v after SetMembers(0)", p.Members(), p.Limiter().Rate())
+	}
+	p.SetLimit(InfiniteRate, 0)
+	if p.Limiter().Rate() != InfiniteRate {
+		t.Fatal("infinite limit was divided")
+	}
+}
+
+func TestPartitionRefresh(t *testing.T) {
+	p := NewPartition(12, 12, 1, newFakeClock(time.Unix(0, 0)))
+	n, err := 4, error(nil)
+	src := func(context.Context) (int, error) { return n, err }
+	if err := p.refresh(src); err != nil || p.Members() != 4 {
+		t.Fatalf("refresh = %v, members %d", err, p.Members())
+	}
+	n = 0
+	if err := p.refresh(src); err == nil || p.Members() != 4 {
+		t.Fatalf("refresh with no members = %v, members %d", err, p.Members())
+	}
+	err = errors.New("registry down")
+	if p.refresh(src) != err {
+		t.Fatal("source error not returned")
+	}
+}
@@ -0,0 +1,89 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPauseDeny(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(10, 10, clk)
+	rl.Pause()
+	if !rl.Paused() {
+		t.Fatal("Paused() = false after Pause")
+	}
+	if rl.Allow() {
+		t.Fatal("Allow succeeded while paused")
+	}
+	if r := rl.ReserveN(1); r.OK() {
+		t.Fatal("reservation OK while paused")
+	}
+	if err := rl.Wait(1); !errors.Is(err, ErrPaused) {
+		t.Fatalf("Wait while paused: %v, want ErrPaused", err)
+	}
+
+	child := rl.NewChild(10, 10)
+	if child.Allow() {
+		t.Fatal("child of a paused limiter allowed a request")
+	}
+
+	rl.Resume()
+	if !rl.Allow() || !child.Allow() {
+		t.Fatal("requests denied after Resume")
+	}
+	if got := rl.AvailableTokens(); got != 8 {
+		t.Fatalf("AvailableTokens() = %v, want 8", got)
+	}
+}
+
+func TestPauseQueue(t *testing.T) {
+	rl := New(1000, 1, nil)
+	rl.SetPauseMode(PauseQueue)
+	rl.Pause()
+
+	done := make(chan error, 1)
+	go func() { done <- rl.Wait(1) }()
+	select {
+	case err := <-done:
+		t.Fatalf("Wait returned while paused: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := rl.WaitN(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled WaitN while paused: %v", err)
+	}
+
+	rl.Resume()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Wait after Resume: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait still blocked after Resume")
+	}
+}
+
+func TestPauseInterruptsSleepingWaiter(t *testing.T) {
+	rl := New(0.01, 1, nil)
+	rl.Allow()
+	done := make(chan error, 1)
+	go func() { done <- rl.Wait(1) }()
+	for rl.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	rl.Pause()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrPaused) {
+			t.Fatalf("Wait: %v, want ErrPaused", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("sleeping waiter not interrupted by Pause")
+	}
+}
@@ -0,0 +1,211 @@
+package ratelimiter
+
+import (
+	"context"
+	"time"
+)
+
+// Penalty configures cooldowns for keys of a KeyedLimiter that are denied
+// repeatedly. A key denied Threshold times within Window is cooled down:
+// its rate drops to zero and its bucket is emptied for Cooldown, after
+// which it refills from empty at its normal rate. Each further cooldown
+// doubles in length, up to MaxCooldown, until the key has gone as long as
+// its last cooldown without a new one.
+type Penalty struct {
+	Threshold   int
+	Window      time.Duration
+	Cooldown    time.Duration
+	MaxCooldown time.Duration // zero means no bound
+}
+
+// penaltyState tracks the recent denials and cooldowns of one key.
+type penaltyState struct {
+	denials     int
+	windowStart time.Time
+	strikes     int           // cooldowns in a row
+	last        time.Duration // length of the latest cooldown
+	until       time.Time     // end of the latest cooldown
+	cooling     bool          // the key's rate is zero until until
+}
+
+// SetPenalty enables cooldowns for keys denied too often by Allow, AllowN
+// or AllowNDetailed, replacing any earlier Penalty. Denials are counted
+// together with admission under the key's lock, so a cooldown takes effect
+// from the very next call. Wait on a key in a cooldown sleeps until it
+// ends. A zero Threshold disables penalties and ends current cooldowns.
+func (kl *KeyedLimiter) SetPenalty(p Penalty) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	cfg := kl.updateConfig(func(c *keyedConfig) {
+		c.penalty = nil
+		if p.Threshold > 0 {
+			c.penalty = &p
+		}
+	})
+	if cfg.penalty != nil {
+		return
+	}
+	now := kl.now()
+	kl.eachShard(func(s *keyedShard) {
+		for key := range s.penalties {
+			kl.liftLocked(s, key, now)
+		}
+		clear(s.penalties)
+	})
+}
+
+// CooldownUntil returns when key's current cooldown ends, and false if key
+// is not cooling down.
+func (kl *KeyedLimiter) CooldownUntil(key string) (time.Time, bool) {
+	s := kl.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.penalties[key]
+	if !ok || !p.cooling || !kl.now().Before(p.until) {
+		return time.Time{}, false
+	}
+	return p.until, true
+}
+
+// ReportDenial counts a denial of key toward its Penalty, for callers that
+// decide on the key's limiter from Get themselves, such as a MultiLimiter
+// spanning the limiters of several keys. It does nothing without a
+// Penalty.
+func (kl *KeyedLimiter) ReportDenial(key string) {
+	pen := kl.config.Load().penalty
+	if pen == nil {
+		return
+	}
+	s := kl.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kl.getLocked(s, key)
+	kl.recordDenialLocked(s, key, kl.now(), pen)
+}
+
+// allowPenalized is AllowNDetailed for a limiter with a Penalty.
+func (kl *KeyedLimiter) allowPenalized(key string, n int, pen *Penalty) Result {
+	s := kl.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rl := kl.getLocked(s, key)
+	res := rl.AllowNDetailed(n)
+	if !res.Allowed {
+		kl.recordDenialLocked(s, key, res.At, pen)
+	}
+	return res
+}
+
+// waitPenalized sleeps until key's cooldown, if any, ends.
+func (kl *KeyedLimiter) waitPenalized(ctx context.Context, key string, n int) error {
+	until, ok := kl.CooldownUntil(key)
+	if !ok {
+		return nil
+	}
+	delay := until.Sub(kl.now())
+	if err := sleepCtx(ctx, kl.clock, delay); err != nil {
+		return ctxError(n, err, delay)
+	}
+	return nil
+}
+
+// recordDenialLocked counts a denial of key at t and starts a cooldown if
+// it reaches the threshold. Callers must hold s.mu.
+func (kl *KeyedLimiter) recordDenialLocked(s *keyedShard, key string, t time.Time, pen *Penalty) {
+	if t.Sub(s.swept) >= pen.Window {
+		kl.sweepPenaltiesLocked(s, t, pen)
+		s.swept = t
+	}
+	p := s.penalties[key]
+	if p == nil {
+		p = &penaltyState{}
+		s.penalties[key] = p
+	}
+	if p.cooling {
+		return
+	}
+	if p.denials == 0 || t.Sub(p.windowStart) >= pen.Window {
+		p.denials = 0
+		p.windowStart = t
+	}
+	p.denials++
+	if p.denials < pen.Threshold {
+		return
+	}
+
+	if p.strikes > 0 && t.Sub(p.until) >= p.last {
+		p.strikes = 0
+	}
+	p.last = pen.Cooldown << p.strikes
+	if pen.MaxCooldown > 0 && (p.last > pen.MaxCooldown || p.last < pen.Cooldown) {
+		p.last = pen.MaxCooldown
+	}
+	p.strikes++
+	p.denials = 0
+	p.until = t.Add(p.last)
+	p.cooling = true
+	rl := s.limiters[key]
+	rl.SetRateAt(t, 0)
+	rl.SetTokens(0)
+}
+
+// sweepPenaltiesLocked drops the penalty state of keys with nothing left
+// to remember at t: no cooldown under way, no denials in the current
+// window and no strikes left to forgive. Callers must hold s.mu.
+func (kl *KeyedLimiter) sweepPenaltiesLocked(s *keyedShard, t time.Time, pen *Penalty) {
+	for key, p := range s.penalties {
+		switch {
+		case p.cooling && t.Before(p.until):
+		case p.denials > 0 && t.Sub(p.windowStart) < pen.Window:
+		case p.strikes > 0 && t.Sub(p.until) < p.last:
+		default:
+			kl.liftLocked(s, key, p.until)
+			delete(s.penalties, key)
+		}
+	}
+}
+
+// resumeCooldownLocked cools down rl, the new limiter of a key evicted
+// during its cooldown, for the rest of the cooldown. Callers must hold
+// s.mu.
+func (kl *KeyedLimiter) resumeCooldownLocked(s *keyedShard, key string, rl *RateLimiter) {
+	p, ok := s.penalties[key]
+	if !ok || !p.cooling {
+		return
+	}
+	now := kl.now()
+	if !now.Before(p.until) {
+		p.cooling = false
+		return
+	}
+	rl.SetRateAt(now, 0)
+	rl.SetTokens(0)
+}
+
+// expireCooldownLocked ends key's cooldown if it is over at t. Callers
+// must hold s.mu.
+func (kl *KeyedLimiter) expireCooldownLocked(s *keyedShard, key string, t time.Time) {
+	if p, ok := s.penalties[key]; ok && p.cooling && !t.Before(p.until) {
+		kl.liftLocked(s, key, p.until)
+	}
+}
+
+// coolingLocked reports whether key's rate is held at zero by a cooldown.
+// Callers must hold s.mu.
+func (s *keyedShard) coolingLocked(key string) bool {
+	p, ok := s.penalties[key]
+	return ok && p.cooling
+}
+
+// liftLocked ends any cooldown of key, restoring its rate from t on.
+// Callers must hold s.mu.
+func (kl *KeyedLimiter) liftLocked(s *keyedShard, key string, t time.Time) {
+	p, ok := s.penalties[key]
+	if !ok || !p.cooling {
+		return
+	}
+	p.cooling = false
+	if rl, ok := s.limiters[key]; ok {
+		rl.SetRateAt(t, s.limitFor(key, kl.config.Load()).rate)
+	}
+}
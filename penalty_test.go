@@ -0,0 +1,124 @@
+package ratelimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPenaltyCooldownEscalates(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(10, 1, clk)
+	kl.SetPenalty(Penalty{Threshold: 3, Window: time.Second, Cooldown: time.Second, MaxCooldown: 3 * time.Second})
+
+	abuse := func() {
+		t.Helper()
+		for i := 0; i < 4; i++ {
+			kl.Allow("a")
+		}
+	}
+	abuse()
+	until, ok := kl.CooldownUntil("a")
+	if !ok || !until.Equal(time.Unix(1, 0)) {
+		t.Fatalf("CooldownUntil after 3 denials = %v, %v; want 1s", until, ok)
+	}
+	clk.Sleep(900 * time.Millisecond)
+	if kl.Allow("a") {
+		t.Fatal("allowed during cooldown")
+	}
+	if !kl.Allow("b") {
+		t.Fatal("another key was penalized")
+	}
+
+	// After the cooldown the bucket refills from empty at the normal rate.
+	clk.Sleep(100 * time.Millisecond)
+	if kl.Allow("a") {
+		t.Fatal("allowed with a bucket that was emptied by the cooldown")
+	}
+	clk.Sleep(100 * time.Millisecond)
+	if !kl.Allow("a") {
+		t.Fatal("denied after the cooldown ended")
+	}
+
+	// Reoffending doubles the cooldown, up to the bound.
+	abuse()
+	if until, _ := kl.CooldownUntil("a"); until.Sub(clk.Now()) != 2*time.Second {
+		t.Fatalf("second cooldown ends at %v, want 2s from now", until)
+	}
+	clk.Sleep(2 * time.Second)
+	abuse()
+	if until, _ := kl.CooldownUntil("a"); until.Sub(clk.Now()) != 3*time.Second {
+		t.Fatalf("third cooldown ends at %v, want the 3s bound", until)
+	}
+
+	// Good behavior for as long as the last cooldown forgives the strikes.
+	clk.Sleep(6 * time.Second)
+	abuse()
+	if until, _ := kl.CooldownUntil("a"); until.Sub(clk.Now()) != time.Second {
+		t.Fatalf("cooldown after a clean period ends at %v, want 1s from now", until)
+	}
+}
+
+func TestPenaltyWaitSleepsThroughCooldown(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(1, 1, clk)
+	kl.SetPenalty(Penalty{Threshold: 1, Window: time.Second, Cooldown: 5 * time.Second})
+	kl.AllowN("a", 2)
+	if err := kl.Wait(context.Background(), "a", 1); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 6*time.Second {
+		t.Fatalf("Wait returned after %v, want 5s cooldown and 1s refill", got)
+	}
+
+	kl.AllowN("a", 2)
+	kl.SetPenalty(Penalty{})
+	if _, ok := kl.CooldownUntil("a"); ok {
+		t.Fatal("cooldown survived disabling penalties")
+	}
+	if r := kl.Get("a").Rate(); r != 1 {
+		t.Fatalf("rate after disabling penalties = %v, want 1", r)
+	}
+}
+
+func TestReportDenial(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(10, 1, clk)
+	kl.ReportDenial("a") // no Penalty: nothing to count
+	kl.SetPenalty(Penalty{Threshold: 2, Window: time.Second, Cooldown: time.Second})
+	kl.ReportDenial("a")
+	if _, ok := kl.CooldownUntil("a"); ok {
+		t.Fatal("cooldown before the threshold")
+	}
+	kl.ReportDenial("a")
+	if _, ok := kl.CooldownUntil("a"); !ok || kl.Get("a").Allow() {
+		t.Fatal("reported denials did not cool the key down")
+	}
+}
+
+func TestPenaltyOutlivesEviction(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(10, 1, clk)
+	kl.SetMaxKeys(1)
+	kl.SetPenalty(Penalty{Threshold: 1, Window: time.Second, Cooldown: 5 * time.Second})
+	kl.AllowN("a", 2)
+	kl.Allow("b") // evicts a
+	if kl.Len() != 1 {
+		t.Fatalf("%d live keys, want 1", kl.Len())
+	}
+	if _, ok := kl.CooldownUntil("a"); !ok {
+		t.Fatal("evicting the key ended its cooldown")
+	}
+	clk.Sleep(time.Second)
+	if kl.Allow("a") {
+		t.Fatal("evicted key was admitted during its cooldown")
+	}
+
+	// Once there is nothing left to remember, a later denial sweeps the
+	// state away.
+	clk.Sleep(time.Minute)
+	kl.ReportDenial("b")
+	if _, ok := kl.shards[0].penalties["a"]; ok {
+		t.Fatal("stale penalty state was kept")
+	}
+}
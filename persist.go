@@ -0,0 +1,126 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Persister saves and loads snapshots of a KeyedLimiter's buckets.
+type Persister interface {
+	Save(states map[string]State) error
+	// Load returns the last saved snapshot, or an empty one if nothing
+	// has been saved yet.
+	Load() (map[string]State, error)
+}
+
+// FilePersister is a Persister that keeps snapshots in a JSON file. Saves
+// write a temporary file and rename it into place, so a crash mid-save
+// leaves the previous snapshot intact.
+type FilePersister struct {
+	path string
+}
+
+// NewFilePersister returns a FilePersister using the file at path.
+func NewFilePersister(path string) *FilePersister {
+	return &FilePersister{path: path}
+}
+
+type snapshotJSON struct {
+	Version int              `json:"v"`
+	Keys    map[string]State `json:"keys"`
+}
+
+func (p *FilePersister) Save(states map[string]State) error {
+	data, err := json.Marshal(snapshotJSON{Version: stateVersion, Keys: states})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p.path), filepath.Base(p.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p.path)
+}
+
+func (p *FilePersister) Load() (map[string]State, error) {
+	data, err := os.ReadFile(p.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]State{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s snapshotJSON
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Version < 1 || s.Version > stateVersion {
+		return nil, fmt.Errorf("rate: unsupported snapshot version %d", s.Version)
+	}
+	return s.Keys, nil
+}
+
+// Snapshot returns the state of every live limiter, by key.
+func (kl *KeyedLimiter) Snapshot() map[string]State {
+	states := make(map[string]State)
+	kl.eachShard(func(s *keyedShard) {
+		for key, rl := range s.limiters {
+			states[key] = rl.State()
+		}
+	})
+	return states
+}
+
+// RestoreSnapshot loads the tokens saved in states into the limiters for
+// their keys. Rates and bursts are not restored: the limiters keep their
+// current configuration, which may have changed since the snapshot.
+func (kl *KeyedLimiter) RestoreSnapshot(states map[string]State) {
+	for key, s := range states {
+		rl := kl.Get(key)
+		cur := rl.State()
+		s.Rate, s.Burst = cur.Rate, cur.Burst
+		if max := float64(s.Burst); s.Tokens > max {
+			s.Tokens = max
+		}
+		rl.Restore(s)
+	}
+}
+
+// Persist restores kl from p, then saves a snapshot to p every interval so
+// that a restart does not reset every bucket to a full burst at once. The
+// returned stop function ends checkpointing and saves a final snapshot.
+func (kl *KeyedLimiter) Persist(p Persister, interval time.Duration) (stop func() error, err error) {
+	states, err := p.Load()
+	if err != nil {
+		return nil, err
+	}
+	kl.RestoreSnapshot(states)
+
+	halt := RunEvery(interval, func() {
+		// A failed checkpoint is retried at the next tick.
+		_ = p.Save(kl.Snapshot())
+	})
+	var once sync.Once
+	return func() error {
+		err := errors.New("rate: persistence already stopped")
+		once.Do(func() {
+			halt()
+			err = p.Save(kl.Snapshot())
+		})
+		return err
+	}, nil
+}
@@ -0,0 +1,74 @@
+package ratelimiter
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPersistAcrossRestart(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	path := filepath.Join(t.TempDir(), "limits.json")
+
+	kl := NewKeyed(1, 5, clk)
+	stop, err := kl.Persist(NewFilePersister(path), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kl.AllowN("alice", 5)
+	kl.AllowN("bob", 2)
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(); err == nil {
+		t.Fatal("expected a second stop to fail")
+	}
+
+	clk.Sleep(time.Second)
+	restarted := NewKeyed(1, 4, clk)
+	stop, err = restarted.Persist(NewFilePersister(path), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if got := restarted.Get("alice").AvailableTokens(); got != 1 {
+		t.Fatalf("expected alice to have refilled 1 token since the snapshot, got %v", got)
+	}
+	if got := restarted.Get("bob").AvailableTokens(); got != 4 {
+		t.Fatalf("expected bob to be capped at the new burst, got %v", got)
+	}
+}
+
+func TestPersistCheckpointsPeriodically(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limits.json")
+	p := NewFilePersister(path)
+	kl := NewKeyed(1, 5, newFakeClock(time.Unix(0, 0)))
+	stop, err := kl.Persist(p, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	kl.AllowN("alice", 3)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		states, err := p.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s, ok := states["alice"]; ok && s.Tokens == 2 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a checkpoint of alice, got %+v", states)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFilePersisterMissingFile(t *testing.T) {
+	states, err := NewFilePersister(filepath.Join(t.TempDir(), "none.json")).Load()
+	if err != nil || len(states) != 0 {
+		t.Fatalf("expected an empty snapshot, got %v, %v", states, err)
+	}
+}
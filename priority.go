@@ -0,0 +1,60 @@
+package ratelimiter
+
+import "sync"
+
+// Priority ranks callers competing for the same limiter. Higher values are
+// more important.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// PriorityLimiter admits callers of different priorities from one limiter,
+// holding back part of the burst for the more important ones. When the
+// bucket runs low, low-priority events are denied first while
+// high-priority events still get through.
+type PriorityLimiter struct {
+	mu         sync.Mutex
+	rl         *RateLimiter
+	thresholds map[Priority]float64
+}
+
+// NewPriority returns a PriorityLimiter drawing from rl. Until thresholds
+// are set, every priority may use the whole burst.
+func NewPriority(rl *RateLimiter) *PriorityLimiter {
+	return &PriorityLimiter{rl: rl, thresholds: make(map[Priority]float64)}
+}
+
+// SetThreshold admits events of priority p only while at least fraction of
+// the burst would remain afterwards. For example, thresholds of 0.5 for
+// PriorityLow and 0.2 for PriorityNormal leave the last fifth of the
+// bucket to PriorityHigh.
+func (pl *PriorityLimiter) SetThreshold(p Priority, fraction float64) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	pl.thresholds[p] = fraction
+}
+
+// Threshold returns the fraction of the burst that events of priority p
+// must leave behind.
+func (pl *PriorityLimiter) Threshold(p Priority) float64 {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return pl.thresholds[p]
+}
+
+func (pl *PriorityLimiter) Allow(p Priority) bool {
+	return pl.AllowN(p, 1)
+}
+
+// AllowN reports whether n events of priority p may happen now, taking the
+// tokens if so. Like RateLimiter.AllowN, it denies while callers are
+// queued in WaitN, whatever p, and in shadow mode reports a denial but
+// admits the events.
+func (pl *PriorityLimiter) AllowN(p Priority, n int) bool {
+	_, allowed := pl.rl.allowAbove(pl.rl.now(), float64(n), pl.Threshold(p))
+	return allowed
+}
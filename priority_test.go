@@ -0,0 +1,73 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPriorityShedsLowFirst(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	pl := NewPriority(New(1, 10, clk))
+	pl.SetThreshold(PriorityLow, 0.5)
+	pl.SetThreshold(PriorityNormal, 0.2)
+
+	for i := 0; i < 5; i++ {
+		if !pl.Allow(PriorityLow) {
+			t.Fatalf("expected low-priority event %d to be allowed", i)
+		}
+	}
+	if pl.Allow(PriorityLow) {
+		t.Fatal("expected low priority to be denied at half the burst")
+	}
+	for i := 0; i < 3; i++ {
+		if !pl.Allow(PriorityNormal) {
+			t.Fatalf("expected normal-priority event %d to be allowed", i)
+		}
+	}
+	if pl.Allow(PriorityNormal) {
+		t.Fatal("expected normal priority to be denied at a fifth of the burst")
+	}
+	if !pl.AllowN(PriorityHigh, 2) {
+		t.Fatal("expected high priority to use the reserved tokens")
+	}
+	if pl.Allow(PriorityHigh) {
+		t.Fatal("expected an empty bucket to deny high priority")
+	}
+}
+
+func TestPriorityDefaultUsesWholeBurst(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	pl := NewPriority(New(1, 3, clk))
+	if !pl.AllowN(PriorityLow, 3) {
+		t.Fatal("expected no threshold to allow the whole burst")
+	}
+}
+
+func TestPriorityQueueAndShadow(t *testing.T) {
+	clk := &blockingClock{newFakeClock(time.Unix(0, 0)), make(chan struct{})}
+	rl := New(1, 4, clk)
+	pl := NewPriority(rl)
+	rl.AllowN(4)
+	done := make(chan error)
+	go func() { done <- rl.Wait(2) }()
+	eventually(t, rl.queued, "the waiter never queued")
+	clk.fakeClock.Sleep(10 * time.Second) // the bucket refills; the waiter still sleeps
+	if pl.Allow(PriorityHigh) {
+		t.Fatal("allowed ahead of a queued waiter")
+	}
+	close(clk.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	rl = New(1, 4, newFakeClock(time.Unix(0, 0)))
+	rl.SetShadow(true)
+	pl = NewPriority(rl)
+	pl.SetThreshold(PriorityLow, 0.5)
+	if !pl.AllowN(PriorityLow, 3) {
+		t.Fatal("shadow mode enforced the threshold")
+	}
+	if s := rl.Stats(); s.Denied != 1 || s.Tokens != 4 {
+		t.Fatalf("shadowed denial: %+v, want it counted and no tokens taken", s)
+	}
+}
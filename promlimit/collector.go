@@ -0,0 +1,122 @@
+// Package promlimit exports ratelimiter metrics to Prometheus.
+package promlimit
+
+import (
+	"math"
+	"sync"
+
+	"github.com/navrang-singh/ratelimiter"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a prometheus.Collector for a set of named limiters. It
+// exports, labelled by limiter name:
+//
+//	<ns>_decisions_total{result="allowed|denied"}  counter
+//	<ns>_wait_seconds                               histogram of Wait delays
+//	<ns>_tokens                                     tokens currently available
+//	<ns>_rate, <ns>_burst                           configured rate and burst
+//	<ns>_keys                                       live keys of a KeyedLimiter
+type Collector struct {
+	mu       sync.Mutex
+	limiters map[string]*ratelimiter.RateLimiter
+	keyed    map[string]*ratelimiter.KeyedLimiter
+
+	decisions *prometheus.CounterVec
+	waits     *prometheus.HistogramVec
+	tokens    *prometheus.Desc
+	rate      *prometheus.Desc
+	burst     *prometheus.Desc
+	keys      *prometheus.Desc
+}
+
+// NewCollector returns a Collector whose metric names start with namespace
+// (for example "ratelimiter").
+func NewCollector(namespace string) *Collector {
+	labels := []string{"limiter"}
+	return &Collector{
+		limiters: make(map[string]*ratelimiter.RateLimiter),
+		keyed:    make(map[string]*ratelimiter.KeyedLimiter),
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "decisions_total",
+			Help:      "Admission decisions by result.",
+		}, []string{"limiter", "result"}),
+		waits: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "wait_seconds",
+			Help:      "Time callers spent blocked in Wait.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+		}, labels),
+		tokens: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "tokens"),
+			"Tokens currently available.", labels, nil),
+		rate: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "rate"),
+			"Configured rate in tokens per second.", labels, nil),
+		burst: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "burst"),
+			"Configured burst size.", labels, nil),
+		keys: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "keys"),
+			"Number of live keys in a keyed limiter.", labels, nil),
+	}
+}
+
+// Watch exports the metrics of rl under name.
+func (c *Collector) Watch(name string, rl *ratelimiter.RateLimiter) {
+	c.mu.Lock()
+	c.limiters[name] = rl
+	c.mu.Unlock()
+	rl.AddObserver(c.observer(name))
+}
+
+// WatchKeyed exports the decisions and key count of kl under name. Keys are
+// not used as labels, to keep cardinality bounded.
+func (c *Collector) WatchKeyed(name string, kl *ratelimiter.KeyedLimiter) {
+	c.mu.Lock()
+	c.keyed[name] = kl
+	c.mu.Unlock()
+	kl.AddObserver(c.observer(name))
+}
+
+func (c *Collector) observer(name string) ratelimiter.Observer {
+	allowed := c.decisions.WithLabelValues(name, "allowed")
+	denied := c.decisions.WithLabelValues(name, "denied")
+	waits := c.waits.WithLabelValues(name)
+	return ratelimiter.ObserverFunc(func(d ratelimiter.Decision) {
+		if d.Allowed {
+			allowed.Inc()
+		} else {
+			denied.Inc()
+		}
+		if d.Waited {
+			waits.Observe(d.Delay.Seconds())
+		}
+	})
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.decisions.Describe(ch)
+	c.waits.Describe(ch)
+	ch <- c.tokens
+	ch <- c.rate
+	ch <- c.burst
+	ch <- c.keys
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.decisions.Collect(ch)
+	c.waits.Collect(ch)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, rl := range c.limiters {
+		rate := float64(rl.Rate())
+		if rl.Rate() == ratelimiter.InfiniteRate {
+			rate = math.Inf(1)
+		}
+		ch <- prometheus.MustNewConstMetric(c.tokens, prometheus.GaugeValue, rl.AvailableTokens(), name)
+		ch <- prometheus.MustNewConstMetric(c.rate, prometheus.GaugeValue, rate, name)
+		ch <- prometheus.MustNewConstMetric(c.burst, prometheus.GaugeValue, float64(rl.Burst()), name)
+	}
+	for name, kl := range c.keyed {
+		ch <- prometheus.MustNewConstMetric(c.keys, prometheus.GaugeValue, float64(kl.Len()), name)
+	}
+}
@@ -0,0 +1,77 @@
+package promlimit
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/navrang-singh/ratelimiter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeClock struct {
+	mu   sync.Mutex
+	time time.Time
+}
+
+func (fc *fakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.time
+}
+
+func (fc *fakeClock) Sleep(d time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.time = fc.time.Add(d)
+}
+
+func TestCollector(t *testing.T) {
+	clk := &fakeClock{}
+	rl := ratelimiter.New(2, 2, clk)
+	kl := ratelimiter.NewKeyed(1, 1, clk)
+
+	c := NewCollector("rl")
+	c.Watch("api", rl)
+	c.WatchKeyed("clients", kl)
+
+	rl.AllowN(2)
+	rl.Allow()
+	rl.Wait(1)
+	kl.Allow("a")
+	kl.Allow("b")
+	kl.Allow("a")
+
+	want := `
+# HELP rl_burst Configured burst size.
+# TYPE rl_burst gauge
+rl_burst{limiter="api"} 2
+# HELP rl_decisions_total Admission decisions by result.
+# TYPE rl_decisions_total counter
+rl_decisions_total{limiter="api",result="allowed"} 2
+rl_decisions_total{limiter="api",result="denied"} 1
+rl_decisions_total{limiter="clients",result="allowed"} 2
+rl_decisions_total{limiter="clients",result="denied"} 1
+# HELP rl_keys Number of live keys in a keyed limiter.
+# TYPE rl_keys gauge
+rl_keys{limiter="clients"} 2
+# HELP rl_rate Configured rate in tokens per second.
+# TYPE rl_rate gauge
+rl_rate{limiter="api"} 2
+# HELP rl_tokens Tokens currently available.
+# TYPE rl_tokens gauge
+rl_tokens{limiter="api"} 0
+`
+	names := []string{"rl_burst", "rl_decisions_total", "rl_keys", "rl_rate", "rl_tokens"}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), names...); err != nil {
+		t.Fatal(err)
+	}
+	if n := testutil.CollectAndCount(c, "rl_wait_seconds"); n != 2 {
+		t.Fatalf("expected wait histograms for both limiters, got %d", n)
+	}
+	if err := prometheus.NewPedanticRegistry().Register(c); err != nil {
+		t.Fatalf("expected collector to register cleanly, got %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// QuotaPeriod is the calendar period of a QuotaLimiter.
+type QuotaPeriod int
+
+const (
+	// Daily quotas reset every day.
+	Daily QuotaPeriod = iota
+	// Monthly quotas reset on the first of every month.
+	Monthly
+)
+
+// QuotaLimiter admits at most limit events per calendar day or month,
+// such as "10,000 requests per day, reset at midnight UTC", which a token
+// bucket cannot express. Unused quota does not carry over unless enabled
+// with SetRollover.
+type QuotaLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	period   QuotaPeriod
+	loc      *time.Location
+	resetAt  time.Duration
+	clock    Clock
+	start    time.Time // of the current period
+	used     int
+	carried  int // unused quota rolled over into the current period
+	maxCarry int
+	onChange func(QuotaState)
+}
+
+// QuotaState is the usage of a QuotaLimiter in one period, for persisting
+// it across restarts.
+type QuotaState struct {
+	PeriodStart time.Time `json:"period_start"`
+	Used        int       `json:"used"`
+	Carried     int       `json:"carried,omitempty"`
+}
+
+// NewQuota returns a QuotaLimiter admitting limit events per period.
+// Periods start at resetAt, a time of day in [0, 24h), on the first of the
+// month for Monthly quotas, in loc; a nil loc means UTC.
+func NewQuota(limit int, period QuotaPeriod, loc *time.Location, resetAt time.Duration, clk Clock) *QuotaLimiter {
+	if clk == nil {
+		clk = realClock{}
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	l := &QuotaLimiter{limit: limit, period: period, loc: loc, resetAt: resetAt, clock: clk}
+	l.start = l.periodStart(clk.Now())
+	return l
+}
+
+func (l *QuotaLimiter) Limit() int {
+	return l.limit
+}
+
+// SetRollover lets unused quota carry over into the next period, up to
+// fraction of the limit: with a limit of 1000 and a fraction of 0.2, a
+// period that used 700 events allows 1200 in the next. Carried quota does
+// not accumulate beyond the cap, and periods in which the limiter was not
+// used count as entirely unused. A fraction of zero disables rollover.
+func (l *QuotaLimiter) SetRollover(fraction float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(l.clock.Now())
+	l.maxCarry = int(fraction * float64(l.limit))
+	l.carried = min(l.carried, l.maxCarry)
+}
+
+// Remaining returns the number of events still admitted in the current
+// period, including any carried over.
+func (l *QuotaLimiter) Remaining() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(l.clock.Now())
+	return l.allowanceLocked() - l.used
+}
+
+// ResetAt returns when the current period ends.
+func (l *QuotaLimiter) ResetAt() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(l.clock.Now())
+	return l.periodEnd(l.start)
+}
+
+func (l *QuotaLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+// AllowN reports whether n events fit in the quota now, recording them if
+// so. A negative n is denied.
+func (l *QuotaLimiter) AllowN(n int) bool {
+	_, ok := l.take(l.clock.Now(), n)
+	return ok
+}
+
+// Wait is shorthand for WaitN(context.Background(), n).
+func (l *QuotaLimiter) Wait(n int) error {
+	return l.WaitN(context.Background(), n)
+}
+
+// WaitN blocks until n events fit in the quota, which may mean until the
+// next period, or ctx is done. A negative n fails with ErrNegativeTokens.
+func (l *QuotaLimiter) WaitN(ctx context.Context, n int) error {
+	if n < 0 {
+		return negativeError(n)
+	}
+	l.mu.Lock()
+	most := l.limit + l.maxCarry
+	l.mu.Unlock()
+	if n > most {
+		return burstError(n, most)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctxError(n, ctx.Err(), 0)
+		default:
+		}
+		delay, ok := l.take(l.clock.Now(), n)
+		if ok {
+			return nil
+		}
+		if err := sleepCtx(ctx, l.clock, delay); err != nil {
+			return ctxError(n, err, delay)
+		}
+	}
+}
+
+// OnChange registers fn to be called with the new state after every change
+// to the usage, so that it can be persisted. fn is called without the
+// limiter's lock held, but calls may arrive out of order under
+// concurrency; persist the state with the highest Used for a period.
+func (l *QuotaLimiter) OnChange(fn func(QuotaState)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onChange = fn
+}
+
+// State returns the usage in the current period.
+func (l *QuotaLimiter) State() QuotaState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(l.clock.Now())
+	return l.stateLocked()
+}
+
+// Restore reloads usage saved from State. Usage from the previous period
+// only determines the quota carried over, if rollover is enabled; usage
+// from earlier periods is ignored.
+func (l *QuotaLimiter) Restore(s QuotaState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(l.clock.Now())
+	switch {
+	case s.PeriodStart.Equal(l.start):
+		l.used = s.Used
+		l.carried = min(s.Carried, l.maxCarry)
+	case l.periodEnd(s.PeriodStart).Equal(l.start):
+		l.carried = l.carryFrom(s.Carried, s.Used)
+	}
+}
+
+func (l *QuotaLimiter) stateLocked() QuotaState {
+	return QuotaState{PeriodStart: l.start, Used: l.used, Carried: l.carried}
+}
+
+// allowanceLocked returns the number of events admitted in the current
+// period.
+func (l *QuotaLimiter) allowanceLocked() int {
+	return l.limit + l.carried
+}
+
+// carryFrom returns the quota carried over from a period that had carried
+// events carried into it and used used.
+func (l *QuotaLimiter) carryFrom(carried, used int) int {
+	return max(0, min(l.limit+carried-used, l.maxCarry))
+}
+
+// take records n events at t if they fit. Otherwise it returns how long
+// until the quota resets. A negative n never fits.
+func (l *QuotaLimiter) take(t time.Time, n int) (time.Duration, bool) {
+	if n < 0 {
+		return InfiniteDuration, false
+	}
+	l.mu.Lock()
+	l.advance(t)
+	if l.used+n > l.allowanceLocked() {
+		end := l.periodEnd(l.start)
+		l.mu.Unlock()
+		return end.Sub(t), false
+	}
+	l.used += n
+	s, fn := l.stateLocked(), l.onChange
+	l.mu.Unlock()
+	if fn != nil {
+		fn(s)
+	}
+	return 0, true
+}
+
+// advance starts a new period if t is past the current one.
+func (l *QuotaLimiter) advance(t time.Time) {
+	end := l.periodEnd(l.start)
+	if t.Before(end) {
+		return
+	}
+	start := l.periodStart(t)
+	if start.Equal(end) {
+		l.carried = l.carryFrom(l.carried, l.used)
+	} else {
+		// At least one whole period went unused.
+		l.carried = min(l.limit, l.maxCarry)
+	}
+	l.start = start
+	l.used = 0
+}
+
+// periodStart returns the start of the period containing t.
+func (l *QuotaLimiter) periodStart(t time.Time) time.Time {
+	t = t.In(l.loc)
+	y, m, d := t.Date()
+	if l.period == Monthly {
+		d = 1
+	}
+	start := time.Date(y, m, d, 0, 0, 0, int(l.resetAt), l.loc)
+	if start.After(t) {
+		start = l.shift(start, -1)
+	}
+	return start
+}
+
+// periodEnd returns the end of the period starting at start.
+func (l *QuotaLimiter) periodEnd(start time.Time) time.Time {
+	return l.shift(start, 1)
+}
+
+// shift moves the period start by k periods, keeping the wall-clock reset
+// time.
+func (l *QuotaLimiter) shift(start time.Time, k int) time.Time {
+	y, m, d := start.In(l.loc).Date()
+	if l.period == Monthly {
+		return time.Date(y, m+time.Month(k), 1, 0, 0, 0, int(l.resetAt), l.loc)
+	}
+	return time.Date(y, m, d+k, 0, 0, 0, int(l.resetAt), l.loc)
+}
@@ -0,0 +1,125 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQuotaDaily(t *testing.T) {
+	clk := newFakeClock(time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC))
+	l := NewQuota(3, Daily, nil, 0, clk)
+	if !l.AllowN(3) || l.Allow() {
+		t.Fatal("daily quota of 3 not enforced")
+	}
+	if want := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC); !l.ResetAt().Equal(want) {
+		t.Fatalf("ResetAt() = %v, want %v", l.ResetAt(), want)
+	}
+
+	start := clk.Now()
+	if err := l.WaitN(context.Background(), 2); err != nil {
+		t.Fatalf("WaitN: %v", err)
+	}
+	if waited := clk.Now().Sub(start); waited != time.Hour {
+		t.Fatalf("WaitN waited %v, want 1h until midnight", waited)
+	}
+	if got := l.Remaining(); got != 1 {
+		t.Fatalf("Remaining() = %d, want 1", got)
+	}
+}
+
+func TestQuotaMonthlyLocalReset(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	// 08:59 on the 1st in Tokyo, just before a 09:00 reset.
+	clk := newFakeClock(time.Date(2024, 2, 1, 8, 59, 0, 0, tokyo))
+	l := NewQuota(10, Monthly, tokyo, 9*time.Hour, clk)
+	l.AllowN(10)
+	if want := time.Date(2024, 2, 1, 9, 0, 0, 0, tokyo); !l.ResetAt().Equal(want) {
+		t.Fatalf("ResetAt() = %v, want %v", l.ResetAt(), want)
+	}
+	clk.Sleep(time.Minute)
+	if got := l.Remaining(); got != 10 {
+		t.Fatalf("Remaining() after reset = %d, want 10", got)
+	}
+	if want := time.Date(2024, 3, 1, 9, 0, 0, 0, tokyo); !l.ResetAt().Equal(want) {
+		t.Fatalf("next ResetAt() = %v, want %v", l.ResetAt(), want)
+	}
+}
+
+func TestQuotaPersistence(t *testing.T) {
+	clk := newFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	var saved QuotaState
+	l := NewQuota(5, Daily, nil, 0, clk)
+	l.OnChange(func(s QuotaState) { saved = s })
+	l.AllowN(4)
+	if saved.Used != 4 {
+		t.Fatalf("OnChange saw %+v, want 4 used", saved)
+	}
+
+	// A restart within the same day keeps the usage.
+	restarted := NewQuota(5, Daily, nil, 0, clk)
+	restarted.Restore(saved)
+	if restarted.AllowN(2) {
+		t.Fatal("restored quota admitted more than the limit")
+	}
+
+	// Usage from an earlier day is ignored.
+	clk.Sleep(24 * time.Hour)
+	fresh := NewQuota(5, Daily, nil, 0, clk)
+	fresh.Restore(saved)
+	if got := fresh.Remaining(); got != 5 {
+		t.Fatalf("Remaining() after restoring a stale state = %d, want 5", got)
+	}
+}
+
+func TestQuotaRollover(t *testing.T) {
+	clk := newFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	l := NewQuota(100, Daily, nil, 0, clk)
+	l.SetRollover(0.2)
+
+	l.AllowN(90)
+	clk.Sleep(24 * time.Hour)
+	if got := l.Remaining(); got != 110 {
+		t.Fatalf("Remaining() after using 90 = %d, want 110", got)
+	}
+	l.AllowN(50)
+	clk.Sleep(24 * time.Hour)
+	if got := l.Remaining(); got != 120 {
+		t.Fatalf("Remaining() after using 50 of 110 = %d, want capped 120", got)
+	}
+	if !l.AllowN(120) || l.Allow() {
+		t.Fatal("rolled-over quota not enforced")
+	}
+	clk.Sleep(24 * time.Hour)
+	if got := l.Remaining(); got != 100 {
+		t.Fatalf("Remaining() after using everything = %d, want 100", got)
+	}
+
+	// The carried quota survives a restart.
+	clk.Sleep(72 * time.Hour)
+	s := l.State()
+	if s.Carried != 20 {
+		t.Fatalf("State() after idle days = %+v, want 20 carried", s)
+	}
+	restarted := NewQuota(100, Daily, nil, 0, clk)
+	restarted.SetRollover(0.2)
+	restarted.Restore(s)
+	if got := restarted.Remaining(); got != 120 {
+		t.Fatalf("Remaining() after restore = %d, want 120", got)
+	}
+}
+
+func TestQuotaNegativeN(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewQuota(2, Daily, nil, 0, clk)
+	if l.AllowN(-5) {
+		t.Fatal("AllowN(-5) admitted")
+	}
+	if err := l.WaitN(context.Background(), -5); !errors.Is(err, ErrNegativeTokens) {
+		t.Fatalf("WaitN(-5) = %v, want ErrNegativeTokens", err)
+	}
+	if !l.AllowN(2) || l.AllowN(1) {
+		t.Fatal("negative n changed the quota")
+	}
+}
@@ -0,0 +1,129 @@
+// Package rate is a drop-in replacement for golang.org/x/time/rate backed
+// by the ratelimiter package. Code written against x/time/rate migrates by
+// changing its import path; the Limiter and Reservation method sets match.
+package rate
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/navrang-singh/ratelimiter"
+)
+
+// Limit is the maximum frequency of events, in events per second.
+type Limit float64
+
+// Inf is the infinite rate limit; it allows all events.
+const Inf = Limit(ratelimiter.InfiniteRate)
+
+// InfDuration is the duration returned by Delay when a Reservation is not
+// OK.
+const InfDuration = ratelimiter.InfiniteDuration
+
+// Every converts a minimum time interval between events to a Limit.
+func Every(interval time.Duration) Limit {
+	return Limit(ratelimiter.Every(interval))
+}
+
+// Reservation holds information about events that are permitted by a
+// Limiter to happen after a delay.
+type Reservation = ratelimiter.Reservation
+
+// Sometimes performs an action occasionally: on the first First calls to
+// Do, every Every-th call, or once per Interval.
+type Sometimes = ratelimiter.Sometimes
+
+// Limiter controls how frequently events are allowed to happen, with the
+// semantics of x/time/rate.Limiter. The zero value is a valid Limiter that
+// rejects all events.
+type Limiter struct {
+	once sync.Once
+	rl   *ratelimiter.RateLimiter
+}
+
+// NewLimiter returns a Limiter that allows events up to rate r and permits
+// bursts of at most b tokens.
+func NewLimiter(r Limit, b int) *Limiter {
+	return &Limiter{rl: ratelimiter.New(ratelimiter.Rate(r), b, nil)}
+}
+
+func (lim *Limiter) limiter() *ratelimiter.RateLimiter {
+	lim.once.Do(func() {
+		if lim.rl == nil {
+			lim.rl = ratelimiter.New(0, 0, nil)
+		}
+	})
+	return lim.rl
+}
+
+// Limit returns the maximum overall event rate.
+func (lim *Limiter) Limit() Limit {
+	return Limit(lim.limiter().Rate())
+}
+
+// Burst returns the maximum burst size.
+func (lim *Limiter) Burst() int {
+	return lim.limiter().Burst()
+}
+
+// Tokens returns the token count now.
+func (lim *Limiter) Tokens() float64 {
+	return lim.TokensAt(time.Now())
+}
+
+// TokensAt returns the token count at time t.
+func (lim *Limiter) TokensAt(t time.Time) float64 {
+	return lim.limiter().TokensAt(t)
+}
+
+// Allow reports whether an event may happen now.
+func (lim *Limiter) Allow() bool {
+	return lim.AllowN(time.Now(), 1)
+}
+
+// AllowN reports whether n events may happen at time t.
+func (lim *Limiter) AllowN(t time.Time, n int) bool {
+	return lim.limiter().AllowNAt(t, n)
+}
+
+// Reserve is shorthand for ReserveN(time.Now(), 1).
+func (lim *Limiter) Reserve() *Reservation {
+	return lim.ReserveN(time.Now(), 1)
+}
+
+// ReserveN returns a Reservation that indicates how long the caller must
+// wait before n events happen.
+func (lim *Limiter) ReserveN(t time.Time, n int) *Reservation {
+	return lim.limiter().ReserveNAt(t, n, InfDuration)
+}
+
+// Wait is shorthand for WaitN(ctx, 1).
+func (lim *Limiter) Wait(ctx context.Context) error {
+	return lim.WaitN(ctx, 1)
+}
+
+// WaitN blocks until lim permits n events to happen or ctx is done.
+func (lim *Limiter) WaitN(ctx context.Context, n int) error {
+	return lim.limiter().WaitN(ctx, n)
+}
+
+// SetLimit is shorthand for SetLimitAt(time.Now(), newLimit).
+func (lim *Limiter) SetLimit(newLimit Limit) {
+	lim.SetLimitAt(time.Now(), newLimit)
+}
+
+// SetLimitAt sets a new Limit for the limiter.
+func (lim *Limiter) SetLimitAt(t time.Time, newLimit Limit) {
+	lim.limiter().SetRateAt(t, ratelimiter.Rate(newLimit))
+}
+
+// SetBurst is shorthand for SetBurstAt(time.Now(), newBurst).
+func (lim *Limiter) SetBurst(newBurst int) {
+	lim.SetBurstAt(time.Now(), newBurst)
+}
+
+// SetBurstAt sets a new burst size for the limiter.
+func (lim *Limiter) SetBurstAt(t time.Time, newBurst int) {
+	lim.limiter().SetBurstAt(t, newBurst)
+}
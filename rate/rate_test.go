@@ -0,0 +1,54 @@
+package rate
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiterMatchesXRate(t *testing.T) {
+	t0 := time.Now()
+	lim := NewLimiter(Every(100*time.Millisecond), 2)
+	if lim.Limit() != 10 || lim.Burst() != 2 {
+		t.Fatalf("unexpected limit %v burst %d", lim.Limit(), lim.Burst())
+	}
+	if !lim.AllowN(t0, 2) {
+		t.Fatal("expected the burst to be allowed")
+	}
+	if lim.AllowN(t0, 1) {
+		t.Fatal("expected an empty bucket to deny")
+	}
+	if !lim.AllowN(t0.Add(100*time.Millisecond), 1) {
+		t.Fatal("expected a token after 100ms")
+	}
+
+	r := lim.ReserveN(t0.Add(100*time.Millisecond), 1)
+	if !r.OK() || r.DelayFrom(t0.Add(100*time.Millisecond)) != 100*time.Millisecond {
+		t.Fatalf("expected a 100ms reservation, got %v", r.DelayFrom(t0.Add(100*time.Millisecond)))
+	}
+	r.CancelAt(t0.Add(100 * time.Millisecond))
+	if got := lim.TokensAt(t0.Add(200 * time.Millisecond)); got != 1 {
+		t.Fatalf("expected canceled tokens back, got %v", got)
+	}
+	if r := lim.ReserveN(t0, 3); r.OK() || r.DelayFrom(t0) != InfDuration {
+		t.Fatal("expected a reservation above the burst to fail")
+	}
+
+	lim.SetLimitAt(t0.Add(200*time.Millisecond), Inf)
+	if !lim.AllowN(t0.Add(200*time.Millisecond), 100) {
+		t.Fatal("expected Inf to allow everything")
+	}
+	if err := lim.WaitN(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZeroLimiterRejects(t *testing.T) {
+	var lim Limiter
+	if lim.Allow() || lim.Limit() != 0 || lim.Burst() != 0 {
+		t.Fatal("expected the zero Limiter to reject all events")
+	}
+	if err := lim.Wait(context.Background()); err == nil {
+		t.Fatal("expected Wait on the zero Limiter to fail")
+	}
+}
@@ -1,9 +1,14 @@
 package ratelimiter
 
 import (
+	"container/list"
+	"context"
+	"errors"
 	"fmt"
 	"math"
+	"math/rand/v2"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,16 +17,38 @@ type Clock interface {
 	Sleep(d time.Duration)
 }
 
+// TimerClock is a Clock that can also start timers. Waits on a clock that
+// implements it are abandoned cleanly when interrupted; on a plain Clock an
+// interrupted Sleep is left to finish in the background.
+type TimerClock interface {
+	Clock
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a stoppable one-shot timer, as returned by TimerClock.NewTimer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
 type realClock struct{}
 
-func (realClock) Now() time.Time        { return time.Now() }
-func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+func (realClock) Now() time.Time                 { return time.Now() }
+func (realClock) Sleep(d time.Duration)          { time.Sleep(d) }
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.t.C }
+func (t realTimer) Stop() bool          { return t.t.Stop() }
 
 // Rate defines events per second.
 type Rate float64
 
 const InfiniteRate = Rate(math.MaxFloat64)
 
+const InfiniteDuration = time.Duration(math.MaxInt64)
+
 func Every(interval time.Duration) Rate {
 	if interval <= 0 {
 		return InfiniteRate
@@ -29,61 +56,216 @@ func Every(interval time.Duration) Rate {
 	return 1 / Rate(interval.Seconds())
 }
 
+// Per returns a Rate of n events every d.
+func Per(n int, d time.Duration) Rate {
+	if d <= 0 {
+		return InfiniteRate
+	}
+	return Rate(n) / Rate(d.Seconds())
+}
+
+func PerSecond(n int) Rate { return Per(n, time.Second) }
+func PerMinute(n int) Rate { return Per(n, time.Minute) }
+func PerHour(n int) Rate   { return Per(n, time.Hour) }
+func PerDay(n int) Rate    { return Per(n, 24*time.Hour) }
+
 // RateLimiter enforces a maximum rate and burst for events.
 type RateLimiter struct {
-	mu          sync.Mutex
-	rate        Rate
-	maxTokens   int
-	tokens      float64
-	updatedAt   time.Time
-	eventAt     time.Time
-	clock       Clock
+	mu        sync.Mutex
+	rate      Rate
+	maxTokens int
+	tokens    float64
+	epoch     time.Time // origin of updatedAt and eventAt
+	updatedAt int64     // ns since epoch
+	eventAt   int64     // ns since epoch
+	clock     Clock
+	nano      NanoClock // clock, if it is one
+	nanoEpoch int64     // nano's reading at epoch
+	observers []Observer
+	parent    *RateLimiter
+	name      string
+	waiters   list.List // of *waiter
+	demand    int       // tokens requested by waiters
+	maxQueue  int
+	maxDemand int
+	maxFuture time.Duration // bound on reservation delays; 0 means none
+	jitter    float64       // see SetJitter
+	changed   chan struct{} // closed when the rate or burst changes
+	shadow    bool
+	paused    chan struct{} // non-nil while paused; closed by Resume
+	pauseMode PauseMode
+	interval  int64 // ns between discrete refills; 0 refills continuously
+	phase     int64 // a discrete refill time, in ns since epoch
+	smooth    bool
+	catchUp   int64   // ns; see SetMaxCatchUp
+	warmup    *warmup // nil unless warming up
+	pub       published
+	counters  counters
+}
+
+// published mirrors the bucket for readers that do not take mu, so that
+// polling accessors never block admission. Writers update it under mu with
+// a sequence lock; readers retry if they overlap a write.
+type published struct {
+	seq       atomic.Uint64 // odd while a write is in progress
+	rate      atomic.Uint64 // math.Float64bits
+	burst     atomic.Int64
+	interval  atomic.Int64
+	phase     atomic.Int64
+	smooth    atomic.Bool
+	catchUp   atomic.Int64
+	tokens    atomic.Uint64 // math.Float64bits
+	updatedAt atomic.Int64
+}
+
+// publishLocked copies the bucket to rl.pub. Callers must hold rl.mu and
+// call it after every change to the rate, burst, refill interval or tokens.
+func (rl *RateLimiter) publishLocked() {
+	p := &rl.pub
+	p.seq.Add(1)
+	p.rate.Store(math.Float64bits(float64(rl.rate)))
+	p.burst.Store(int64(rl.maxTokens))
+	p.interval.Store(rl.interval)
+	p.phase.Store(rl.phase)
+	p.smooth.Store(rl.smooth)
+	p.catchUp.Store(rl.catchUp)
+	p.tokens.Store(math.Float64bits(rl.tokens))
+	p.updatedAt.Store(rl.updatedAt)
+	p.seq.Add(1)
+}
+
+// loadPublished returns a consistent copy of the bucket without locking.
+func (rl *RateLimiter) loadPublished() (b bucket, tokens float64, updatedAt int64) {
+	p := &rl.pub
+	for {
+		seq := p.seq.Load()
+		if seq&1 == 1 {
+			continue
+		}
+		b.rate = Rate(math.Float64frombits(p.rate.Load()))
+		b.burst = int(p.burst.Load())
+		b.interval = p.interval.Load()
+		b.phase = p.phase.Load()
+		b.smooth = p.smooth.Load()
+		b.catchUp = p.catchUp.Load()
+		tokens = math.Float64frombits(p.tokens.Load())
+		updatedAt = p.updatedAt.Load()
+		if p.seq.Load() == seq {
+			return b, tokens, updatedAt
+		}
+	}
+}
+
+// waiter is a caller queued in WaitN.
+type waiter struct {
+	n     int
+	ready chan struct{} // closed when the waiter reaches the front
 }
 
 func New(rate Rate, burst int, clk Clock) *RateLimiter {
 	if clk == nil {
 		clk = realClock{}
 	}
-	now := clk.Now()
-	return &RateLimiter{
+	rl := &RateLimiter{
 		rate:      rate,
 		maxTokens: burst,
 		tokens:    float64(burst),
-		updatedAt: now,
-		eventAt:   now,
 		clock:     clk,
 	}
+	rl.setEpoch()
+	rl.publishLocked()
+	return rl
+}
+
+// nanos converts t to nanoseconds since the limiter's epoch. Keeping
+// times as integers makes the hot path cheaper than time.Time arithmetic.
+func (rl *RateLimiter) nanos(t time.Time) int64 {
+	return int64(t.Sub(rl.epoch))
+}
+
+// timeAt converts nanoseconds since the limiter's epoch to a time.
+func (rl *RateLimiter) timeAt(ns int64) time.Time {
+	return rl.epoch.Add(time.Duration(ns))
+}
+
+// NewChecked is like New but rejects limits with undefined behavior
+// instead of accepting them silently. It returns an error matching
+// ErrInvalidLimit if rate is NaN or negative or burst is negative. A rate
+// of +Inf is treated as InfiniteRate. A zero rate gives a bucket that
+// never refills: only the initial burst is admitted until SetRate raises
+// it, as described there. A zero burst admits nothing but requests for
+// zero tokens unless the rate is infinite.
+func NewChecked(rate Rate, burst int, clk Clock) (*RateLimiter, error) {
+	switch {
+	case math.IsNaN(float64(rate)):
+		return nil, fmt.Errorf("%w: rate is NaN", ErrInvalidLimit)
+	case rate < 0:
+		return nil, fmt.Errorf("%w: rate %v is negative", ErrInvalidLimit, float64(rate))
+	case burst < 0:
+		return nil, fmt.Errorf("%w: burst %d is negative", ErrInvalidLimit, burst)
+	}
+	if math.IsInf(float64(rate), 1) {
+		rate = InfiniteRate
+	}
+	return New(rate, burst, clk), nil
 }
 
+// Rate returns the refill rate. Like Burst and AvailableTokens, it does
+// not take the limiter's lock and never delays admission.
 func (rl *RateLimiter) Rate() Rate {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	return rl.rate
+	b, _, _ := rl.loadPublished()
+	return b.rate
 }
 
 func (rl *RateLimiter) Burst() int {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	return rl.maxTokens
+	b, _, _ := rl.loadPublished()
+	return b.burst
 }
 
 func (rl *RateLimiter) AvailableTokens() float64 {
-	return rl.tokensAt(rl.clock.Now())
+	return rl.TokensAt(rl.now())
 }
 
-func (rl *RateLimiter) tokensAt(t time.Time) float64 {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	return rl.updateTokens(t)
+// TokensAt returns the number of tokens available at time t.
+func (rl *RateLimiter) TokensAt(t time.Time) float64 {
+	b, tokens, updatedAt := rl.loadPublished()
+	return b.tokensAt(tokens, updatedAt, rl.nanos(t))
 }
 
+// updateTokens returns the tokens in the bucket at t. Callers must hold
+// rl.mu.
 func (rl *RateLimiter) updateTokens(t time.Time) float64 {
-	if t.Before(rl.updatedAt) {
-		t = rl.updatedAt
+	now := rl.nanos(t)
+	if rl.catchUp > 0 && now-rl.updatedAt > rl.catchUp {
+		// Forget the time beyond the window, as if the clock had skipped
+		// it, so that later calls do not credit it either.
+		rl.updatedAt = now - rl.catchUp
+		rl.publishLocked()
 	}
-	delta := rl.rate.tokensFromDuration(t.Sub(rl.updatedAt))
-	tokens := rl.tokens + delta
-	if max := float64(rl.maxTokens); tokens > max {
+	return rl.bucketLocked().tokensAt(rl.tokens, rl.updatedAt, now)
+}
+
+// stampLocked returns t as the new update time of the bucket, in ns since
+// the epoch. A t before the last update, from a clock stepped back or a
+// replayed timestamp, counts as the last update unless the bucket was full
+// then: storing it would credit the same span of time twice. Callers must
+// hold rl.mu.
+func (rl *RateLimiter) stampLocked(t time.Time) int64 {
+	ns := rl.nanos(t)
+	if ns < rl.updatedAt && rl.tokens < float64(rl.bucketLocked().capacity()) {
+		return rl.updatedAt
+	}
+	return ns
+}
+
+// refill returns the tokens in a bucket holding tokens, after refilling at
+// rate for elapsed, capped at burst.
+func refill(tokens float64, elapsed time.Duration, rate Rate, burst int) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	tokens = snapTokens(tokens + rate.tokensFromDuration(elapsed))
+	if max := float64(burst); tokens > max {
 		tokens = max
 	}
 	return tokens
@@ -93,141 +275,555 @@ func (rl *RateLimiter) Allow() bool {
 	return rl.AllowN(1)
 }
 
+// AllowN reports whether n events may happen now, taking the tokens if so.
+// While callers are queued in WaitN, AllowN denies rather than take tokens
+// from them. AllowN(0) takes nothing and reports whether the bucket is out
+// of debt; a negative n is always denied, even in shadow mode, rather than
+// adding tokens.
 func (rl *RateLimiter) AllowN(n int) bool {
-	return rl.reserve(rl.clock.Now(), n, 0).ok
+	return rl.AllowNAt(rl.now(), n)
 }
 
-func (rl *RateLimiter) Wait(n int) error {
-	t := rl.clock.Now()
+// AllowAt is shorthand for AllowNAt(t, 1).
+func (rl *RateLimiter) AllowAt(t time.Time) bool {
+	return rl.AllowNAt(t, 1)
+}
+
+// AllowNAt is AllowN as of time t rather than the clock's current time.
+// Together with ReserveNAt and WaitNAt it lets simulations and log replays
+// drive the limiter with recorded timestamps. A t earlier than a previous
+// call's refills nothing and is otherwise taken as that call's time.
+func (rl *RateLimiter) AllowNAt(t time.Time, n int) bool {
+	_, allowed := rl.allow(t, float64(n))
+	return allowed
+}
+
+// allow implements AllowNAt and AllowCost, also returning the reservation
+// made, which is not OK if the limiter denied the request, even in shadow
+// mode.
+func (rl *RateLimiter) allow(t time.Time, cost float64) (Reservation, bool) {
+	return rl.allowAbove(t, cost, 0)
+}
+
+// allowAbove is allow, except that it also refuses to leave fewer than
+// floor times the burst in the bucket.
+func (rl *RateLimiter) allowAbove(t time.Time, cost, floor float64) (Reservation, bool) {
+	n := costN(cost)
+	if rl.parent != nil {
+		var r Reservation
+		if !rl.queued() {
+			r = rl.reserveAbove(t, cost, 0, floor)
+		}
+		shadow := !r.ok && cost >= 0 && rl.Shadow()
+		rl.observe(Decision{Time: t, N: n, Allowed: r.ok, Remaining: r.remaining, Shadowed: shadow})
+		return r, r.ok || shadow
+	}
 
+	// The common case takes the lock once and allocates nothing.
+	var r Reservation
 	rl.mu.Lock()
-	burst := rl.maxTokens
-	rate := rl.rate
+	if rl.waiters.Len() == 0 {
+		r = rl.reserveLocked(t, cost, 0, floor)
+	} else {
+		r.remaining = rl.updateTokens(t)
+	}
+	shadow := !r.ok && cost >= 0 && rl.shadow
+	observers := rl.observers
 	rl.mu.Unlock()
+	d := Decision{Time: t, N: n, Allowed: r.ok, Remaining: r.remaining, Shadowed: shadow}
+	rl.counters.record(d)
+	if len(observers) > 0 {
+		notify(observers, d)
+	}
+	return r, r.ok || shadow
+}
 
-	if n > burst && rate != InfiniteRate {
-		return fmt.Errorf("rate: Wait(n=%d) exceeds limiter's burst %d", n, burst)
+// Wait blocks until n tokens are available. It is shorthand for
+// WaitN(context.Background(), n).
+func (rl *RateLimiter) Wait(n int) error {
+	return rl.WaitN(context.Background(), n)
+}
+
+// WaitN blocks until n tokens are available or ctx is done. Concurrent
+// callers are served in arrival order, so a large request is not starved
+// by later small ones. Failures are reported as a *WaitError. If the queue
+// is bounded and full, WaitN fails with ErrQueueFull without waiting. If
+// the tokens cannot be had before ctx's deadline, WaitN fails at once with
+// ErrWaitTimeout, without taking them. If ctx is done before the
+// reservation fires, the reserved tokens are returned to the limiter and
+// the error also matches ctx.Err(). WaitN(0) returns once the bucket is out
+// of debt, taking nothing; a negative n fails with ErrNegativeTokens.
+func (rl *RateLimiter) WaitN(ctx context.Context, n int) error {
+	return rl.WaitNMaxDuration(ctx, n, InfiniteDuration)
+}
+
+// WaitMaxDuration is shorthand for
+// WaitNMaxDuration(context.Background(), n, max).
+func (rl *RateLimiter) WaitMaxDuration(n int, max time.Duration) error {
+	return rl.WaitNMaxDuration(context.Background(), n, max)
+}
+
+// WaitNMaxDuration is WaitN, except that it refuses to wait longer than
+// max in all: if the tokens cannot be had in time, it fails at once with
+// ErrDelayExceeded and the delay they would have needed as RetryAfter,
+// without taking them. This keeps a caller at a low rate from silently
+// sleeping for a very long time.
+func (rl *RateLimiter) WaitNMaxDuration(ctx context.Context, n int, max time.Duration) error {
+	return rl.waitCost(ctx, float64(n), max)
+}
+
+// waitCost implements WaitNMaxDuration and WaitCost.
+func (rl *RateLimiter) waitCost(ctx context.Context, cost float64, max time.Duration) error {
+	if !(cost >= 0) {
+		return negativeError(costN(cost))
+	}
+	t := rl.now()
+	if rl.Shadow() {
+		rl.shadowWait(t, cost)
+		return nil
 	}
+	r, err := rl.wait(ctx, cost, max)
+	rl.observe(Decision{
+		Time:      t,
+		N:         costN(cost),
+		Allowed:   err == nil,
+		Waited:    true,
+		Delay:     rl.now().Sub(t),
+		Remaining: r.remaining,
+	})
+	return err
+}
 
-	r := rl.reserve(t, n, InfiniteDuration)
+// WaitNAt is WaitN for a caller arriving at time t, without blocking: it
+// takes the tokens and returns the time at which the caller would have
+// been let through. It fails like WaitN if the request exceeds the burst
+// or can never be met. Because nothing sleeps, callers in WaitN are not
+// consulted, and replayed waiters are served in the order WaitNAt is
+// called.
+func (rl *RateLimiter) WaitNAt(t time.Time, n int) (time.Time, error) {
+	if err := rl.checkBurst(float64(n)); err != nil {
+		return time.Time{}, err
+	}
+	r := rl.reserve(t, float64(n), InfiniteDuration)
 	if !r.ok {
-		return fmt.Errorf("rate: Wait(n=%d) cannot reserve tokens", n)
+		if future := rl.maxFutureReserve(); future > 0 && r.need > future && r.need != InfiniteDuration {
+			return time.Time{}, &WaitError{Err: ErrReserveTooFar, N: n, RetryAfter: r.need - future}
+		}
+		return time.Time{}, neverError(n)
+	}
+	rl.observe(Decision{Time: t, N: n, Allowed: true, Waited: true, Delay: r.DelayFrom(t), Remaining: r.remaining})
+	if r.timeToAct.Before(t) {
+		return t, nil
+	}
+	return r.timeToAct, nil
+}
+
+// wait implements waitCost, returning the reservation it waited on.
+func (rl *RateLimiter) wait(ctx context.Context, cost float64, maxDelay time.Duration) (Reservation, error) {
+	n := costN(cost)
+	start := rl.now()
+	select {
+	case <-ctx.Done():
+		return Reservation{}, ctxError(n, ctx.Err(), 0)
+	default:
+	}
+
+	if err := rl.checkBurst(cost); err != nil {
+		return Reservation{}, err
+	}
+
+	elem, err := rl.enqueue(ctx, n)
+	if errors.Is(err, ErrQueueFull) {
+		return Reservation{}, &WaitError{Err: ErrQueueFull, N: n}
+	}
+	if err != nil {
+		return Reservation{}, ctxError(n, err, 0)
+	}
+	defer rl.dequeue(elem)
+
+	for started := false; ; started = true {
+		if err := rl.awaitResume(ctx, n); err != nil {
+			return Reservation{}, err
+		}
+		// Time has passed if we queued behind other waiters or were paused.
+		t := rl.now()
+		changed := rl.changes()
+		maxWait := InfiniteDuration
+		if maxDelay != InfiniteDuration {
+			maxWait = start.Add(maxDelay).Sub(t)
+		}
+		// Waits that would outlast ctx fail now rather than holding tokens
+		// they cannot use.
+		untilDeadline := InfiniteDuration
+		if d, ok := ctx.Deadline(); ok {
+			untilDeadline = time.Until(d)
+		}
+		r := rl.reserve(t, cost, min(maxWait, untilDeadline))
+		if !r.ok {
+			if rl.isPaused() {
+				continue
+			}
+			switch future := rl.maxFutureReserve(); {
+			case r.need == InfiniteDuration:
+				// Only a higher rate will ever provide the tokens.
+				if err := rl.awaitRate(ctx, changed); err != nil {
+					return r, ctxError(n, err, InfiniteDuration)
+				}
+				continue
+			case future > 0 && r.need > future && future < min(maxWait, untilDeadline):
+				return r, &WaitError{Err: ErrReserveTooFar, N: n, RetryAfter: r.need - future}
+			case r.need > maxWait:
+				return r, &WaitError{Err: ErrDelayExceeded, N: n, RetryAfter: r.need}
+			case r.need > untilDeadline:
+				return r, &WaitError{Err: ErrWaitTimeout, N: n, RetryAfter: r.need, cause: context.DeadlineExceeded}
+			}
+			return r, neverError(n)
+		}
+		delay := r.DelayFrom(t)
+		if delay <= 0 {
+			return r, nil
+		}
+		delay += rl.jitterFor(delay, min(maxWait, untilDeadline))
+		if !started {
+			rl.observeWaitStart(Decision{Time: t, N: n, Allowed: true, Waited: true, Delay: delay, Remaining: r.remaining})
+		}
+		err := sleepUntil(ctx, rl.clock, delay, changed)
+		if err == nil {
+			return r, nil
+		}
+		now := rl.now()
+		r.CancelAt(now)
+		if err != errWoken {
+			return r, ctxError(n, err, r.timeToAct.Sub(now))
+		}
+		// The limiter changed; reserve again on the new terms.
+	}
+}
+
+// awaitRate blocks a waiter on a limiter with a zero rate, or with an
+// ancestor at a zero rate, until that rate changes or ctx is done. changed
+// is rl's own change channel.
+func (rl *RateLimiter) awaitRate(ctx context.Context, changed <-chan struct{}) error {
+	for l := rl; l != nil; l = l.parent {
+		if l.Rate() != 0 {
+			continue
+		}
+		if l != rl {
+			changed = l.changes()
+			if l.Rate() != 0 {
+				return nil
+			}
+		}
+		break
+	}
+	select {
+	case <-changed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// changes returns a channel that is closed the next time the rate or burst
+// changes, so that waiters can recompute their delay.
+func (rl *RateLimiter) changes() <-chan struct{} {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.changed == nil {
+		rl.changed = make(chan struct{})
 	}
-	delay := r.DelayFrom(t)
-	if delay > 0 {
-		rl.clock.Sleep(delay)
+	return rl.changed
+}
+
+// wakeLocked wakes callers sleeping in WaitN after a change to the rate or
+// burst. Callers must hold rl.mu.
+func (rl *RateLimiter) wakeLocked() {
+	if rl.changed != nil {
+		close(rl.changed)
+		rl.changed = nil
+	}
+}
+
+// checkBurst fails if n tokens exceed the burst of rl or any of its
+// ancestors, so that waiting for them would never end.
+func (rl *RateLimiter) checkBurst(cost float64) error {
+	if !(cost >= 0) {
+		return negativeError(costN(cost))
+	}
+	for l := rl; l != nil; l = l.parent {
+		b, _, _ := l.loadPublished()
+		if cost > float64(b.burst) && b.rate != InfiniteRate {
+			return burstError(costN(cost), b.burst)
+		}
 	}
 	return nil
 }
 
-func (rl *RateLimiter) SetRate(newRate Rate) {
-	rl.SetRateAt(rl.clock.Now(), newRate)
+// enqueue joins the line of waiters for n tokens and blocks until the
+// caller is at its front or ctx is done. It returns ErrQueueFull if the line
+// is at its limits. On success the caller must call dequeue once it has its
+// tokens.
+func (rl *RateLimiter) enqueue(ctx context.Context, n int) (*list.Element, error) {
+	w := &waiter{n: n, ready: make(chan struct{})}
+	rl.mu.Lock()
+	if (rl.maxQueue > 0 && rl.waiters.Len() >= rl.maxQueue) ||
+		(rl.maxDemand > 0 && rl.demand+n > rl.maxDemand) {
+		rl.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	elem := rl.waiters.PushBack(w)
+	rl.demand += n
+	if rl.waiters.Front() == elem {
+		close(w.ready)
+	}
+	rl.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return elem, nil
+	case <-ctx.Done():
+		rl.dequeue(elem)
+		return nil, ctx.Err()
+	}
 }
 
-func (rl *RateLimiter) SetRateAt(t time.Time, newRate Rate) {
+// dequeue leaves the line of waiters, letting the next one go if elem was
+// at the front.
+func (rl *RateLimiter) dequeue(elem *list.Element) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
-	rl.tokens = rl.updateTokens(t)
-	rl.rate = newRate
-	rl.updatedAt = t
+	wasFront := rl.waiters.Front() == elem
+	rl.waiters.Remove(elem)
+	rl.demand -= elem.Value.(*waiter).n
+	if next := rl.waiters.Front(); wasFront && next != nil {
+		close(next.Value.(*waiter).ready)
+	}
 }
 
-func (rl *RateLimiter) SetBurst(newBurst int) {
-	rl.SetBurstAt(rl.clock.Now(), newBurst)
+// Waiting returns the number of callers blocked in WaitN.
+func (rl *RateLimiter) Waiting() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.waiters.Len()
 }
 
-func (rl *RateLimiter) SetBurstAt(t time.Time, newBurst int) {
+// SetMaxWaiting bounds the number of callers that may block in WaitN at
+// once. Further calls fail fast with ErrQueueFull instead of piling up
+// during overload. Zero or less removes the bound, which is the default.
+func (rl *RateLimiter) SetMaxWaiting(n int) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
-	rl.maxTokens = newBurst
-	rl.tokens = rl.updateTokens(t)
-	rl.updatedAt = t
-	rl.eventAt = t
+	rl.maxQueue = n
 }
 
-// internal reservation struct
+// SetMaxQueuedTokens bounds the total tokens requested by callers blocked
+// in WaitN, failing further calls with ErrQueueFull. Zero or less removes
+// the bound, which is the default.
+func (rl *RateLimiter) SetMaxQueuedTokens(n int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.maxDemand = n
+}
 
-type reservation struct {
-	ok        bool
-	r         *RateLimiter
-	tokens    int
-	timeToAct time.Time
-	rate      Rate
+// SetMaxFutureReserve bounds how far ahead a reservation may act, so
+// that a crowd of callers in ReserveN or WaitN cannot mortgage the
+// limiter's capacity for a long time to come. Beyond the bound ReserveN
+// returns a Reservation that is not OK, and WaitN and WaitNAt fail at once
+// with ErrReserveTooFar. A bound on an ancestor applies to its children
+// too. Zero or less removes the bound, which is the default.
+func (rl *RateLimiter) SetMaxFutureReserve(d time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.maxFuture = max(d, 0)
 }
 
-const InfiniteDuration = time.Duration(math.MaxInt64)
+// SetJitter makes WaitN sleep up to fraction longer than its delay, at
+// random, so that clients sharing a configuration and woken by the same
+// refill do not all act at the same instant. Jitter only ever delays a
+// caller, never lets it act before its tokens, and does not extend a wait
+// beyond ctx's deadline or the bound of WaitNMaxDuration. Zero or less,
+// the default, disables it.
+func (rl *RateLimiter) SetJitter(fraction float64) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.jitter = max(fraction, 0)
+}
 
-func (r *reservation) DelayFrom(t time.Time) time.Duration {
-	if !r.ok {
-		return InfiniteDuration
-	}
-	delay := r.timeToAct.Sub(t)
-	if delay < 0 {
+// jitterFor returns a random extra delay for a wait of delay, keeping the
+// total within limit.
+func (rl *RateLimiter) jitterFor(delay, limit time.Duration) time.Duration {
+	rl.mu.Lock()
+	fraction := rl.jitter
+	rl.mu.Unlock()
+	if fraction <= 0 || delay >= limit {
 		return 0
 	}
-	return delay
+	extra := time.Duration(rand.Float64() * fraction * float64(delay))
+	return min(extra, limit-delay)
 }
 
-func (r *reservation) Cancel() {
-	r.CancelAt(r.r.clock.Now())
+// maxFutureReserve returns the tightest bound set by SetMaxFutureReserve
+// on rl or its ancestors, or zero.
+func (rl *RateLimiter) maxFutureReserve() time.Duration {
+	var bound time.Duration
+	for l := rl; l != nil; l = l.parent {
+		l.mu.Lock()
+		if d := l.maxFuture; d > 0 && (bound == 0 || d < bound) {
+			bound = d
+		}
+		l.mu.Unlock()
+	}
+	return bound
 }
 
-func (r *reservation) CancelAt(t time.Time) {
-	if !r.ok {
-		return
-	}
-	r.r.mu.Lock()
-	defer r.r.mu.Unlock()
+// queued reports whether any caller is waiting in WaitN.
+func (rl *RateLimiter) queued() bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.waiters.Len() > 0
+}
 
-	if r.r.rate == InfiniteRate || r.tokens == 0 || r.timeToAct.Before(t) {
-		return
-	}
+// errWoken is returned by sleepUntil when its wake channel is closed.
+var errWoken = errors.New("ratelimiter: woken")
 
-	restore := float64(r.tokens) - r.rate.tokensFromDuration(r.r.eventAt.Sub(r.timeToAct))
-	if restore <= 0 {
-		return
+// sleepCtx waits for d on clk, returning early with ctx.Err() if ctx is
+// done first.
+func sleepCtx(ctx context.Context, clk Clock, d time.Duration) error {
+	return sleepUntil(ctx, clk, d, nil)
+}
+
+// sleepUntil is sleepCtx that also returns errWoken early if wake is
+// closed. A TimerClock's timer is stopped on early return; Clock.Sleep
+// cannot be interrupted, so on a plain Clock the sleeping goroutine is left
+// to finish in the background.
+func sleepUntil(ctx context.Context, clk Clock, d time.Duration, wake <-chan struct{}) error {
+	var fired <-chan time.Time
+	if tc, ok := clk.(TimerClock); ok {
+		timer := tc.NewTimer(d)
+		defer timer.Stop()
+		fired = timer.C()
+	} else if ctx.Done() == nil && wake == nil {
+		clk.Sleep(d)
+		return nil
+	} else {
+		done := make(chan time.Time, 1)
+		go func() {
+			clk.Sleep(d)
+			done <- time.Time{}
+		}()
+		fired = done
 	}
-	tokens := r.r.updateTokens(t) + restore
-	if max := float64(r.r.maxTokens); tokens > max {
-		tokens = max
+	select {
+	case <-fired:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-wake:
+		return errWoken
 	}
-	r.r.updatedAt = t
-	r.r.tokens = tokens
+}
 
-	if r.timeToAct == r.r.eventAt {
-		prev := r.timeToAct.Add(r.rate.durationFromTokens(float64(-r.tokens)))
-		if !prev.Before(t) {
-			r.r.eventAt = prev
-		}
+// SetRate changes the refill rate from now on, carrying the tokens over.
+// A zero rate is a supported way to block a limiter: once its tokens run
+// out, AllowN denies, ReserveN returns reservations that are not OK and
+// WaitN blocks until the rate is raised or its context ends.
+func (rl *RateLimiter) SetRate(newRate Rate) {
+	rl.SetRateAt(rl.now(), newRate)
+}
+
+func (rl *RateLimiter) SetRateAt(t time.Time, newRate Rate) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.warmup != nil {
+		rl.warmup.target = newRate
+		newRate = rl.warmup.rateAt(rl.nanos(t))
 	}
+	rl.setRateLocked(t, newRate)
+	rl.wakeLocked()
+}
+
+// setRateLocked changes the rate from t on, without waking waiters.
+// Callers must hold rl.mu.
+func (rl *RateLimiter) setRateLocked(t time.Time, newRate Rate) {
+	rl.tokens = rl.updateTokens(t)
+	rl.rate = newRate
+	rl.updatedAt = rl.stampLocked(t)
+	rl.publishLocked()
+}
+
+func (rl *RateLimiter) SetBurst(newBurst int) {
+	rl.SetBurstAt(rl.now(), newBurst)
 }
 
-func (rl *RateLimiter) reserve(t time.Time, n int, maxWait time.Duration) reservation {
+func (rl *RateLimiter) SetBurstAt(t time.Time, newBurst int) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
+	rl.maxTokens = newBurst
+	rl.tokens = rl.updateTokens(t)
+	rl.updatedAt = rl.stampLocked(t)
+	rl.eventAt = rl.updatedAt
+	rl.publishLocked()
+	rl.wakeLocked()
+}
+
+func (rl *RateLimiter) reserve(t time.Time, cost float64, maxWait time.Duration) Reservation {
+	return rl.reserveAbove(t, cost, maxWait, 0)
+}
 
+// reserveAbove is reserve, except that it also refuses to leave fewer than
+// floor times the burst in the bucket.
+func (rl *RateLimiter) reserveAbove(t time.Time, cost float64, maxWait time.Duration, floor float64) Reservation {
+	if rl.parent != nil {
+		return rl.reserveChain(t, cost, maxWait, floor)
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.reserveLocked(t, cost, maxWait, floor)
+}
+
+// reserveLocked is reserveAbove for a limiter without a parent. Callers
+// must hold rl.mu.
+func (rl *RateLimiter) reserveLocked(t time.Time, cost float64, maxWait time.Duration, floor float64) Reservation {
+	if rl.warmup != nil {
+		rl.warmLocked(t)
+	}
+	if rl.paused != nil || !(cost >= 0) {
+		return Reservation{r: rl, rate: rl.rate, tokens: cost, remaining: rl.updateTokens(t)}
+	}
 	if rl.rate == InfiniteRate {
-		return reservation{ok: true, r: rl, tokens: n, timeToAct: t}
+		return Reservation{ok: true, r: rl, tokens: cost, timeToAct: t, remaining: rl.updateTokens(t)}
 	}
 
-	tokens := rl.updateTokens(t) - float64(n)
+	tokens := rl.updateTokens(t) - cost
 	var wait time.Duration
 	if tokens < 0 {
-		wait = rl.rate.durationFromTokens(-tokens)
+		wait = rl.bucketLocked().untilTokens(rl.stampLocked(t), -tokens)
+	}
+	if rl.maxFuture > 0 {
+		maxWait = min(maxWait, rl.maxFuture)
 	}
 
-	ok := n <= rl.maxTokens && wait <= maxWait
-	res := reservation{
-		ok:    ok,
-		r:     rl,
-		rate:  rl.rate,
-		tokens: n,
+	ok := cost <= float64(rl.maxTokens) && wait <= maxWait && wait != InfiniteDuration &&
+		(floor <= 0 || tokens >= floor*float64(rl.maxTokens))
+	res := Reservation{
+		ok:        ok,
+		r:         rl,
+		rate:      rl.rate,
+		tokens:    cost,
+		remaining: tokens + cost,
+		need:      wait,
 	}
 	if ok {
 		res.timeToAct = t.Add(wait)
-		rl.updatedAt = t
+		res.remaining = tokens
+	}
+	// A reservation of zero tokens only checks; it must not move eventAt,
+	// which later cancellations are measured against.
+	if ok && cost > 0 {
+		rl.updatedAt = rl.stampLocked(t)
 		rl.tokens = tokens
-		rl.eventAt = res.timeToAct
+		rl.eventAt = rl.updatedAt + int64(wait)
+		rl.publishLocked()
 	}
 	return res
 }
@@ -247,5 +843,15 @@ func (r Rate) tokensFromDuration(d time.Duration) float64 {
 	if r <= 0 {
 		return 0
 	}
-	return d.Seconds() * float64(r)
+	return snapTokens(d.Seconds() * float64(r))
+}
+
+// snapTokens rounds token counts within a rounding error of a whole token
+// to it. Rates such as 11 per day are not exact in float64, and without
+// this waiting exactly a day would refill 10.999999999999998 tokens.
+func snapTokens(tokens float64) float64 {
+	if rounded := math.Round(tokens); math.Abs(tokens-rounded) < 1e-9 {
+		return rounded
+	}
+	return tokens
 }
@@ -1,22 +1,42 @@
 package ratelimiter
 
 import (
+	"context"
+	"errors"
+	"math"
+	"sync"
 	"testing"
 	"time"
 )
 
 type fakeClock struct {
+	mu   sync.Mutex
 	time time.Time
 }
 
 func (fc *fakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
 	return fc.time
 }
 
 func (fc *fakeClock) Sleep(d time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
 	fc.time = fc.time.Add(d)
 }
 
+// blockingClock is a fakeClock whose Sleep blocks until release is closed.
+type blockingClock struct {
+	*fakeClock
+	release chan struct{}
+}
+
+func (bc *blockingClock) Sleep(d time.Duration) {
+	<-bc.release
+	bc.fakeClock.Sleep(d)
+}
+
 func newFakeClock(start time.Time) *fakeClock {
 	return &fakeClock{time: start}
 }
@@ -47,7 +67,7 @@ func TestAllowN(t *testing.T) {
 	if !rl.AllowN(3) {
 		t.Errorf("expected 3 tokens to be allowed initially")
 	}
-	if rl.AllowN( 1) {
+	if rl.AllowN(1) {
 		t.Errorf("expected token to be denied")
 	}
 
@@ -124,7 +144,7 @@ func TestTokensAndAdvance(t *testing.T) {
 	if tok := rl.AvailableTokens(); tok != 3 {
 		t.Fatalf("expected 3 tokens initially, got %f", tok)
 	}
-	_ = rl.AllowN( 2)
+	_ = rl.AllowN(2)
 	if tok := rl.AvailableTokens(); tok > 1.01 {
 		t.Fatalf("expected about 1 token left, got %f", tok)
 	}
@@ -141,3 +161,591 @@ func TestInfRate(t *testing.T) {
 		t.Fatalf("expected all tokens to be allowed with Inf rate")
 	}
 }
+
+func TestWaitN(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(Every(100*time.Millisecond), 1, clk)
+	if err := rl.WaitN(context.Background(), 1); err != nil {
+		t.Fatalf("expected first wait to succeed, got %v", err)
+	}
+	if err := rl.WaitN(context.Background(), 1); err != nil {
+		t.Fatalf("expected second wait to succeed, got %v", err)
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 100*time.Millisecond {
+		t.Fatalf("expected clock to advance 100ms, got %v", got)
+	}
+}
+
+func TestWaitMaxDuration(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(Every(time.Minute), 2, clk)
+	rl.AllowN(2)
+
+	err := rl.WaitMaxDuration(1, 30*time.Second)
+	var we *WaitError
+	if !errors.As(err, &we) || !errors.Is(err, ErrDelayExceeded) || we.RetryAfter != time.Minute {
+		t.Fatalf("WaitMaxDuration = %#v, want ErrDelayExceeded after a minute", err)
+	}
+	if clk.Now() != time.Unix(0, 0) {
+		t.Fatal("WaitMaxDuration slept before failing")
+	}
+	if err := rl.WaitMaxDuration(1, time.Minute); err != nil {
+		t.Fatalf("WaitMaxDuration within the bound = %v", err)
+	}
+	if clk.Now() != time.Unix(60, 0) {
+		t.Fatalf("clock at %v, want a minute later", clk.Now())
+	}
+	// The failed call took no tokens, so the next one is due a minute on.
+	if got := rl.AvailableTokens(); got != 0 {
+		t.Fatalf("%v tokens left, want 0", got)
+	}
+	if err := rl.WaitMaxDuration(3, time.Hour); !errors.Is(err, ErrBurstExceeded) {
+		t.Fatalf("WaitMaxDuration beyond the burst = %v", err)
+	}
+}
+
+func TestWaitNFailsBeforeDeadline(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(Every(time.Hour), 1, clk)
+	rl.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	err := rl.WaitN(ctx, 1)
+	var we *WaitError
+	if !errors.As(err, &we) || !errors.Is(err, ErrWaitTimeout) || !errors.Is(err, context.DeadlineExceeded) || we.RetryAfter != time.Hour {
+		t.Fatalf("WaitN past the deadline = %#v", err)
+	}
+	if ctx.Err() != nil || clk.Now() != time.Unix(0, 0) {
+		t.Fatal("WaitN waited for the deadline")
+	}
+	// No tokens were taken: an hour on, exactly one is back.
+	clk.Sleep(time.Hour)
+	if !rl.Allow() {
+		t.Fatal("the failed wait consumed a token")
+	}
+}
+
+func TestWaitNContextCanceled(t *testing.T) {
+	clk := &blockingClock{newFakeClock(time.Unix(0, 0)), make(chan struct{})}
+	defer close(clk.release)
+	rl := New(Every(time.Second), 1, clk)
+	rl.Allow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- rl.WaitN(ctx, 1) }()
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	clk.fakeClock.Sleep(time.Second)
+	if !rl.Allow() {
+		t.Fatalf("expected canceled wait to refund its token")
+	}
+}
+
+func TestWaitNContextAlreadyDone(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(Every(time.Second), 1, clk)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := rl.WaitN(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if !rl.Allow() {
+		t.Fatalf("expected no tokens to be consumed")
+	}
+}
+
+func TestWaitNServesArrivalOrder(t *testing.T) {
+	clk := &blockingClock{newFakeClock(time.Unix(0, 0)), make(chan struct{})}
+	rl := New(1, 3, clk)
+	rl.AllowN(3)
+
+	errc := make(chan error, 4)
+	go func() { errc <- rl.WaitN(context.Background(), 3) }()
+	for rl.Waiting() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		go func() { errc <- rl.WaitN(context.Background(), 1) }()
+	}
+	for rl.Waiting() < 4 {
+		time.Sleep(time.Millisecond)
+	}
+	if got := rl.AvailableTokens(); got != -3 {
+		t.Fatalf("expected only the first waiter to hold a reservation, got %v tokens", got)
+	}
+	if rl.Allow() {
+		t.Fatal("expected Allow not to take tokens from queued waiters")
+	}
+
+	close(clk.release)
+	for i := 0; i < 4; i++ {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 6*time.Second {
+		t.Fatalf("expected waiters to be served back to back in 6s, got %v", got)
+	}
+	if got := rl.Waiting(); got != 0 {
+		t.Fatalf("expected an empty queue, got %d waiters", got)
+	}
+}
+
+func TestWaitNCanceledWhileQueued(t *testing.T) {
+	clk := &blockingClock{newFakeClock(time.Unix(0, 0)), make(chan struct{})}
+	rl := New(1, 1, clk)
+	rl.Allow()
+
+	errc := make(chan error, 1)
+	go func() { errc <- rl.WaitN(context.Background(), 1) }()
+	for rl.Waiting() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := make(chan error, 1)
+	go func() { queued <- rl.WaitN(ctx, 1) }()
+	for rl.Waiting() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-queued; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if got := rl.Waiting(); got != 1 {
+		t.Fatalf("expected the canceled waiter to leave the queue, got %d", got)
+	}
+	close(clk.release)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitNQueueBounds(t *testing.T) {
+	clk := &blockingClock{newFakeClock(time.Unix(0, 0)), make(chan struct{})}
+	rl := New(1, 5, clk)
+	rl.AllowN(5)
+	rl.SetMaxWaiting(2)
+	rl.SetMaxQueuedTokens(4)
+
+	errc := make(chan error, 2)
+	go func() { errc <- rl.WaitN(context.Background(), 3) }()
+	for rl.Waiting() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := rl.WaitN(context.Background(), 2); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected the token bound to reject, got %v", err)
+	}
+	go func() { errc <- rl.WaitN(context.Background(), 1) }()
+	for rl.Waiting() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := rl.WaitN(context.Background(), 1); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected the waiter bound to reject, got %v", err)
+	}
+
+	close(clk.release)
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+	rl.SetMaxWaiting(0)
+	if err := rl.WaitN(context.Background(), 1); err != nil {
+		t.Fatalf("expected an unbounded queue to accept, got %v", err)
+	}
+}
+
+func TestNewChecked(t *testing.T) {
+	for _, tc := range []struct {
+		rate  Rate
+		burst int
+	}{
+		{Rate(math.NaN()), 1},
+		{-1, 1},
+		{1, -1},
+	} {
+		if _, err := NewChecked(tc.rate, tc.burst, nil); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("NewChecked(%v, %d): expected ErrInvalidLimit, got %v", tc.rate, tc.burst, err)
+		}
+	}
+
+	rl, err := NewChecked(Rate(math.Inf(1)), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rl.Rate() != InfiniteRate || !rl.AllowN(100) {
+		t.Fatalf("expected +Inf to behave as InfiniteRate, got %v", rl.Rate())
+	}
+	if _, err := NewChecked(0, 0, nil); err != nil {
+		t.Fatalf("expected zero rate and burst to be valid, got %v", err)
+	}
+}
+
+func TestPerDayRefillsExactly(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	for _, n := range []int{11, 22, 29, 10000} {
+		rl := New(PerDay(n), n, clk)
+		if !rl.AllowN(n) {
+			t.Fatalf("%d/day: expected a full bucket", n)
+		}
+		clk.Sleep(24 * time.Hour)
+		if got := rl.AvailableTokens(); got != float64(n) {
+			t.Fatalf("%d/day: expected a day to refill %d tokens, got %v", n, n, got)
+		}
+		if !rl.AllowN(n) {
+			t.Fatalf("%d/day: expected a day to refill the bucket, got %v tokens", n, rl.AvailableTokens())
+		}
+		if d := rl.ReserveN(1).Delay(); d > 24*time.Hour/time.Duration(n)+time.Nanosecond {
+			t.Fatalf("%d/day: unexpected delay %v", n, d)
+		}
+	}
+}
+
+func TestPer(t *testing.T) {
+	if got := PerMinute(120); got != 2 {
+		t.Fatalf("PerMinute(120) = %v", got)
+	}
+	if got := PerHour(3600); got != PerSecond(1) {
+		t.Fatalf("PerHour(3600) = %v", got)
+	}
+	if got := Per(1, 0); got != InfiniteRate {
+		t.Fatalf("Per(1, 0) = %v", got)
+	}
+}
+
+func TestAllowDoesNotAllocate(t *testing.T) {
+	rl := New(1e9, 1<<30, newFakeClock(time.Unix(0, 0)))
+	if allocs := testing.AllocsPerRun(1000, func() { rl.Allow() }); allocs != 0 {
+		t.Fatalf("expected Allow not to allocate, got %v allocations", allocs)
+	}
+}
+
+func TestWaitNWokenBySetRate(t *testing.T) {
+	rl := New(0.1, 1, nil)
+	rl.Allow()
+
+	done := make(chan error, 1)
+	go func() { done <- rl.WaitN(context.Background(), 1) }()
+	for rl.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	rl.SetRate(1000)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("WaitN: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitN kept sleeping on the old rate after SetRate")
+	}
+}
+
+func TestWaitNCanceledStopsTimer(t *testing.T) {
+	rl := New(0.001, 1, nil)
+	rl.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := rl.WaitN(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitN error = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("WaitN returned after %v, want shortly after the deadline", elapsed)
+	}
+	if got := rl.AvailableTokens(); got < -0.01 {
+		t.Fatalf("AvailableTokens() = %v, want the canceled reservation refunded", got)
+	}
+}
+
+func TestReplayAtRecordedTimes(t *testing.T) {
+	// The limiter's own clock is never consulted during a replay.
+	rl := New(2, 2, newFakeClock(time.Unix(0, 0)))
+	base := time.Unix(1_700_000_000, 0)
+	rl.SetRateAt(base, 2)
+
+	log := []struct {
+		offset time.Duration
+		want   bool
+	}{
+		{0, true},
+		{0, true},
+		{100 * time.Millisecond, false},
+		{500 * time.Millisecond, true},
+		{600 * time.Millisecond, false},
+		{2 * time.Second, true},
+	}
+	for i, ev := range log {
+		if got := rl.AllowAt(base.Add(ev.offset)); got != ev.want {
+			t.Fatalf("event %d at +%v: AllowAt = %v, want %v", i, ev.offset, got, ev.want)
+		}
+	}
+
+	at := base.Add(3 * time.Second)
+	if r := rl.ReserveAt(at); !r.OK() || r.DelayFrom(at) != 0 {
+		t.Fatalf("ReserveAt: ok=%v delay=%v, want an immediate reservation", r.OK(), r.DelayFrom(at))
+	}
+}
+
+func TestWaitNAt(t *testing.T) {
+	rl := New(1, 2, newFakeClock(time.Unix(0, 0)))
+	base := time.Unix(1_700_000_000, 0)
+	rl.SetRateAt(base, 1)
+
+	for i, want := range []time.Time{base, base, base.Add(time.Second), base.Add(2 * time.Second)} {
+		got, err := rl.WaitNAt(base, 1)
+		if err != nil {
+			t.Fatalf("waiter %d: %v", i, err)
+		}
+		if !got.Equal(want) {
+			t.Fatalf("waiter %d admitted at %v, want %v", i, got, want)
+		}
+	}
+
+	if _, err := rl.WaitNAt(base, 3); !errors.Is(err, ErrBurstExceeded) {
+		t.Fatalf("WaitNAt over burst: %v, want ErrBurstExceeded", err)
+	}
+}
+
+func TestReadsDoNotTakeLock(t *testing.T) {
+	fc := newFakeClock(time.Unix(0, 0))
+	rl := New(10, 5, fc)
+	rl.AllowN(3)
+
+	rl.mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if got := rl.Rate(); got != 10 {
+			t.Errorf("Rate() = %v, want 10", got)
+		}
+		if got := rl.Burst(); got != 5 {
+			t.Errorf("Burst() = %d, want 5", got)
+		}
+		if got := rl.AvailableTokens(); got != 2 {
+			t.Errorf("AvailableTokens() = %v, want 2", got)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reads blocked on the limiter's lock")
+	}
+	rl.mu.Unlock()
+
+	rl.SetRate(20)
+	rl.SetBurst(8)
+	fc.Sleep(100 * time.Millisecond)
+	if got := rl.AvailableTokens(); got != 4 {
+		t.Fatalf("AvailableTokens() after reconfigure = %v, want 4", got)
+	}
+}
+
+func TestReadsConsistentUnderContention(t *testing.T) {
+	rl := New(1000, 10, nil)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				rl.Allow()
+				rl.SetBurst(10)
+			}
+		}
+	}()
+	for i := 0; i < 10000; i++ {
+		if tokens := rl.AvailableTokens(); tokens > 10 || math.IsNaN(tokens) {
+			t.Fatalf("AvailableTokens() = %v, want at most the burst", tokens)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func BenchmarkAvailableTokensParallel(b *testing.B) {
+	rl := New(1e9, 1<<30, nil)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rl.AvailableTokens()
+		}
+	})
+}
+
+func BenchmarkAllow(b *testing.B) {
+	rl := New(1e9, 1<<30, nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rl.Allow()
+	}
+}
+
+func TestZeroRateBlocks(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(0, 1, clk)
+	if !rl.Allow() || rl.Allow() {
+		t.Fatal("zero-rate bucket did not admit exactly its initial token")
+	}
+	if r := rl.Reserve(); r.OK() || r.Delay() != InfiniteDuration {
+		t.Fatal("reservation on a blocked limiter is OK")
+	}
+	if tok := rl.AvailableTokens(); tok != 0 {
+		t.Fatalf("refused reservation left %v tokens", tok)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := rl.WaitN(ctx, 1); !errors.Is(err, ErrWaitTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitN on a blocked limiter = %v, want a timeout at the deadline", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- rl.Wait(1) }()
+	for rl.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Wait on a blocked limiter returned %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	rl.SetRate(10)
+	if err := <-done; err != nil {
+		t.Fatalf("Wait after raising the rate = %v", err)
+	}
+	if clk.Now().Sub(time.Unix(0, 0)) != 100*time.Millisecond {
+		t.Fatalf("waited until %v, want a token at the new rate", clk.Now())
+	}
+}
+
+func TestZeroRateParentBlocks(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	parent := New(0, 1, clk)
+	parent.Allow()
+	child := parent.NewChild(10, 10)
+	done := make(chan error, 1)
+	go func() { done <- child.Wait(1) }()
+	for child.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	parent.SetRate(10)
+	if err := <-done; err != nil {
+		t.Fatalf("Wait after raising the parent's rate = %v", err)
+	}
+}
+
+func TestZeroAndNegativeN(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(10, 2, clk)
+	if !rl.AllowN(0) || rl.AvailableTokens() != 2 {
+		t.Fatal("AllowN(0) on a full bucket did not admit without taking tokens")
+	}
+	if rl.AllowN(-1) || rl.AvailableTokens() != 2 {
+		t.Fatalf("AllowN(-1) admitted or changed the bucket to %v tokens", rl.AvailableTokens())
+	}
+	if r := rl.ReserveN(-1); r.OK() {
+		t.Fatal("ReserveN(-1) is OK")
+	}
+	if err := rl.WaitN(context.Background(), -1); !errors.Is(err, ErrNegativeTokens) {
+		t.Fatalf("WaitN(-1) = %v, want ErrNegativeTokens", err)
+	}
+	if _, err := rl.WaitNAt(clk.Now(), -1); !errors.Is(err, ErrNegativeTokens) {
+		t.Fatalf("WaitNAt(-1) = %v, want ErrNegativeTokens", err)
+	}
+	rl.SetShadow(true)
+	if rl.AllowN(-1) {
+		t.Fatal("shadow mode admitted AllowN(-1)")
+	}
+	rl.SetShadow(false)
+
+	// In debt, AllowN(0) is denied and WaitN(0) waits the debt out.
+	rl.ReserveN(2)
+	r := rl.ReserveN(1)
+	if rl.AllowN(0) {
+		t.Fatal("AllowN(0) admitted while the bucket is in debt")
+	}
+	if err := rl.WaitN(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 100*time.Millisecond {
+		t.Fatalf("WaitN(0) returned after %v, want the debt paid off", got)
+	}
+	// Zero-token checks must not disturb refunds of reservations.
+	r.Cancel()
+	if tok := rl.AvailableTokens(); tok != 1 {
+		t.Fatalf("cancel after checks left %v tokens, want 1", tok)
+	}
+
+	atom := NewAtomic(10, 2, clk)
+	if atom.AllowN(-1) || atom.AvailableTokens() != 2 {
+		t.Fatal("AtomicLimiter.AllowN(-1) admitted or added tokens")
+	}
+	if err := atom.Wait(-1); !errors.Is(err, ErrNegativeTokens) {
+		t.Fatalf("AtomicLimiter.Wait(-1) = %v, want ErrNegativeTokens", err)
+	}
+}
+
+func TestZeroBurst(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(10, 0, clk)
+	if rl.Allow() {
+		t.Fatal("zero burst admitted an event")
+	}
+	if !rl.AllowN(0) {
+		t.Fatal("zero burst denied a request for no tokens")
+	}
+	if err := rl.Wait(1); !errors.Is(err, ErrBurstExceeded) {
+		t.Fatalf("Wait(1) with zero burst = %v, want ErrBurstExceeded", err)
+	}
+	if !New(InfiniteRate, 0, clk).Allow() {
+		t.Fatal("infinite rate with zero burst denied an event")
+	}
+}
+
+func TestJitter(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := NewWithOptions(10, WithClock(clk), WithJitter(0.5))
+	rl.Allow()
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 20; i++ {
+		before := clk.Now()
+		r := rl.ReserveN(1)
+		want := r.Delay()
+		r.Cancel()
+		if err := rl.Wait(1); err != nil {
+			t.Fatal(err)
+		}
+		got := clk.Now().Sub(before)
+		if got < want || got > want+want/2 {
+			t.Fatalf("jittered wait of %v for a %v delay", got, want)
+		}
+		seen[got] = true
+	}
+	if len(seen) < 2 {
+		t.Fatal("jitter did not vary the delays")
+	}
+
+	rl.SetJitter(0)
+	before := clk.Now()
+	rl.Wait(1)
+	if got := clk.Now().Sub(before); got > 100*time.Millisecond {
+		t.Fatalf("waited %v without jitter, want at most 100ms", got)
+	}
+	rl.SetJitter(10)
+	rl.Allow()
+	if err := rl.WaitMaxDuration(1, 150*time.Millisecond); err != nil {
+		t.Fatalf("jitter pushed a wait past its bound: %v", err)
+	}
+}
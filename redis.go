@@ -0,0 +1,195 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RedisScripter is the subset of a Redis client used by RedisLimiter. With
+// github.com/redis/go-redis it can be satisfied by a one-line adapter:
+//
+//	func (a adapter) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+//		return a.Client.Eval(ctx, script, keys, args...).Result()
+//	}
+type RedisScripter interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// redisTokenBucket updates the bucket stored in the hash KEYS[1] and
+// reserves ARGV[4] tokens if they are available within ARGV[5]
+// microseconds (negative means no bound). A negative token count returns
+// tokens to the bucket. Times are microseconds taken from the caller's
+// clock, so replicas sharing a key should have synchronized clocks.
+//
+// It returns {ok, wait}, where wait is in microseconds and -1 means the
+// tokens will never become available.
+const redisTokenBucket = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local max_wait = tonumber(ARGV[5])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+if now > ts then
+  if rate > 0 then
+    tokens = math.min(burst, tokens + (now - ts) * rate / 1e6)
+  end
+  ts = now
+end
+
+local wait = 0
+if n < 0 then
+  tokens = math.min(burst, tokens - n)
+else
+  if n > burst then
+    return {0, -1}
+  end
+  if tokens < n then
+    if rate <= 0 then
+      return {0, -1}
+    end
+    wait = math.ceil((n - tokens) * 1e6 / rate)
+  end
+  if max_wait >= 0 and wait > max_wait then
+    return {0, wait}
+  end
+  tokens = tokens - n
+end
+
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(ts))
+if rate > 0 then
+  redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) * 1e3 / rate) + 1000)
+end
+return {1, wait}
+`
+
+// RedisLimiter is a token bucket whose state lives in Redis, so that every
+// process sharing the key enforces a single, global limit. The bucket math
+// runs in a Lua script and is therefore atomic across clients.
+type RedisLimiter struct {
+	client RedisScripter
+	key    string
+	rate   Rate
+	burst  int
+	clock  Clock
+}
+
+// NewRedis returns a RedisLimiter storing its bucket under key.
+func NewRedis(client RedisScripter, key string, rate Rate, burst int, clk Clock) *RedisLimiter {
+	if clk == nil {
+		clk = realClock{}
+	}
+	return &RedisLimiter{
+		client: client,
+		key:    key,
+		rate:   rate,
+		burst:  burst,
+		clock:  clk,
+	}
+}
+
+func (l *RedisLimiter) Rate() Rate {
+	return l.rate
+}
+
+func (l *RedisLimiter) Burst() int {
+	return l.burst
+}
+
+func (l *RedisLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+// AllowN reports whether n tokens can be taken immediately. Errors talking
+// to Redis are treated as a denial; use AllowNContext to observe them.
+func (l *RedisLimiter) AllowN(n int) bool {
+	ok, err := l.AllowNContext(context.Background(), n)
+	return ok && err == nil
+}
+
+// AllowNContext is like AllowN but honors ctx and reports Redis errors.
+// A negative n is denied without contacting the server.
+func (l *RedisLimiter) AllowNContext(ctx context.Context, n int) (bool, error) {
+	if n < 0 {
+		return false, nil
+	}
+	ok, _, err := l.reserve(ctx, n, 0)
+	return ok, err
+}
+
+// Wait is shorthand for WaitN(context.Background(), n).
+func (l *RedisLimiter) Wait(n int) error {
+	return l.WaitN(context.Background(), n)
+}
+
+// WaitN blocks until n tokens are available or ctx is done. Tokens reserved
+// for a wait that is abandoned because ctx is done are returned to Redis.
+// A negative n fails with ErrNegativeTokens.
+func (l *RedisLimiter) WaitN(ctx context.Context, n int) error {
+	if n < 0 {
+		return negativeError(n)
+	}
+	select {
+	case <-ctx.Done():
+		return ctxError(n, ctx.Err(), 0)
+	default:
+	}
+	if n > l.burst && l.rate != InfiniteRate {
+		return burstError(n, l.burst)
+	}
+	ok, wait, err := l.reserve(ctx, n, InfiniteDuration)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return neverError(n)
+	}
+	if wait <= 0 {
+		return nil
+	}
+	if err := sleepCtx(ctx, l.clock, wait); err != nil {
+		// Use a fresh context: ctx is already done.
+		_, _, _ = l.reserve(context.Background(), -n, 0)
+		return ctxError(n, err, wait)
+	}
+	return nil
+}
+
+// reserve runs the token bucket script for n tokens and returns whether they
+// were taken and how long the caller must wait before using them.
+func (l *RedisLimiter) reserve(ctx context.Context, n int, maxWait time.Duration) (bool, time.Duration, error) {
+	if l.rate == InfiniteRate {
+		return true, 0, nil
+	}
+	maxWaitMicros := int64(-1)
+	if maxWait != InfiniteDuration {
+		maxWaitMicros = maxWait.Microseconds()
+	}
+	now := l.clock.Now().UnixMicro()
+	res, err := l.client.Eval(ctx, redisTokenBucket, []string{l.key},
+		float64(l.rate), l.burst, now, n, maxWaitMicros)
+	if err != nil {
+		return false, 0, err
+	}
+	vals, ok := res.([]interface{})
+	if !ok || len(vals) != 2 {
+		return false, 0, fmt.Errorf("rate: unexpected redis reply %v", res)
+	}
+	allowed, ok1 := vals[0].(int64)
+	wait, ok2 := vals[1].(int64)
+	if !ok1 || !ok2 {
+		return false, 0, fmt.Errorf("rate: unexpected redis reply %v", res)
+	}
+	if wait < 0 {
+		return allowed == 1, InfiniteDuration, nil
+	}
+	return allowed == 1, time.Duration(wait) * time.Microsecond, nil
+}
@@ -0,0 +1,149 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis evaluates redisTokenBucket in Go against an in-memory hash.
+type fakeRedis struct {
+	mu      sync.Mutex
+	buckets map[string][2]float64
+	err     error
+	calls   int
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{buckets: make(map[string][2]float64)}
+}
+
+func (f *fakeRedis) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	if script != redisTokenBucket {
+		return nil, errors.New("unknown script")
+	}
+	rate := args[0].(float64)
+	burst := float64(args[1].(int))
+	now := float64(args[2].(int64))
+	n := float64(args[3].(int))
+	maxWait := float64(args[4].(int64))
+
+	state, ok := f.buckets[keys[0]]
+	tokens, ts := state[0], state[1]
+	if !ok {
+		tokens, ts = burst, now
+	}
+	if now > ts {
+		if rate > 0 {
+			tokens = math.Min(burst, tokens+(now-ts)*rate/1e6)
+		}
+		ts = now
+	}
+	wait := 0.0
+	if n < 0 {
+		tokens = math.Min(burst, tokens-n)
+	} else {
+		if n > burst {
+			return []interface{}{int64(0), int64(-1)}, nil
+		}
+		if tokens < n {
+			if rate <= 0 {
+				return []interface{}{int64(0), int64(-1)}, nil
+			}
+			wait = math.Ceil((n - tokens) * 1e6 / rate)
+		}
+		if maxWait >= 0 && wait > maxWait {
+			return []interface{}{int64(0), int64(wait)}, nil
+		}
+		tokens -= n
+	}
+	f.buckets[keys[0]] = [2]float64{tokens, ts}
+	return []interface{}{int64(1), int64(wait)}, nil
+}
+
+func TestRedisLimiterSharedBucket(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	redis := newFakeRedis()
+	a := NewRedis(redis, "api", Every(100*time.Millisecond), 2, clk)
+	b := NewRedis(redis, "api", Every(100*time.Millisecond), 2, clk)
+
+	if !a.Allow() || !b.Allow() {
+		t.Fatalf("expected burst to be shared and allowed")
+	}
+	if a.Allow() || b.Allow() {
+		t.Fatalf("expected shared bucket to be exhausted")
+	}
+	clk.Sleep(100 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatalf("expected token after refill")
+	}
+}
+
+func TestRedisLimiterWaitN(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewRedis(newFakeRedis(), "api", Every(100*time.Millisecond), 1, clk)
+
+	if err := l.WaitN(context.Background(), 1); err != nil {
+		t.Fatalf("expected first wait to succeed, got %v", err)
+	}
+	if err := l.WaitN(context.Background(), 1); err != nil {
+		t.Fatalf("expected second wait to succeed, got %v", err)
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 100*time.Millisecond {
+		t.Fatalf("expected to wait 100ms, got %v", got)
+	}
+	if err := l.WaitN(context.Background(), 2); err == nil {
+		t.Fatalf("expected wait above burst to fail")
+	}
+}
+
+func TestRedisLimiterErrors(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	redis := newFakeRedis()
+	redis.err = errors.New("connection refused")
+	l := NewRedis(redis, "api", Every(time.Second), 1, clk)
+
+	if l.Allow() {
+		t.Fatalf("expected Allow to deny on redis error")
+	}
+	if _, err := l.AllowNContext(context.Background(), 1); err == nil {
+		t.Fatalf("expected error to be reported")
+	}
+	if err := l.WaitN(context.Background(), 1); err == nil {
+		t.Fatalf("expected WaitN to report error")
+	}
+}
+
+func TestRedisLimiterInfiniteRate(t *testing.T) {
+	redis := newFakeRedis()
+	l := NewRedis(redis, "api", InfiniteRate, 0, nil)
+	if !l.AllowN(1000) {
+		t.Fatalf("expected infinite rate to allow")
+	}
+	if redis.calls != 0 {
+		t.Fatalf("expected infinite rate to skip redis, got %d calls", redis.calls)
+	}
+}
+
+func TestRedisLimiterNegativeN(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewRedis(newFakeRedis(), "api", Every(time.Second), 2, clk)
+	if l.AllowN(-5) {
+		t.Fatal("AllowN(-5) admitted")
+	}
+	if err := l.WaitN(context.Background(), -5); !errors.Is(err, ErrNegativeTokens) {
+		t.Fatalf("WaitN(-5) = %v, want ErrNegativeTokens", err)
+	}
+	if !l.AllowN(2) || l.AllowN(1) {
+		t.Fatal("negative n changed the bucket")
+	}
+}
@@ -0,0 +1,164 @@
+package ratelimiter
+
+import (
+	"math"
+	"time"
+)
+
+// bucket describes how a RateLimiter's bucket fills.
+type bucket struct {
+	rate     Rate
+	burst    int
+	interval int64 // ns between discrete refills; 0 refills continuously
+	phase    int64 // a discrete refill time, in ns since the epoch
+	smooth   bool  // holds at most one token
+	catchUp  int64 // most ns of elapsed time credited; 0 means no bound
+}
+
+// bucketLocked returns how rl's bucket fills. Callers must hold rl.mu.
+func (rl *RateLimiter) bucketLocked() bucket {
+	return bucket{rate: rl.rate, burst: rl.maxTokens, interval: rl.interval, phase: rl.phase, smooth: rl.smooth, catchUp: rl.catchUp}
+}
+
+// capacity returns the most tokens the bucket holds: the burst, or at most
+// one in smooth mode.
+func (b bucket) capacity() int {
+	if b.smooth {
+		return min(b.burst, 1)
+	}
+	return b.burst
+}
+
+// tokensAt returns the tokens in the bucket at to, given that it held
+// tokens at from.
+func (b bucket) tokensAt(tokens float64, from, to int64) float64 {
+	if b.catchUp > 0 && to-from > b.catchUp {
+		to = from + b.catchUp
+	}
+	elapsed := time.Duration(to - from)
+	if b.interval > 0 && to > from {
+		refills := floorDiv(to-b.phase, b.interval) - floorDiv(from-b.phase, b.interval)
+		elapsed = time.Duration(refills * b.interval)
+	}
+	return refill(tokens, elapsed, b.rate, b.capacity())
+}
+
+// untilTokens returns how long after at the bucket will have gained tokens
+// more tokens.
+func (b bucket) untilTokens(at int64, tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	d := b.rate.durationFromTokens(tokens)
+	if b.interval == 0 || d == InfiniteDuration {
+		return d
+	}
+	// Round up to whole refills, the first of which is at the next
+	// interval boundary.
+	per := b.rate.tokensFromDuration(time.Duration(b.interval))
+	refills := math.Ceil(snapTokens(tokens / per))
+	next := b.phase + (floorDiv(at-b.phase, b.interval)+1)*b.interval
+	wait := float64(next-at) + (refills-1)*float64(b.interval)
+	if wait > float64(math.MaxInt64) {
+		return InfiniteDuration
+	}
+	return time.Duration(wait)
+}
+
+// floorDiv returns a/b rounded towards negative infinity, for b > 0.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// SetRefillInterval switches the bucket to discrete refills: every
+// interval, the tokens earned at the limiter's rate over the interval are
+// added at once, rather than continuously. For example, rate PerMinute(30)
+// with a one-minute interval adds 30 tokens every minute. With AlignToClock
+// refills happen on multiples of interval since the zero time (on the
+// minute, for one minute); with AlignToFirstRequest they are counted from
+// the call to SetRefillInterval. An interval of zero or less restores
+// continuous refill.
+func (rl *RateLimiter) SetRefillInterval(interval time.Duration, align WindowAlignment) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	t := rl.now()
+	rl.tokens = rl.updateTokens(t)
+	rl.updatedAt = rl.stampLocked(t)
+	if interval <= 0 {
+		rl.interval, rl.phase = 0, 0
+	} else {
+		rl.interval = int64(interval)
+		rl.phase = rl.updatedAt
+		if align == AlignToClock {
+			rl.phase = rl.nanos(t.Truncate(interval))
+		}
+	}
+	rl.publishLocked()
+	rl.wakeLocked()
+}
+
+// WithRefillInterval makes the limiter refill in discrete steps, as
+// SetRefillInterval does.
+func WithRefillInterval(interval time.Duration, align WindowAlignment) Option {
+	return func(o *limiterOptions) {
+		o.refillInterval = interval
+		o.refillAlign = align
+	}
+}
+
+// SetMaxCatchUp bounds the elapsed time a refill credits: however long
+// the gap between two calls, the bucket gains at most d worth of tokens.
+// Readings from the system clock are monotonic, but a Clock reading wall
+// time, an NTP step or a VM resumed from suspend can make hours appear to
+// pass at once; with a bound, such a jump cannot refill a bucket that was
+// in debt or emptied on purpose. The bound also slows refills after
+// genuine idle periods longer than d, so d should be at least the longest
+// gap between calls the bucket must fully recover from. Zero or less
+// removes the bound, which is the default.
+//
+// Clock readings earlier than the last update of a bucket that is not
+// full are always taken as the last update, so a clock stepped back
+// neither refills nor freezes the bucket.
+func (rl *RateLimiter) SetMaxCatchUp(d time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	t := rl.now()
+	rl.tokens = rl.updateTokens(t)
+	rl.updatedAt = rl.stampLocked(t)
+	rl.catchUp = int64(max(d, 0))
+	rl.publishLocked()
+}
+
+// WithMaxCatchUp bounds the time a refill credits, as SetMaxCatchUp does.
+func WithMaxCatchUp(d time.Duration) Option {
+	return func(o *limiterOptions) { o.maxCatchUp = d }
+}
+
+// SetSmooth turns smooth pacing on or off. A smooth limiter holds at most
+// one token, so events are spaced at least 1/rate apart instead of being
+// let through in bursts, for downstreams that throttle on instantaneous
+// rather than sustained rate. Callers of WaitN queue for their slot as
+// usual. The burst still bounds how many tokens one request may take; such
+// a request waits for the tokens beyond the first to accrue, so AllowN for
+// more than one token never succeeds.
+func (rl *RateLimiter) SetSmooth(on bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	t := rl.now()
+	rl.tokens = rl.updateTokens(t)
+	rl.updatedAt = rl.stampLocked(t)
+	rl.smooth = on
+	rl.tokens = min(rl.tokens, float64(rl.bucketLocked().capacity()))
+	rl.publishLocked()
+	rl.wakeLocked()
+}
+
+// WithSmooth starts the limiter in smooth pacing mode, as SetSmooth(true)
+// does.
+func WithSmooth() Option {
+	return func(o *limiterOptions) { o.smooth = true }
+}
@@ -0,0 +1,152 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefillIntervalOnTheMinute(t *testing.T) {
+	clk := newFakeClock(time.Unix(20, 0))
+	rl := NewWithOptions(PerMinute(30),
+		WithBurst(30),
+		WithClock(clk),
+		WithRefillInterval(time.Minute, AlignToClock),
+	)
+	if !rl.AllowN(30) {
+		t.Fatal("full bucket denied")
+	}
+	clk.Sleep(39 * time.Second)
+	if got := rl.AvailableTokens(); got != 0 {
+		t.Fatalf("AvailableTokens() before the minute = %v, want 0", got)
+	}
+	if got, want := rl.NextAvailableAt(1), time.Unix(60, 0); !got.Equal(want) {
+		t.Fatalf("NextAvailableAt(1) = %v, want %v", got, want)
+	}
+
+	start := clk.Now()
+	if err := rl.Wait(10); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if waited := clk.Now().Sub(start); waited != time.Second {
+		t.Fatalf("Wait took %v, want 1s to the minute", waited)
+	}
+	if got := rl.AvailableTokens(); got != 20 {
+		t.Fatalf("AvailableTokens() after the minute = %v, want 20", got)
+	}
+
+	// Taking more than one refill's worth waits for several refills.
+	rl.AllowN(20)
+	if got, want := rl.NextAvailableAt(30), time.Unix(120, 0); !got.Equal(want) {
+		t.Fatalf("NextAvailableAt(30) = %v, want %v", got, want)
+	}
+	rl.SetBurst(60)
+	if got, want := rl.NextAvailableAt(45), time.Unix(180, 0); !got.Equal(want) {
+		t.Fatalf("NextAvailableAt(45) = %v, want %v", got, want)
+	}
+}
+
+func TestRefillIntervalFromFirstRequest(t *testing.T) {
+	clk := newFakeClock(time.Unix(20, 0))
+	rl := New(1, 10, clk)
+	rl.AllowN(10)
+	rl.SetRefillInterval(10*time.Second, AlignToFirstRequest)
+
+	clk.Sleep(9 * time.Second)
+	if got := rl.AvailableTokens(); got != 0 {
+		t.Fatalf("AvailableTokens() = %v, want 0", got)
+	}
+	clk.Sleep(time.Second)
+	if got := rl.AvailableTokens(); got != 10 {
+		t.Fatalf("AvailableTokens() = %v, want 10", got)
+	}
+
+	rl.AllowN(10)
+	rl.SetRefillInterval(0, AlignToClock)
+	clk.Sleep(time.Second)
+	if got := rl.AvailableTokens(); got != 1 {
+		t.Fatalf("AvailableTokens() after restoring continuous refill = %v, want 1", got)
+	}
+}
+
+func TestSmoothSpacesEvents(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := NewWithOptions(10, WithBurst(5), WithClock(clk), WithSmooth())
+
+	if !rl.Allow() || rl.Allow() {
+		t.Fatal("smooth limiter released a burst")
+	}
+	var fired []time.Duration
+	start := clk.Now()
+	for i := 0; i < 3; i++ {
+		if err := rl.Wait(1); err != nil {
+			t.Fatalf("Wait: %v", err)
+		}
+		fired = append(fired, clk.Now().Sub(start))
+	}
+	for i, want := range []time.Duration{100, 200, 300} {
+		if fired[i] != want*time.Millisecond {
+			t.Fatalf("wait %d fired at %v, want %vms", i, fired[i], want)
+		}
+	}
+
+	// Idling does not bank a burst.
+	clk.Sleep(time.Hour)
+	if got := rl.AvailableTokens(); got != 1 {
+		t.Fatalf("AvailableTokens() after idling = %v, want 1", got)
+	}
+	if rl.AllowN(2) {
+		t.Fatal("AllowN(2) succeeded in smooth mode")
+	}
+	if err := rl.Wait(3); err != nil {
+		t.Fatalf("Wait(3): %v", err)
+	}
+
+	rl.SetSmooth(false)
+	clk.Sleep(time.Hour)
+	if got := rl.AvailableTokens(); got != 5 {
+		t.Fatalf("AvailableTokens() after leaving smooth mode = %v, want 5", got)
+	}
+}
+
+func TestBackwardClockDoesNotRefill(t *testing.T) {
+	start := time.Unix(1000, 0)
+	rl := New(1, 2, newFakeClock(start))
+	rl.AllowNAt(start, 2)
+	// A clock stepped back an hour, then forward again to just after the
+	// first call, must not credit the hour twice.
+	if rl.AllowNAt(start.Add(-time.Hour), 1) {
+		t.Fatal("allowed after the clock went back")
+	}
+	if tok := rl.TokensAt(start.Add(500 * time.Millisecond)); tok != 0.5 {
+		t.Fatalf("%v tokens half a second after emptying, want 0.5", tok)
+	}
+	// Nor freeze it: the wait is measured on the caller's clock.
+	back := start.Add(-time.Hour)
+	if got := rl.ReserveNAt(back, 1, InfiniteDuration).DelayFrom(back); got != time.Second {
+		t.Fatalf("reservation after a backward step waits %v, want 1s", got)
+	}
+}
+
+func TestMaxCatchUp(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := NewWithOptions(1, WithBurst(10), WithClock(clk), WithMaxCatchUp(2*time.Second))
+	rl.AllowN(10)
+	clk.Sleep(time.Hour)
+	if tok := rl.AvailableTokens(); tok != 2 {
+		t.Fatalf("%v tokens after an hour-long jump, want 2", tok)
+	}
+	if !rl.AllowN(2) || rl.Allow() {
+		t.Fatal("admitted other than the two tokens credited")
+	}
+	// Denied calls must not leave the skipped hour to be credited later.
+	clk.Sleep(time.Second)
+	if tok := rl.AvailableTokens(); tok != 1 {
+		t.Fatalf("%v tokens a second later, want 1", tok)
+	}
+
+	rl.SetMaxCatchUp(0)
+	clk.Sleep(time.Hour)
+	if tok := rl.AvailableTokens(); tok != 10 {
+		t.Fatalf("%v tokens after an hour without a bound, want 10", tok)
+	}
+}
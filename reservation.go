@@ -0,0 +1,126 @@
+package ratelimiter
+
+import "time"
+
+// Reservation holds tokens reserved by ReserveN for an event that may take
+// place in the future. A Reservation that is not OK never acts.
+type Reservation struct {
+	ok        bool
+	r         *RateLimiter
+	tokens    float64
+	timeToAct time.Time
+	rate      Rate
+	remaining float64
+	// need is the wait the tokens required, for a reservation refused
+	// because it exceeded maxWait.
+	need time.Duration
+	// parent is the matching reservation on the limiter's parent, if any.
+	parent *Reservation
+}
+
+// Reserve is shorthand for ReserveN(1).
+func (rl *RateLimiter) Reserve() *Reservation {
+	return rl.ReserveN(1)
+}
+
+// ReserveN reserves n tokens without blocking and reports how long the
+// caller must wait before acting on them. If n exceeds the burst, the
+// returned Reservation is not OK, as it is for a negative n. Callers that
+// decide not to act should call Cancel so the tokens are returned to the
+// limiter.
+func (rl *RateLimiter) ReserveN(n int) *Reservation {
+	return rl.ReserveNAt(rl.now(), n, InfiniteDuration)
+}
+
+// ReserveAt is shorthand for ReserveNAt(t, 1, InfiniteDuration).
+func (rl *RateLimiter) ReserveAt(t time.Time) *Reservation {
+	return rl.ReserveNAt(t, 1, InfiniteDuration)
+}
+
+// ReserveNAt is ReserveN as of time t rather than the clock's current time.
+// The reservation is not OK if the tokens cannot be had within maxWait of t.
+func (rl *RateLimiter) ReserveNAt(t time.Time, n int, maxWait time.Duration) *Reservation {
+	r := rl.reserve(t, float64(n), maxWait)
+	rl.observe(Decision{Time: t, N: n, Allowed: r.ok, Delay: r.DelayFrom(t), Remaining: r.remaining})
+	return &r
+}
+
+// OK reports whether the limiter can provide the requested tokens within
+// the maximum wait time. If OK is false, Delay returns InfiniteDuration and
+// Cancel does nothing.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay is shorthand for DelayFrom(now) on the limiter's clock.
+func (r *Reservation) Delay() time.Duration {
+	return r.DelayFrom(r.r.now())
+}
+
+// DelayFrom returns how long the caller must wait from t before acting on
+// the reservation. Zero means act immediately; InfiniteDuration means the
+// reservation can never be satisfied.
+func (r *Reservation) DelayFrom(t time.Time) time.Duration {
+	if !r.ok {
+		return InfiniteDuration
+	}
+	delay := r.timeToAct.Sub(t)
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// Cancel is shorthand for CancelAt(now) on the limiter's clock.
+func (r *Reservation) Cancel() {
+	r.CancelAt(r.r.now())
+}
+
+// CancelAt indicates that the reservation holder will not act on it and
+// returns as many tokens as possible to the limiter, taking into account
+// reservations made after this one: only the tokens no later reservation
+// has counted on come back. Tokens charged to parent limiters are returned
+// to them too. A reservation that has already acted at t returns nothing,
+// and only the first cancellation of a reservation has an effect. WaitN
+// cancels its reservation this way when its context ends or the limiter
+// is paused with PauseDeny.
+func (r *Reservation) CancelAt(t time.Time) {
+	if !r.ok {
+		return
+	}
+	for ; r != nil; r = r.parent {
+		r.cancelAt(t)
+	}
+}
+
+// cancelAt returns r's tokens to r.r alone.
+func (r *Reservation) cancelAt(t time.Time) {
+	r.r.mu.Lock()
+	defer r.r.mu.Unlock()
+
+	if r.r.rate == InfiniteRate || r.tokens == 0 || r.timeToAct.Before(t) {
+		return
+	}
+	n := r.tokens
+	r.tokens = 0 // a second Cancel returns nothing
+
+	actAt := r.r.nanos(r.timeToAct)
+	// A change of rate or burst since r was made moves eventAt to the
+	// change, before actAt; only r's own tokens come back then.
+	restore := n - r.rate.tokensFromDuration(time.Duration(max(r.r.eventAt-actAt, 0)))
+	if restore <= 0 {
+		return
+	}
+	tokens := r.r.updateTokens(t) + restore
+	if max := float64(r.r.bucketLocked().capacity()); tokens > max {
+		tokens = max
+	}
+	r.r.updatedAt = r.r.stampLocked(t)
+	r.r.tokens = tokens
+	if actAt == r.r.eventAt {
+		// The last reservation is gone: the next event may happen as soon
+		// as the one before it, or now.
+		r.r.eventAt = max(actAt-int64(r.rate.durationFromTokens(n)), r.r.updatedAt)
+	}
+	r.r.publishLocked()
+}
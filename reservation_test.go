@@ -0,0 +1,182 @@
+package ratelimiter
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReserveN(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(Every(100*time.Millisecond), 2, clk)
+
+	r := rl.ReserveN(2)
+	if !r.OK() || r.Delay() != 0 {
+		t.Fatalf("expected immediate reservation, got ok=%v delay=%v", r.OK(), r.Delay())
+	}
+	r = rl.Reserve()
+	if !r.OK() {
+		t.Fatalf("expected reservation to be OK")
+	}
+	if d := r.Delay(); d != 100*time.Millisecond {
+		t.Fatalf("expected 100ms delay, got %v", d)
+	}
+	clk.Sleep(40 * time.Millisecond)
+	if d := r.Delay(); d != 60*time.Millisecond {
+		t.Fatalf("expected 60ms delay after 40ms, got %v", d)
+	}
+}
+
+func TestReserveNExceedsBurst(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(Every(100*time.Millisecond), 2, clk)
+
+	r := rl.ReserveN(3)
+	if r.OK() {
+		t.Fatalf("expected reservation above burst to fail")
+	}
+	if d := r.Delay(); d != InfiniteDuration {
+		t.Fatalf("expected InfiniteDuration, got %v", d)
+	}
+	r.Cancel()
+	if tok := rl.AvailableTokens(); tok != 2 {
+		t.Fatalf("expected failed reservation to leave tokens untouched, got %f", tok)
+	}
+}
+
+func TestReservationCancel(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(Every(100*time.Millisecond), 2, clk)
+
+	rl.AllowN(2)
+	r := rl.ReserveN(2)
+	if d := r.Delay(); d != 200*time.Millisecond {
+		t.Fatalf("expected 200ms delay, got %v", d)
+	}
+	r.Cancel()
+	if tok := rl.AvailableTokens(); tok != 0 {
+		t.Fatalf("expected canceled tokens to be restored, got %f", tok)
+	}
+	if d := rl.Reserve().Delay(); d != 100*time.Millisecond {
+		t.Fatalf("expected next reservation to wait 100ms, got %v", d)
+	}
+}
+
+func TestReservationCancelTwice(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(1, 2, clk)
+	rl.AllowN(2)
+	r := rl.Reserve()
+	r.Cancel()
+	r.Cancel()
+	if tok := rl.AvailableTokens(); tok != 0 {
+		t.Fatalf("two cancellations left %v tokens, want 0", tok)
+	}
+}
+
+func TestReservationCancelBeforeLater(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(1, 3, clk)
+	rl.AllowN(3)
+	first := rl.ReserveN(2)
+	second := rl.Reserve()
+
+	// second counted on one of first's tokens, so canceling first returns
+	// only the other.
+	first.Cancel()
+	if tok := rl.AvailableTokens(); tok != -2 {
+		t.Fatalf("%v tokens after canceling the earlier reservation, want -2", tok)
+	}
+	second.Cancel()
+	if tok := rl.AvailableTokens(); tok != -1 {
+		t.Fatalf("%v tokens after canceling both, want -1", tok)
+	}
+
+	// Once the last reservation is gone, the one before it is refunded in
+	// full.
+	a, b := rl.Reserve(), rl.Reserve()
+	b.Cancel()
+	a.Cancel()
+	if tok := rl.AvailableTokens(); tok != -1 {
+		t.Fatalf("%v tokens after canceling in reverse order, want -1", tok)
+	}
+}
+
+func TestReservationCancelRefundsParents(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	parent := New(1, 2, clk)
+	child := parent.NewChild(1, 2)
+	child.AllowN(2)
+	r := child.Reserve()
+	r.Cancel()
+	if p, c := parent.AvailableTokens(), child.AvailableTokens(); p != 0 || c != 0 {
+		t.Fatalf("after cancel: parent %v, child %v tokens, want 0 and 0", p, c)
+	}
+}
+
+func TestReservationCancelAfterSetBurst(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(1, 1, clk)
+	rl.Allow()
+	r := rl.Reserve()
+	rl.SetBurst(1)
+	r.Cancel()
+	if tok := rl.AvailableTokens(); tok != 0 {
+		t.Fatalf("cancel after SetBurst left %v tokens, want 0", tok)
+	}
+}
+
+func TestWaitNRefundsOnPause(t *testing.T) {
+	rl := New(Every(time.Hour), 1, nil)
+	rl.Allow()
+	done := make(chan error, 1)
+	go func() { done <- rl.Wait(1) }()
+	for rl.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	rl.Pause()
+	if err := <-done; !errors.Is(err, ErrPaused) {
+		t.Fatalf("Wait when paused = %v, want ErrPaused", err)
+	}
+	if tok := rl.AvailableTokens(); tok < -0.01 {
+		t.Fatalf("%v tokens after the wait was abandoned, want its token back", tok)
+	}
+}
+
+func TestMaxFutureReserve(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := NewWithOptions(1, WithBurst(2), WithClock(clk), WithMaxFutureReserve(2*time.Second))
+	rl.AllowN(2)
+	if !rl.Reserve().OK() || !rl.Reserve().OK() {
+		t.Fatal("reservations within the bound refused")
+	}
+	r := rl.Reserve()
+	if r.OK() || r.Delay() != InfiniteDuration {
+		t.Fatal("reservation three seconds ahead accepted")
+	}
+	if tok := rl.AvailableTokens(); tok != -2 {
+		t.Fatalf("refused reservation took tokens: %v left", tok)
+	}
+
+	err := rl.Wait(1)
+	var we *WaitError
+	if !errors.As(err, &we) || !errors.Is(err, ErrReserveTooFar) || we.RetryAfter != time.Second {
+		t.Fatalf("Wait beyond the bound = %#v", err)
+	}
+	if _, err := rl.WaitNAt(clk.Now(), 1); !errors.Is(err, ErrReserveTooFar) {
+		t.Fatalf("WaitNAt beyond the bound = %v", err)
+	}
+	if clk.Now() != time.Unix(0, 0) {
+		t.Fatal("a refused wait slept")
+	}
+
+	child := rl.NewChild(10, 10)
+	if child.Reserve().OK() {
+		t.Fatal("child reserved beyond its parent's bound")
+	}
+	rl.SetMaxFutureReserve(0)
+	if !rl.Reserve().OK() {
+		t.Fatal("reservation refused after removing the bound")
+	}
+}
@@ -0,0 +1,69 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"time"
+)
+
+// Result describes an admission decision together with the state of the
+// bucket it was made against, so callers can report both without querying
+// the limiter again.
+type Result struct {
+	At      time.Time // when the decision was made
+	Allowed bool
+	Limit   int // burst size
+
+	// Remaining is the number of tokens left after the decision.
+	Remaining float64
+
+	// RetryAfter is how long a denied caller should wait before the tokens
+	// it asked for are available, or InfiniteDuration if they never will
+	// be. It is zero for allowed requests.
+	RetryAfter time.Duration
+
+	// ResetAt is when the bucket is full again, or the zero time if it can
+	// never refill that far.
+	ResetAt time.Time
+}
+
+// AllowNDetailed is AllowN, returning a Result that describes the bucket
+// right after the decision.
+func (rl *RateLimiter) AllowNDetailed(n int) Result {
+	t := rl.now()
+	r, allowed := rl.allow(t, float64(n))
+	b, _, _ := rl.loadPublished()
+	at := rl.nanos(t)
+
+	res := Result{At: t, Allowed: allowed, Limit: b.burst, Remaining: r.remaining, ResetAt: t}
+	if !allowed {
+		res.RetryAfter = InfiniteDuration
+		if n <= b.burst {
+			res.RetryAfter = b.untilTokens(at, float64(n)-r.remaining)
+		}
+	}
+	if max := float64(b.capacity()); r.remaining < max {
+		res.ResetAt = timeAfter(t, b.untilTokens(at, max-r.remaining))
+	}
+	return res
+}
+
+// AllowNDetailed is AllowN for key, returning a Result.
+func (kl *KeyedLimiter) AllowNDetailed(key string, n int) Result {
+	if res, ok := kl.ruledResult(key); ok {
+		return res
+	}
+	if pen := kl.config.Load().penalty; pen != nil {
+		return kl.allowPenalized(key, n, pen)
+	}
+	return kl.Get(key).AllowNDetailed(n)
+}
+
+// SetHeaders writes the RateLimit-* headers described at Status.SetHeaders
+// for the bucket after the decision and, if the request was denied and can
+// be retried, Retry-After for RetryAfter.
+func (r Result) SetHeaders(h http.Header) {
+	setLimitHeaders(h, r.At, r.Limit, r.Remaining, r.ResetAt)
+	if !r.Allowed && r.RetryAfter != InfiniteDuration {
+		setRetryAfter(h, r.RetryAfter)
+	}
+}
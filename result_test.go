@@ -0,0 +1,36 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAllowNDetailed(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(2, 4, clk)
+
+	res := rl.AllowNDetailed(3)
+	if !res.Allowed || res.Limit != 4 || res.Remaining != 1 || res.RetryAfter != 0 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if want := time.Unix(1, 500_000_000); !res.ResetAt.Equal(want) {
+		t.Fatalf("ResetAt = %v, want %v", res.ResetAt, want)
+	}
+
+	res = rl.AllowNDetailed(3)
+	if res.Allowed || res.Remaining != 1 || res.RetryAfter != time.Second {
+		t.Fatalf("unexpected denial %+v", res)
+	}
+
+	if res := rl.AllowNDetailed(5); res.Allowed || res.RetryAfter != InfiniteDuration {
+		t.Fatalf("over-burst request: %+v, want RetryAfter InfiniteDuration", res)
+	}
+
+	rl.AllowN(1)
+	h := http.Header{}
+	rl.AllowNDetailed(1).SetHeaders(h)
+	if h.Get("RateLimit-Remaining") != "0" || h.Get("Retry-After") != "1" || h.Get("RateLimit-Reset") != "2" {
+		t.Fatalf("unexpected headers %v", h)
+	}
+}
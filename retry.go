@@ -0,0 +1,106 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Backoff configures the retries of a Retrier. The zero value makes three
+// attempts, 100ms and then 200ms apart.
+type Backoff struct {
+	// Attempts is the most calls made in all, including the first. Zero or
+	// less means 3.
+	Attempts int
+	// Initial is the delay before the first retry. Zero means 100ms.
+	Initial time.Duration
+	// Max caps the delay between attempts, the first included. Zero means
+	// no cap.
+	Max time.Duration
+	// Multiplier scales the delay after every retry. Less than 1 means 2.
+	Multiplier float64
+	// AttemptTimeout bounds each call, through its context. Zero means
+	// attempts are bounded only by the context passed to Do.
+	AttemptTimeout time.Duration
+	// Retryable reports whether a failed attempt should be retried. Nil
+	// retries every error.
+	Retryable func(error) bool
+}
+
+// Retrier calls functions under a Limiter, retrying failures with
+// exponential backoff. Every attempt, retries included, takes a token, so
+// a struggling dependency is not hit harder by its own retries than the
+// limiter allows.
+type Retrier struct {
+	l     Limiter
+	b     Backoff
+	clock Clock
+}
+
+// NewRetrier returns a Retrier gating attempts with l and spacing them as
+// b describes. clk times the backoff; nil means the system clock.
+func NewRetrier(l Limiter, b Backoff, clk Clock) *Retrier {
+	if clk == nil {
+		clk = realClock{}
+	}
+	if b.Attempts <= 0 {
+		b.Attempts = 3
+	}
+	if b.Initial <= 0 {
+		b.Initial = 100 * time.Millisecond
+	}
+	if b.Max > 0 && b.Initial > b.Max {
+		b.Initial = b.Max
+	}
+	if b.Multiplier < 1 {
+		b.Multiplier = 2
+	}
+	return &Retrier{l: l, b: b, clock: clk}
+}
+
+// Do calls fn until it succeeds, returns an error that is not retryable or
+// has been called Backoff.Attempts times, waiting for a token from the
+// limiter before every call and backing off between failures. ctx bounds
+// the whole operation: Do gives up rather than back off past its
+// deadline. It returns nil on success, and otherwise the error of the
+// last attempt, joined with the limiter's or ctx's error if one ended the
+// retries.
+func (r *Retrier) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	var last error
+	delay := r.b.Initial
+	for attempt := 1; ; attempt++ {
+		if err := r.l.WaitN(ctx, 1); err != nil {
+			return errors.Join(last, err)
+		}
+		last = r.call(ctx, fn)
+		if last == nil {
+			return nil
+		}
+		if attempt == r.b.Attempts || r.b.Retryable != nil && !r.b.Retryable(last) {
+			return last
+		}
+		if err := ctx.Err(); err != nil {
+			return errors.Join(last, err)
+		}
+		if d, ok := ctx.Deadline(); ok && d.Sub(r.clock.Now()) < delay {
+			return errors.Join(last, context.DeadlineExceeded)
+		}
+		if err := sleepCtx(ctx, r.clock, delay); err != nil {
+			return errors.Join(last, err)
+		}
+		delay = time.Duration(float64(delay) * r.b.Multiplier)
+		if r.b.Max > 0 && delay > r.b.Max {
+			delay = r.b.Max
+		}
+	}
+}
+
+// call makes one attempt, bounded by AttemptTimeout.
+func (r *Retrier) call(ctx context.Context, fn func(ctx context.Context) error) error {
+	if r.b.AttemptTimeout <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.b.AttemptTimeout)
+	defer cancel()
+	return fn(ctx)
+}
@@ -0,0 +1,117 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetrierBacksOff(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(1000, 10, clk)
+	r := NewRetrier(rl, Backoff{Attempts: 4, Initial: time.Second, Max: 3 * time.Second}, clk)
+
+	var at []time.Duration
+	errBusy := errors.New("busy")
+	err := r.Do(context.Background(), func(ctx context.Context) error {
+		at = append(at, clk.Now().Sub(time.Unix(0, 0)))
+		if len(at) < 4 {
+			return errBusy
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Duration{0, time.Second, 3 * time.Second, 6 * time.Second}
+	for i := range want {
+		if at[i] != want[i] {
+			t.Fatalf("attempts at %v, want %v", at, want)
+		}
+	}
+	if tok := rl.AvailableTokens(); tok >= 10 {
+		t.Fatalf("attempts left %v tokens, want them taken", tok)
+	}
+
+	calls := 0
+	err = r.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return errBusy
+	})
+	if !errors.Is(err, errBusy) || calls != 4 {
+		t.Fatalf("Do = %v after %d calls, want busy after 4", err, calls)
+	}
+}
+
+func TestRetrierStops(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	errFatal := errors.New("fatal")
+	r := NewRetrier(New(1000, 10, clk), Backoff{
+		Retryable: func(err error) bool { return !errors.Is(err, errFatal) },
+	}, clk)
+	calls := 0
+	err := r.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return errFatal
+	})
+	if !errors.Is(err, errFatal) || calls != 1 {
+		t.Fatalf("Do = %v after %d calls, want no retry of a fatal error", err, calls)
+	}
+
+	// A limiter that cannot admit the attempt ends the retries.
+	r = NewRetrier(New(1, 0, clk), Backoff{}, clk)
+	err = r.Do(context.Background(), func(ctx context.Context) error { return nil })
+	if !errors.Is(err, ErrBurstExceeded) {
+		t.Fatalf("Do on a zero burst = %v, want ErrBurstExceeded", err)
+	}
+
+	// Backing off past the deadline gives up at once.
+	clk = newFakeClock(time.Now())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r = NewRetrier(New(1000, 10, clk), Backoff{Initial: time.Second}, clk)
+	err = r.Do(ctx, func(ctx context.Context) error { return errFatal })
+	if !errors.Is(err, errFatal) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Do near the deadline = %v", err)
+	}
+}
+
+func TestRetrierAttemptTimeout(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	r := NewRetrier(New(1000, 10, clk), Backoff{Attempts: 2, Initial: time.Millisecond, AttemptTimeout: 5 * time.Millisecond}, clk)
+	calls := 0
+	err := r.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || calls != 2 {
+		t.Fatalf("Do = %v after %d calls, want timed-out attempts retried", err, calls)
+	}
+}
+
+func TestRetrierBackoffClock(t *testing.T) {
+	clk := newFakeClock(time.Now())
+	r := NewRetrier(New(1000, 10, clk), Backoff{Attempts: 3, Initial: 5 * time.Second, Max: 2 * time.Second}, clk)
+	var at []time.Duration
+	start := clk.Now()
+	attempt := func(ctx context.Context) error {
+		at = append(at, clk.Now().Sub(start))
+		return errors.New("busy")
+	}
+	r.Do(context.Background(), attempt)
+	if len(at) != 3 || at[1] != 2*time.Second || at[2] != 4*time.Second {
+		t.Fatalf("attempts at %v, want the initial delay capped at 2s", at)
+	}
+
+	// The deadline is measured on the Retrier's clock, on which only 1s
+	// is left after the first backoff.
+	ctx, cancel := context.WithDeadline(context.Background(), clk.Now().Add(3*time.Second))
+	defer cancel()
+	at, start = at[:0], clk.Now()
+	err := r.Do(ctx, attempt)
+	if len(at) != 2 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Do = %v after %d attempts, want 2 before the deadline", err, len(at))
+	}
+}
@@ -0,0 +1,115 @@
+package ratelimiter
+
+import (
+	"maps"
+	"slices"
+)
+
+// KeyRule overrides limiting for some keys of a KeyedLimiter.
+type KeyRule int
+
+const (
+	// RuleNone limits the key as usual.
+	RuleNone KeyRule = iota
+	// RuleBypass always admits the key, for trusted internal callers.
+	RuleBypass
+	// RuleDeny always denies the key, for known bad actors.
+	RuleDeny
+)
+
+func (r KeyRule) String() string {
+	switch r {
+	case RuleNone:
+		return "none"
+	case RuleBypass:
+		return "bypass"
+	case RuleDeny:
+		return "deny"
+	}
+	return "unknown"
+}
+
+// ruleFunc is a rule applying to the keys matched by a predicate.
+type ruleFunc struct {
+	name  string
+	rule  KeyRule
+	match func(key string) bool
+}
+
+// SetRule sets the rule for key; RuleNone removes it. Rules are checked
+// before the key's bucket, so bypassed and denied keys neither take tokens
+// nor create a limiter, and their decisions are not observed. WaitN fails
+// at once with ErrDenied for denied keys.
+func (kl *KeyedLimiter) SetRule(key string, rule KeyRule) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	kl.updateConfig(func(c *keyedConfig) {
+		c.rules = maps.Clone(c.rules)
+		if rule == RuleNone {
+			delete(c.rules, key)
+			return
+		}
+		if c.rules == nil {
+			c.rules = make(map[string]KeyRule)
+		}
+		c.rules[key] = rule
+	})
+}
+
+// SetRuleFunc applies rule to every key for which match returns true, such
+// as keys with an "internal:" prefix. It replaces any predicate registered
+// under the same name; a nil match removes it. Rules set for a key with
+// SetRule take precedence over predicates, which are tried in the order
+// they were first registered. match must be safe for concurrent use.
+func (kl *KeyedLimiter) SetRuleFunc(name string, rule KeyRule, match func(key string) bool) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	kl.updateConfig(func(c *keyedConfig) {
+		funcs := slices.Clone(c.ruleFuncs)
+		i := slices.IndexFunc(funcs, func(f ruleFunc) bool { return f.name == name })
+		switch {
+		case match == nil && i >= 0:
+			funcs = slices.Delete(funcs, i, i+1)
+		case match != nil && i >= 0:
+			funcs[i] = ruleFunc{name, rule, match}
+		case match != nil:
+			funcs = append(funcs, ruleFunc{name, rule, match})
+		}
+		c.ruleFuncs = funcs
+	})
+}
+
+// Rule returns the rule that applies to key.
+func (kl *KeyedLimiter) Rule(key string) KeyRule {
+	return kl.config.Load().ruleFor(key)
+}
+
+func (c *keyedConfig) ruleFor(key string) KeyRule {
+	if r, ok := c.rules[key]; ok {
+		return r
+	}
+	for _, f := range c.ruleFuncs {
+		if f.rule != RuleNone && f.match(key) {
+			return f.rule
+		}
+	}
+	return RuleNone
+}
+
+// ruledResult returns the Result for key if a rule applies to it, or false
+// if it is limited as usual.
+func (kl *KeyedLimiter) ruledResult(key string) (Result, bool) {
+	cfg := kl.config.Load()
+	if cfg.rules == nil && cfg.ruleFuncs == nil {
+		return Result{}, false
+	}
+	switch cfg.ruleFor(key) {
+	case RuleBypass:
+		now := kl.now()
+		return Result{At: now, Allowed: true, Remaining: float64(cfg.burst), Limit: cfg.burst, ResetAt: now}, true
+	case RuleDeny:
+		now := kl.now()
+		return Result{At: now, Limit: cfg.burst, RetryAfter: InfiniteDuration}, true
+	}
+	return Result{}, false
+}
@@ -0,0 +1,48 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyRules(t *testing.T) {
+	kl := NewKeyed(1, 1, newFakeClock(time.Unix(0, 0)))
+	kl.SetRule("trusted", RuleBypass)
+	kl.SetRule("abuser", RuleDeny)
+	kl.SetRuleFunc("internal", RuleBypass, func(key string) bool { return strings.HasPrefix(key, "internal:") })
+	kl.SetRuleFunc("banned", RuleDeny, func(key string) bool { return strings.HasPrefix(key, "internal:bad") })
+
+	for i := 0; i < 3; i++ {
+		if !kl.Allow("trusted") || !kl.Allow("internal:batch") {
+			t.Fatal("bypassed key denied")
+		}
+	}
+	if kl.Allow("abuser") {
+		t.Fatal("denied key allowed")
+	}
+	if err := kl.Wait(context.Background(), "abuser", 1); !errors.Is(err, ErrDenied) {
+		t.Fatalf("Wait for denied key: %v, want ErrDenied", err)
+	}
+	if res := kl.AllowNDetailed("abuser", 1); res.Allowed || res.RetryAfter != InfiniteDuration {
+		t.Fatalf("AllowNDetailed for denied key: %+v", res)
+	}
+	// Predicates apply in registration order.
+	if got := kl.Rule("internal:bad"); got != RuleBypass {
+		t.Fatalf("Rule(internal:bad) = %v, want bypass", got)
+	}
+	if n := kl.Len(); n != 0 {
+		t.Fatalf("rules created %d limiters", n)
+	}
+
+	kl.SetRule("trusted", RuleNone)
+	kl.SetRuleFunc("internal", RuleBypass, nil)
+	if got := kl.Rule("internal:bad"); got != RuleDeny {
+		t.Fatalf("Rule(internal:bad) after removing internal = %v, want deny", got)
+	}
+	if !kl.Allow("trusted") || kl.Allow("trusted") {
+		t.Fatal("key not limited after its rule was removed")
+	}
+}
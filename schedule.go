@@ -0,0 +1,172 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ScheduleEntry is one period of a Schedule: from Start, a time of day,
+// until the next entry starts, the limiter allows Rate with Burst.
+type ScheduleEntry struct {
+	// Start is the time of day the entry takes effect, as an offset from
+	// midnight in [0, 24h). It is wall-clock time, so 09:00 stays 09:00
+	// across daylight saving changes.
+	Start time.Duration
+	// Days restricts the entry to the given weekdays. Empty means every
+	// day.
+	Days  []time.Weekday
+	Rate  Rate
+	Burst int
+}
+
+// Schedule maps times of day to limits, e.g. 100/s in business hours and
+// 500/s overnight for batch work.
+type Schedule struct {
+	// Location is the time zone Start times are read in. Nil means UTC.
+	Location *time.Location
+	Entries  []ScheduleEntry
+}
+
+// ScheduleLimiter is a token bucket whose rate and burst follow a
+// Schedule. Transitions take effect on the first call at or after the
+// scheduled time, as read from the limiter's clock, and carry the tokens
+// over as SetRate and SetBurst do.
+type ScheduleLimiter struct {
+	mu      sync.Mutex
+	rl      *RateLimiter
+	loc     *time.Location
+	entries []ScheduleEntry // by Start
+	next    time.Time       // next transition
+}
+
+// NewSchedule returns a ScheduleLimiter following s. It fails with
+// ErrInvalidLimit if s has no entries or an entry starts outside a day.
+func NewSchedule(s Schedule, clk Clock) (*ScheduleLimiter, error) {
+	if len(s.Entries) == 0 {
+		return nil, fmt.Errorf("%w: empty schedule", ErrInvalidLimit)
+	}
+	for _, e := range s.Entries {
+		if e.Start < 0 || e.Start >= 24*time.Hour {
+			return nil, fmt.Errorf("%w: schedule start %v is not a time of day", ErrInvalidLimit, e.Start)
+		}
+	}
+	if clk == nil {
+		clk = realClock{}
+	}
+	l := &ScheduleLimiter{loc: s.Location, entries: append([]ScheduleEntry(nil), s.Entries...)}
+	if l.loc == nil {
+		l.loc = time.UTC
+	}
+	sort.SliceStable(l.entries, func(i, j int) bool { return l.entries[i].Start < l.entries[j].Start })
+
+	now := clk.Now()
+	e, ok := l.activeAt(now)
+	if !ok {
+		return nil, fmt.Errorf("%w: schedule never applies", ErrInvalidLimit)
+	}
+	l.rl = New(e.Rate, e.Burst, clk)
+	l.next = l.nextAfter(now)
+	return l, nil
+}
+
+// Limiter returns the underlying limiter, for observers and metrics. Its
+// rate and burst are changed by the schedule.
+func (l *ScheduleLimiter) Limiter() *RateLimiter {
+	return l.rl
+}
+
+// Current returns the schedule entry in effect now.
+func (l *ScheduleLimiter) Current() ScheduleEntry {
+	e, _ := l.activeAt(l.advance())
+	return e
+}
+
+func (l *ScheduleLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+func (l *ScheduleLimiter) AllowN(n int) bool {
+	l.advance()
+	return l.rl.AllowN(n)
+}
+
+// Wait is shorthand for WaitN(context.Background(), n).
+func (l *ScheduleLimiter) Wait(n int) error {
+	return l.WaitN(context.Background(), n)
+}
+
+// WaitN blocks until n tokens are available or ctx is done. A wait that
+// spans a transition runs at the rate in effect when it started.
+func (l *ScheduleLimiter) WaitN(ctx context.Context, n int) error {
+	l.advance()
+	return l.rl.WaitN(ctx, n)
+}
+
+// advance applies the entry in effect now if a transition is due, and
+// returns now.
+func (l *ScheduleLimiter) advance() time.Time {
+	now := l.rl.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Before(l.next) {
+		return now
+	}
+	if e, ok := l.activeAt(now); ok {
+		l.rl.SetRateAt(now, e.Rate)
+		l.rl.SetBurstAt(now, e.Burst)
+	}
+	l.next = l.nextAfter(now)
+	return now
+}
+
+// activeAt returns the entry in effect at t: the one that started most
+// recently, looking back up to a week.
+func (l *ScheduleLimiter) activeAt(t time.Time) (ScheduleEntry, bool) {
+	t = t.In(l.loc)
+	for back := 0; back <= 7; back++ {
+		day := t.AddDate(0, 0, -back)
+		for i := len(l.entries) - 1; i >= 0; i-- {
+			e := l.entries[i]
+			if e.appliesOn(day.Weekday()) && !l.startOn(day, e).After(t) {
+				return e, true
+			}
+		}
+	}
+	return ScheduleEntry{}, false
+}
+
+// nextAfter returns the first transition after t, or the far future if
+// there is none within a week.
+func (l *ScheduleLimiter) nextAfter(t time.Time) time.Time {
+	t = t.In(l.loc)
+	for ahead := 0; ahead <= 7; ahead++ {
+		day := t.AddDate(0, 0, ahead)
+		for _, e := range l.entries {
+			if start := l.startOn(day, e); e.appliesOn(day.Weekday()) && start.After(t) {
+				return start
+			}
+		}
+	}
+	return time.Unix(1<<62, 0)
+}
+
+// startOn returns when e starts on the day containing day.
+func (l *ScheduleLimiter) startOn(day time.Time, e ScheduleEntry) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d, 0, 0, 0, int(e.Start), l.loc)
+}
+
+func (e ScheduleEntry) appliesOn(wd time.Weekday) bool {
+	if len(e.Days) == 0 {
+		return true
+	}
+	for _, d := range e.Days {
+		if d == wd {
+			return true
+		}
+	}
+	return false
+}
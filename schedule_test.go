@@ -0,0 +1,65 @@
+package ratelimiter
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScheduleTransitions(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database unavailable:", err)
+	}
+	// Monday 2024-03-04, 08:00 in New York.
+	clk := newFakeClock(time.Date(2024, 3, 4, 8, 0, 0, 0, ny))
+	l, err := NewSchedule(Schedule{
+		Location: ny,
+		Entries: []ScheduleEntry{
+			{Start: 18 * time.Hour, Rate: 500, Burst: 50},
+			{Start: 9 * time.Hour, Rate: 100, Burst: 10, Days: []time.Weekday{
+				time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday,
+			}},
+		},
+	}, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(at time.Time, rate Rate, burst int) {
+		t.Helper()
+		clk.mu.Lock()
+		clk.time = at
+		clk.mu.Unlock()
+		l.Allow()
+		if got := l.Limiter(); got.Rate() != rate || got.Burst() != burst {
+			t.Fatalf("at %v: rate %v burst %d, want %v and %d", at, got.Rate(), got.Burst(), rate, burst)
+		}
+	}
+	// Before 09:00 Monday the overnight entry from Sunday 18:00 applies.
+	if got := l.Limiter().Rate(); got != 500 {
+		t.Fatalf("initial rate %v, want 500", got)
+	}
+	check(time.Date(2024, 3, 4, 9, 0, 0, 0, ny), 100, 10)
+	check(time.Date(2024, 3, 4, 17, 59, 0, 0, ny), 100, 10)
+	check(time.Date(2024, 3, 4, 18, 0, 0, 0, ny), 500, 50)
+	// Weekends stay on the overnight entry all day.
+	check(time.Date(2024, 3, 9, 12, 0, 0, 0, ny), 500, 50)
+	// 09:00 is wall-clock time across the switch to daylight saving time.
+	check(time.Date(2024, 3, 11, 8, 59, 0, 0, ny), 500, 50)
+	check(time.Date(2024, 3, 11, 9, 0, 0, 0, ny), 100, 10)
+	if got := l.Current(); got.Rate != 100 {
+		t.Fatalf("Current() = %+v", got)
+	}
+}
+
+func TestNewScheduleInvalid(t *testing.T) {
+	for _, s := range []Schedule{
+		{},
+		{Entries: []ScheduleEntry{{Start: 25 * time.Hour, Rate: 1, Burst: 1}}},
+	} {
+		if _, err := NewSchedule(s, nil); !errors.Is(err, ErrInvalidLimit) {
+			t.Fatalf("NewSchedule(%+v) error = %v, want ErrInvalidLimit", s, err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package ratelimiter
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Semaphore is a weighted semaphore: heavy operations can claim more of its
+// capacity than light ones. Waiters are served in FIFO order, so a large
+// request is not starved by a stream of small ones. The Clock is used to
+// account for time spent waiting.
+type Semaphore struct {
+	mu      sync.Mutex
+	size    int64
+	cur     int64
+	clock   Clock
+	waiters list.List // of *semWaiter
+	waited  time.Duration
+}
+
+type semWaiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// NewSemaphore returns a Semaphore with the given total weight.
+func NewSemaphore(size int64, clk Clock) *Semaphore {
+	if clk == nil {
+		clk = realClock{}
+	}
+	return &Semaphore{size: size, clock: clk}
+}
+
+func (s *Semaphore) Size() int64 {
+	return s.size
+}
+
+// InUse returns the weight currently held.
+func (s *Semaphore) InUse() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cur
+}
+
+// Waiting returns the number of callers blocked in AcquireN.
+func (s *Semaphore) Waiting() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.waiters.Len()
+}
+
+// WaitTime returns the total time callers have spent blocked in AcquireN.
+func (s *Semaphore) WaitTime() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.waited
+}
+
+// Acquire is shorthand for AcquireN(ctx, 1).
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	return s.AcquireN(ctx, 1)
+}
+
+// Release is shorthand for ReleaseN(1).
+func (s *Semaphore) Release() {
+	s.ReleaseN(1)
+}
+
+// TryAcquireN acquires weight n if it is available without waiting.
+func (s *Semaphore) TryAcquireN(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// AcquireN acquires weight n, blocking until it is available or ctx is
+// done. On failure it returns ctx.Err() and leaves the semaphore unchanged.
+func (s *Semaphore) AcquireN(ctx context.Context, n int64) error {
+	s.mu.Lock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+	if n > s.size {
+		s.mu.Unlock()
+		return fmt.Errorf("rate: AcquireN(n=%d) exceeds semaphore size %d", n, s.size)
+	}
+	w := &semWaiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(w)
+	start := s.clock.Now()
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		s.mu.Lock()
+		s.waited += s.clock.Now().Sub(start)
+		s.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.waited += s.clock.Now().Sub(start)
+		select {
+		case <-w.ready:
+			// Acquired while ctx was being canceled; give it back.
+			s.cur -= n
+		default:
+			isFront := s.waiters.Front() == elem
+			s.waiters.Remove(elem)
+			if !isFront {
+				return ctx.Err()
+			}
+		}
+		// A front waiter leaving may unblock the ones behind it.
+		s.notifyLocked()
+		return ctx.Err()
+	}
+}
+
+// ReleaseN releases weight n.
+func (s *Semaphore) ReleaseN(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur -= n
+	if s.cur < 0 {
+		panic("ratelimiter: released more than held")
+	}
+	s.notifyLocked()
+}
+
+// notifyLocked wakes waiters in order for as long as the front one fits.
+func (s *Semaphore) notifyLocked() {
+	for s.waiters.Len() > 0 {
+		front := s.waiters.Front()
+		w := front.Value.(*semWaiter)
+		if s.size-s.cur < w.n {
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(front)
+		close(w.ready)
+	}
+}
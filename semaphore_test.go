@@ -0,0 +1,71 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSemaphoreWeights(t *testing.T) {
+	s := NewSemaphore(10, nil)
+	if !s.TryAcquireN(7) {
+		t.Fatalf("expected weight 7 to be acquired")
+	}
+	if s.TryAcquireN(4) {
+		t.Fatalf("expected weight 4 to exceed remaining capacity")
+	}
+	if !s.TryAcquireN(3) {
+		t.Fatalf("expected weight 3 to fit")
+	}
+	s.ReleaseN(7)
+	if got := s.InUse(); got != 3 {
+		t.Fatalf("expected 3 in use, got %d", got)
+	}
+	if err := s.AcquireN(context.Background(), 11); err == nil {
+		t.Fatalf("expected acquiring more than size to fail")
+	}
+}
+
+func TestSemaphoreFIFO(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	s := NewSemaphore(4, clk)
+	s.TryAcquireN(3)
+
+	heavy := make(chan error, 1)
+	go func() { heavy <- s.AcquireN(context.Background(), 4) }()
+	for s.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if s.TryAcquireN(1) {
+		t.Fatalf("expected light request not to jump ahead of queued heavy one")
+	}
+
+	clk.Sleep(time.Second)
+	s.ReleaseN(3)
+	if err := <-heavy; err != nil {
+		t.Fatalf("expected heavy request to be served, got %v", err)
+	}
+	if got := s.WaitTime(); got != time.Second {
+		t.Fatalf("expected 1s of recorded waiting, got %v", got)
+	}
+}
+
+func TestSemaphoreAcquireCanceled(t *testing.T) {
+	s := NewSemaphore(2, nil)
+	s.TryAcquireN(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- s.AcquireN(ctx, 2) }()
+	for s.InUse() != 1 || s.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if !s.TryAcquireN(1) {
+		t.Fatalf("expected canceled front waiter to unblock smaller requests")
+	}
+}
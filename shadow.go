@@ -0,0 +1,54 @@
+package ratelimiter
+
+import "time"
+
+// SetShadow turns shadow mode on or off. A limiter in shadow mode keeps
+// its books exactly as when enforcing, and reports every decision to its
+// observers, but never denies or delays a caller: Allow and AllowN always
+// succeed, and Wait and WaitN return at once. Decisions that would have
+// gone the other way are marked Shadowed, so a new limit can be checked
+// against production traffic before it is enforced. Reservations are not
+// affected.
+func (rl *RateLimiter) SetShadow(on bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.shadow = on
+}
+
+// Shadow reports whether the limiter is in shadow mode.
+func (rl *RateLimiter) Shadow() bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.shadow
+}
+
+// shadowWait accounts for a WaitN in shadow mode and reports what WaitN
+// would have done, without blocking. The caller does not queue, so the
+// tokens are taken as if it had been served at once.
+func (rl *RateLimiter) shadowWait(t time.Time, cost float64) {
+	d := Decision{Time: t, N: costN(cost), Waited: true}
+	if err := rl.checkBurst(cost); err != nil {
+		d.Delay = InfiniteDuration
+		d.Remaining = rl.TokensAt(t)
+	} else {
+		r := rl.reserve(t, cost, InfiniteDuration)
+		d.Allowed = r.ok
+		d.Delay = r.DelayFrom(t)
+		d.Remaining = r.remaining
+	}
+	d.Shadowed = !d.Allowed || d.Delay > 0
+	rl.observe(d)
+}
+
+// SetShadow turns shadow mode on or off for the limiter of every key,
+// current and future.
+func (kl *KeyedLimiter) SetShadow(on bool) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	kl.updateConfig(func(c *keyedConfig) { c.shadow = on })
+	kl.eachShard(func(s *keyedShard) {
+		for _, rl := range s.limiters {
+			rl.SetShadow(on)
+		}
+	})
+}
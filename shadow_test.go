@@ -0,0 +1,77 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShadowAllowNeverDenies(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	var decisions []Decision
+	rl := NewWithOptions(1,
+		WithBurst(2),
+		WithClock(clk),
+		WithShadow(),
+		WithObserver(ObserverFunc(func(d Decision) { decisions = append(decisions, d) })),
+	)
+
+	for i := 0; i < 4; i++ {
+		if !rl.Allow() {
+			t.Fatalf("Allow %d denied in shadow mode", i)
+		}
+	}
+	want := []bool{true, true, false, false}
+	for i, d := range decisions {
+		if d.Allowed != want[i] || d.Shadowed == want[i] {
+			t.Fatalf("decision %d: allowed=%v shadowed=%v, want allowed=%v", i, d.Allowed, d.Shadowed, want[i])
+		}
+	}
+	// Shadowed denials take no tokens, as when enforcing.
+	if got := rl.AvailableTokens(); got != 0 {
+		t.Fatalf("AvailableTokens() = %v, want 0", got)
+	}
+
+	rl.SetShadow(false)
+	if rl.Allow() {
+		t.Fatal("Allow succeeded after leaving shadow mode with an empty bucket")
+	}
+}
+
+func TestShadowWaitDoesNotBlock(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(1, 1, clk)
+	rl.SetShadow(true)
+	var decisions []Decision
+	rl.AddObserver(ObserverFunc(func(d Decision) { decisions = append(decisions, d) }))
+
+	for _, n := range []int{1, 1, 5} {
+		if err := rl.Wait(n); err != nil {
+			t.Fatalf("Wait(%d) in shadow mode: %v", n, err)
+		}
+	}
+	if !clk.Now().Equal(time.Unix(0, 0)) {
+		t.Fatalf("shadow Wait slept until %v", clk.Now())
+	}
+	if d := decisions[0]; d.Shadowed || d.Delay != 0 {
+		t.Fatalf("first wait: %+v, want an unshadowed immediate decision", d)
+	}
+	if d := decisions[1]; !d.Shadowed || !d.Allowed || d.Delay != time.Second {
+		t.Fatalf("second wait: %+v, want a shadowed 1s delay", d)
+	}
+	if d := decisions[2]; !d.Shadowed || d.Allowed || d.Delay != InfiniteDuration {
+		t.Fatalf("over-burst wait: %+v, want a shadowed denial", d)
+	}
+}
+
+func TestKeyedShadow(t *testing.T) {
+	kl := NewKeyed(1, 1, newFakeClock(time.Unix(0, 0)))
+	kl.Allow("a")
+	kl.SetShadow(true)
+	if !kl.Allow("a") || !kl.Allow("b") || !kl.Allow("b") {
+		t.Fatal("keyed limiter denied in shadow mode")
+	}
+	kl.SetShadow(false)
+	if kl.Allow("a") || kl.Allow("b") {
+		t.Fatal("keyed limiter allowed after leaving shadow mode")
+	}
+}
@@ -0,0 +1,197 @@
+package ratelimiter
+
+import (
+	"context"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// SketchLimiter is an approximate keyed limiter for very many keys (per
+// URL, per device, ...). It admits at most limit events per key in any
+// window, like a SlidingWindowLimiter per key, but keeps its counts in
+// count-min sketches of fixed size instead of per-key state, so memory use
+// does not grow with the number of keys.
+//
+// Counts are never underestimated, so a key is never admitted over its
+// limit. They are overestimated when keys collide: with high probability
+// (1 - e^-depth) by at most e/width of all events in the window, which can
+// cause keys to be denied early when the limiter is very busy.
+type SketchLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	clock  Clock
+	seed   maphash.Seed
+	width  int
+	depth  int
+	start  time.Time
+	curr   []uint32 // depth rows of width counters
+	prev   []uint32
+}
+
+// NewSketch returns a SketchLimiter admitting limit events per key per
+// window, using sketches of depth rows of width counters. Zero width or
+// depth select 2048 and 4, which use 64KiB and keep the overestimate under
+// 0.14% of the window's events 98% of the time. Fixed windows are aligned
+// to multiples of window since the zero time.
+func NewSketch(limit int, window time.Duration, width, depth int, clk Clock) *SketchLimiter {
+	if clk == nil {
+		clk = realClock{}
+	}
+	if width <= 0 {
+		width = 2048
+	}
+	if depth <= 0 {
+		depth = 4
+	}
+	return &SketchLimiter{
+		limit:  limit,
+		window: window,
+		clock:  clk,
+		seed:   maphash.MakeSeed(),
+		width:  width,
+		depth:  depth,
+		start:  clk.Now().Truncate(window),
+		curr:   make([]uint32, width*depth),
+		prev:   make([]uint32, width*depth),
+	}
+}
+
+func (l *SketchLimiter) Limit() int {
+	return l.limit
+}
+
+func (l *SketchLimiter) Window() time.Duration {
+	return l.window
+}
+
+// For returns a Limiter for key, so that the sketch can be used wherever a
+// Limiter is expected. It allocates no per-key state.
+func (l *SketchLimiter) For(key string) Limiter {
+	return sketchKey{l, key}
+}
+
+func (l *SketchLimiter) Allow(key string) bool {
+	return l.AllowN(key, 1)
+}
+
+// AllowN reports whether n events for key fit in the sliding window now,
+// recording them if so. A negative n is denied.
+func (l *SketchLimiter) AllowN(key string, n int) bool {
+	_, ok := l.take(l.clock.Now(), key, n)
+	return ok
+}
+
+// WaitN blocks until n events for key fit in the sliding window or ctx is
+// done. A negative n fails with ErrNegativeTokens.
+func (l *SketchLimiter) WaitN(ctx context.Context, key string, n int) error {
+	if n < 0 {
+		return negativeError(n)
+	}
+	if n > l.limit {
+		return burstError(n, l.limit)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctxError(n, ctx.Err(), 0)
+		default:
+		}
+		delay, ok := l.take(l.clock.Now(), key, n)
+		if ok {
+			return nil
+		}
+		if err := sleepCtx(ctx, l.clock, delay); err != nil {
+			return ctxError(n, err, delay)
+		}
+	}
+}
+
+// take records n events for key at t if they fit. Otherwise it returns how
+// long to wait before trying again. A negative n never fits.
+func (l *SketchLimiter) take(t time.Time, key string, n int) (time.Duration, bool) {
+	if n < 0 {
+		return InfiniteDuration, false
+	}
+	h := maphash.String(l.seed, key)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.advance(t)
+	curr, prev := l.estimate(h)
+	elapsed := t.Sub(l.start)
+	weight := 1 - float64(elapsed)/float64(l.window)
+	if float64(prev)*weight+float64(curr)+float64(n) <= float64(l.limit) {
+		l.add(h, n)
+		return 0, true
+	}
+
+	// As in SlidingWindowLimiter, wait for the previous window's weight to
+	// fall far enough, or else until the next window.
+	delay := l.window - elapsed
+	if room := l.limit - int(curr) - n; room >= 0 && prev > 0 {
+		needed := time.Duration((1 - float64(room)/float64(prev)) * float64(l.window))
+		if d := needed - elapsed; d < delay {
+			delay = d
+		}
+	}
+	if delay <= 0 {
+		delay = time.Nanosecond
+	}
+	return delay, false
+}
+
+// cell returns the index of the counter for hash h in row i, using double
+// hashing to derive depth independent-enough indexes from one hash.
+func (l *SketchLimiter) cell(h uint64, i int) int {
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	return i*l.width + int((h1+uint32(i)*h2)%uint32(l.width))
+}
+
+// estimate returns the smallest counts for h in the current and previous
+// windows.
+func (l *SketchLimiter) estimate(h uint64) (curr, prev uint32) {
+	curr, prev = ^uint32(0), ^uint32(0)
+	for i := 0; i < l.depth; i++ {
+		c := l.cell(h, i)
+		curr = min(curr, l.curr[c])
+		prev = min(prev, l.prev[c])
+	}
+	return curr, prev
+}
+
+func (l *SketchLimiter) add(h uint64, n int) {
+	for i := 0; i < l.depth; i++ {
+		l.curr[l.cell(h, i)] += uint32(n)
+	}
+}
+
+// advance rolls the fixed windows forward to the one containing t.
+func (l *SketchLimiter) advance(t time.Time) {
+	start := t.Truncate(l.window)
+	if !start.After(l.start) {
+		return
+	}
+	if start.Sub(l.start) == l.window {
+		l.curr, l.prev = l.prev, l.curr
+	} else {
+		clear(l.prev)
+	}
+	clear(l.curr)
+	l.start = start
+}
+
+// sketchKey is the Limiter returned by SketchLimiter.For.
+type sketchKey struct {
+	l   *SketchLimiter
+	key string
+}
+
+func (k sketchKey) Allow() bool       { return k.l.AllowN(k.key, 1) }
+func (k sketchKey) AllowN(n int) bool { return k.l.AllowN(k.key, n) }
+func (k sketchKey) Wait(n int) error  { return k.l.WaitN(context.Background(), k.key, n) }
+
+func (k sketchKey) WaitN(ctx context.Context, n int) error {
+	return k.l.WaitN(ctx, k.key, n)
+}
@@ -0,0 +1,84 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSketchLimitsEachKey(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewSketch(3, time.Minute, 0, 0, clk)
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow("a") {
+			t.Fatalf("request %d for a denied", i)
+		}
+	}
+	if l.Allow("a") {
+		t.Fatal("fourth request for a allowed")
+	}
+	if !l.Allow("b") {
+		t.Fatal("b denied because of a")
+	}
+
+	// Half a window later half of the previous window still counts.
+	clk.Sleep(90 * time.Second)
+	if !l.Allow("a") {
+		t.Fatal("a denied after the window slid")
+	}
+	if l.AllowN("a", 2) {
+		t.Fatal("a allowed over its limit with half of the old window still counting")
+	}
+}
+
+func TestSketchNeverAdmitsOverLimit(t *testing.T) {
+	// A tiny sketch forces collisions; keys may be denied early but never
+	// admitted over their limit.
+	l := NewSketch(5, time.Minute, 16, 2, newFakeClock(time.Unix(0, 0)))
+	allowed := map[string]int{}
+	for round := 0; round < 10; round++ {
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("device-%d", i)
+			if l.Allow(key) {
+				allowed[key]++
+			}
+		}
+	}
+	for key, n := range allowed {
+		if n > 5 {
+			t.Fatalf("%s admitted %d times, limit 5", key, n)
+		}
+	}
+}
+
+func TestSketchFor(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewSketch(1, time.Second, 0, 0, clk)
+	var lim Limiter = l.For("k")
+	if !lim.Allow() || lim.Allow() {
+		t.Fatal("For(k) did not share the key's count")
+	}
+	if err := lim.Wait(1); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if err := lim.WaitN(context.Background(), 2); !errors.Is(err, ErrBurstExceeded) {
+		t.Fatalf("WaitN over the limit: %v, want ErrBurstExceeded", err)
+	}
+}
+
+func TestSketchNegativeN(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewSketch(2, time.Minute, 0, 0, clk)
+	if l.AllowN("k", -5) {
+		t.Fatal("AllowN(-5) admitted")
+	}
+	if err := l.WaitN(context.Background(), "k", -5); !errors.Is(err, ErrNegativeTokens) {
+		t.Fatalf("WaitN(-5) = %v, want ErrNegativeTokens", err)
+	}
+	if !l.AllowN("k", 2) || l.AllowN("k", 1) {
+		t.Fatal("negative n changed the counts")
+	}
+}
@@ -0,0 +1,134 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SlidingWindowLimiter admits at most limit events in any window of the
+// given length, approximately. It keeps only the counts of the current and
+// previous fixed windows and weights the previous count by how much of it
+// still overlaps the sliding window, so its memory use is constant.
+type SlidingWindowLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	clock  Clock
+	start  time.Time
+	curr   int
+	prev   int
+}
+
+// NewSlidingWindow returns a SlidingWindowLimiter admitting limit events
+// per window. Fixed windows are aligned to multiples of window since the
+// zero time.
+func NewSlidingWindow(limit int, window time.Duration, clk Clock) *SlidingWindowLimiter {
+	if clk == nil {
+		clk = realClock{}
+	}
+	return &SlidingWindowLimiter{
+		limit:  limit,
+		window: window,
+		clock:  clk,
+		start:  clk.Now().Truncate(window),
+	}
+}
+
+func (l *SlidingWindowLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+func (l *SlidingWindowLimiter) Window() time.Duration {
+	return l.window
+}
+
+func (l *SlidingWindowLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+// AllowN reports whether n events fit in the sliding window now, recording
+// them if so. A negative n is denied.
+func (l *SlidingWindowLimiter) AllowN(n int) bool {
+	_, ok := l.take(l.clock.Now(), n)
+	return ok
+}
+
+// Wait is shorthand for WaitN(context.Background(), n).
+func (l *SlidingWindowLimiter) Wait(n int) error {
+	return l.WaitN(context.Background(), n)
+}
+
+// WaitN blocks until n events fit in the sliding window or ctx is done. A
+// negative n fails with ErrNegativeTokens.
+func (l *SlidingWindowLimiter) WaitN(ctx context.Context, n int) error {
+	if n < 0 {
+		return negativeError(n)
+	}
+	if limit := l.Limit(); n > limit {
+		return burstError(n, limit)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctxError(n, ctx.Err(), 0)
+		default:
+		}
+		delay, ok := l.take(l.clock.Now(), n)
+		if ok {
+			return nil
+		}
+		if err := sleepCtx(ctx, l.clock, delay); err != nil {
+			return ctxError(n, err, delay)
+		}
+	}
+}
+
+// take records n events at t if they fit. Otherwise it returns how long to
+// wait before trying again. A negative n never fits.
+func (l *SlidingWindowLimiter) take(t time.Time, n int) (time.Duration, bool) {
+	if n < 0 {
+		return InfiniteDuration, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.advance(t)
+	elapsed := t.Sub(l.start)
+	weight := 1 - float64(elapsed)/float64(l.window)
+	if float64(l.prev)*weight+float64(l.curr+n) <= float64(l.limit) {
+		l.curr += n
+		return 0, true
+	}
+
+	// The previous window's weight falls linearly; find when it has fallen
+	// far enough, or else retry at the start of the next window.
+	delay := l.window - elapsed
+	if room := l.limit - l.curr - n; room >= 0 && l.prev > 0 {
+		needed := time.Duration((1 - float64(room)/float64(l.prev)) * float64(l.window))
+		if d := needed - elapsed; d < delay {
+			delay = d
+		}
+	}
+	if delay <= 0 {
+		delay = time.Nanosecond
+	}
+	return delay, false
+}
+
+// advance rolls the fixed windows forward to the one containing t.
+func (l *SlidingWindowLimiter) advance(t time.Time) {
+	start := t.Truncate(l.window)
+	if !start.After(l.start) {
+		return
+	}
+	if start.Sub(l.start) == l.window {
+		l.prev = l.curr
+	} else {
+		l.prev = 0
+	}
+	l.curr = 0
+	l.start = start
+}
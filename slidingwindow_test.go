@@ -0,0 +1,67 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowAllow(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewSlidingWindow(10, time.Minute, clk)
+
+	if !l.AllowN(10) {
+		t.Fatalf("expected full window to be allowed")
+	}
+	if l.Allow() {
+		t.Fatalf("expected window to be exhausted")
+	}
+
+	// 15s into the next window the previous count weighs 75%: 7.5 used.
+	clk.Sleep(75 * time.Second)
+	if !l.AllowN(2) {
+		t.Fatalf("expected 2 events to fit")
+	}
+	if l.Allow() {
+		t.Fatalf("expected estimate of 9.5 to leave no room for another event")
+	}
+
+	// Two windows later the old counts no longer count.
+	clk.Sleep(2 * time.Minute)
+	if !l.AllowN(10) {
+		t.Fatalf("expected stale windows to be forgotten")
+	}
+}
+
+func TestSlidingWindowWaitN(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewSlidingWindow(4, time.Minute, clk)
+	l.AllowN(4)
+
+	if err := l.WaitN(context.Background(), 1); err != nil {
+		t.Fatalf("expected wait to succeed, got %v", err)
+	}
+	// Next window opens at 60s with weight 1; a quarter of the previous
+	// count must decay, which takes another 15s.
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 75*time.Second {
+		t.Fatalf("expected to wait 75s, got %v", got)
+	}
+	if err := l.WaitN(context.Background(), 5); err == nil {
+		t.Fatalf("expected wait above limit to fail")
+	}
+}
+
+func TestSlidingWindowNegativeN(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewSlidingWindow(2, time.Minute, clk)
+	if l.AllowN(-5) {
+		t.Fatal("AllowN(-5) admitted")
+	}
+	if err := l.WaitN(context.Background(), -5); !errors.Is(err, ErrNegativeTokens) {
+		t.Fatalf("WaitN(-5) = %v, want ErrNegativeTokens", err)
+	}
+	if !l.AllowN(2) || l.AllowN(1) {
+		t.Fatal("negative n changed the window")
+	}
+}
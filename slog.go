@@ -0,0 +1,39 @@
+package ratelimiter
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SlogObserver returns an Observer that logs denied requests at Warn level
+// and waits lasting at least longWait at Info level, with the fields key
+// (for keyed limiters), n, delay and remaining, plus shadow for decisions
+// that a limiter in shadow mode did not enforce. A zero longWait disables
+// wait logging.
+func SlogObserver(logger *slog.Logger, longWait time.Duration) Observer {
+	return ObserverFunc(func(d Decision) {
+		switch {
+		case !d.Allowed:
+			logger.LogAttrs(context.Background(), slog.LevelWarn, "rate limit denied", decisionAttrs(d)...)
+		case d.Waited && longWait > 0 && d.Delay >= longWait:
+			logger.LogAttrs(context.Background(), slog.LevelInfo, "rate limit wait", decisionAttrs(d)...)
+		}
+	})
+}
+
+func decisionAttrs(d Decision) []slog.Attr {
+	attrs := make([]slog.Attr, 0, 5)
+	if d.Key != "" {
+		attrs = append(attrs, slog.String("key", d.Key))
+	}
+	attrs = append(attrs,
+		slog.Int("n", d.N),
+		slog.Duration("delay", d.Delay),
+		slog.Float64("remaining", d.Remaining),
+	)
+	if d.Shadowed {
+		attrs = append(attrs, slog.Bool("shadow", true))
+	}
+	return attrs
+}
@@ -0,0 +1,42 @@
+package ratelimiter
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlogObserver(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	kl := NewKeyed(Every(time.Second), 1, newFakeClock(time.Unix(0, 0)))
+	kl.AddObserver(SlogObserver(logger, 500*time.Millisecond))
+
+	kl.Allow("alice")
+	kl.Allow("alice")
+	kl.Wait(context.Background(), "alice", 1)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		`level=WARN msg="rate limit denied" key=alice n=1 delay=0s remaining=0`,
+		`level=INFO msg="rate limit wait" key=alice n=1 delay=1s remaining=-1`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d log lines, got %q", len(want), lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package ratelimiter
+
+import (
+	"sync"
+	"time"
+)
+
+// Sometimes runs an action occasionally, for sampling logs or other side
+// effects too costly to perform on every call. Do runs its function on the
+// first First calls, on every Every-th call, and when Interval has passed
+// since it last ran; with several fields set, any one of them suffices.
+// A zero Sometimes runs the action exactly once. It behaves like
+// x/time/rate.Sometimes, except that time is read from Clock, so tests can
+// control it.
+//
+//	var sometimes = ratelimiter.Sometimes{First: 3, Interval: 10 * time.Second}
+//	sometimes.Do(func() { log.Print("slow request") })
+type Sometimes struct {
+	First    int           // run on the first First calls
+	Every    int           // run on every Every-th call, counting from the first
+	Interval time.Duration // run if Interval has passed since the last run
+	Clock    Clock         // nil means the system clock
+
+	mu    sync.Mutex
+	count int       // calls so far
+	last  time.Time // of the last run
+}
+
+// Do calls f if this call is one to sample. Calls to Do are serialized,
+// so f runs at most once at a time and never concurrently with a decision.
+func (s *Sometimes) Do(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clk := s.Clock
+	if clk == nil {
+		clk = realClock{}
+	}
+	if s.count == 0 ||
+		s.First > 0 && s.count < s.First ||
+		s.Every > 0 && s.count%s.Every == 0 ||
+		s.Interval > 0 && clk.Now().Sub(s.last) >= s.Interval {
+		f()
+		s.last = clk.Now()
+	}
+	s.count++
+}
@@ -0,0 +1,37 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSometimes(t *testing.T) {
+	count := func(s *Sometimes, calls int, between time.Duration) int {
+		clk, _ := s.Clock.(*fakeClock)
+		ran := 0
+		for i := 0; i < calls; i++ {
+			s.Do(func() { ran++ })
+			if clk != nil {
+				clk.Sleep(between)
+			}
+		}
+		return ran
+	}
+
+	if got := count(&Sometimes{}, 10, 0); got != 1 {
+		t.Errorf("zero Sometimes ran %d times, want once", got)
+	}
+	if got := count(&Sometimes{First: 3}, 10, 0); got != 3 {
+		t.Errorf("First: 3 ran %d times, want 3", got)
+	}
+	if got := count(&Sometimes{Every: 4}, 10, 0); got != 3 {
+		t.Errorf("Every: 4 ran %d times in 10 calls, want 3", got)
+	}
+	if got := count(&Sometimes{First: 2, Every: 5}, 10, 0); got != 3 {
+		t.Errorf("First: 2, Every: 5 ran %d times in 10 calls, want 3", got)
+	}
+	s := &Sometimes{Interval: time.Second, Clock: newFakeClock(time.Unix(0, 0))}
+	if got := count(s, 10, 300*time.Millisecond); got != 3 {
+		t.Errorf("Interval: 1s ran %d times over 3s, want 3", got)
+	}
+}
@@ -0,0 +1,149 @@
+package ratelimiter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLDialect selects the SQL syntax used by an SQLStore.
+type SQLDialect int
+
+const (
+	Postgres SQLDialect = iota
+	MySQL
+	SQLite
+)
+
+// SQLStore is a Store in a database/sql table with one row per key, for
+// global limits and quotas at rates where adding Redis is not worth it:
+// every Allow is a SELECT and a conditional UPDATE. Rows carry a version
+// column that every write increments, and CompareAndSwap updates a row
+// only WHERE its version is unchanged, so no row locks are held between
+// statements.
+//
+// The table is created by Migrate, or by running the statements from
+// SQLSchema in an external migration tool.
+type SQLStore struct {
+	db      *sql.DB
+	table   string
+	dialect SQLDialect
+	q       sqlQueries
+}
+
+// sqlQueries are the statements of an SQLStore, built once.
+type sqlQueries struct {
+	get, set, create, update string
+}
+
+// NewSQLStore returns an SQLStore keeping buckets in table, which must be
+// a plain, optionally schema-qualified, identifier.
+func NewSQLStore(db *sql.DB, table string, dialect SQLDialect) (*SQLStore, error) {
+	if err := checkSQLTable(table); err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db, table: table, dialect: dialect, q: newSQLQueries(table, dialect)}, nil
+}
+
+func checkSQLTable(table string) error {
+	for _, part := range strings.Split(table, ".") {
+		if part == "" || strings.IndexFunc(part, func(r rune) bool {
+			return !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+		}) >= 0 || part[0] >= '0' && part[0] <= '9' {
+			return fmt.Errorf("rate: bad SQL table name %q", table)
+		}
+	}
+	return nil
+}
+
+func newSQLQueries(table string, dialect SQLDialect) sqlQueries {
+	cols := "bucket_key, tokens, updated_at, version"
+	switch dialect {
+	case MySQL:
+		return sqlQueries{
+			get: "SELECT tokens, updated_at, version FROM " + table + " WHERE bucket_key = ?",
+			set: "INSERT INTO " + table + " (" + cols + ") VALUES (?, ?, ?, 1) " +
+				"ON DUPLICATE KEY UPDATE tokens = VALUES(tokens), updated_at = VALUES(updated_at), version = version + 1",
+			create: "INSERT IGNORE INTO " + table + " (" + cols + ") VALUES (?, ?, ?, 1)",
+			update: "UPDATE " + table + " SET tokens = ?, updated_at = ?, version = version + 1 WHERE bucket_key = ? AND version = ?",
+		}
+	default:
+		p := func(i int) string { return "?" }
+		if dialect == Postgres {
+			p = func(i int) string { return fmt.Sprintf("$%d", i) }
+		}
+		return sqlQueries{
+			get: "SELECT tokens, updated_at, version FROM " + table + " WHERE bucket_key = " + p(1),
+			set: "INSERT INTO " + table + " (" + cols + ") VALUES (" + p(1) + ", " + p(2) + ", " + p(3) + ", 1) " +
+				"ON CONFLICT (bucket_key) DO UPDATE SET tokens = excluded.tokens, updated_at = excluded.updated_at, version = " + table + ".version + 1",
+			create: "INSERT INTO " + table + " (" + cols + ") VALUES (" + p(1) + ", " + p(2) + ", " + p(3) + ", 1) " +
+				"ON CONFLICT (bucket_key) DO NOTHING",
+			update: "UPDATE " + table + " SET tokens = " + p(1) + ", updated_at = " + p(2) + ", version = version + 1 " +
+				"WHERE bucket_key = " + p(3) + " AND version = " + p(4),
+		}
+	}
+}
+
+// SQLSchema returns the statements creating an SQLStore's table if it
+// does not exist. updated_at holds Unix nanoseconds.
+func SQLSchema(table string, dialect SQLDialect) []string {
+	tokens := "DOUBLE PRECISION"
+	if dialect == SQLite {
+		tokens = "REAL"
+	}
+	return []string{
+		"CREATE TABLE IF NOT EXISTS " + table + " (" +
+			"bucket_key VARCHAR(255) NOT NULL PRIMARY KEY, " +
+			"tokens " + tokens + " NOT NULL, " +
+			"updated_at BIGINT NOT NULL, " +
+			"version BIGINT NOT NULL)",
+	}
+}
+
+// Migrate creates the store's table if it does not exist.
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	for _, stmt := range SQLSchema(s.table, s.dialect) {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) Get(ctx context.Context, key string) (BucketState, bool, error) {
+	var st BucketState
+	var updated, version int64
+	err := s.db.QueryRowContext(ctx, s.q.get, key).Scan(&st.Tokens, &updated, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return BucketState{}, false, nil
+	}
+	if err != nil {
+		return BucketState{}, false, err
+	}
+	st.UpdatedAt = time.Unix(0, updated)
+	st.Version = uint64(version)
+	return st, true, nil
+}
+
+func (s *SQLStore) Set(ctx context.Context, key string, st BucketState) error {
+	_, err := s.db.ExecContext(ctx, s.q.set, key, st.Tokens, st.UpdatedAt.UnixNano())
+	return err
+}
+
+func (s *SQLStore) CompareAndSwap(ctx context.Context, key string, old, new BucketState) (bool, error) {
+	var res sql.Result
+	var err error
+	if old.Version == 0 {
+		res, err = s.db.ExecContext(ctx, s.q.create, key, new.Tokens, new.UpdatedAt.UnixNano())
+	} else {
+		res, err = s.db.ExecContext(ctx, s.q.update, new.Tokens, new.UpdatedAt.UnixNano(), key, int64(old.Version))
+	}
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
@@ -0,0 +1,156 @@
+package ratelimiter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSQL is a database/sql driver for one SQLStore table, executing the
+// store's own statements against a map.
+type fakeSQL struct {
+	mu    sync.Mutex
+	q     sqlQueries
+	table bool
+	rows  map[string][3]driver.Value // tokens, updated_at, version
+}
+
+func (f *fakeSQL) Connect(context.Context) (driver.Conn, error) { return f, nil }
+func (f *fakeSQL) Driver() driver.Driver                        { return nil }
+func (f *fakeSQL) Prepare(string) (driver.Stmt, error)          { return nil, errors.New("not supported") }
+func (f *fakeSQL) Close() error                                 { return nil }
+func (f *fakeSQL) Begin() (driver.Tx, error)                    { return nil, errors.New("not supported") }
+
+func (f *fakeSQL) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS ") {
+		f.table = true
+		return driver.RowsAffected(0), nil
+	}
+	if !f.table {
+		return nil, errors.New("no such table")
+	}
+	switch query {
+	case f.q.set:
+		key := args[0].Value.(string)
+		version, _ := f.rows[key][2].(int64)
+		f.rows[key] = [3]driver.Value{args[1].Value, args[2].Value, version + 1}
+		return driver.RowsAffected(1), nil
+	case f.q.create:
+		key := args[0].Value.(string)
+		if _, ok := f.rows[key]; ok {
+			return driver.RowsAffected(0), nil
+		}
+		f.rows[key] = [3]driver.Value{args[1].Value, args[2].Value, int64(1)}
+		return driver.RowsAffected(1), nil
+	case f.q.update:
+		key := args[2].Value.(string)
+		row, ok := f.rows[key]
+		if !ok || row[2] != args[3].Value {
+			return driver.RowsAffected(0), nil
+		}
+		f.rows[key] = [3]driver.Value{args[0].Value, args[1].Value, row[2].(int64) + 1}
+		return driver.RowsAffected(1), nil
+	}
+	return nil, errors.New("unexpected statement: " + query)
+}
+
+func (f *fakeSQL) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if query != f.q.get {
+		return nil, errors.New("unexpected query: " + query)
+	}
+	rows := &fakeSQLRows{}
+	if row, ok := f.rows[args[0].Value.(string)]; ok {
+		rows.rows = append(rows.rows, row)
+	}
+	return rows, nil
+}
+
+type fakeSQLRows struct {
+	rows [][3]driver.Value
+}
+
+func (r *fakeSQLRows) Columns() []string { return []string{"tokens", "updated_at", "version"} }
+func (r *fakeSQLRows) Close() error      { return nil }
+
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0][:])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestSQLStore(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeSQL{rows: make(map[string][3]driver.Value)}
+	db := sql.OpenDB(fake)
+	defer db.Close()
+	s, err := NewSQLStore(db, "ratelimit.buckets", SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.q = s.q
+	if err := s.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := s.CompareAndSwap(ctx, "k", BucketState{}, BucketState{Tokens: 1.5, UpdatedAt: time.Unix(10, 5)}); !ok || err != nil {
+		t.Fatalf("create-only swap on a missing key: %v, %v", ok, err)
+	}
+	old, found, err := s.Get(ctx, "k")
+	if err != nil || !found || old.Tokens != 1.5 || !old.UpdatedAt.Equal(time.Unix(10, 5)) || old.Version != 1 {
+		t.Fatalf("Get = %+v, %v, %v", old, found, err)
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", BucketState{}, BucketState{Tokens: 2}); ok {
+		t.Fatal("create-only swap succeeded on an existing key")
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", old, BucketState{Tokens: 2}); !ok {
+		t.Fatal("swap with the current version failed")
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", old, BucketState{Tokens: 3}); ok {
+		t.Fatal("swap with a stale version succeeded")
+	}
+	if err := s.Set(ctx, "k", BucketState{Tokens: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if cur, _, _ := s.Get(ctx, "k"); cur.Tokens != 4 || cur.Version != 3 {
+		t.Fatalf("after Set: %+v", cur)
+	}
+	if _, found, err := s.Get(ctx, "missing"); found || err != nil {
+		t.Fatalf("Get of a missing key = %v, %v", found, err)
+	}
+
+	clk := newFakeClock(time.Unix(0, 0))
+	a := NewStoreLimiter(s, "api", 1, 2, clk)
+	b := NewStoreLimiter(s, "api", 1, 2, clk)
+	if !a.Allow() || !b.Allow() || a.Allow() {
+		t.Fatal("limiters on one table did not share a bucket")
+	}
+}
+
+func TestSQLStoreDialects(t *testing.T) {
+	for _, table := range []string{"", "1x", "a;drop", "a..b", "x-y"} {
+		if _, err := NewSQLStore(nil, table, Postgres); err == nil {
+			t.Errorf("NewSQLStore accepted table %q", table)
+		}
+	}
+	pg := newSQLQueries("buckets", Postgres)
+	if want := "WHERE bucket_key = $3 AND version = $4"; !strings.HasSuffix(pg.update, want) {
+		t.Errorf("Postgres update = %q, want suffix %q", pg.update, want)
+	}
+	my := newSQLQueries("buckets", MySQL)
+	if !strings.HasPrefix(my.create, "INSERT IGNORE") || strings.Contains(my.update, "$") {
+		t.Errorf("MySQL statements = %+v", my)
+	}
+}
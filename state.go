@@ -0,0 +1,160 @@
+package ratelimiter
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// stateVersion is the current snapshot format. Decoders accept every
+// version up to it, so old snapshots stay readable as fields are added.
+const stateVersion = 1
+
+// State is a snapshot of a limiter's bucket: enough to recreate it in
+// another process or after a restart.
+type State struct {
+	Rate      Rate      `json:"rate"`
+	Burst     int       `json:"burst"`
+	Tokens    float64   `json:"tokens"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// State returns a snapshot of the limiter's bucket.
+func (rl *RateLimiter) State() State {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return State{Rate: rl.rate, Burst: rl.maxTokens, Tokens: rl.tokens, UpdatedAt: rl.timeAt(rl.updatedAt)}
+}
+
+// Restore replaces the limiter's bucket with s. The clock, observers and
+// other configuration are kept.
+func (rl *RateLimiter) Restore(s State) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.clock == nil {
+		rl.clock = realClock{}
+	}
+	if rl.epoch.IsZero() {
+		rl.setEpoch()
+	}
+	if s.UpdatedAt.IsZero() {
+		s.UpdatedAt = rl.now()
+	}
+	rl.rate = s.Rate
+	rl.maxTokens = s.Burst
+	rl.tokens = s.Tokens
+	rl.updatedAt = rl.nanos(s.UpdatedAt)
+	rl.eventAt = rl.updatedAt
+	if s.Tokens < 0 {
+		rl.eventAt += int64(rl.bucketLocked().untilTokens(rl.eventAt, -s.Tokens))
+	}
+	rl.publishLocked()
+	rl.wakeLocked()
+}
+
+// Reset refills the bucket to its burst, as if it had been idle. Callers
+// waiting in WaitN start over against the full bucket.
+func (rl *RateLimiter) Reset() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.setTokensLocked(rl.now(), float64(rl.maxTokens))
+}
+
+// Drain empties the bucket, so that nothing is admitted until it refills.
+func (rl *RateLimiter) Drain() {
+	rl.SetTokens(0)
+}
+
+// SetTokens sets the number of available tokens, capped at the burst. A
+// negative number puts the bucket in debt, as outstanding reservations do.
+func (rl *RateLimiter) SetTokens(tokens float64) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.setTokensLocked(rl.now(), min(tokens, float64(rl.maxTokens)))
+}
+
+// charge takes n tokens for events admitted elsewhere, such as by peers
+// sharing a limit, leaving the bucket at most a burst in debt. Unlike
+// AvailableTokens followed by SetTokens, it cannot undo tokens taken by
+// concurrent callers.
+func (rl *RateLimiter) charge(n float64) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	t := rl.now()
+	tokens := rl.updateTokens(t)
+	rl.setTokensLocked(t, max(tokens-n, min(tokens, -float64(rl.maxTokens))))
+}
+
+func (rl *RateLimiter) setTokensLocked(t time.Time, tokens float64) {
+	rl.updatedAt = rl.stampLocked(t)
+	rl.tokens = tokens
+	rl.eventAt = rl.updatedAt
+	if tokens < 0 {
+		rl.eventAt += int64(rl.bucketLocked().untilTokens(rl.eventAt, -tokens))
+	}
+	rl.publishLocked()
+	rl.wakeLocked()
+}
+
+type stateJSON struct {
+	Version int `json:"v"`
+	State
+}
+
+// MarshalJSON encodes the limiter's State with a format version.
+func (rl *RateLimiter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(stateJSON{Version: stateVersion, State: rl.State()})
+}
+
+// UnmarshalJSON restores a State encoded by MarshalJSON.
+func (rl *RateLimiter) UnmarshalJSON(data []byte) error {
+	var s stateJSON
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s.Version < 1 || s.Version > stateVersion {
+		return fmt.Errorf("rate: unsupported state version %d", s.Version)
+	}
+	rl.Restore(s.State)
+	return nil
+}
+
+// MarshalBinary encodes the limiter's State compactly: a version byte, the
+// rate, burst and tokens as 8 bytes each, then the update time as encoded
+// by time.Time.MarshalBinary.
+func (rl *RateLimiter) MarshalBinary() ([]byte, error) {
+	s := rl.State()
+	at, err := s.UpdatedAt.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 25, 25+len(at))
+	buf[0] = stateVersion
+	binary.BigEndian.PutUint64(buf[1:], math.Float64bits(float64(s.Rate)))
+	binary.BigEndian.PutUint64(buf[9:], uint64(s.Burst))
+	binary.BigEndian.PutUint64(buf[17:], math.Float64bits(s.Tokens))
+	return append(buf, at...), nil
+}
+
+// UnmarshalBinary restores a State encoded by MarshalBinary.
+func (rl *RateLimiter) UnmarshalBinary(data []byte) error {
+	if len(data) < 25 {
+		return errors.New("rate: state too short")
+	}
+	if v := data[0]; v < 1 || v > stateVersion {
+		return fmt.Errorf("rate: unsupported state version %d", v)
+	}
+	s := State{
+		Rate:   Rate(math.Float64frombits(binary.BigEndian.Uint64(data[1:]))),
+		Burst:  int(int64(binary.BigEndian.Uint64(data[9:]))),
+		Tokens: math.Float64frombits(binary.BigEndian.Uint64(data[17:])),
+	}
+	if err := s.UpdatedAt.UnmarshalBinary(data[25:]); err != nil {
+		return err
+	}
+	rl.Restore(s)
+	return nil
+}
@@ -0,0 +1,99 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	clk := newFakeClock(time.Unix(100, 0))
+	rl := New(2, 5, clk)
+	rl.AllowN(4)
+	rl.ReserveN(2)
+
+	for name, codec := range map[string]struct {
+		marshal   func(*RateLimiter) ([]byte, error)
+		unmarshal func(*RateLimiter, []byte) error
+	}{
+		"json":   {(*RateLimiter).MarshalJSON, (*RateLimiter).UnmarshalJSON},
+		"binary": {(*RateLimiter).MarshalBinary, (*RateLimiter).UnmarshalBinary},
+	} {
+		data, err := codec.marshal(rl)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		restored := New(1, 1, clk)
+		if err := codec.unmarshal(restored, data); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got, want := restored.State(), rl.State(); !got.UpdatedAt.Equal(want.UpdatedAt) || got.Rate != want.Rate || got.Burst != want.Burst || got.Tokens != want.Tokens {
+			t.Fatalf("%s: restored %+v, want %+v", name, got, want)
+		}
+		if got := restored.ReserveN(1).Delay(); got != time.Second {
+			t.Fatalf("%s: expected the restored debt to delay 1s, got %v", name, got)
+		}
+	}
+}
+
+func TestStateRejectsUnknownVersion(t *testing.T) {
+	var rl RateLimiter
+	if err := json.Unmarshal([]byte(`{"v":99,"rate":1,"burst":1}`), &rl); err == nil {
+		t.Fatal("expected an unknown JSON version to be rejected")
+	}
+	if err := rl.UnmarshalBinary([]byte{99}); err == nil {
+		t.Fatal("expected a short or unknown binary state to be rejected")
+	}
+	if err := json.Unmarshal([]byte(`{"v":1,"rate":1,"burst":2,"tokens":2,"future":true}`), &rl); err != nil {
+		t.Fatalf("expected unknown fields to be ignored, got %v", err)
+	}
+	if !rl.AllowN(2) {
+		t.Fatal("expected a zero limiter to work after unmarshaling")
+	}
+}
+
+func TestResetDrainSetTokens(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(1, 5, clk)
+
+	rl.Drain()
+	if rl.Allow() {
+		t.Fatal("Allow succeeded on a drained bucket")
+	}
+	rl.Reset()
+	if got := rl.AvailableTokens(); got != 5 {
+		t.Fatalf("AvailableTokens() after Reset = %v, want 5", got)
+	}
+	rl.SetTokens(2.5)
+	if got := rl.AvailableTokens(); got != 2.5 {
+		t.Fatalf("AvailableTokens() after SetTokens(2.5) = %v", got)
+	}
+	rl.SetTokens(100)
+	if got := rl.AvailableTokens(); got != 5 {
+		t.Fatalf("SetTokens above the burst left %v tokens, want 5", got)
+	}
+	rl.SetTokens(-2)
+	if got := rl.NextAvailableAt(1); !got.Equal(time.Unix(3, 0)) {
+		t.Fatalf("after SetTokens(-2) a token is next available at %v, want 3s", got)
+	}
+}
+
+func TestResetWakesWaiters(t *testing.T) {
+	rl := New(0.01, 1, nil)
+	rl.Allow()
+	done := make(chan error, 1)
+	go func() { done <- rl.Wait(1) }()
+	for rl.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	rl.Reset()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Wait: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not notice the Reset")
+	}
+}
@@ -0,0 +1,121 @@
+package ratelimiter
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats are cumulative counts of a limiter's decisions.
+type Stats struct {
+	Since   time.Time // creation, or the last ResetStats
+	Allowed uint64
+	Denied  uint64
+
+	// LastSeen is the time of the most recent decision, or the zero time
+	// if there has been none.
+	LastSeen time.Time
+
+	// TotalWait and MaxWait cover the delays of Wait and WaitN calls.
+	TotalWait time.Duration
+	MaxWait   time.Duration
+
+	// Tokens is the number of tokens available when Stats was called.
+	Tokens float64
+}
+
+// counters accumulates Stats with atomic operations, so that recording a
+// decision costs no more than an atomic add or two.
+type counters struct {
+	since     atomic.Int64 // ns since the limiter's epoch
+	allowed   atomic.Uint64
+	denied    atomic.Uint64
+	totalWait atomic.Int64
+	maxWait   atomic.Int64
+	lastSeen  atomic.Int64 // Unix ns plus one, so that 0 means none
+}
+
+func (c *counters) record(d Decision) {
+	c.lastSeen.Store(d.Time.UnixNano() + 1)
+	if d.Allowed {
+		c.allowed.Add(1)
+	} else {
+		c.denied.Add(1)
+	}
+	if !d.Waited || d.Delay <= 0 || d.Delay == InfiniteDuration {
+		return
+	}
+	c.totalWait.Add(int64(d.Delay))
+	for {
+		max := c.maxWait.Load()
+		if int64(d.Delay) <= max || c.maxWait.CompareAndSwap(max, int64(d.Delay)) {
+			return
+		}
+	}
+}
+
+// Stats returns the decisions made by rl since it was created or since
+// the last ResetStats. It does not take the limiter's lock. Decisions in
+// shadow mode are counted as they would have been enforced.
+func (rl *RateLimiter) Stats() Stats {
+	c := &rl.counters
+	s := Stats{
+		Since:     rl.timeAt(c.since.Load()),
+		Allowed:   c.allowed.Load(),
+		Denied:    c.denied.Load(),
+		TotalWait: time.Duration(c.totalWait.Load()),
+		MaxWait:   time.Duration(c.maxWait.Load()),
+		Tokens:    rl.AvailableTokens(),
+	}
+	if ns := c.lastSeen.Load(); ns != 0 {
+		s.LastSeen = time.Unix(0, ns-1)
+	}
+	return s
+}
+
+// ResetStats zeroes the counters reported by Stats. Decisions made while
+// it runs may be counted in either period.
+func (rl *RateLimiter) ResetStats() {
+	c := &rl.counters
+	c.allowed.Store(0)
+	c.denied.Store(0)
+	c.totalWait.Store(0)
+	c.maxWait.Store(0)
+	c.since.Store(rl.nanos(rl.now()))
+}
+
+// KeyStats returns the Stats of key's limiter, and false if key has no live
+// limiter. Counts start over if the key is evicted and seen again.
+func (kl *KeyedLimiter) KeyStats(key string) (Stats, bool) {
+	s := kl.shard(key)
+	s.mu.Lock()
+	rl, ok := s.limiters[key]
+	s.mu.Unlock()
+	if !ok {
+		return Stats{}, false
+	}
+	return rl.Stats(), true
+}
+
+// RangeStats calls fn with the Stats of every live key, in no particular
+// order, until fn returns false. Keys added or removed meanwhile may or may
+// not be visited. fn is called without any lock held.
+func (kl *KeyedLimiter) RangeStats(fn func(key string, s Stats) bool) {
+	type entry struct {
+		key string
+		rl  *RateLimiter
+	}
+	var entries []entry
+	for _, s := range kl.shards {
+		s.mu.Lock()
+		entries = entries[:0]
+		for key, rl := range s.limiters {
+			entries = append(entries, entry{key, rl})
+		}
+		s.mu.Unlock()
+		for _, e := range entries {
+			if !fn(e.key, e.rl.Stats()) {
+				return
+			}
+		}
+	}
+}
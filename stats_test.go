@@ -0,0 +1,80 @@
+package ratelimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(1, 2, clk)
+
+	rl.AllowN(2)
+	rl.Allow()
+	rl.Wait(1) // waits 1s
+	rl.Allow()
+	rl.WaitN(context.Background(), 2) // waits 2s
+
+	s := rl.Stats()
+	if s.Allowed != 3 || s.Denied != 2 {
+		t.Fatalf("allowed %d denied %d, want 3 and 2", s.Allowed, s.Denied)
+	}
+	if s.TotalWait != 3*time.Second || s.MaxWait != 2*time.Second {
+		t.Fatalf("total wait %v max wait %v, want 3s and 2s", s.TotalWait, s.MaxWait)
+	}
+	if s.Tokens != 0 || !s.Since.Equal(time.Unix(0, 0)) {
+		t.Fatalf("tokens %v since %v, want 0 and the creation time", s.Tokens, s.Since)
+	}
+
+	rl.ResetStats()
+	if s := rl.Stats(); s.Allowed != 0 || s.Denied != 0 || s.TotalWait != 0 || s.MaxWait != 0 || !s.Since.Equal(clk.Now()) {
+		t.Fatalf("after ResetStats: %+v", s)
+	}
+}
+
+func TestStatsDoNotAllocate(t *testing.T) {
+	rl := New(1e9, 1<<30, newFakeClock(time.Unix(0, 0)))
+	if allocs := testing.AllocsPerRun(1000, func() {
+		rl.Allow()
+		rl.Stats()
+	}); allocs != 0 {
+		t.Fatalf("expected no allocations, got %v", allocs)
+	}
+}
+
+func TestKeyStats(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyedSharded(1, 1, 4, clk)
+	for i := 0; i < 5; i++ {
+		kl.Allow("noisy")
+	}
+	clk.Sleep(time.Second)
+	kl.Allow("quiet")
+
+	s, ok := kl.KeyStats("noisy")
+	if !ok || s.Allowed != 1 || s.Denied != 4 || !s.LastSeen.Equal(time.Unix(0, 0)) {
+		t.Fatalf("KeyStats(noisy) = %+v, %v", s, ok)
+	}
+	if _, ok := kl.KeyStats("unknown"); ok {
+		t.Fatal("KeyStats reported an unknown key")
+	}
+
+	denied := map[string]uint64{}
+	kl.RangeStats(func(key string, s Stats) bool {
+		denied[key] = s.Denied
+		return true
+	})
+	if len(denied) != 2 || denied["noisy"] != 4 || denied["quiet"] != 0 {
+		t.Fatalf("RangeStats saw %v", denied)
+	}
+
+	visited := 0
+	kl.RangeStats(func(string, Stats) bool {
+		visited++
+		return false
+	})
+	if visited != 1 {
+		t.Fatalf("RangeStats kept going after fn returned false: %d visits", visited)
+	}
+}
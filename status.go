@@ -0,0 +1,105 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Status is a snapshot of a token bucket.
+type Status struct {
+	At        time.Time // when the snapshot was taken
+	Limit     int       // burst size
+	Remaining float64   // tokens available at At
+
+	// NextAt is when at least one whole token is available and ResetAt is
+	// when the bucket is full again. Both are the zero time if the rate is
+	// zero and the bucket can never refill that far.
+	NextAt  time.Time
+	ResetAt time.Time
+}
+
+// Status returns a snapshot of the bucket taken at the current time.
+func (rl *RateLimiter) Status() Status {
+	t := rl.now()
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	tokens := rl.updateTokens(t)
+	b, at := rl.bucketLocked(), rl.nanos(t)
+	s := Status{
+		At:        t,
+		Limit:     rl.maxTokens,
+		Remaining: tokens,
+		NextAt:    t,
+		ResetAt:   t,
+	}
+	if tokens < 1 {
+		s.NextAt = timeAfter(t, b.untilTokens(at, 1-tokens))
+	}
+	if max := float64(b.capacity()); tokens < max {
+		s.ResetAt = timeAfter(t, b.untilTokens(at, max-tokens))
+	}
+	return s
+}
+
+// NextAvailableAt returns the earliest time at which n tokens will be
+// available, without reserving them. It is the current time if they are
+// available now, and the zero time if they never will be because n exceeds
+// the burst or the rate is zero. Callers queued in WaitN are not taken into
+// account.
+func (rl *RateLimiter) NextAvailableAt(n int) time.Time {
+	t := rl.now()
+	b, tokens, updatedAt := rl.loadPublished()
+	if n > b.burst && b.rate != InfiniteRate {
+		return time.Time{}
+	}
+	at := rl.nanos(t)
+	tokens = b.tokensAt(tokens, updatedAt, at)
+	if missing := float64(n) - tokens; missing > 0 {
+		return timeAfter(t, b.untilTokens(at, missing))
+	}
+	return t
+}
+
+// timeAfter returns t+d, or the zero time if d is InfiniteDuration.
+func timeAfter(t time.Time, d time.Duration) time.Time {
+	if d == InfiniteDuration {
+		return time.Time{}
+	}
+	return t.Add(d)
+}
+
+// SetHeaders writes the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers described by the IETF RateLimit header fields
+// draft to h. If no whole token remains it also writes Retry-After, so it
+// suits responses to requests being throttled; for a response to an
+// admission decision, use Result.SetHeaders, which writes Retry-After
+// only on denials. All durations are in whole seconds, rounded up.
+func (s Status) SetHeaders(h http.Header) {
+	setLimitHeaders(h, s.At, s.Limit, s.Remaining, s.ResetAt)
+	if s.Remaining < 1 && !s.NextAt.IsZero() {
+		setRetryAfter(h, s.NextAt.Sub(s.At))
+	}
+}
+
+// setLimitHeaders writes the RateLimit-* headers for a bucket of limit
+// tokens with remaining left at, full again at resetAt.
+func setLimitHeaders(h http.Header, at time.Time, limit int, remaining float64, resetAt time.Time) {
+	h.Set("RateLimit-Limit", strconv.Itoa(limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(max(int(remaining), 0)))
+	if !resetAt.IsZero() {
+		h.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(resetAt.Sub(at)), 10))
+	}
+}
+
+func setRetryAfter(h http.Header, d time.Duration) {
+	h.Set("Retry-After", strconv.FormatInt(ceilSeconds(d), 10))
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,97 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatus(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(Every(time.Second), 3, clk)
+	rl.AllowN(3)
+	clk.Sleep(500 * time.Millisecond)
+
+	s := rl.Status()
+	if s.Limit != 3 || s.Remaining != 0.5 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+	if want := time.Unix(1, 0); !s.NextAt.Equal(want) {
+		t.Fatalf("expected next token at %v, got %v", want, s.NextAt)
+	}
+	if want := time.Unix(3, 0); !s.ResetAt.Equal(want) {
+		t.Fatalf("expected reset at %v, got %v", want, s.ResetAt)
+	}
+
+	blocked := New(0, 1, clk)
+	blocked.Allow()
+	s = blocked.Status()
+	if !s.NextAt.IsZero() || !s.ResetAt.IsZero() {
+		t.Fatalf("expected drained zero-rate bucket to never refill, got %+v", s)
+	}
+}
+
+func TestStatusSetHeaders(t *testing.T) {
+	at := time.Unix(0, 0)
+	h := http.Header{}
+	Status{At: at, Limit: 10, Remaining: 0.5, NextAt: at.Add(200 * time.Millisecond), ResetAt: at.Add(9500 * time.Millisecond)}.SetHeaders(h)
+
+	want := map[string]string{
+		"RateLimit-Limit":     "10",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "10",
+		"Retry-After":         "1",
+	}
+	for k, v := range want {
+		if got := h.Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestMiddlewareHeaders(t *testing.T) {
+	kl := NewKeyed(Every(time.Second), 2, newFakeClock(time.Unix(0, 0)))
+	h := (&Middleware{Limiter: kl, Headers: true}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	var rec *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if i == 0 && rec.Header().Get("RateLimit-Remaining") != "1" {
+			t.Fatalf("expected 1 remaining after first request, got %q", rec.Header().Get("RateLimit-Remaining"))
+		}
+		if i == 1 && (rec.Code != http.StatusOK || rec.Header().Get("Retry-After") != "") {
+			t.Fatalf("request taking the last token: %d with Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+		}
+	}
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected throttled response with Retry-After, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestNextAvailableAt(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(2, 4, clk)
+	if got := rl.NextAvailableAt(4); !got.Equal(clk.Now()) {
+		t.Fatalf("NextAvailableAt(4) on a full bucket = %v, want now", got)
+	}
+	rl.AllowN(4)
+	if got, want := rl.NextAvailableAt(3), time.Unix(1, 500_000_000); !got.Equal(want) {
+		t.Fatalf("NextAvailableAt(3) = %v, want %v", got, want)
+	}
+	if rl.AvailableTokens() != 0 {
+		t.Fatal("NextAvailableAt reserved tokens")
+	}
+	if got := rl.NextAvailableAt(5); !got.IsZero() {
+		t.Fatalf("NextAvailableAt over the burst = %v, want the zero time", got)
+	}
+	if got := New(0, 1, clk).NextAvailableAt(1); !got.Equal(clk.Now()) {
+		t.Fatalf("NextAvailableAt on a full zero-rate bucket = %v, want now", got)
+	}
+	blocked := New(0, 1, clk)
+	blocked.Allow()
+	if got := blocked.NextAvailableAt(1); !got.IsZero() {
+		t.Fatalf("NextAvailableAt on an empty zero-rate bucket = %v, want the zero time", got)
+	}
+}
@@ -0,0 +1,269 @@
+package ratelimiter
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// BucketState is the state of a token bucket as kept by a Store.
+type BucketState struct {
+	Tokens    float64
+	UpdatedAt time.Time
+
+	// Version is assigned by the Store and changes on every write. Callers
+	// treat it as opaque and pass it back to CompareAndSwap unchanged.
+	Version uint64
+}
+
+// Store persists token bucket state keyed by string, so the bucket math in
+// StoreLimiter can run against any backend. Implementations must be safe
+// for concurrent use and must honor the following contract:
+//
+//   - Get returns ok == false if nothing is stored under key.
+//   - Set stores s unconditionally.
+//   - CompareAndSwap stores new only if the version currently stored under
+//     key equals old.Version, and reports whether it did. The zero
+//     BucketState as old means "only if nothing is stored under key".
+//     new.Version is ignored; the store assigns the next version.
+type Store interface {
+	Get(ctx context.Context, key string) (s BucketState, ok bool, err error)
+	Set(ctx context.Context, key string, s BucketState) error
+	CompareAndSwap(ctx context.Context, key string, old, new BucketState) (bool, error)
+}
+
+// encodeBucket encodes the tokens and update time of s in 16 bytes, for
+// stores that keep opaque values.
+func encodeBucket(s BucketState) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, math.Float64bits(s.Tokens))
+	binary.BigEndian.PutUint64(b[8:], uint64(s.UpdatedAt.UnixNano()))
+	return b
+}
+
+// decodeBucket decodes a value written by encodeBucket.
+func decodeBucket(b []byte) (BucketState, error) {
+	if len(b) != 16 {
+		return BucketState{}, fmt.Errorf("rate: bad bucket encoding of %d bytes", len(b))
+	}
+	return BucketState{
+		Tokens:    math.Float64frombits(binary.BigEndian.Uint64(b)),
+		UpdatedAt: time.Unix(0, int64(binary.BigEndian.Uint64(b[8:]))),
+	}, nil
+}
+
+// MemoryStore is an in-process Store.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]BucketState
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]BucketState)}
+}
+
+func (m *MemoryStore) Get(_ context.Context, key string) (BucketState, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.buckets[key]
+	return s, ok, nil
+}
+
+func (m *MemoryStore) Set(_ context.Context, key string, s BucketState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.Version = m.buckets[key].Version + 1
+	m.buckets[key] = s
+	return nil
+}
+
+func (m *MemoryStore) CompareAndSwap(_ context.Context, key string, old, new BucketState) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets[key].Version != old.Version {
+		return false, nil
+	}
+	new.Version = old.Version + 1
+	m.buckets[key] = new
+	return true, nil
+}
+
+// ErrStoreContention is returned when a StoreLimiter cannot update its
+// bucket because other writers keep winning the compare-and-swap.
+var ErrStoreContention = errors.New("rate: too much contention on store")
+
+// maxCASAttempts bounds the compare-and-swap retries of one StoreLimiter
+// operation.
+const maxCASAttempts = 16
+
+// StoreLimiter is a token bucket whose state is kept in a Store under a
+// single key. Any number of StoreLimiters, in any number of processes, may
+// share a key; updates are serialized through CompareAndSwap.
+type StoreLimiter struct {
+	store Store
+	key   string
+	rate  Rate
+	burst int
+	clock Clock
+}
+
+// NewStoreLimiter returns a StoreLimiter keeping its bucket under key.
+func NewStoreLimiter(store Store, key string, rate Rate, burst int, clk Clock) *StoreLimiter {
+	if clk == nil {
+		clk = realClock{}
+	}
+	return &StoreLimiter{
+		store: store,
+		key:   key,
+		rate:  rate,
+		burst: burst,
+		clock: clk,
+	}
+}
+
+func (l *StoreLimiter) Rate() Rate {
+	return l.rate
+}
+
+func (l *StoreLimiter) Burst() int {
+	return l.burst
+}
+
+func (l *StoreLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+// AllowN reports whether n tokens can be taken immediately. Store errors
+// are treated as a denial; use AllowNContext to observe them.
+func (l *StoreLimiter) AllowN(n int) bool {
+	ok, err := l.AllowNContext(context.Background(), n)
+	return ok && err == nil
+}
+
+// AllowNContext is like AllowN but honors ctx and reports store errors.
+// A negative n is denied without contacting the store.
+func (l *StoreLimiter) AllowNContext(ctx context.Context, n int) (bool, error) {
+	if n < 0 {
+		return false, nil
+	}
+	ok, _, err := l.reserve(ctx, n, 0)
+	return ok, err
+}
+
+// Wait is shorthand for WaitN(context.Background(), n).
+func (l *StoreLimiter) Wait(n int) error {
+	return l.WaitN(context.Background(), n)
+}
+
+// WaitN blocks until n tokens are available or ctx is done. Tokens reserved
+// for a wait that is abandoned because ctx is done are returned. A negative
+// n fails with ErrNegativeTokens.
+func (l *StoreLimiter) WaitN(ctx context.Context, n int) error {
+	if n < 0 {
+		return negativeError(n)
+	}
+	select {
+	case <-ctx.Done():
+		return ctxError(n, ctx.Err(), 0)
+	default:
+	}
+	if n > l.burst && l.rate != InfiniteRate {
+		return burstError(n, l.burst)
+	}
+	ok, wait, err := l.reserve(ctx, n, InfiniteDuration)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return neverError(n)
+	}
+	if wait <= 0 {
+		return nil
+	}
+	if err := sleepCtx(ctx, l.clock, wait); err != nil {
+		// Use a fresh context: ctx is already done.
+		_ = l.update(context.Background(), func(tokens float64) (float64, bool) {
+			return tokens + float64(n), true
+		})
+		return ctxError(n, err, wait)
+	}
+	return nil
+}
+
+// reserve takes n tokens if they are available within maxWait and returns
+// how long the caller must wait before using them.
+func (l *StoreLimiter) reserve(ctx context.Context, n int, maxWait time.Duration) (bool, time.Duration, error) {
+	if l.rate == InfiniteRate {
+		return true, 0, nil
+	}
+	var wait time.Duration
+	ok := false
+	err := l.update(ctx, func(tokens float64) (float64, bool) {
+		tokens -= float64(n)
+		wait = 0
+		if tokens < 0 {
+			wait = l.rate.durationFromTokens(-tokens)
+		}
+		ok = n <= l.burst && wait <= maxWait
+		return tokens, ok
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	return ok, wait, nil
+}
+
+// take removes n tokens from the stored bucket, even if that leaves it in
+// debt, and returns the tokens left.
+func (l *StoreLimiter) take(ctx context.Context, n int) (float64, error) {
+	var left float64
+	err := l.update(ctx, func(tokens float64) (float64, bool) {
+		left = tokens - float64(n)
+		return left, n != 0
+	})
+	return left, err
+}
+
+// update refills the stored bucket to now and applies fn to its tokens,
+// writing the result back if fn reports true. It retries on concurrent
+// modification.
+func (l *StoreLimiter) update(ctx context.Context, fn func(tokens float64) (float64, bool)) error {
+	for i := 0; i < maxCASAttempts; i++ {
+		old, found, err := l.store.Get(ctx, l.key)
+		if err != nil {
+			return err
+		}
+		now := l.clock.Now()
+		tokens := float64(l.burst)
+		if found {
+			// A replica whose clock lags another's must not move the
+			// bucket's time back, or the interval between the two would
+			// be refilled twice.
+			if now.Before(old.UpdatedAt) {
+				now = old.UpdatedAt
+			}
+			tokens = refill(old.Tokens, now.Sub(old.UpdatedAt), l.rate, l.burst)
+		} else {
+			old = BucketState{}
+		}
+		tokens, write := fn(tokens)
+		if !write {
+			return nil
+		}
+		if max := float64(l.burst); tokens > max {
+			tokens = max
+		}
+		swapped, err := l.store.CompareAndSwap(ctx, l.key, old, BucketState{Tokens: tokens, UpdatedAt: now})
+		if err != nil {
+			return err
+		}
+		if swapped {
+			return nil
+		}
+	}
+	return ErrStoreContention
+}
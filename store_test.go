@@ -0,0 +1,98 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreCompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	if ok, _ := s.CompareAndSwap(ctx, "k", BucketState{Version: 1}, BucketState{Tokens: 1}); ok {
+		t.Fatalf("expected swap with stale version to fail on missing key")
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", BucketState{}, BucketState{Tokens: 1}); !ok {
+		t.Fatalf("expected swap to create missing key")
+	}
+	old, found, _ := s.Get(ctx, "k")
+	if !found || old.Tokens != 1 {
+		t.Fatalf("expected stored state, got %+v found=%v", old, found)
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", BucketState{}, BucketState{Tokens: 2}); ok {
+		t.Fatalf("expected create-only swap to fail on existing key")
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", old, BucketState{Tokens: 2}); !ok {
+		t.Fatalf("expected swap with current version to succeed")
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "k", old, BucketState{Tokens: 3}); ok {
+		t.Fatalf("expected swap with old version to fail")
+	}
+}
+
+func TestStoreLimiterSharedBucket(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	store := NewMemoryStore()
+	a := NewStoreLimiter(store, "api", Every(100*time.Millisecond), 2, clk)
+	b := NewStoreLimiter(store, "api", Every(100*time.Millisecond), 2, clk)
+
+	if !a.Allow() || !b.Allow() {
+		t.Fatalf("expected burst to be shared and allowed")
+	}
+	if a.Allow() || b.Allow() {
+		t.Fatalf("expected shared bucket to be exhausted")
+	}
+	if err := a.WaitN(context.Background(), 1); err != nil {
+		t.Fatalf("expected wait to succeed, got %v", err)
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 100*time.Millisecond {
+		t.Fatalf("expected to wait 100ms, got %v", got)
+	}
+}
+
+func TestStoreLimiterClockSkew(t *testing.T) {
+	store := NewMemoryStore()
+	ahead := newFakeClock(time.Unix(10, 0))
+	behind := newFakeClock(time.Unix(0, 0))
+	a := NewStoreLimiter(store, "api", Every(time.Second), 2, ahead)
+	b := NewStoreLimiter(store, "api", Every(time.Second), 2, behind)
+
+	if !a.Allow() || !b.Allow() {
+		t.Fatal("expected the burst to be shared and allowed")
+	}
+	// b's update must not have moved the bucket's time back 10s, which
+	// would refill the bucket for a.
+	if a.Allow() {
+		t.Fatal("leading replica was refilled after the lagging one's update")
+	}
+}
+
+// racingStore loses every compare-and-swap.
+type racingStore struct{ *MemoryStore }
+
+func (racingStore) CompareAndSwap(context.Context, string, BucketState, BucketState) (bool, error) {
+	return false, nil
+}
+
+func TestStoreLimiterContention(t *testing.T) {
+	l := NewStoreLimiter(racingStore{NewMemoryStore()}, "api", Every(time.Second), 1, nil)
+	if _, err := l.AllowNContext(context.Background(), 1); !errors.Is(err, ErrStoreContention) {
+		t.Fatalf("expected ErrStoreContention, got %v", err)
+	}
+}
+
+func TestStoreLimiterNegativeN(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	l := NewStoreLimiter(NewMemoryStore(), "api", Every(time.Second), 2, clk)
+	if l.AllowN(-5) {
+		t.Fatal("AllowN(-5) admitted")
+	}
+	if err := l.WaitN(context.Background(), -5); !errors.Is(err, ErrNegativeTokens) {
+		t.Fatalf("WaitN(-5) = %v, want ErrNegativeTokens", err)
+	}
+	if !l.AllowN(2) || l.AllowN(1) {
+		t.Fatal("negative n changed the bucket")
+	}
+}
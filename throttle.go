@@ -0,0 +1,180 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Throttled is a function wrapped by Throttle.
+type Throttled struct {
+	fn func()
+	l  Limiter
+
+	mu      sync.Mutex
+	pending bool               // a trailing call is waiting for a token
+	cancel  context.CancelFunc // of the trailing call's wait
+	gen     uint64             // counts trailing calls, so a stale one is ignored
+	closed  bool
+}
+
+// Throttle wraps fn so that calls run it at most at l's rate. A call the
+// limiter admits runs fn at once; calls it does not are coalesced into
+// one trailing call, run as soon as l has a token, so the last call in a
+// burst is never lost, unless the limiter fails the wait for it, as a
+// paused one does. fn may run on another goroutine.
+func Throttle(fn func(), l Limiter) *Throttled {
+	return &Throttled{fn: fn, l: l}
+}
+
+// Call runs fn now if the limiter admits it and otherwise schedules the
+// trailing call, if none is pending. It does nothing after Close.
+func (t *Throttled) Call() {
+	t.mu.Lock()
+	if t.closed || t.pending {
+		t.mu.Unlock()
+		return
+	}
+	if t.l.Allow() {
+		t.mu.Unlock()
+		t.fn()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.gen++
+	t.pending, t.cancel = true, cancel
+	gen := t.gen
+	t.mu.Unlock()
+	go t.trail(ctx, gen)
+}
+
+// trail waits for a token for trailing call gen and runs fn, unless Flush
+// ran it first. A failed wait drops the call, so later calls can schedule
+// another.
+func (t *Throttled) trail(ctx context.Context, gen uint64) {
+	err := t.l.WaitN(ctx, 1)
+	t.mu.Lock()
+	run := err == nil && t.pending && t.gen == gen
+	if t.pending && t.gen == gen {
+		t.pending = false
+		t.cancel()
+		t.cancel = nil
+	}
+	t.mu.Unlock()
+	if run {
+		t.fn()
+	}
+}
+
+// Flush runs the pending trailing call now, without waiting for the
+// limiter, and reports whether there was one.
+func (t *Throttled) Flush() bool {
+	t.mu.Lock()
+	pending := t.pending
+	if pending {
+		t.pending = false
+		t.cancel()
+		t.cancel = nil
+	}
+	t.mu.Unlock()
+	if pending {
+		t.fn()
+	}
+	return pending
+}
+
+// Close flushes any pending trailing call and makes further calls do
+// nothing.
+func (t *Throttled) Close() {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+	t.Flush()
+}
+
+// Debounced is a function wrapped by Debounce.
+type Debounced struct {
+	fn    func()
+	d     time.Duration
+	clock Clock
+
+	mu       sync.Mutex
+	pending  bool
+	deadline time.Time // when the pending call runs
+	timing   bool      // a goroutine is waiting for the deadline
+	closed   bool
+	stop     chan struct{} // closed by Close
+}
+
+// Debounce wraps fn so that a run of calls runs it once, d after the last
+// of them: each call pushes the pending run back by d. It suits events
+// such as file changes or webhooks that arrive in flurries and need only
+// be handled once things settle. fn runs on another goroutine. clk times
+// the delay; nil means the system clock.
+func Debounce(fn func(), d time.Duration, clk Clock) *Debounced {
+	if clk == nil {
+		clk = realClock{}
+	}
+	return &Debounced{fn: fn, d: d, clock: clk, stop: make(chan struct{})}
+}
+
+// Call schedules fn to run d from now, replacing any run already pending.
+// It does nothing after Close.
+func (db *Debounced) Call() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return
+	}
+	db.pending = true
+	db.deadline = db.clock.Now().Add(db.d)
+	if !db.timing {
+		db.timing = true
+		go db.wait()
+	}
+}
+
+// wait runs fn once the deadline, which calls may push back, has passed.
+func (db *Debounced) wait() {
+	for {
+		db.mu.Lock()
+		if !db.pending || db.closed {
+			db.timing = false
+			db.mu.Unlock()
+			return
+		}
+		delay := db.deadline.Sub(db.clock.Now())
+		if delay <= 0 {
+			db.pending, db.timing = false, false
+			db.mu.Unlock()
+			db.fn()
+			return
+		}
+		db.mu.Unlock()
+		sleepUntil(context.Background(), db.clock, delay, db.stop)
+	}
+}
+
+// Flush runs the pending call now, if there is one, and reports whether
+// there was.
+func (db *Debounced) Flush() bool {
+	db.mu.Lock()
+	pending := db.pending
+	db.pending = false
+	db.mu.Unlock()
+	if pending {
+		db.fn()
+	}
+	return pending
+}
+
+// Close flushes any pending call and makes further calls do nothing.
+func (db *Debounced) Close() {
+	db.mu.Lock()
+	if !db.closed {
+		db.closed = true
+		close(db.stop)
+	}
+	db.mu.Unlock()
+	db.Flush()
+}
@@ -0,0 +1,96 @@
+package ratelimiter
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// eventually polls cond for up to a second.
+func eventually(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	var runs atomic.Int32
+	th := Throttle(func() { runs.Add(1) }, New(Every(50*time.Millisecond), 1, nil))
+	th.Call()
+	if runs.Load() != 1 {
+		t.Fatal("admitted call did not run at once")
+	}
+	th.Call()
+	th.Call()
+	eventually(t, func() bool { return runs.Load() == 2 }, "trailing call never ran")
+	time.Sleep(80 * time.Millisecond)
+	if runs.Load() != 2 {
+		t.Fatalf("%d runs, want the denied calls coalesced into one", runs.Load())
+	}
+
+	th.Call()
+	th.Call() // pending behind the limiter
+	if !th.Flush() || runs.Load() != 4 {
+		t.Fatal("Flush did not run the pending call")
+	}
+	th.Close()
+	th.Call()
+	time.Sleep(80 * time.Millisecond)
+	if runs.Load() != 4 {
+		t.Fatalf("%d runs, want none after a flush and Close", runs.Load())
+	}
+}
+
+func TestThrottleFailedWait(t *testing.T) {
+	var runs atomic.Int32
+	rl := New(Every(time.Hour), 1, nil)
+	th := Throttle(func() { runs.Add(1) }, rl)
+	rl.Pause()
+	th.Call() // the trailing wait fails with ErrPaused
+	eventually(t, func() bool {
+		th.mu.Lock()
+		defer th.mu.Unlock()
+		return !th.pending
+	}, "failed trailing call still pending")
+	rl.Resume()
+	th.Call()
+	th.Call()
+	if runs.Load() != 1 {
+		t.Fatalf("%d runs after resuming, want 1", runs.Load())
+	}
+	if !th.Flush() || runs.Load() != 2 {
+		t.Fatal("no trailing call scheduled after a failed one")
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	var runs atomic.Int32
+	db := Debounce(func() { runs.Add(1) }, 20*time.Millisecond, nil)
+	for i := 0; i < 5; i++ {
+		db.Call()
+		time.Sleep(5 * time.Millisecond)
+	}
+	eventually(t, func() bool { return runs.Load() == 1 }, "debounced call never ran")
+	time.Sleep(50 * time.Millisecond)
+	if runs.Load() != 1 {
+		t.Fatalf("%d runs for one flurry of calls, want 1", runs.Load())
+	}
+
+	db.Call()
+	if !db.Flush() || runs.Load() != 2 {
+		t.Fatal("Flush did not run the pending call")
+	}
+	if db.Flush() {
+		t.Fatal("second Flush found a pending call")
+	}
+	db.Call()
+	db.Close()
+	db.Call()
+	time.Sleep(50 * time.Millisecond)
+	if runs.Load() != 3 {
+		t.Fatalf("%d runs, want the pending call flushed by Close and no more", runs.Load())
+	}
+}
@@ -0,0 +1,103 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Ticker delivers ticks on a channel at a limiter's rate, for driving
+// pollers and other periodic work. Unlike time.Ticker it follows the
+// limiter: SetRate and SetBurst take effect on the next tick, a paused
+// limiter pauses the ticks, and ticks share the limiter's tokens with its
+// other callers.
+//
+// After the receiver falls behind, ticks are delivered back to back until
+// the tokens banked meanwhile are spent, so a poller catches up with at
+// most burst extra polls. A limiter with a burst of 1 never catches up.
+type Ticker struct {
+	C <-chan time.Time // unbuffered; ticks are sent as the tokens are taken
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// tickerRetry is how long a Ticker waits before retrying a wait that failed
+// other than by a pause.
+const tickerRetry = 100 * time.Millisecond
+
+// NewTicker starts a Ticker taking one token from rl per tick. Stop it to
+// release its goroutine.
+func NewTicker(rl *RateLimiter) *Ticker {
+	c := make(chan time.Time)
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &Ticker{C: c, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(t.done)
+		for {
+			if err := rl.WaitN(ctx, 1); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				// Paused with PauseDeny, or failing otherwise: keep
+				// ticking once the limiter recovers.
+				if resumed := rl.resumed(); resumed != nil {
+					select {
+					case <-resumed:
+					case <-ctx.Done():
+						return
+					}
+				} else if sleepCtx(ctx, rl.clock, tickerRetry) != nil {
+					return
+				}
+				continue
+			}
+			select {
+			case c <- rl.clock.Now():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return t
+}
+
+// Stop turns off the ticker and waits for its goroutine to exit. No more
+// ticks are sent after Stop returns. Like time.Ticker.Stop, it does not
+// close C.
+func (t *Ticker) Stop() {
+	t.cancel()
+	<-t.done
+}
+
+// RunEvery calls fn every interval in a new goroutine until the returned
+// stop function is called. Stopping waits for a call in progress to
+// return; stopping again does nothing. It is the loop behind the package's
+// background workers, such as Persist and Gossip, exported for adapters
+// that run their own.
+func RunEvery(interval time.Duration, fn func()) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
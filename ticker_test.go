@@ -0,0 +1,91 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTicker(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(10, 1, clk)
+	tk := NewTicker(rl)
+	defer tk.Stop()
+
+	prev := <-tk.C
+	for i := 0; i < 3; i++ {
+		tick := <-tk.C
+		if d := tick.Sub(prev); d != 100*time.Millisecond {
+			t.Fatalf("ticks %v apart at 10/s", d)
+		}
+		prev = tick
+	}
+
+	// A rate change applies from the tick after the one already pending.
+	rl.SetRate(20)
+	<-tk.C
+	prev = <-tk.C
+	if d := (<-tk.C).Sub(prev); d != 50*time.Millisecond {
+		t.Fatalf("ticks %v apart after raising the rate to 20/s", d)
+	}
+}
+
+func TestTickerCatchesUp(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	tk := NewTicker(New(10, 3, clk))
+	defer tk.Stop()
+	for i := 0; i < 4; i++ {
+		<-tk.C
+	}
+	clk.Sleep(time.Second)
+	// The tick pending during the idle second may be stale; the burst
+	// banked meanwhile follows at a single instant.
+	prev, run, longest := <-tk.C, 1, 1
+	for i := 0; i < 4; i++ {
+		tick := <-tk.C
+		if tick.Equal(prev) {
+			run++
+		} else {
+			run = 1
+		}
+		longest = max(longest, run)
+		prev = tick
+	}
+	if longest < 3 {
+		t.Fatalf("at most %d ticks at once after an idle second, want 3", longest)
+	}
+}
+
+func TestTickerStop(t *testing.T) {
+	tk := NewTicker(New(InfiniteRate, 1, nil))
+	<-tk.C
+	tk.Stop()
+	select {
+	case <-tk.C:
+		t.Fatal("tick after Stop")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestTickerPauseResume(t *testing.T) {
+	rl := New(100, 1, nil)
+	tk := NewTicker(rl)
+	defer tk.Stop()
+	<-tk.C
+	rl.Pause()
+	// Drain a tick taken before the pause.
+	select {
+	case <-tk.C:
+	case <-time.After(50 * time.Millisecond):
+	}
+	select {
+	case <-tk.C:
+		t.Fatal("tick while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	rl.Resume()
+	select {
+	case <-tk.C:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("no tick after Resume")
+	}
+}
@@ -0,0 +1,113 @@
+package ratelimiter
+
+import (
+	"maps"
+	"time"
+)
+
+// SetTier defines the named tier, such as a pricing plan, with its rate
+// and burst. Keys are assigned to tiers by the function given to
+// SetTierFunc; keys in no defined tier get the defaults, and overrides set
+// with SetRate and SetBurst take precedence over tiers. Redefining a tier
+// rescales the live keys in it as UpdateTier does.
+func (kl *KeyedLimiter) SetTier(name string, rate Rate, burst int) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	cfg := kl.updateConfig(func(c *keyedConfig) {
+		c.tiers = maps.Clone(c.tiers)
+		if c.tiers == nil {
+			c.tiers = make(map[string]limit)
+		}
+		c.tiers[name] = limit{rate: rate, burst: burst}
+	})
+	kl.eachShard(func(s *keyedShard) {
+		for key, tier := range s.keyTiers {
+			if tier == name {
+				kl.rescaleLocked(s, key, cfg)
+			}
+		}
+	})
+}
+
+// SetTierFunc sets the function that returns the tier of a key, or "" for
+// none. It is called once when a key's limiter is created, and again by
+// UpdateTier. Setting it reassigns all live keys. fn must be safe for
+// concurrent use and must not call back into kl.
+func (kl *KeyedLimiter) SetTierFunc(fn func(key string) string) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	cfg := kl.updateConfig(func(c *keyedConfig) { c.tierOf = fn })
+	kl.eachShard(func(s *keyedShard) {
+		for key := range s.limiters {
+			kl.retierLocked(s, key, cfg)
+		}
+	})
+}
+
+// UpdateTier looks up key's tier again, for example after its customer
+// changed plans. If the tier changed, the key's bucket is rescaled to the
+// new burst keeping the fraction of it that was full, so that an upgrade
+// takes effect at once without handing out a fresh burst.
+func (kl *KeyedLimiter) UpdateTier(key string) {
+	s := kl.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.limiters[key]; ok {
+		kl.retierLocked(s, key, kl.config.Load())
+	}
+}
+
+// Tier returns the tier of key's live limiter, or "" if it has none.
+func (kl *KeyedLimiter) Tier(key string) string {
+	s := kl.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keyTiers[key]
+}
+
+// retierLocked reassigns key to its current tier. Callers must hold s.mu.
+func (kl *KeyedLimiter) retierLocked(s *keyedShard, key string, cfg *keyedConfig) {
+	tier := ""
+	if cfg.tierOf != nil {
+		tier = cfg.tierOf(key)
+	}
+	if tier == s.keyTiers[key] {
+		return
+	}
+	if tier == "" {
+		delete(s.keyTiers, key)
+	} else {
+		s.keyTiers[key] = tier
+	}
+	kl.rescaleLocked(s, key, cfg)
+}
+
+// rescaleLocked applies key's limit to its live limiter, if it has no
+// override. Callers must hold s.mu.
+func (kl *KeyedLimiter) rescaleLocked(s *keyedShard, key string, cfg *keyedConfig) {
+	rl, ok := s.limiters[key]
+	if _, overridden := s.overrides[key]; !ok || overridden {
+		return
+	}
+	l := s.limitFor(key, cfg)
+	if s.coolingLocked(key) {
+		l.rate = 0
+	}
+	rl.rescaleAt(rl.now(), l.rate, l.burst)
+}
+
+// rescaleAt changes the rate and burst from t on, scaling the tokens in
+// the bucket by the change in burst.
+func (rl *RateLimiter) rescaleAt(t time.Time, rate Rate, burst int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	tokens := rl.updateTokens(t)
+	if rl.maxTokens > 0 {
+		tokens *= float64(burst) / float64(rl.maxTokens)
+	} else {
+		tokens = float64(burst)
+	}
+	rl.rate = rate
+	rl.maxTokens = burst
+	rl.setTokensLocked(t, tokens)
+}
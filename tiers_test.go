@@ -0,0 +1,62 @@
+package ratelimiter
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTiers(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(1, 1, clk)
+	kl.SetTier("free", 1, 2)
+	kl.SetTier("pro", 20, 40)
+
+	var mu sync.Mutex
+	plans := map[string]string{"alice": "free", "bob": "pro"}
+	kl.SetTierFunc(func(key string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		return plans[key]
+	})
+
+	if !kl.AllowN("bob", 40) || kl.Allow("bob") {
+		t.Fatal("pro tier burst not applied")
+	}
+	if !kl.Allow("alice") {
+		t.Fatal("free tier denied")
+	}
+	if !kl.Allow("carol") || kl.Allow("carol") {
+		t.Fatal("key in no tier did not get the defaults")
+	}
+
+	// Upgrading alice with half her free burst left leaves her with half
+	// the pro burst.
+	mu.Lock()
+	plans["alice"] = "pro"
+	mu.Unlock()
+	kl.UpdateTier("alice")
+	if got := kl.Tier("alice"); got != "pro" {
+		t.Fatalf("Tier(alice) = %q, want pro", got)
+	}
+	if rl := kl.Get("alice"); rl.Rate() != 20 || rl.Burst() != 40 || rl.AvailableTokens() != 20 {
+		t.Fatalf("alice after upgrade: rate %v burst %d tokens %v", rl.Rate(), rl.Burst(), rl.AvailableTokens())
+	}
+
+	// Redefining a tier rescales its keys.
+	kl.SetTier("pro", 100, 80)
+	if rl := kl.Get("alice"); rl.Rate() != 100 || rl.AvailableTokens() != 40 {
+		t.Fatalf("alice after redefining pro: rate %v tokens %v", rl.Rate(), rl.AvailableTokens())
+	}
+
+	// Overrides win over tiers.
+	kl.SetBurst("bob", 5)
+	kl.SetTier("pro", 100, 100)
+	if b := kl.Get("bob").Burst(); b != 5 {
+		t.Fatalf("bob's burst override replaced by tier: %d", b)
+	}
+	kl.ClearOverride("bob")
+	if b := kl.Get("bob").Burst(); b != 100 {
+		t.Fatalf("bob's burst after clearing the override = %d, want the tier's 100", b)
+	}
+}
@@ -0,0 +1,99 @@
+package ratelimiter
+
+import (
+	"sort"
+	"sync"
+)
+
+// KeyCount is a key and an approximate count of its denials.
+type KeyCount struct {
+	Key   string
+	Count uint64
+}
+
+// TrackTopDenied makes kl track the keys denied most often, in space
+// bounded by k, for abuse dashboards. It uses the Misra-Gries summary: any
+// key denied more than a 1/(k+1) share of all denials is reported, and
+// counts are low by at most that share. Calling it again starts a new
+// summary with the new k; k of zero or less stops tracking.
+func (kl *KeyedLimiter) TrackTopDenied(k int) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	if kl.topDenied == nil {
+		kl.topDenied = &misraGries{}
+		kl.addObserverLocked(kl.topDenied)
+	}
+	kl.topDenied.reset(k)
+}
+
+// TopDenied returns the keys tracked by TrackTopDenied, most denied first.
+// It returns nil if tracking is off.
+func (kl *KeyedLimiter) TopDenied() []KeyCount {
+	kl.mu.Lock()
+	mg := kl.topDenied
+	kl.mu.Unlock()
+	if mg == nil {
+		return nil
+	}
+	return mg.top()
+}
+
+// misraGries is an Observer that keeps a Misra-Gries heavy-hitters
+// summary of denied keys in at most k counters.
+type misraGries struct {
+	mu     sync.Mutex
+	k      int
+	counts map[string]uint64
+}
+
+func (mg *misraGries) reset(k int) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.k = k
+	mg.counts = nil
+	if k > 0 {
+		mg.counts = make(map[string]uint64, k)
+	}
+}
+
+func (mg *misraGries) Observe(d Decision) {
+	if d.Allowed {
+		return
+	}
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	if mg.k <= 0 {
+		return
+	}
+	if _, ok := mg.counts[d.Key]; ok || len(mg.counts) < mg.k {
+		mg.counts[d.Key]++
+		return
+	}
+	for key, c := range mg.counts {
+		if c == 1 {
+			delete(mg.counts, key)
+		} else {
+			mg.counts[key] = c - 1
+		}
+	}
+}
+
+func (mg *misraGries) top() []KeyCount {
+	mg.mu.Lock()
+	if mg.k <= 0 {
+		mg.mu.Unlock()
+		return nil
+	}
+	top := make([]KeyCount, 0, len(mg.counts))
+	for key, c := range mg.counts {
+		top = append(top, KeyCount{key, c})
+	}
+	mg.mu.Unlock()
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Key < top[j].Key
+	})
+	return top
+}
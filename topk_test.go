@@ -0,0 +1,41 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTopDenied(t *testing.T) {
+	kl := NewKeyed(0, 1, newFakeClock(time.Unix(0, 0)))
+	if kl.TopDenied() != nil {
+		t.Fatal("TopDenied reported keys before tracking was enabled")
+	}
+	kl.TrackTopDenied(3)
+
+	// Two abusers among many keys that are each denied once.
+	for i := 0; i < 100; i++ {
+		kl.Allow("abuser-1")
+		kl.Allow("abuser-2")
+		kl.Allow("abuser-1")
+		kl.Allow(fmt.Sprintf("user-%d", i))
+		kl.Allow(fmt.Sprintf("user-%d", i))
+	}
+
+	top := kl.TopDenied()
+	if len(top) > 3 {
+		t.Fatalf("tracked %d keys, want at most 3", len(top))
+	}
+	if len(top) < 2 || top[0].Key != "abuser-1" || top[1].Key != "abuser-2" {
+		t.Fatalf("TopDenied() = %v, want abuser-1 then abuser-2", top)
+	}
+	// Counts are underestimates by at most denials/(k+1).
+	if top[0].Count > 199 || top[0].Count < 199-400/4 {
+		t.Fatalf("abuser-1 count %d outside the error bound", top[0].Count)
+	}
+
+	kl.TrackTopDenied(0)
+	if kl.TopDenied() != nil {
+		t.Fatal("TopDenied reported keys after tracking was stopped")
+	}
+}
@@ -0,0 +1,113 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transport is an http.RoundTripper that waits on a per-host limiter before
+// sending each request.
+type Transport struct {
+	// Base sends the requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+
+	// Limiter holds one limiter per request host (URL.Host). Its key rules
+	// and penalties apply to hosts as to any other key.
+	Limiter *KeyedLimiter
+
+	// FollowHeaders makes the transport honor the server's own limits:
+	// Retry-After on 429 and 503 responses, and RateLimit-Remaining: 0 with
+	// RateLimit-Reset, pause the host's limiter until the given time, and a
+	// RateLimit-Policy of "q;w=s" sets the host's rate to q per s seconds.
+	FollowHeaders bool
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.Limiter.Wait(req.Context(), req.URL.Host, 1); err != nil {
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err == nil && t.FollowHeaders {
+		follow(t.Limiter.Get(req.URL.Host), resp)
+	}
+	return resp, err
+}
+
+// follow adjusts rl according to the rate limit headers of resp.
+func follow(rl *RateLimiter, resp *http.Response) {
+	now := rl.now()
+	h := resp.Header
+
+	if r, ok := parsePolicy(h.Get("RateLimit-Policy")); ok {
+		rl.SetRateAt(now, r)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if d, ok := parseRetryAfter(h.Get("Retry-After"), now); ok {
+			rl.pauseUntil(now, now.Add(d))
+		}
+	}
+	if h.Get("RateLimit-Remaining") == "0" {
+		if secs, err := strconv.Atoi(h.Get("RateLimit-Reset")); err == nil && secs > 0 {
+			rl.pauseUntil(now, now.Add(time.Duration(secs)*time.Second))
+		}
+	}
+}
+
+// parseRetryAfter parses a Retry-After value given in seconds or as an
+// HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, secs > 0
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now), true
+	}
+	return 0, false
+}
+
+// parsePolicy parses the first item of a RateLimit-Policy value such as
+// "100;w=60" into a rate of 100 per 60 seconds.
+func parsePolicy(v string) (Rate, bool) {
+	item, _, _ := strings.Cut(v, ",")
+	parts := strings.Split(item, ";")
+	quota, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || quota <= 0 {
+		return 0, false
+	}
+	for _, p := range parts[1:] {
+		k, val, _ := strings.Cut(strings.TrimSpace(p), "=")
+		if k != "w" {
+			continue
+		}
+		if w, err := strconv.Atoi(val); err == nil && w > 0 {
+			return Rate(float64(quota) / float64(w)), true
+		}
+	}
+	return 0, false
+}
+
+// pauseUntil removes tokens so that, absent other activity, the next token
+// becomes available at until rather than earlier.
+func (rl *RateLimiter) pauseUntil(now, until time.Time) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.rate == InfiniteRate || !until.After(now) {
+		return
+	}
+	tokens := rl.updateTokens(now)
+	if limit := 1 - rl.rate.tokensFromDuration(until.Sub(now)); tokens > limit {
+		tokens = limit
+	}
+	rl.updatedAt = rl.stampLocked(now)
+	rl.tokens = tokens
+	rl.publishLocked()
+}
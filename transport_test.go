@@ -0,0 +1,87 @@
+package ratelimiter
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func respond(status int, header http.Header) roundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: status, Header: header, Request: req}, nil
+	}
+}
+
+func TestTransportLimitsPerHost(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	tr := &Transport{
+		Base:    respond(http.StatusOK, http.Header{}),
+		Limiter: NewKeyed(Every(100*time.Millisecond), 1, clk),
+	}
+	for _, url := range []string{"http://a.example/", "http://b.example/", "http://a.example/x"} {
+		if _, err := tr.RoundTrip(httptest.NewRequest(http.MethodGet, url, nil)); err != nil {
+			t.Fatalf("%s: %v", url, err)
+		}
+	}
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 100*time.Millisecond {
+		t.Fatalf("expected only the second request to a.example to wait, got %v", got)
+	}
+}
+
+func TestTransportKeyRules(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(Every(time.Second), 1, clk)
+	kl.SetRule("banned.example", RuleDeny)
+	tr := &Transport{Base: respond(http.StatusOK, http.Header{}), Limiter: kl}
+	_, err := tr.RoundTrip(httptest.NewRequest(http.MethodGet, "http://banned.example/", nil))
+	if !errors.Is(err, ErrDenied) {
+		t.Fatalf("request to a denied host: %v, want ErrDenied", err)
+	}
+	if kl.Len() != 0 {
+		t.Fatal("denied host was given a limiter")
+	}
+}
+
+func TestTransportFollowsRetryAfter(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(Every(100*time.Millisecond), 5, clk)
+	tr := &Transport{
+		Base:          respond(http.StatusTooManyRequests, http.Header{"Retry-After": {"3"}}),
+		Limiter:       kl,
+		FollowHeaders: true,
+	}
+	tr.RoundTrip(httptest.NewRequest(http.MethodGet, "http://a.example/", nil))
+	tr.Base = respond(http.StatusOK, http.Header{})
+	tr.RoundTrip(httptest.NewRequest(http.MethodGet, "http://a.example/", nil))
+	if got := clk.Now().Sub(time.Unix(0, 0)); got != 3*time.Second {
+		t.Fatalf("expected to pause 3s after Retry-After, got %v", got)
+	}
+}
+
+func TestTransportFollowsRateLimitHeaders(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(Every(100*time.Millisecond), 5, clk)
+	tr := &Transport{
+		Base: respond(http.StatusOK, http.Header{
+			"Ratelimit-Policy":    {"10;w=60"},
+			"Ratelimit-Remaining": {"0"},
+			"Ratelimit-Reset":     {"2"},
+		}),
+		Limiter:       kl,
+		FollowHeaders: true,
+	}
+	tr.RoundTrip(httptest.NewRequest(http.MethodGet, "http://a.example/", nil))
+	rl := kl.Get("a.example")
+	if got, want := rl.Rate(), Rate(10.0/60); got != want {
+		t.Fatalf("expected rate from policy %v, got %v", want, got)
+	}
+	if d := rl.Status().NextAt.Sub(time.Unix(2, 0)); d < -time.Microsecond || d > time.Microsecond {
+		t.Fatalf("expected next token at reset, off by %v", d)
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimiter
+
+import "time"
+
+// ExpireIdle removes the limiters whose buckets have been full and unused
+// for at least ttl, and returns how many it removed. A removed key starts
+// again from a full bucket, so eviction never changes what is admitted.
+func (kl *KeyedLimiter) ExpireIdle(ttl time.Duration) int {
+	cfg := kl.config.Load()
+	removed := 0
+	kl.eachShard(func(s *keyedShard) {
+		for key, rl := range s.limiters {
+			now := rl.now()
+			if fullAt, ok := rl.fullSince(now); ok && now.Sub(fullAt) >= ttl {
+				s.evictLocked(key, EvictedIdle, cfg)
+				removed++
+			}
+		}
+	})
+	return removed
+}
+
+// SetTTL starts a background sweeper that calls ExpireIdle(ttl) every ttl,
+// keeping the number of limiters bounded for keys such as client IPs.
+// Calling it again replaces the sweeper; a ttl of zero or less stops it.
+// Close stops the sweeper for good.
+func (kl *KeyedLimiter) SetTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		kl.stopSweeper()
+		return
+	}
+	stop := RunEvery(ttl, func() { kl.ExpireIdle(ttl) })
+	kl.mu.Lock()
+	old := kl.sweepStop
+	kl.sweepStop = stop
+	kl.mu.Unlock()
+	if old != nil {
+		old()
+	}
+}
+
+// Close stops the sweeper started by SetTTL, if any.
+func (kl *KeyedLimiter) Close() error {
+	kl.stopSweeper()
+	return nil
+}
+
+func (kl *KeyedLimiter) stopSweeper() {
+	kl.mu.Lock()
+	stop := kl.sweepStop
+	kl.sweepStop = nil
+	kl.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
+
+// fullSince reports when the bucket last became full, if it is full at
+// now and nobody is waiting on it.
+func (rl *RateLimiter) fullSince(now time.Time) (time.Time, bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	b := rl.bucketLocked()
+	full := float64(b.capacity())
+	if rl.waiters.Len() > 0 || rl.updateTokens(now) < full {
+		return time.Time{}, false
+	}
+	if rl.rate == InfiniteRate || rl.tokens >= full {
+		return rl.timeAt(rl.updatedAt), true
+	}
+	return rl.timeAt(rl.updatedAt).Add(b.untilTokens(rl.updatedAt, full-rl.tokens)), true
+}
@@ -0,0 +1,49 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpireIdle(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	kl := NewKeyed(1, 2, clk)
+	kl.Allow("idle")
+	kl.AllowN("busy", 2)
+	kl.Get("untouched")
+
+	clk.Sleep(time.Second)
+	// idle refilled at 1s, busy is still refilling and untouched was
+	// full from the start.
+	if got := kl.ExpireIdle(time.Second); got != 1 {
+		t.Fatalf("expected only the untouched key to expire, removed %d", got)
+	}
+	clk.Sleep(time.Second)
+	if got := kl.ExpireIdle(time.Second); got != 1 {
+		t.Fatalf("expected the idle key to expire, removed %d", got)
+	}
+	if got := kl.Len(); got != 1 {
+		t.Fatalf("expected the refilling key to remain, got %d keys", got)
+	}
+}
+
+func TestSetTTLSweeps(t *testing.T) {
+	kl := NewKeyed(InfiniteRate, 1, nil)
+	defer kl.Close()
+	kl.Allow("a")
+	kl.SetTTL(time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for kl.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the sweeper to evict the idle key")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	kl.SetTTL(0)
+	kl.Allow("b")
+	time.Sleep(5 * time.Millisecond)
+	if kl.Len() != 1 {
+		t.Fatal("expected SetTTL(0) to stop the sweeper")
+	}
+}
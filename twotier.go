@@ -0,0 +1,114 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// TwoTierLimiter admits events against a local token bucket and reconciles
+// it with a global bucket in a Store in batches, taking the network round
+// trip off the hot path. Each Sync charges the store with the events
+// admitted locally since the previous one and resets the local bucket to
+// what is left globally.
+//
+// Between syncs every instance refills at the full global rate, so a
+// cluster of k instances may admit up to k times the burst plus k times
+// the rate per sync interval more than the limit allows. The excess is
+// charged to the global bucket as debt and repaid before further events
+// are admitted anywhere.
+type TwoTierLimiter struct {
+	mu      sync.Mutex
+	local   *RateLimiter
+	global  *StoreLimiter
+	pending int // events admitted or waiting locally since the last sync
+}
+
+// NewTwoTier returns a TwoTierLimiter in front of global, with a local
+// bucket of the same rate and burst, full until the first Sync.
+func NewTwoTier(global *StoreLimiter) *TwoTierLimiter {
+	return &TwoTierLimiter{local: New(global.rate, global.burst, global.clock), global: global}
+}
+
+// Local returns the local limiter, for observers and metrics.
+func (l *TwoTierLimiter) Local() *RateLimiter {
+	return l.local
+}
+
+func (l *TwoTierLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+func (l *TwoTierLimiter) AllowN(n int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.local.AllowN(n) {
+		return false
+	}
+	l.pending += n
+	return true
+}
+
+// Wait is shorthand for WaitN(context.Background(), n).
+func (l *TwoTierLimiter) Wait(n int) error {
+	return l.WaitN(context.Background(), n)
+}
+
+// WaitN blocks until n tokens are available locally or ctx is done.
+func (l *TwoTierLimiter) WaitN(ctx context.Context, n int) error {
+	// Count the events before they take local tokens, so that a Sync
+	// meanwhile cannot reset the local bucket as if they had not happened.
+	l.mu.Lock()
+	l.pending += n
+	l.mu.Unlock()
+	if err := l.local.WaitN(ctx, n); err != nil {
+		l.mu.Lock()
+		l.pending -= n
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Sync charges the global bucket with the events admitted since the last
+// Sync and resets the local bucket to the global tokens left, less any
+// events admitted or waiting meanwhile. If the store fails, the events are
+// charged at the next Sync. A wait that fails after its events were
+// charged is credited back at the next Sync.
+func (l *TwoTierLimiter) Sync(ctx context.Context) error {
+	l.mu.Lock()
+	n := l.pending
+	l.pending = 0
+	l.mu.Unlock()
+
+	left, err := l.global.take(ctx, n)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil {
+		l.pending += n
+		return err
+	}
+	l.local.SetTokens(left - float64(l.pending))
+	return nil
+}
+
+// Start calls Sync every interval in a new goroutine, passing failures to
+// onError if it is not nil. The returned stop function ends syncing and
+// charges the events admitted since the last Sync.
+func (l *TwoTierLimiter) Start(interval time.Duration, onError func(error)) (stop func() error) {
+	halt := RunEvery(interval, func() {
+		if err := l.Sync(context.Background()); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+	var once sync.Once
+	return func() error {
+		err := errors.New("rate: syncing already stopped")
+		once.Do(func() {
+			halt()
+			err = l.Sync(context.Background())
+		})
+		return err
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTwoTierSync(t *testing.T) {
+	ctx := context.Background()
+	clk := newFakeClock(time.Unix(0, 0))
+	store := NewMemoryStore()
+	a := NewTwoTier(NewStoreLimiter(store, "api", 1, 2, clk))
+	b := NewTwoTier(NewStoreLimiter(store, "api", 1, 2, clk))
+
+	// Before syncing, each instance admits a full burst.
+	if !a.AllowN(2) || !b.AllowN(2) {
+		t.Fatal("local bursts denied")
+	}
+	if err := a.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Local().AvailableTokens(); got != -2 {
+		t.Fatalf("b after sync has %v tokens, want a debt of 2", got)
+	}
+
+	clk.Sleep(3 * time.Second)
+	if err := a.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Local().AvailableTokens(); got != 1 {
+		t.Fatalf("a after sync has %v tokens, want 1", got)
+	}
+	if !a.Allow() || a.Allow() {
+		t.Fatal("a did not admit exactly the global token")
+	}
+}
+
+type failingStore struct {
+	Store
+	err error
+}
+
+func (s *failingStore) Get(ctx context.Context, key string) (BucketState, bool, error) {
+	if s.err != nil {
+		return BucketState{}, false, s.err
+	}
+	return s.Store.Get(ctx, key)
+}
+
+func TestTwoTierSyncRetriesCharges(t *testing.T) {
+	ctx := context.Background()
+	clk := newFakeClock(time.Unix(0, 0))
+	store := &failingStore{Store: NewMemoryStore(), err: errors.New("down")}
+	l := NewTwoTier(NewStoreLimiter(store, "api", 1, 5, clk))
+	l.AllowN(3)
+	if err := l.Sync(ctx); err == nil {
+		t.Fatal("Sync succeeded with the store down")
+	}
+	store.err = nil
+	l.AllowN(1)
+	if err := l.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s, _, _ := store.Get(ctx, "api"); s.Tokens != 1 {
+		t.Fatalf("global bucket has %v tokens, want 1", s.Tokens)
+	}
+}
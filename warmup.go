@@ -0,0 +1,80 @@
+package ratelimiter
+
+import "time"
+
+// warmup ramps a limiter's rate up to target.
+type warmup struct {
+	target   Rate
+	fraction float64
+	length   int64 // ns
+	idle     int64 // ns; 0 ramps only once
+	start    int64 // ns since epoch
+}
+
+// rateAt returns the rate at now, ns since the epoch.
+func (w *warmup) rateAt(now int64) Rate {
+	if w.target == InfiniteRate {
+		return InfiniteRate
+	}
+	progress := 1.0
+	if elapsed := now - w.start; elapsed < w.length {
+		progress = float64(max(elapsed, 0)) / float64(w.length)
+	}
+	return w.target * Rate(w.fraction+(1-w.fraction)*progress)
+}
+
+// SetWarmup makes the limiter start slowly, for backends such as cold
+// caches that cannot take the full rate at once. The rate starts at
+// fraction of its configured value and rises linearly to it over length.
+// The ramp starts now, and again whenever the limiter has admitted nothing
+// for idle; zero idle ramps up only once. SetRate changes the rate being
+// ramped up to. Waiters that started during the ramp may wait a little
+// longer than necessary, and the burst is released as usual, so pair a
+// warmup with a small burst or smooth pacing to protect the backend from
+// the first requests. A length of zero or less turns warmup off.
+func (rl *RateLimiter) SetWarmup(fraction float64, length, idle time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	t := rl.now()
+	target := rl.rate
+	if rl.warmup != nil {
+		target = rl.warmup.target
+	}
+	defer rl.wakeLocked()
+	if length <= 0 {
+		rl.warmup = nil
+		rl.setRateLocked(t, target)
+		return
+	}
+	rl.warmup = &warmup{
+		target:   target,
+		fraction: min(max(fraction, 0), 1),
+		length:   int64(length),
+		idle:     int64(idle),
+		start:    rl.nanos(t),
+	}
+	rl.setRateLocked(t, rl.warmup.rateAt(rl.nanos(t)))
+}
+
+// WithWarmup makes the limiter ramp up its rate, as SetWarmup does.
+func WithWarmup(fraction float64, length, idle time.Duration) Option {
+	return func(o *limiterOptions) {
+		o.warmupFraction = fraction
+		o.warmupLength = length
+		o.warmupIdle = idle
+	}
+}
+
+// warmLocked brings the rate of a warming limiter up to date before a
+// reservation at t, restarting the ramp if the limiter has been idle.
+// Callers must hold rl.mu.
+func (rl *RateLimiter) warmLocked(t time.Time) {
+	w := rl.warmup
+	now := rl.nanos(t)
+	if w.idle > 0 && now-rl.updatedAt >= w.idle {
+		w.start = now
+	}
+	if rate := w.rateAt(now); rate != rl.rate {
+		rl.setRateLocked(t, rate)
+	}
+}
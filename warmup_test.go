@@ -0,0 +1,59 @@
+package ratelimiter
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWarmupRamp(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := NewWithOptions(100, WithBurst(1), WithClock(clk), WithWarmup(0.1, 10*time.Second, time.Minute))
+	if got := rl.Rate(); got != 10 {
+		t.Fatalf("Rate() at start = %v, want 10", got)
+	}
+
+	clk.Sleep(5 * time.Second)
+	rl.Allow()
+	if got := rl.Rate(); math.Abs(float64(got)-55) > 1e-9 {
+		t.Fatalf("Rate() halfway = %v, want 55", got)
+	}
+
+	clk.Sleep(5 * time.Second)
+	rl.Allow()
+	if got := rl.Rate(); got != 100 {
+		t.Fatalf("Rate() after warmup = %v, want 100", got)
+	}
+
+	// SetRate changes the target; the ramp is over, so it applies at once.
+	rl.SetRate(200)
+	if got := rl.Rate(); got != 200 {
+		t.Fatalf("Rate() after SetRate = %v, want 200", got)
+	}
+
+	// A long idle period starts the ramp over.
+	clk.Sleep(2 * time.Minute)
+	rl.Allow()
+	if got := rl.Rate(); got != 20 {
+		t.Fatalf("Rate() after idling = %v, want 20", got)
+	}
+
+	rl.SetWarmup(0, 0, 0)
+	if got := rl.Rate(); got != 200 {
+		t.Fatalf("Rate() with warmup off = %v, want 200", got)
+	}
+}
+
+func TestWarmupWaitsPaced(t *testing.T) {
+	clk := newFakeClock(time.Unix(0, 0))
+	rl := New(10, 1, clk)
+	rl.SetWarmup(0.5, time.Hour, 0)
+	rl.Allow()
+	start := clk.Now()
+	if err := rl.Wait(1); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if waited := clk.Now().Sub(start); waited != 200*time.Millisecond {
+		t.Fatalf("Wait took %v at half rate, want 200ms", waited)
+	}
+}